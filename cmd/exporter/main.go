@@ -46,11 +46,17 @@ func run(logger *slog.Logger) error {
 		logger.Info("EXPORT_TORRENT_LEVEL_METRICS set to true, so will export torrent-level metrics")
 	}
 
-	client, err := transmission.New(transmission.ClientParams{
+	clientParams := transmission.ClientParams{
 		Host:     host,
 		User:     user,
 		Password: password,
-	})
+	}
+	if logger.Enabled(context.Background(), slog.LevelDebug) {
+		clientParams.Logger = logger.With("component", "transmission-client")
+		logger.Debug("LOG_LEVEL set to debug, so will log Transmission RPC requests")
+	}
+
+	client, err := transmission.New(clientParams)
 	if err != nil {
 		return fmt.Errorf("error instantiating transmission client: %w", err)
 	}
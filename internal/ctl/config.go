@@ -0,0 +1,72 @@
+package ctl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	defaultHost           = "http://localhost:9091"
+	defaultConfigPathHelp = "$XDG_CONFIG_HOME/transmissionctl/config.json"
+)
+
+// Config is how to connect to Transmission.
+type Config struct {
+	Host     string `json:"host"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+// loadConfig builds the config from, in order of precedence, flags, the
+// TRANSMISSION_* environment variables and the config file.
+//
+// An explicitly given config file must exist; the default one is optional.
+func loadConfig(path, host, user string) (Config, error) {
+	config := Config{Host: defaultHost}
+
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv("TRANSMISSIONCTL_CONFIG")
+		explicit = path != ""
+	}
+	if !explicit {
+		if dir, err := os.UserConfigDir(); err == nil {
+			path = filepath.Join(dir, "transmissionctl", "config.json")
+		}
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && !explicit:
+		case err != nil:
+			return Config{}, fmt.Errorf("error reading config file: %w", err)
+		default:
+			if err := json.Unmarshal(data, &config); err != nil {
+				return Config{}, fmt.Errorf("error parsing config file %s: %w", path, err)
+			}
+		}
+	}
+
+	for name, field := range map[string]*string{
+		"TRANSMISSION_HOST":     &config.Host,
+		"TRANSMISSION_USER":     &config.User,
+		"TRANSMISSION_PASSWORD": &config.Password,
+	} {
+		if value, ok := os.LookupEnv(name); ok {
+			*field = value
+		}
+	}
+
+	if host != "" {
+		config.Host = host
+	}
+	if user != "" {
+		config.User = user
+	}
+	return config, nil
+}
@@ -0,0 +1,226 @@
+// Package ctl implements transmissionctl, a command-line client for
+// Transmission.
+package ctl
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+)
+
+// ErrUsage is returned for invalid command lines, after printing usage.
+var ErrUsage = errors.New("invalid usage")
+
+// command is a transmissionctl subcommand.
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(ctx context.Context, app *app, args []string) error
+	// offline commands don't need a client.
+	offline bool
+}
+
+var commands []command
+
+func init() {
+	// Assigned in init because the help command refers to commands.
+	commands = []command{
+		{name: "list", args: "[-filter query] [-sort field]", summary: "List torrents", run: runList},
+		{name: "info", args: "ids...", summary: "Show torrent details", run: runInfo},
+		{name: "add", args: "[flags] file|magnet|url...", summary: "Add torrents", run: runAdd},
+		{name: "remove", args: "[-delete-data] ids...", summary: "Remove torrents", run: runRemove},
+		{name: "start", args: "[-now] ids...", summary: "Start torrents", run: runStart},
+		{name: "stop", args: "ids...", summary: "Stop torrents", run: runIDsCommand("stop", (*transmission.Client).TorrentStop)},
+		{name: "verify", args: "ids...", summary: "Verify torrent data", run: runIDsCommand("verify", (*transmission.Client).TorrentVerify)},
+		{name: "reannounce", args: "ids...", summary: "Ask trackers for more peers", run: runIDsCommand("reannounce", (*transmission.Client).TorrentReannounce)},
+		{name: "set", args: "[flags] ids...", summary: "Change torrent settings", run: runSet},
+		{name: "move", args: "-to dir [-move=false] ids...", summary: "Change torrent locations", run: runMove},
+		{name: "rename", args: "id path name", summary: "Rename a torrent file or directory", run: runRename},
+		{name: "queue", args: "top|up|down|bottom ids...", summary: "Move torrents in the queue", run: runQueue},
+		{name: "session", args: "[get|stats|set key=value...|update-blocklist]", summary: "Show or change session settings", run: runSession},
+		{name: "groups", args: "[list [names...]|set -name name [flags]]", summary: "Show or change bandwidth groups", run: runGroups},
+		{name: "free-space", args: "path", summary: "Show free space in a directory", run: runFreeSpace},
+		{name: "top", args: "[-interval duration]", summary: "Show a live view of torrents", run: runTop},
+		{name: "port-test", summary: "Check that the peer port is reachable", run: runPortTest},
+		{name: "watch", args: "config.json", summary: "Add torrent files dropped into directories", run: runWatch},
+		{name: "policy", args: "[-listen addr] config.json", summary: "Apply seeding rules on a schedule", run: runPolicy},
+		{name: "guard", args: "[-listen addr] config.json", summary: "Protect private torrents from hit-and-runs", run: runGuard},
+		{name: "disk-guard", args: "[-listen addr] config.json", summary: "Stop downloads before the disk fills", run: runDiskGuard},
+		{name: "stalled", args: "[-listen addr] config.json", summary: "Escalate torrents that are stuck downloading", run: runStalled},
+		{name: "schedule", args: "[-listen addr] config.json", summary: "Switch bandwidth profiles on a schedule", run: runSchedule},
+		{name: "datacap", args: "[-listen addr] config.json", summary: "Account for data usage against a monthly cap", run: runDataCap},
+		{name: "help", args: "[command]", summary: "Show help", run: runHelp, offline: true},
+	}
+}
+
+// app holds the state shared by commands.
+type app struct {
+	stdout io.Writer
+	stderr io.Writer
+	stdin  io.Reader
+	config Config
+	format format
+	dryRun bool
+	client *transmission.Client
+}
+
+// Run runs transmissionctl with args, excluding the program name.
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	a := &app{stdin: stdin, stdout: stdout, stderr: stderr}
+
+	var opts globalOptions
+	flags := a.globalFlags(&opts)
+	if err := flags.Parse(args); err != nil {
+		return usageError(err)
+	}
+	if flags.NArg() == 0 {
+		a.usage()
+		return ErrUsage
+	}
+
+	cmd, ok := findCommand(flags.Arg(0))
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", flags.Arg(0))
+		a.usage()
+		return ErrUsage
+	}
+
+	if !cmd.offline {
+		config, err := loadConfig(opts.configPath, opts.host, opts.user)
+		if err != nil {
+			return err
+		}
+		a.config = config
+		a.client, err = transmission.New(transmission.ClientParams{
+			Host:     config.Host,
+			User:     config.User,
+			Password: config.Password,
+			DryRun:   a.dryRun,
+		})
+		if err != nil {
+			return fmt.Errorf("error instantiating transmission client: %w", err)
+		}
+	}
+
+	if err := cmd.run(ctx, a, flags.Args()[1:]); err != nil {
+		return err
+	}
+	if a.dryRun && a.client != nil {
+		return a.printPlan()
+	}
+	return nil
+}
+
+func findCommand(name string) (command, bool) {
+	i := slices.IndexFunc(commands, func(c command) bool { return c.name == name })
+	if i < 0 {
+		return command{}, false
+	}
+	return commands[i], true
+}
+
+func (a *app) usage() {
+	fmt.Fprintf(a.stderr, "Usage: transmissionctl [flags] command [args]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(a.stderr, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(a.stderr, "\nTorrent ids are numeric IDs, info hashes or \"all\".\n\nFlags:\n")
+	a.globalFlags(&globalOptions{}).PrintDefaults()
+}
+
+// globalOptions are the flags given before the command.
+type globalOptions struct {
+	configPath string
+	host       string
+	user       string
+}
+
+func (a *app) globalFlags(opts *globalOptions) *flag.FlagSet {
+	flags := flag.NewFlagSet("transmissionctl", flag.ContinueOnError)
+	flags.SetOutput(a.stderr)
+	flags.Usage = func() { a.usage() }
+	flags.StringVar(&opts.configPath, "config", "", "config file (default $TRANSMISSIONCTL_CONFIG or "+defaultConfigPathHelp+")")
+	flags.StringVar(&opts.host, "host", "", "Transmission URL (default $TRANSMISSION_HOST or "+defaultHost+")")
+	flags.StringVar(&opts.user, "user", "", "RPC user name (default $TRANSMISSION_USER)")
+	flags.BoolVar(&a.dryRun, "dry-run", false, "print the changes that would be made instead of making them")
+	a.formatFlag(flags)
+	return flags
+}
+
+// flags returns a flag set for a command, with the output flag that every
+// command accepts.
+func (a *app) flags(name string) *flag.FlagSet {
+	cmd, _ := findCommand(name)
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(a.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(a.stderr, "Usage: transmissionctl %s %s\n\n%s.\n", cmd.name, cmd.args, cmd.summary)
+		if hasFlags(flags) {
+			fmt.Fprintf(a.stderr, "\nFlags:\n")
+			flags.PrintDefaults()
+		}
+	}
+	a.formatFlag(flags)
+	return flags
+}
+
+func hasFlags(flags *flag.FlagSet) bool {
+	found := false
+	flags.VisitAll(func(*flag.Flag) { found = true })
+	return found
+}
+
+// parse parses a command's flags and checks its number of arguments.
+func parse(flags *flag.FlagSet, args []string, minArgs, maxArgs int) error {
+	if err := flags.Parse(args); err != nil {
+		return usageError(err)
+	}
+	if flags.NArg() < minArgs || maxArgs >= 0 && flags.NArg() > maxArgs {
+		flags.Usage()
+		return ErrUsage
+	}
+	return nil
+}
+
+// usageError converts flag errors, which the flag package has already
+// printed, to ErrUsage.
+func usageError(err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return ErrUsage
+}
+
+func runHelp(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		a.usage()
+		return nil
+	}
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	if err := cmd.run(ctx, a, []string{"-h"}); !errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return nil
+}
+
+func (a *app) printPlan() error {
+	plan := a.client.DryRunPlan()
+	if len(plan) == 0 {
+		fmt.Fprintln(a.stderr, "dry run: no changes")
+		return nil
+	}
+	for _, operation := range plan {
+		fmt.Fprintf(a.stderr, "dry run: %s %s\n", operation.Method, strings.TrimSpace(string(operation.Arguments)))
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package ctl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+)
+
+type rpcCall struct {
+	Method    string          `json:"method"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type fakeTransmission struct {
+	mutex sync.Mutex
+	calls []rpcCall
+	// responses are the arguments returned per method.
+	responses map[string]any
+}
+
+func (f *fakeTransmission) callsTo(method string) []rpcCall {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var result []rpcCall
+	for _, call := range f.calls {
+		if call.Method == method {
+			result = append(result, call)
+		}
+	}
+	return result
+}
+
+var testTorrents = []transmission.Torrent{
+	{ID: 1, Name: "Some.Movie", Status: transmission.TorrentStatusSeed, PercentDone: 1, SizeWhenDone: 2 << 30, UploadRatio: 2.5, ETA: -1, Labels: []string{"movies"}},
+	{ID: 2, Name: "Some.Show", Status: transmission.TorrentStatusDownload, PercentDone: 0.5, SizeWhenDone: 700 << 20, UploadRatio: 0.1, RateDownload: 1 << 20, ETA: 90, Labels: []string{"tv", "hd"}},
+}
+
+// runCtl runs transmissionctl against a fake server and returns its output.
+func runCtl(t *testing.T, args ...string) (*fakeTransmission, string, string, error) {
+	t.Helper()
+
+	fake := &fakeTransmission{responses: map[string]any{
+		"torrent-get": transmission.TorrentGetResult{Torrents: testTorrents},
+		"session-get": transmission.Session{DownloadDir: "/downloads", RPCVersion: 17},
+	}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var call rpcCall
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&call))
+		fake.mutex.Lock()
+		fake.calls = append(fake.calls, call)
+		fake.mutex.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]any{"result": "success", "arguments": fake.responses[call.Method]})
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("TRANSMISSIONCTL_CONFIG", "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	var stdout, stderr bytes.Buffer
+	err := Run(context.Background(), append([]string{"-host", server.URL}, args...), strings.NewReader(""), &stdout, &stderr)
+	return fake, stdout.String(), stderr.String(), err
+}
+
+func TestList(t *testing.T) {
+	t.Run("table", func(t *testing.T) {
+		fake, stdout, _, err := runCtl(t, "list", "-sort", "name")
+		require.NoError(t, err)
+		assert.Equal(t, strings.Join([]string{
+			"ID  NAME        STATUS    DONE    SIZE       RATIO  DOWN       UP  ETA    LABELS",
+			"1   Some.Movie  seed      100.0%  2.0 GiB    2.50   -          -   -      movies",
+			"2   Some.Show   download  50.0%   700.0 MiB  0.10   1.0 MiB/s  -   1m30s  tv,hd",
+			"",
+		}, "\n"), stdout)
+
+		var args transmission.TorrentGetArgs
+		require.NoError(t, json.Unmarshal(fake.callsTo("torrent-get")[0].Arguments, &args))
+		assert.Contains(t, args.Fields, "name")
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		fake, stdout, _, err := runCtl(t, "-o", "csv", "list", "-filter", `"tv" in labels`)
+		require.NoError(t, err)
+		assert.Equal(t, "ID,NAME,STATUS,DONE,SIZE,RATIO,DOWN,UP,ETA,LABELS\n2,Some.Show,download,50.0%,700.0 MiB,0.10,1.0 MiB/s,-,1m30s,\"tv,hd\"\n", stdout)
+
+		var args transmission.TorrentGetArgs
+		require.NoError(t, json.Unmarshal(fake.callsTo("torrent-get")[0].Arguments, &args))
+		assert.Contains(t, args.Fields, "labels")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		_, stdout, _, err := runCtl(t, "list", "-o", "json", "-filter", "id == 1")
+		require.NoError(t, err)
+		var torrents []transmission.Torrent
+		require.NoError(t, json.Unmarshal([]byte(stdout), &torrents))
+		assert.Equal(t, testTorrents[:1], torrents)
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		_, _, _, err := runCtl(t, "list", "-filter", "nope > 1")
+		assert.EqualError(t, err, "query: unknown field nope at offset 0")
+	})
+}
+
+func TestTorrentCommands(t *testing.T) {
+	t.Run("stop by ids", func(t *testing.T) {
+		fake, _, _, err := runCtl(t, "stop", "1", "C12FE1C06BBA254A9DC9F519B335AA7C1367A88A")
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"ids":[1,"c12fe1c06bba254a9dc9f519b335aa7c1367a88a"]}`, string(fake.callsTo("torrent-stop")[0].Arguments))
+	})
+
+	t.Run("remove by filter", func(t *testing.T) {
+		fake, _, _, err := runCtl(t, "remove", "-delete-data", "-filter", `status == "seed"`)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"ids":[1],"delete_local_data":true}`, string(fake.callsTo("torrent-remove")[0].Arguments))
+	})
+
+	t.Run("filter without matches", func(t *testing.T) {
+		fake, _, stderr, err := runCtl(t, "verify", "-filter", "id == 3")
+		require.NoError(t, err)
+		assert.Empty(t, fake.callsTo("torrent-verify"))
+		assert.Contains(t, stderr, "no torrents match")
+	})
+
+	t.Run("set", func(t *testing.T) {
+		fake, _, _, err := runCtl(t, "set", "-ratio", "1.5", "-download-limit", "off", "-priority", "high", "-labels", "a, b", "2")
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"ids": [2],
+			"seedRatioLimit": 1.5,
+			"seedRatioMode": 1,
+			"downloadLimited": false,
+			"bandwidthPriority": 1,
+			"labels": ["a", "b"]
+		}`, string(fake.callsTo("torrent-set")[0].Arguments))
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		fake, _, _, err := runCtl(t, "queue", "top", "all")
+		require.NoError(t, err)
+		assert.JSONEq(t, `{}`, string(fake.callsTo("queue-move-top")[0].Arguments))
+	})
+
+	t.Run("move", func(t *testing.T) {
+		fake, _, _, err := runCtl(t, "move", "-to", "/new", "1")
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"ids":[1],"location":"/new","move":true}`, string(fake.callsTo("torrent-set-location")[0].Arguments))
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		_, _, _, err := runCtl(t, "start", "abc")
+		assert.ErrorContains(t, err, `invalid torrent id "abc"`)
+	})
+}
+
+func TestSession(t *testing.T) {
+	t.Run("get", func(t *testing.T) {
+		_, stdout, _, err := runCtl(t, "session")
+		require.NoError(t, err)
+		assert.Regexp(t, `download-dir\s+/downloads`, stdout)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		fake, _, _, err := runCtl(t, "session", "set", "speed-limit-down=100", "speed-limit-down-enabled=true", "download-dir=/data", "encryption=required")
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"speed-limit-down": 100,
+			"speed-limit-down-enabled": true,
+			"download-dir": "/data",
+			"encryption": "required"
+		}`, string(fake.callsTo("session-set")[0].Arguments))
+	})
+
+	t.Run("unknown setting", func(t *testing.T) {
+		_, _, _, err := runCtl(t, "session", "set", "nope=1")
+		assert.EqualError(t, err, `unknown setting "nope"`)
+	})
+}
+
+func TestDryRun(t *testing.T) {
+	fake, _, stderr, err := runCtl(t, "-dry-run", "stop", "-filter", `status == "seed"`)
+	require.NoError(t, err)
+	assert.Len(t, fake.callsTo("torrent-get"), 1)
+	assert.Empty(t, fake.callsTo("torrent-stop"))
+	assert.Equal(t, "dry run: torrent-stop {\"ids\":[1]}\n", stderr)
+}
+
+func TestUsage(t *testing.T) {
+	_, _, stderr, err := runCtl(t, "nope")
+	assert.ErrorIs(t, err, ErrUsage)
+	assert.Contains(t, stderr, `unknown command "nope"`)
+
+	_, _, stderr, err = runCtl(t, "rename", "1")
+	assert.ErrorIs(t, err, ErrUsage)
+	assert.Contains(t, stderr, "Usage: transmissionctl rename id path name")
+
+	_, _, _, err = runCtl(t, "top")
+	assert.EqualError(t, err, "top needs a terminal")
+
+	_, _, stderr, err = runCtl(t, "help", "set")
+	assert.NoError(t, err)
+	assert.Contains(t, stderr, "-ratio")
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"host":"http://file:9091","user":"file-user","password":"file-password"}`), 0o600))
+
+	t.Setenv("TRANSMISSION_HOST", "")
+	os.Unsetenv("TRANSMISSION_HOST")
+	t.Setenv("TRANSMISSION_USER", "env-user")
+	t.Setenv("TRANSMISSIONCTL_CONFIG", path)
+
+	config, err := loadConfig("", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, Config{Host: "http://file:9091", User: "env-user", Password: "file-password"}, config)
+
+	config, err = loadConfig("", "http://flag:9091", "flag-user")
+	require.NoError(t, err)
+	assert.Equal(t, Config{Host: "http://flag:9091", User: "flag-user", Password: "file-password"}, config)
+
+	_, err = loadConfig(filepath.Join(dir, "missing.json"), "", "")
+	assert.Error(t, err, "an explicit config file must exist")
+
+	t.Setenv("TRANSMISSIONCTL_CONFIG", "")
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	config, err = loadConfig("", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, defaultHost, config.Host, "the default config file is optional")
+}
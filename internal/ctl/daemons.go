@@ -0,0 +1,198 @@
+package ctl
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/j-dumbell/go-qbittorrent/internal/daemon"
+	"github.com/j-dumbell/go-qbittorrent/internal/datacap"
+	"github.com/j-dumbell/go-qbittorrent/internal/diskguard"
+	"github.com/j-dumbell/go-qbittorrent/internal/guard"
+	"github.com/j-dumbell/go-qbittorrent/internal/policy"
+	"github.com/j-dumbell/go-qbittorrent/internal/schedule"
+	"github.com/j-dumbell/go-qbittorrent/internal/stalled"
+	"github.com/j-dumbell/go-qbittorrent/internal/watch"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// logger returns the logger for long-running commands, which log to stderr
+// at the level given by $LOG_LEVEL.
+func (a *app) logger() *slog.Logger {
+	level := slog.LevelInfo
+	switch strings.ToLower(os.Getenv("LOG_LEVEL")) {
+	case "debug":
+		level = slog.LevelDebug
+	case "warn":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	}
+	return slog.New(slog.NewTextHandler(a.stderr, &slog.HandlerOptions{Level: level}))
+}
+
+func runWatch(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("watch")
+	if err := parse(flags, args, 1, 1); err != nil {
+		return err
+	}
+	config, err := watch.LoadConfig(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	watch.New(a.client, *config, a.logger()).Run(ctx)
+	return nil
+}
+
+// runDaemon calls run until ctx is done, serving handler on addr meanwhile
+// unless addr is empty. If the server fails, run's context is cancelled.
+func runDaemon(ctx context.Context, logger *slog.Logger, addr string, handler http.Handler, run func(ctx context.Context)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	serveErr := make(chan error, 1)
+	if addr == "" {
+		serveErr <- nil
+	} else {
+		go func() {
+			serveErr <- daemon.Serve(ctx, addr, handler, logger)
+			cancel()
+		}()
+	}
+
+	run(ctx)
+	cancel()
+	return <-serveErr
+}
+
+func runPolicy(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("policy")
+	listen := flags.String("listen", "", "address to serve metrics on, e.g. :2113")
+	if err := parse(flags, args, 1, 1); err != nil {
+		return err
+	}
+	config, err := policy.LoadConfig(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	logger := a.logger()
+	reg := prometheus.NewRegistry()
+	engine, err := policy.New(a.client, *config, policy.Options{Logger: logger, Registerer: reg, DryRun: a.dryRun})
+	if err != nil {
+		return err
+	}
+	return runDaemon(ctx, logger, *listen, daemon.NewMux(reg), engine.Run)
+}
+
+func runGuard(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("guard")
+	listen := flags.String("listen", "", "address to serve the RPC proxy and metrics on, e.g. :9092; requests are only checked when this is set and clients use the proxy")
+	if err := parse(flags, args, 1, 1); err != nil {
+		return err
+	}
+	config, err := guard.LoadConfig(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	target, err := url.Parse(a.config.Host)
+	if err != nil {
+		return fmt.Errorf("invalid host %q: %w", a.config.Host, err)
+	}
+
+	logger := a.logger()
+	reg := prometheus.NewRegistry()
+	g, err := guard.New(a.client, *config, guard.Options{Logger: logger, Registerer: reg, DryRun: a.dryRun})
+	if err != nil {
+		return err
+	}
+	mux := daemon.NewMux(reg)
+	mux.Handle("/transmission/", g.Proxy(target))
+	return runDaemon(ctx, logger, *listen, mux, g.Run)
+}
+
+func runDiskGuard(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("disk-guard")
+	listen := flags.String("listen", "", "address to serve metrics on, e.g. :2114")
+	if err := parse(flags, args, 1, 1); err != nil {
+		return err
+	}
+	config, err := diskguard.LoadConfig(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	logger := a.logger()
+	reg := prometheus.NewRegistry()
+	g, err := diskguard.New(a.client, *config, diskguard.Options{Logger: logger, Registerer: reg, DryRun: a.dryRun})
+	if err != nil {
+		return err
+	}
+	return runDaemon(ctx, logger, *listen, daemon.NewMux(reg), g.Run)
+}
+
+func runStalled(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("stalled")
+	listen := flags.String("listen", "", "address to serve metrics on, e.g. :2115")
+	if err := parse(flags, args, 1, 1); err != nil {
+		return err
+	}
+	config, err := stalled.LoadConfig(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	logger := a.logger()
+	reg := prometheus.NewRegistry()
+	r, err := stalled.New(a.client, *config, stalled.Options{Logger: logger, Registerer: reg, DryRun: a.dryRun})
+	if err != nil {
+		return err
+	}
+	return runDaemon(ctx, logger, *listen, daemon.NewMux(reg), r.Run)
+}
+
+func runSchedule(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("schedule")
+	listen := flags.String("listen", "", "address to serve the status endpoint and metrics on, e.g. :2116")
+	if err := parse(flags, args, 1, 1); err != nil {
+		return err
+	}
+	config, err := schedule.LoadConfig(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	logger := a.logger()
+	reg := prometheus.NewRegistry()
+	s, err := schedule.New(a.client, *config, schedule.Options{Logger: logger, Registerer: reg, DryRun: a.dryRun})
+	if err != nil {
+		return err
+	}
+	mux := daemon.NewMux(reg)
+	mux.Handle("/status", s.StatusHandler())
+	return runDaemon(ctx, logger, *listen, mux, s.Run)
+}
+
+func runDataCap(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("datacap")
+	listen := flags.String("listen", "", "address to serve metrics on, e.g. :2117")
+	if err := parse(flags, args, 1, 1); err != nil {
+		return err
+	}
+	config, err := datacap.LoadConfig(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	logger := a.logger()
+	reg := prometheus.NewRegistry()
+	accountant, err := datacap.New(a.client, *config, datacap.Options{Logger: logger, Registerer: reg, DryRun: a.dryRun})
+	if err != nil {
+		return err
+	}
+	return runDaemon(ctx, logger, *listen, daemon.NewMux(reg), accountant.Run)
+}
@@ -0,0 +1,79 @@
+package ctl
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+type format string
+
+const (
+	formatTable format = "table"
+	formatJSON  format = "json"
+	formatCSV   format = "csv"
+)
+
+func (f *format) String() string {
+	return string(*f)
+}
+
+func (f *format) Set(value string) error {
+	switch format(value) {
+	case formatTable, formatJSON, formatCSV:
+		*f = format(value)
+		return nil
+	}
+	return fmt.Errorf("must be one of %s, %s or %s", formatTable, formatJSON, formatCSV)
+}
+
+func (a *app) formatFlag(flags *flag.FlagSet) {
+	if a.format == "" {
+		a.format = formatTable
+	}
+	flags.Var(&a.format, "o", "output format: table, json or csv")
+}
+
+// table is tabular command output.
+type table struct {
+	headers []string
+	rows    [][]string
+}
+
+func (t *table) add(row ...string) {
+	t.rows = append(t.rows, row)
+}
+
+// fieldTable returns a two column table for a single object.
+func fieldTable() *table {
+	return &table{headers: []string{"FIELD", "VALUE"}}
+}
+
+// write prints value as JSON, or t as a table or CSV.
+func (a *app) write(value any, t *table) error {
+	switch a.format {
+	case formatJSON:
+		encoder := json.NewEncoder(a.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	case formatCSV:
+		w := csv.NewWriter(a.stdout)
+		if err := w.Write(t.headers); err != nil {
+			return err
+		}
+		if err := w.WriteAll(t.rows); err != nil {
+			return err
+		}
+		return w.Error()
+	}
+
+	w := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(t.headers, "\t"))
+	for _, row := range t.rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
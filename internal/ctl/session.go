@@ -0,0 +1,264 @@
+package ctl
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/j-dumbell/go-qbittorrent/internal/humanize"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+)
+
+func runSession(ctx context.Context, a *app, args []string) error {
+	subcommand := "get"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		subcommand, args = args[0], args[1:]
+	}
+
+	flags := a.flags("session")
+	switch subcommand {
+	case "get":
+		if err := parse(flags, args, 0, 0); err != nil {
+			return err
+		}
+		session, err := a.client.SessionGet(ctx)
+		if err != nil {
+			return err
+		}
+		return a.write(session, objectTable(*session))
+	case "stats":
+		if err := parse(flags, args, 0, 0); err != nil {
+			return err
+		}
+		stats, err := a.client.SessionStats(ctx)
+		if err != nil {
+			return err
+		}
+		t := fieldTable()
+		t.add("active torrents", strconv.Itoa(stats.ActiveTorrentCount))
+		t.add("paused torrents", strconv.Itoa(stats.PausedTorrentCount))
+		t.add("torrents", strconv.Itoa(stats.TorrentCount))
+		t.add("download rate", humanize.Rate(int64(stats.DownloadSpeed)))
+		t.add("upload rate", humanize.Rate(int64(stats.UploadSpeed)))
+		t.add("downloaded this session", humanize.Bytes(int64(stats.CurrentStats.DownloadedBytes)))
+		t.add("uploaded this session", humanize.Bytes(int64(stats.CurrentStats.UploadedBytes)))
+		t.add("downloaded in total", humanize.Bytes(int64(stats.CumulativeStats.DownloadedBytes)))
+		t.add("uploaded in total", humanize.Bytes(int64(stats.CumulativeStats.UploadedBytes)))
+		return a.write(stats, t)
+	case "set":
+		if err := parse(flags, args, 1, -1); err != nil {
+			return err
+		}
+		var setArgs transmission.SessionSetArgs
+		if err := decodeKeyValues(flags.Args(), &setArgs); err != nil {
+			return err
+		}
+		return a.client.SessionSet(ctx, setArgs)
+	case "update-blocklist":
+		if err := parse(flags, args, 0, 0); err != nil {
+			return err
+		}
+		return a.client.BlocklistUpdate(ctx)
+	}
+
+	flags.Usage()
+	return ErrUsage
+}
+
+// decodeKeyValues decodes key=value arguments into dst, whose JSON field
+// names are the keys. Values are JSON if they parse as JSON, otherwise
+// strings.
+func decodeKeyValues(args []string, dst any) error {
+	known := jsonFieldNames(reflect.TypeOf(dst).Elem())
+
+	values := make(map[string]json.RawMessage, len(args))
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return fmt.Errorf("invalid setting %q: must be key=value", arg)
+		}
+		if !slices.Contains(known, key) {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+		if json.Valid([]byte(value)) {
+			values[key] = json.RawMessage(value)
+		} else {
+			values[key], _ = json.Marshal(value)
+		}
+	}
+
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(encoded, dst); err != nil {
+		return fmt.Errorf("invalid setting: %w", err)
+	}
+	return nil
+}
+
+func jsonFieldNames(t reflect.Type) []string {
+	var names []string
+	for _, field := range reflect.VisibleFields(t) {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// objectTable returns a field table with the JSON fields of a struct.
+func objectTable(v any) *table {
+	t := fieldTable()
+	value := reflect.ValueOf(v)
+	for _, field := range reflect.VisibleFields(value.Type()) {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fieldValue := value.FieldByIndex(field.Index)
+		if stringer, ok := fieldValue.Interface().(fmt.Stringer); ok {
+			t.add(name, stringer.String())
+			continue
+		}
+		switch fieldValue.Kind() {
+		case reflect.Struct, reflect.Slice, reflect.Map, reflect.Pointer:
+			encoded, _ := json.Marshal(fieldValue.Interface())
+			t.add(name, string(encoded))
+		default:
+			t.add(name, fmt.Sprint(fieldValue.Interface()))
+		}
+	}
+	return t
+}
+
+func runGroups(ctx context.Context, a *app, args []string) error {
+	subcommand := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		subcommand, args = args[0], args[1:]
+	}
+
+	switch subcommand {
+	case "list":
+		flags := a.flags("groups")
+		if err := parse(flags, args, 0, -1); err != nil {
+			return err
+		}
+		var getArgs *transmission.GroupGetArgs
+		if flags.NArg() > 0 {
+			getArgs = &transmission.GroupGetArgs{Group: flags.Args()}
+		}
+		result, err := a.client.GroupGet(ctx, getArgs)
+		if err != nil {
+			return err
+		}
+
+		t := &table{headers: []string{"NAME", "DOWN LIMIT", "UP LIMIT", "HONORS SESSION LIMITS"}}
+		for _, group := range result.Group {
+			t.add(
+				group.Name,
+				formatLimit(group.SpeedLimitDown, group.SpeedLimitDownEnabled),
+				formatLimit(group.SpeedLimitUp, group.SpeedLimitUpEnabled),
+				strconv.FormatBool(group.HonorsSessionLimits),
+			)
+		}
+		groups := result.Group
+		if groups == nil {
+			groups = []transmission.Group{}
+		}
+		return a.write(groups, t)
+	case "set":
+		return runGroupSet(ctx, a, args)
+	}
+
+	a.flags("groups").Usage()
+	return ErrUsage
+}
+
+func runGroupSet(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("groups")
+	var (
+		name          = flags.String("name", "", "group name")
+		downloadLimit = flags.String("download-limit", "", "download limit in KB/s, or off")
+		uploadLimit   = flags.String("upload-limit", "", "upload limit in KB/s, or off")
+		honorsSession = flags.Bool("honor-session-limits", true, "whether session speed limits apply")
+	)
+	if err := parse(flags, args, 0, 0); err != nil {
+		return err
+	}
+	if *name == "" {
+		flags.Usage()
+		return ErrUsage
+	}
+
+	setArgs := transmission.GroupSetArgs{Name: *name}
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "download-limit":
+			if setArgs.SpeedLimitDown, setArgs.SpeedLimitDownEnabled, err = parseLimit(*downloadLimit); err != nil {
+				err = fmt.Errorf("invalid -download-limit: %w", err)
+			}
+		case "upload-limit":
+			if setArgs.SpeedLimitUp, setArgs.SpeedLimitUpEnabled, err = parseLimit(*uploadLimit); err != nil {
+				err = fmt.Errorf("invalid -upload-limit: %w", err)
+			}
+		case "honor-session-limits":
+			setArgs.HonorsSessionLimits = honorsSession
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return a.client.GroupSet(ctx, setArgs)
+}
+
+func formatLimit(kilobytesPerSecond int64, enabled bool) string {
+	if !enabled {
+		return "-"
+	}
+	return fmt.Sprintf("%d KB/s", kilobytesPerSecond)
+}
+
+func runFreeSpace(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("free-space")
+	if err := parse(flags, args, 1, 1); err != nil {
+		return err
+	}
+	result, err := a.client.FreeSpace(ctx, transmission.FreeSpaceArgs{Path: flags.Arg(0)})
+	if err != nil {
+		return err
+	}
+
+	t := fieldTable()
+	t.add("path", result.Path)
+	t.add("free", humanize.Bytes(int64(result.SizeBytes)))
+	if result.TotalSize > 0 {
+		t.add("total", humanize.Bytes(int64(result.TotalSize)))
+	}
+	return a.write(result, t)
+}
+
+func runPortTest(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("port-test")
+	if err := parse(flags, args, 0, 0); err != nil {
+		return err
+	}
+	result, err := a.client.PortTest(ctx)
+	if err != nil {
+		return err
+	}
+
+	t := fieldTable()
+	t.add("open", strconv.FormatBool(result.PortIsOpen))
+	if result.IPProtocol != "" {
+		t.add("protocol", result.IPProtocol)
+	}
+	return a.write(result, t)
+}
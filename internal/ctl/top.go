@@ -0,0 +1,28 @@
+package ctl
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/internal/top"
+)
+
+func runTop(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("top")
+	interval := flags.Duration("interval", 2*time.Second, "how often to refresh")
+	if err := parse(flags, args, 0, 0); err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		return errors.New("-interval must be positive")
+	}
+
+	in, inOK := a.stdin.(*os.File)
+	out, outOK := a.stdout.(*os.File)
+	if !inOK || !outOK {
+		return errors.New("top needs a terminal")
+	}
+	return top.Run(ctx, a.client, in, out, *interval)
+}
@@ -0,0 +1,559 @@
+package ctl
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/j-dumbell/go-qbittorrent/internal/humanize"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission/query"
+)
+
+var hashPattern = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+
+// listFields are the fields shown by list and info, besides any needed by
+// the filter.
+var listFields = []string{
+	"id", "name", "status", "percentDone", "totalSize", "sizeWhenDone",
+	"uploadRatio", "rateDownload", "rateUpload", "eta", "labels",
+}
+
+// infoFields are the fields shown by info.
+var infoFields = []string{
+	"id", "name", "hashString", "status", "error", "errorString", "percentDone",
+	"totalSize", "sizeWhenDone", "leftUntilDone", "downloadedEver",
+	"uploadedEver", "uploadRatio", "rateDownload", "rateUpload", "eta",
+	"peersConnected", "downloadDir", "labels", "addedDate", "doneDate",
+	"activityDate", "isPrivate", "comment", "creator", "magnetLink",
+	"trackerList", "trackers", "fileCount",
+}
+
+// parseIDs parses torrent ids given on the command line. "all" selects every
+// torrent.
+func parseIDs(args []string) (*transmission.TorrentIDs, error) {
+	if len(args) == 1 && args[0] == "all" {
+		return transmission.AllTorrents, nil
+	}
+
+	ids := make([]any, 0, len(args))
+	for _, arg := range args {
+		if id, err := strconv.ParseInt(arg, 10, 64); err == nil && id > 0 {
+			ids = append(ids, id)
+			continue
+		}
+		if !hashPattern.MatchString(arg) {
+			return nil, fmt.Errorf("invalid torrent id %q: must be a number, an info hash or \"all\"", arg)
+		}
+		ids = append(ids, strings.ToLower(arg))
+	}
+	return transmission.NewTorrentIDs(ids...), nil
+}
+
+// selectTorrents returns the torrents selected by ids, or by a query if one
+// is given. It returns nil if the query matches no torrents.
+func (a *app) selectTorrents(ctx context.Context, args []string, filter string) (*transmission.TorrentIDs, error) {
+	if filter == "" {
+		return parseIDs(args)
+	}
+	if len(args) > 0 {
+		return nil, errors.New("give either torrent ids or -filter, not both")
+	}
+
+	q, err := query.Compile(filter)
+	if err != nil {
+		return nil, err
+	}
+	result, err := a.client.TorrentGet(ctx, transmission.TorrentGetArgs{Fields: q.Fields("id")})
+	if err != nil {
+		return nil, err
+	}
+	var ids []any
+	for _, torrent := range q.Filter(result.Torrents) {
+		ids = append(ids, torrent.ID)
+	}
+	if len(ids) == 0 {
+		fmt.Fprintln(a.stderr, "no torrents match the filter")
+		return nil, nil
+	}
+	return transmission.NewTorrentIDs(ids...), nil
+}
+
+// selectFlags parses the flags of a command that acts on torrents given by
+// ids or -filter.
+func (a *app) selectFlags(ctx context.Context, flags *flag.FlagSet, args []string) (*transmission.TorrentIDs, bool, error) {
+	filter := flags.String("filter", "", "select torrents with a query instead of ids")
+	if err := parse(flags, args, 0, -1); err != nil {
+		return nil, false, err
+	}
+	if flags.NArg() == 0 && *filter == "" {
+		flags.Usage()
+		return nil, false, ErrUsage
+	}
+
+	ids, err := a.selectTorrents(ctx, flags.Args(), *filter)
+	if err != nil || (ids == nil && *filter != "") {
+		return nil, false, err
+	}
+	return ids, true, nil
+}
+
+func runList(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("list")
+	filter := flags.String("filter", "", "only list torrents matching a query, e.g. 'status == \"seed\" && uploadRatio > 2'")
+	sortBy := flags.String("sort", "id", "sort by id, name, size, ratio, progress, added or activity")
+	if err := parse(flags, args, 0, 0); err != nil {
+		return err
+	}
+
+	q, err := query.Compile(*filter)
+	if err != nil {
+		return err
+	}
+	sortField, compare, err := torrentSort(*sortBy)
+	if err != nil {
+		return err
+	}
+
+	result, err := a.client.TorrentGet(ctx, transmission.TorrentGetArgs{Fields: q.Fields(append(listFields, sortField)...)})
+	if err != nil {
+		return err
+	}
+	torrents := q.Filter(result.Torrents)
+	slices.SortStableFunc(torrents, compare)
+
+	t := &table{headers: []string{"ID", "NAME", "STATUS", "DONE", "SIZE", "RATIO", "DOWN", "UP", "ETA", "LABELS"}}
+	for _, torrent := range torrents {
+		t.add(
+			strconv.FormatInt(torrent.ID, 10),
+			torrent.Name,
+			torrent.Status.String(),
+			humanize.Percent(torrent.PercentDone),
+			humanize.Bytes(torrent.SizeWhenDone),
+			humanize.Ratio(torrent.UploadRatio),
+			humanize.Rate(torrent.RateDownload),
+			humanize.Rate(torrent.RateUpload),
+			humanize.ETA(torrent.ETA),
+			strings.Join(torrent.Labels, ","),
+		)
+	}
+	if torrents == nil {
+		torrents = []transmission.Torrent{}
+	}
+	return a.write(torrents, t)
+}
+
+func torrentSort(name string) (string, func(a, b transmission.Torrent) int, error) {
+	switch name {
+	case "id":
+		return "id", func(a, b transmission.Torrent) int { return cmp.Compare(a.ID, b.ID) }, nil
+	case "name":
+		return "name", func(a, b transmission.Torrent) int { return strings.Compare(a.Name, b.Name) }, nil
+	case "size":
+		return "sizeWhenDone", func(a, b transmission.Torrent) int { return cmp.Compare(a.SizeWhenDone, b.SizeWhenDone) }, nil
+	case "ratio":
+		return "uploadRatio", func(a, b transmission.Torrent) int { return cmp.Compare(a.UploadRatio, b.UploadRatio) }, nil
+	case "progress":
+		return "percentDone", func(a, b transmission.Torrent) int { return cmp.Compare(a.PercentDone, b.PercentDone) }, nil
+	case "added":
+		return "addedDate", func(a, b transmission.Torrent) int { return cmp.Compare(a.AddedDate, b.AddedDate) }, nil
+	case "activity":
+		return "activityDate", func(a, b transmission.Torrent) int { return cmp.Compare(a.ActivityDate, b.ActivityDate) }, nil
+	}
+	return "", nil, fmt.Errorf("can't sort by %q", name)
+}
+
+func runInfo(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("info")
+	if err := parse(flags, args, 1, -1); err != nil {
+		return err
+	}
+	ids, err := parseIDs(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	result, err := a.client.TorrentGet(ctx, transmission.TorrentGetArgs{IDs: ids, Fields: infoFields})
+	if err != nil {
+		return err
+	}
+	if len(result.Torrents) == 0 {
+		return errors.New("no such torrents")
+	}
+
+	t := fieldTable()
+	for i, torrent := range result.Torrents {
+		if i > 0 {
+			t.add("", "")
+		}
+		t.add("id", strconv.FormatInt(torrent.ID, 10))
+		t.add("name", torrent.Name)
+		t.add("hash", torrent.HashString)
+		t.add("status", torrent.Status.String())
+		if torrent.Error != transmission.TorrentErrorNone {
+			t.add("error", fmt.Sprintf("%s: %s", torrent.Error, torrent.ErrorString))
+		}
+		t.add("progress", humanize.Percent(torrent.PercentDone))
+		t.add("size", humanize.Bytes(torrent.TotalSize))
+		t.add("wanted", humanize.Bytes(torrent.SizeWhenDone))
+		t.add("left", humanize.Bytes(torrent.LeftUntilDone))
+		t.add("downloaded", humanize.Bytes(torrent.DownloadedEver))
+		t.add("uploaded", humanize.Bytes(torrent.UploadedEver))
+		t.add("ratio", humanize.Ratio(torrent.UploadRatio))
+		t.add("download rate", humanize.Rate(torrent.RateDownload))
+		t.add("upload rate", humanize.Rate(torrent.RateUpload))
+		t.add("eta", humanize.ETA(torrent.ETA))
+		t.add("peers", strconv.FormatInt(torrent.PeersConnected, 10))
+		t.add("location", torrent.DownloadDir)
+		t.add("labels", strings.Join(torrent.Labels, ","))
+		t.add("added", humanize.Time(torrent.AddedDate))
+		t.add("completed", humanize.Time(torrent.DoneDate))
+		t.add("last activity", humanize.Time(torrent.ActivityDate))
+		t.add("private", strconv.FormatBool(torrent.IsPrivate))
+		t.add("files", strconv.FormatInt(torrent.FileCount, 10))
+		for tier, announces := range torrent.TrackerTiers() {
+			t.add(fmt.Sprintf("tracker tier %d", tier+1), strings.Join(announces, " "))
+		}
+		if torrent.Comment != "" {
+			t.add("comment", torrent.Comment)
+		}
+		t.add("magnet", torrent.MagnetLink)
+	}
+	return a.write(result.Torrents, t)
+}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func splitLabels(value string) []string {
+	var labels []string
+	for _, label := range strings.Split(value, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+func runAdd(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("add")
+	var (
+		paused      = flags.Bool("paused", false, "add without starting")
+		downloadDir = flags.String("download-dir", "", "download directory (default the session's)")
+		labels      = flags.String("labels", "", "comma separated labels")
+		priority    = flags.String("priority", "", "bandwidth priority: low, normal or high")
+		want        stringList
+		skip        stringList
+	)
+	flags.Var(&want, "want", "only download files matching a glob, e.g. '*.mkv' (repeatable)")
+	flags.Var(&skip, "skip", "don't download files matching a glob, e.g. 'Sample/**' (repeatable)")
+	if err := parse(flags, args, 1, -1); err != nil {
+		return err
+	}
+
+	opts := transmission.AddOptions{
+		Labels:        splitLabels(*labels),
+		DownloadDir:   *downloadDir,
+		FilesWanted:   want,
+		FilesUnwanted: skip,
+	}
+	if *paused {
+		opts.Paused = paused
+	}
+	if *priority != "" {
+		p, err := parseEnum[transmission.Priority](*priority)
+		if err != nil {
+			return fmt.Errorf("invalid priority: %w", err)
+		}
+		opts.BandwidthPriority = &p
+	}
+
+	var (
+		results []*transmission.AddResult
+		errs    []error
+	)
+	t := &table{headers: []string{"ID", "NAME", "HASH", "RESULT"}}
+	for _, source := range flags.Args() {
+		result, err := a.add(ctx, source, opts)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error adding %s: %w", source, err))
+			continue
+		}
+		results = append(results, result)
+
+		outcome := "added"
+		switch {
+		case result.DryRun:
+			outcome = "dry run"
+		case result.Duplicate:
+			outcome = "duplicate"
+		case result.FileSelectionPending:
+			outcome = "added, file selection pending"
+		}
+		t.add(strconv.FormatInt(result.Torrent.ID, 10), result.Torrent.Name, result.Torrent.HashString, outcome)
+	}
+
+	if err := a.write(results, t); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+func (a *app) add(ctx context.Context, source string, opts transmission.AddOptions) (*transmission.AddResult, error) {
+	switch {
+	case source == "-":
+		return a.client.TorrentAddReader(ctx, a.stdin, opts)
+	case strings.HasPrefix(source, "magnet:"):
+		return a.client.TorrentAddMagnet(ctx, source, opts)
+	case strings.HasPrefix(source, "http://"), strings.HasPrefix(source, "https://"):
+		return a.client.TorrentAddURL(ctx, source, opts)
+	}
+	return a.client.TorrentAddFile(ctx, source, opts)
+}
+
+// parseEnum parses an enum label or number using its JSON decoding.
+func parseEnum[T any](value string) (T, error) {
+	var result T
+	encoded := strconv.Quote(value)
+	if _, err := strconv.Atoi(value); err == nil {
+		encoded = value
+	}
+	err := json.Unmarshal([]byte(encoded), &result)
+	return result, err
+}
+
+func runRemove(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("remove")
+	deleteData := flags.Bool("delete-data", false, "also delete the downloaded data")
+	ids, ok, err := a.selectFlags(ctx, flags, args)
+	if !ok {
+		return err
+	}
+	return a.client.TorrentRemove(ctx, transmission.TorrentRemoveArgs{IDs: ids, DeleteLocalData: *deleteData})
+}
+
+func runStart(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("start")
+	now := flags.Bool("now", false, "start immediately, bypassing the queue")
+	ids, ok, err := a.selectFlags(ctx, flags, args)
+	if !ok {
+		return err
+	}
+	if *now {
+		return a.client.TorrentStartNow(ctx, ids)
+	}
+	return a.client.TorrentStart(ctx, ids)
+}
+
+// runIDsCommand returns a command that calls method with the selected
+// torrents.
+func runIDsCommand(name string, method func(*transmission.Client, context.Context, *transmission.TorrentIDs) error) func(context.Context, *app, []string) error {
+	return func(ctx context.Context, a *app, args []string) error {
+		ids, ok, err := a.selectFlags(ctx, a.flags(name), args)
+		if !ok {
+			return err
+		}
+		return method(a.client, ctx, ids)
+	}
+}
+
+func runSet(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("set")
+	var (
+		ratio         = flags.Float64("ratio", 0, "seed ratio limit; also sets -ratio-mode single")
+		ratioMode     = flags.String("ratio-mode", "", "seed ratio mode: global, single or unlimited")
+		idleLimit     = flags.Int("idle-limit", 0, "seed idle limit in minutes; also sets -idle-mode single")
+		idleMode      = flags.String("idle-mode", "", "seed idle mode: global, single or unlimited")
+		downloadLimit = flags.String("download-limit", "", "download limit in KB/s, or off")
+		uploadLimit   = flags.String("upload-limit", "", "upload limit in KB/s, or off")
+		peerLimit     = flags.Int("peer-limit", 0, "maximum number of peers")
+		priority      = flags.String("priority", "", "bandwidth priority: low, normal or high")
+		honorsSession = flags.Bool("honor-session-limits", true, "whether session speed limits apply")
+		labels        = flags.String("labels", "", "replace labels with a comma separated list")
+		addLabels     = flags.String("add-labels", "", "comma separated labels to add")
+		removeLabels  = flags.String("remove-labels", "", "comma separated labels to remove")
+	)
+	ids, ok, err := a.selectFlags(ctx, flags, args)
+	if !ok {
+		return err
+	}
+
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var setArgs transmission.TorrentSetArgs
+	if set["ratio"] {
+		setArgs.SeedRatioLimit = ratio
+		mode := transmission.RatioModeSingle
+		setArgs.SeedRatioMode = &mode
+	}
+	if set["ratio-mode"] {
+		mode, err := parseEnum[transmission.RatioMode](*ratioMode)
+		if err != nil {
+			return fmt.Errorf("invalid -ratio-mode: %w", err)
+		}
+		setArgs.SeedRatioMode = &mode
+	}
+	if set["idle-limit"] {
+		setArgs.SeedIdleLimit = idleLimit
+		mode := transmission.IdleModeSingle
+		setArgs.SeedIdleMode = &mode
+	}
+	if set["idle-mode"] {
+		mode, err := parseEnum[transmission.IdleMode](*idleMode)
+		if err != nil {
+			return fmt.Errorf("invalid -idle-mode: %w", err)
+		}
+		setArgs.SeedIdleMode = &mode
+	}
+	if set["download-limit"] {
+		if setArgs.DownloadLimit, setArgs.DownloadLimited, err = parseLimit(*downloadLimit); err != nil {
+			return fmt.Errorf("invalid -download-limit: %w", err)
+		}
+	}
+	if set["upload-limit"] {
+		if setArgs.UploadLimit, setArgs.UploadLimited, err = parseLimit(*uploadLimit); err != nil {
+			return fmt.Errorf("invalid -upload-limit: %w", err)
+		}
+	}
+	if set["peer-limit"] {
+		setArgs.PeerLimit = peerLimit
+	}
+	if set["priority"] {
+		p, err := parseEnum[transmission.Priority](*priority)
+		if err != nil {
+			return fmt.Errorf("invalid -priority: %w", err)
+		}
+		setArgs.BandwidthPriority = &p
+	}
+	if set["honor-session-limits"] {
+		setArgs.HonorsSessionLimits = honorsSession
+	}
+	if set["labels"] {
+		replacement := splitLabels(*labels)
+		if replacement == nil {
+			replacement = []string{}
+		}
+		setArgs.Labels = &replacement
+	}
+
+	if encoded, _ := json.Marshal(setArgs); string(encoded) != "{}" {
+		// TorrentSetArgs takes the ids as a list rather than TorrentIDs;
+		// leaving it empty selects all torrents.
+		if ids != nil {
+			encoded, _ := json.Marshal(ids)
+			if err := json.Unmarshal(encoded, &setArgs.Ids); err != nil {
+				return err
+			}
+		}
+		if err := a.client.TorrentSet(ctx, setArgs); err != nil {
+			return err
+		}
+	}
+	if set["add-labels"] {
+		if _, err := a.client.AddLabels(ctx, ids, splitLabels(*addLabels)...); err != nil {
+			return err
+		}
+	}
+	if set["remove-labels"] {
+		if _, err := a.client.RemoveLabels(ctx, ids, splitLabels(*removeLabels)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseLimit parses a speed limit in KB/s, or "off" for no limit.
+func parseLimit(value string) (*int, *bool, error) {
+	if value == "off" {
+		limited := false
+		return nil, &limited, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return nil, nil, fmt.Errorf("%q is not a number of KB/s or off", value)
+	}
+	limited := true
+	return &limit, &limited, nil
+}
+
+func runMove(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("move")
+	to := flags.String("to", "", "new location")
+	move := flags.Bool("move", true, "move the data; if false, look for the data in the new location")
+	ids, ok, err := a.selectFlags(ctx, flags, args)
+	if !ok {
+		return err
+	}
+	if *to == "" {
+		return errors.New("-to is required")
+	}
+	return a.client.TorrentSetLocation(ctx, transmission.TorrentSetLocationArgs{IDs: ids, Location: *to, Move: *move})
+}
+
+func runRename(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("rename")
+	if err := parse(flags, args, 3, 3); err != nil {
+		return err
+	}
+	ids, err := parseIDs(flags.Args()[:1])
+	if err != nil {
+		return err
+	}
+	if ids == transmission.AllTorrents {
+		return errors.New("rename needs a single torrent")
+	}
+
+	result, err := a.client.TorrentRenamePath(ctx, transmission.TorrentRenamePathArgs{
+		IDs:  ids,
+		Path: flags.Arg(1),
+		Name: flags.Arg(2),
+	})
+	if err != nil {
+		return err
+	}
+	t := fieldTable()
+	t.add("id", strconv.FormatInt(result.ID, 10))
+	t.add("path", result.Path)
+	t.add("name", result.Name)
+	return a.write(result, t)
+}
+
+func runQueue(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		a.flags("queue").Usage()
+		return ErrUsage
+	}
+
+	methods := map[string]func(*transmission.Client, context.Context, *transmission.TorrentIDs) error{
+		"top":    (*transmission.Client).QueueMoveTop,
+		"up":     (*transmission.Client).QueueMoveUp,
+		"down":   (*transmission.Client).QueueMoveDown,
+		"bottom": (*transmission.Client).QueueMoveBottom,
+	}
+	method, ok := methods[args[0]]
+	if !ok {
+		if args[0] == "-h" || args[0] == "-help" {
+			return parse(a.flags("queue"), args, 0, -1)
+		}
+		a.flags("queue").Usage()
+		return ErrUsage
+	}
+	return runIDsCommand("queue", method)(ctx, a, args[1:])
+}
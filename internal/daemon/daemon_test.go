@@ -0,0 +1,131 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDuration(t *testing.T) {
+	for input, expected := range map[string]time.Duration{
+		"90s":   90 * time.Second,
+		"1h30m": 90 * time.Minute,
+		"14d":   14 * 24 * time.Hour,
+		"2w":    14 * 24 * time.Hour,
+	} {
+		actual, err := ParseDuration(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, actual, input)
+	}
+
+	for _, input := range []string{"", "1.5d", "d", "soon"} {
+		_, err := ParseDuration(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for input, expected := range map[string]int64{
+		"0":       0,
+		"512":     512,
+		"10B":     10,
+		"1.5kB":   1500,
+		"500 MB":  500_000_000,
+		"2GiB":    2 << 30,
+		"1TB":     1_000_000_000_000,
+		"0.5 TiB": 1 << 39,
+	} {
+		actual, err := ParseSize(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, actual, input)
+	}
+
+	for _, input := range []string{"", "GB", "-1GB", "10 PB", "lots"} {
+		_, err := ParseSize(input)
+		assert.Error(t, err, input)
+	}
+
+	var sizes []Size
+	require.NoError(t, json.Unmarshal([]byte(`[1024, "1KiB"]`), &sizes))
+	assert.Equal(t, []Size{1024, 1024}, sizes)
+	assert.Error(t, json.Unmarshal([]byte(`[true]`), &sizes))
+}
+
+func TestLoadConfig(t *testing.T) {
+	type config struct {
+		Interval Duration `json:"interval"`
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"interval": "1d"}`), 0o600))
+	var c config
+	require.NoError(t, LoadConfig(path, &c))
+	assert.Equal(t, 24*time.Hour, c.Interval.Or(time.Minute))
+	assert.Equal(t, time.Minute, Duration(0).Or(time.Minute))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"intreval": "1d"}`), 0o600))
+	assert.ErrorContains(t, LoadConfig(path, &c), `unknown field "intreval"`)
+}
+
+func TestState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	state := map[string]int{"a": 1}
+	require.NoError(t, LoadState(path, &state), "a missing state file isn't an error")
+	assert.Equal(t, map[string]int{"a": 1}, state)
+
+	require.NoError(t, SaveState(path, map[string]int{"b": 2}))
+	state = nil
+	require.NoError(t, LoadState(path, &state))
+	assert.Equal(t, map[string]int{"b": 2}, state)
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary files are left behind")
+}
+
+func TestEvery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	Every(ctx, time.Millisecond, func(context.Context) {
+		calls++
+		if calls == 3 {
+			cancel()
+		}
+	})
+	assert.Equal(t, 3, calls)
+}
+
+func TestServe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_total", Help: "Test."})
+	reg.MustRegister(counter)
+	counter.Inc()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- serve(ctx, listener, NewMux(reg), slog.New(slog.DiscardHandler)) }()
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/metrics")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "test_total 1")
+
+	cancel()
+	assert.NoError(t, <-done)
+}
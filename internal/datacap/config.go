@@ -0,0 +1,100 @@
+package datacap
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/internal/daemon"
+)
+
+const (
+	defaultInterval = time.Minute
+	defaultResetDay = 1
+
+	countUpload   = "upload"
+	countDownload = "download"
+	countTotal    = "total"
+)
+
+// Config is the accountant's config file.
+type Config struct {
+	// Interval is how often the session stats are sampled. Defaults to 1m.
+	Interval daemon.Duration `json:"interval"`
+	// Allowance is the data allowed in each period.
+	Allowance daemon.Size `json:"allowance"`
+	// Count is what counts towards the allowance: "upload" (the default),
+	// "download" or "total".
+	Count string `json:"count"`
+	// ResetDay is the day of the month each period starts on, between 1 (the
+	// default) and 28.
+	ResetDay int `json:"resetDay"`
+	// Timezone is the IANA time zone periods start in, such as
+	// "Europe/London". Defaults to the local time zone.
+	Timezone string `json:"timezone"`
+	// StateFile is where usage is kept between samples and restarts.
+	StateFile string `json:"stateFile"`
+	// Thresholds are applied once the given share of the allowance is used,
+	// each on top of the ones before it, until the next period starts.
+	Thresholds []Threshold `json:"thresholds"`
+}
+
+// Threshold is a change to the session settings once Percent of the
+// allowance is used.
+type Threshold struct {
+	Percent float64 `json:"percent"`
+	// AltSpeed turns on Transmission's alternative speed limits.
+	AltSpeed bool `json:"altSpeed"`
+	// SpeedLimitUp, if set, is the upload speed limit to enforce, in kB/s.
+	SpeedLimitUp *int `json:"speedLimitUp"`
+}
+
+// LoadConfig reads and validates the config file at path.
+func LoadConfig(path string) (*Config, error) {
+	var config Config
+	if err := daemon.LoadConfig(path, &config); err != nil {
+		return nil, err
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+func (c *Config) validate() error {
+	if c.Allowance <= 0 {
+		return errors.New("allowance is required")
+	}
+	switch c.Count {
+	case "":
+		c.Count = countUpload
+	case countUpload, countDownload, countTotal:
+	default:
+		return fmt.Errorf("count must be %s, %s or %s", countUpload, countDownload, countTotal)
+	}
+	if c.ResetDay == 0 {
+		c.ResetDay = defaultResetDay
+	}
+	if c.ResetDay < 1 || c.ResetDay > 28 {
+		return errors.New("resetDay must be between 1 and 28")
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("timezone: %w", err)
+	}
+	if c.StateFile == "" {
+		return errors.New("stateFile is required")
+	}
+	for i, t := range c.Thresholds {
+		switch {
+		case t.Percent <= 0:
+			return fmt.Errorf("thresholds[%d]: percent must be positive", i)
+		case i > 0 && t.Percent <= c.Thresholds[i-1].Percent:
+			return fmt.Errorf("thresholds[%d]: percents must increase", i)
+		case !t.AltSpeed && t.SpeedLimitUp == nil:
+			return fmt.Errorf("thresholds[%d]: altSpeed or speedLimitUp is required", i)
+		case t.SpeedLimitUp != nil && *t.SpeedLimitUp < 0:
+			return fmt.Errorf("thresholds[%d]: speedLimitUp can't be negative", i)
+		}
+	}
+	return nil
+}
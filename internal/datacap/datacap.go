@@ -0,0 +1,335 @@
+// Package datacap keeps track of the data Transmission uses in each billing
+// period, and limits uploads as the allowance runs out.
+//
+// Transmission's own cumulative stats can't be used for this directly: they
+// are only saved from time to time, so a crash loses some of them, and they
+// start again from zero if the daemon's config is lost. Instead the session
+// stats are sampled regularly and the usage between samples is added up in a
+// state file, taking daemon restarts into account.
+package datacap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/internal/daemon"
+	"github.com/j-dumbell/go-qbittorrent/internal/humanize"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxHistory is the number of past periods kept in the state file.
+const maxHistory = 12
+
+// Client is the subset of *transmission.Client used by the accountant.
+type Client interface {
+	SessionStats(ctx context.Context) (*transmission.SessionStatsResult, error)
+	SessionGet(ctx context.Context) (*transmission.Session, error)
+	SessionSet(ctx context.Context, args transmission.SessionSetArgs) error
+}
+
+// Options are the accountant's optional settings.
+type Options struct {
+	Logger *slog.Logger
+	// Registerer, if set, registers the accountant's metrics.
+	Registerer prometheus.Registerer
+	// DryRun logs the limits that would be applied without applying them.
+	// Usage is still counted, but the state file isn't written.
+	DryRun bool
+}
+
+// Accountant counts usage and applies thresholds.
+type Accountant struct {
+	client     Client
+	interval   time.Duration
+	allowance  int64
+	count      string
+	resetDay   int
+	location   *time.Location
+	stateFile  string
+	thresholds []Threshold
+	logger     *slog.Logger
+	metrics    *metrics
+	dryRun     bool
+	now        func() time.Time
+
+	state state
+}
+
+// state is the state file's content.
+type state struct {
+	PeriodStart time.Time `json:"periodStart,omitzero"`
+	Usage       usage     `json:"usage"`
+	// Last is the last sample, which the next one is compared with.
+	Last *sample `json:"last,omitempty"`
+	// Thresholds is the number of thresholds applied.
+	Thresholds int `json:"thresholds"`
+	// Saved holds the settings the thresholds change, from before the first
+	// was applied, to restore when the next period starts.
+	Saved *saved `json:"saved,omitempty"`
+	// History is the usage of past periods, by their start date.
+	History map[string]usage `json:"history,omitempty"`
+}
+
+type saved struct {
+	AltSpeedEnabled     bool `json:"altSpeedEnabled"`
+	SpeedLimitUp        int  `json:"speedLimitUp"`
+	SpeedLimitUpEnabled bool `json:"speedLimitUpEnabled"`
+}
+
+// New returns an accountant for config, which must have been validated by
+// LoadConfig. Usage so far is read from the state file.
+func New(client Client, config Config, opts Options) (*Accountant, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("error loading time zone: %w", err)
+	}
+	m := newMetrics()
+	if opts.Registerer != nil {
+		if err := m.register(opts.Registerer); err != nil {
+			return nil, fmt.Errorf("error registering metrics: %w", err)
+		}
+	}
+	a := &Accountant{
+		client:     client,
+		interval:   config.Interval.Or(defaultInterval),
+		allowance:  int64(config.Allowance),
+		count:      config.Count,
+		resetDay:   config.ResetDay,
+		location:   location,
+		stateFile:  config.StateFile,
+		thresholds: config.Thresholds,
+		logger:     logger,
+		metrics:    m,
+		dryRun:     opts.DryRun,
+		now:        time.Now,
+	}
+	if err := daemon.LoadState(a.stateFile, &a.state); err != nil {
+		return nil, err
+	}
+	m.allowance.Set(float64(a.allowance))
+	return a, nil
+}
+
+// Run samples the session stats every interval until ctx is done.
+func (a *Accountant) Run(ctx context.Context) {
+	a.logger.Info("starting data cap accounting",
+		"allowance", humanize.Bytes(a.allowance),
+		"count", a.count,
+		"resetDay", a.resetDay,
+		"thresholds", len(a.thresholds),
+		"interval", a.interval,
+		"dryRun", a.dryRun,
+	)
+	daemon.Every(ctx, a.interval, func(ctx context.Context) {
+		if err := a.Sample(ctx); err != nil {
+			a.logger.Error("error sampling data usage", "err", err)
+		}
+	})
+}
+
+// Sample adds the usage since the last sample to the current period's, and
+// applies or lifts the thresholds' limits.
+func (a *Accountant) Sample(ctx context.Context) error {
+	if err := a.sample(ctx); err != nil {
+		a.metrics.samples.WithLabelValues(resultError).Inc()
+		return err
+	}
+	a.metrics.samples.WithLabelValues(resultSuccess).Inc()
+	a.metrics.lastSample.Set(float64(a.now().Unix()))
+	return nil
+}
+
+func (a *Accountant) sample(ctx context.Context) error {
+	stats, err := a.client.SessionStats(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting session stats: %w", err)
+	}
+	s := newSample(stats)
+	now := a.now().In(a.location)
+
+	if start := periodStart(now, a.resetDay); !a.state.PeriodStart.Equal(start) {
+		a.startPeriod(start)
+	}
+	if a.state.Last == nil {
+		a.logger.Info("counting data usage from now on")
+	} else {
+		used, restarted := s.since(*a.state.Last)
+		if restarted {
+			a.logger.Info("Transmission restarted or its stats were reset since the last sample",
+				"uploaded", humanize.Bytes(used.Uploaded),
+				"downloaded", humanize.Bytes(used.Downloaded),
+			)
+			a.metrics.restarts.Inc()
+		}
+		a.state.Usage = a.state.Usage.add(used)
+	}
+	a.state.Last = &s
+
+	enforceErr := a.enforce(ctx)
+	a.observe()
+	if !a.dryRun {
+		if err := daemon.SaveState(a.stateFile, a.state); err != nil {
+			return err
+		}
+	}
+	return enforceErr
+}
+
+// startPeriod archives the current period's usage and starts a new period.
+func (a *Accountant) startPeriod(start time.Time) {
+	if !a.state.PeriodStart.IsZero() {
+		a.logger.Info("data cap period ended",
+			"start", a.state.PeriodStart.Format(time.DateOnly),
+			"uploaded", humanize.Bytes(a.state.Usage.Uploaded),
+			"downloaded", humanize.Bytes(a.state.Usage.Downloaded),
+		)
+		if a.state.History == nil {
+			a.state.History = map[string]usage{}
+		}
+		a.state.History[a.state.PeriodStart.Format(time.DateOnly)] = a.state.Usage
+		for _, key := range slices.Sorted(maps.Keys(a.state.History)) {
+			if len(a.state.History) <= maxHistory {
+				break
+			}
+			delete(a.state.History, key)
+		}
+	}
+	a.state.PeriodStart = start
+	a.state.Usage = usage{}
+}
+
+// enforce applies the settings of the thresholds reached, or restores the
+// previous settings if none are reached any more.
+func (a *Accountant) enforce(ctx context.Context) error {
+	used := a.state.Usage.counted(a.count)
+	reached := 0
+	for _, t := range a.thresholds {
+		if float64(used) >= t.Percent/100*float64(a.allowance) {
+			reached++
+		}
+	}
+	if reached == 0 {
+		a.state.Thresholds = 0
+		if a.state.Saved == nil {
+			return nil
+		}
+		return a.restore(ctx)
+	}
+
+	logger := a.logger.With(
+		"thresholds", reached,
+		"percent", a.thresholds[reached-1].Percent,
+		"used", humanize.Bytes(used),
+		"allowance", humanize.Bytes(a.allowance),
+	)
+	newlyReached := reached > a.state.Thresholds
+	if a.dryRun {
+		if newlyReached {
+			logger.Warn("data cap threshold reached, not limiting in dry run")
+			a.metrics.actions.WithLabelValues(actionLimit, resultDryRun).Inc()
+		}
+		a.state.Thresholds = reached
+		return nil
+	}
+
+	session, err := a.client.SessionGet(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting session: %w", err)
+	}
+	args := limits(a.thresholds[:reached])
+	if !limitsChanged(session, args) {
+		a.state.Thresholds = reached
+		return nil
+	}
+	if a.state.Saved == nil {
+		a.state.Saved = &saved{
+			AltSpeedEnabled:     session.AltSpeedEnabled,
+			SpeedLimitUp:        session.SpeedLimitUp,
+			SpeedLimitUpEnabled: session.SpeedLimitUpEnabled,
+		}
+	}
+	if err := a.client.SessionSet(ctx, args); err != nil {
+		logger.Error("error applying data cap limits", "err", err)
+		a.metrics.actions.WithLabelValues(actionLimit, resultError).Inc()
+		return fmt.Errorf("error applying data cap limits: %w", err)
+	}
+	if newlyReached {
+		logger.Warn("data cap threshold reached, applied limits")
+	} else {
+		logger.Warn("data cap limits were changed, applied them again")
+	}
+	a.metrics.actions.WithLabelValues(actionLimit, resultSuccess).Inc()
+	a.state.Thresholds = reached
+	return nil
+}
+
+// restore puts back the settings the thresholds changed.
+func (a *Accountant) restore(ctx context.Context) error {
+	var args transmission.SessionSetArgs
+	for _, t := range a.thresholds {
+		if t.AltSpeed {
+			args.AltSpeedEnabled = &a.state.Saved.AltSpeedEnabled
+		}
+		if t.SpeedLimitUp != nil {
+			args.SpeedLimitUp = &a.state.Saved.SpeedLimitUp
+			args.SpeedLimitUpEnabled = &a.state.Saved.SpeedLimitUpEnabled
+		}
+	}
+	if a.dryRun {
+		a.logger.Info("new data cap period, not restoring previous settings in dry run")
+		a.metrics.actions.WithLabelValues(actionRestore, resultDryRun).Inc()
+		return nil
+	}
+	if err := a.client.SessionSet(ctx, args); err != nil {
+		a.logger.Error("error restoring settings from before the data cap limits", "err", err)
+		a.metrics.actions.WithLabelValues(actionRestore, resultError).Inc()
+		return fmt.Errorf("error restoring settings: %w", err)
+	}
+	a.logger.Info("new data cap period, restored previous settings")
+	a.metrics.actions.WithLabelValues(actionRestore, resultSuccess).Inc()
+	a.state.Saved = nil
+	return nil
+}
+
+// limits returns the session settings of thresholds, each applied on top of
+// the ones before it.
+func limits(thresholds []Threshold) transmission.SessionSetArgs {
+	var args transmission.SessionSetArgs
+	enabled := true
+	for _, t := range thresholds {
+		if t.AltSpeed {
+			args.AltSpeedEnabled = &enabled
+		}
+		if t.SpeedLimitUp != nil {
+			args.SpeedLimitUp = t.SpeedLimitUp
+			args.SpeedLimitUpEnabled = &enabled
+		}
+	}
+	return args
+}
+
+// limitsChanged returns whether any of the settings in args differ from
+// session's.
+func limitsChanged(session *transmission.Session, args transmission.SessionSetArgs) bool {
+	return (args.AltSpeedEnabled != nil && *args.AltSpeedEnabled != session.AltSpeedEnabled) ||
+		(args.SpeedLimitUp != nil && *args.SpeedLimitUp != session.SpeedLimitUp) ||
+		(args.SpeedLimitUpEnabled != nil && *args.SpeedLimitUpEnabled != session.SpeedLimitUpEnabled)
+}
+
+func (a *Accountant) observe() {
+	used := a.state.Usage.counted(a.count)
+	a.metrics.used.WithLabelValues(countUpload).Set(float64(a.state.Usage.Uploaded))
+	a.metrics.used.WithLabelValues(countDownload).Set(float64(a.state.Usage.Downloaded))
+	a.metrics.remaining.Set(float64(max(0, a.allowance-used)))
+	a.metrics.periodStart.Set(float64(a.state.PeriodStart.Unix()))
+	a.metrics.thresholds.Set(float64(a.state.Thresholds))
+}
@@ -0,0 +1,223 @@
+package datacap
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gb = 1_000_000_000
+
+type fakeClient struct {
+	stats   transmission.SessionStatsResult
+	session transmission.Session
+	calls   []string
+}
+
+func (f *fakeClient) SessionStats(context.Context) (*transmission.SessionStatsResult, error) {
+	stats := f.stats
+	return &stats, nil
+}
+
+func (f *fakeClient) SessionGet(context.Context) (*transmission.Session, error) {
+	session := f.session
+	return &session, nil
+}
+
+func (f *fakeClient) SessionSet(_ context.Context, args transmission.SessionSetArgs) error {
+	data, _ := json.Marshal(args)
+	f.calls = append(f.calls, string(data))
+	return json.Unmarshal(data, &f.session)
+}
+
+// upload adds to the uploaded stats, as Transmission would.
+func (f *fakeClient) upload(bytes int) {
+	f.stats.CurrentStats.UploadedBytes += bytes
+	f.stats.CumulativeStats.UploadedBytes += bytes
+	f.stats.CurrentStats.SecondsActive += 60
+	f.stats.CumulativeStats.SecondsActive += 60
+}
+
+// restart restarts the daemon, losing the cumulative stats of the last
+// unsaved seconds.
+func (f *fakeClient) restart(unsaved int) {
+	f.stats.CumulativeStats.UploadedBytes -= unsaved
+	f.stats.CurrentStats = transmission.Stats{}
+}
+
+func testConfig(t *testing.T, stateFile string) Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "datacap.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"allowance": "100GB",
+		"resetDay": 15,
+		"timezone": "UTC",
+		"stateFile": "`+stateFile+`",
+		"thresholds": [
+			{"percent": 50, "altSpeed": true},
+			{"percent": 90, "speedLimitUp": 10}
+		]
+	}`), 0o600))
+	c, err := LoadConfig(path)
+	require.NoError(t, err)
+	return *c
+}
+
+func TestSample(t *testing.T) {
+	ctx := context.Background()
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	config := testConfig(t, stateFile)
+	client := &fakeClient{session: transmission.Session{SpeedLimitUp: 1000}}
+	now := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	newAccountant := func(opts Options) *Accountant {
+		a, err := New(client, config, opts)
+		require.NoError(t, err)
+		a.now = func() time.Time { return now }
+		return a
+	}
+
+	// What was used before the first sample isn't counted.
+	client.upload(500 * gb)
+	a := newAccountant(Options{Registerer: prometheus.NewRegistry()})
+	require.NoError(t, a.Sample(ctx))
+	client.upload(30 * gb)
+	require.NoError(t, a.Sample(ctx))
+	assert.Equal(t, usage{Uploaded: 30 * gb}, a.state.Usage)
+	assert.Empty(t, client.calls)
+
+	assert.Equal(t, float64(70*gb), testutil.ToFloat64(a.metrics.remaining))
+
+	// The daemon restarts, losing 5GB of its cumulative stats; a restarted
+	// accountant carries on from the state file.
+	client.upload(10 * gb)
+	client.restart(5 * gb)
+	client.upload(15 * gb)
+	a = newAccountant(Options{})
+	require.NoError(t, a.Sample(ctx))
+	assert.Equal(t, usage{Uploaded: 50 * gb}, a.state.Usage, "30GB + 5GB saved before the restart + 15GB since")
+	assert.Equal(t, []string{`{"alt-speed-enabled":true}`}, client.calls)
+
+	// Limits changed by hand are applied again.
+	client.calls = nil
+	client.session.AltSpeedEnabled = false
+	require.NoError(t, a.Sample(ctx))
+	assert.Equal(t, []string{`{"alt-speed-enabled":true}`}, client.calls)
+
+	client.calls = nil
+	client.upload(45 * gb)
+	require.NoError(t, a.Sample(ctx))
+	assert.Equal(t, []string{`{"alt-speed-enabled":true,"speed-limit-up":10,"speed-limit-up-enabled":true}`}, client.calls)
+
+	// The next period starts on the 15th: the previous settings come back.
+	client.calls = nil
+	now = time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+	client.upload(gb)
+	require.NoError(t, a.Sample(ctx))
+	assert.Equal(t, []string{`{"alt-speed-enabled":false,"speed-limit-up":1000,"speed-limit-up-enabled":false}`}, client.calls)
+	assert.Equal(t, usage{Uploaded: gb}, a.state.Usage)
+	assert.Equal(t, map[string]usage{"2024-05-15": {Uploaded: 95 * gb}}, a.state.History)
+
+	data, err := os.ReadFile(stateFile)
+	require.NoError(t, err)
+	var s state
+	require.NoError(t, json.Unmarshal(data, &s))
+	assert.Equal(t, a.state.Usage, s.Usage)
+	assert.Nil(t, s.Saved)
+}
+
+func TestSampleDryRun(t *testing.T) {
+	ctx := context.Background()
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	client := &fakeClient{}
+	a, err := New(client, testConfig(t, stateFile), Options{DryRun: true})
+	require.NoError(t, err)
+
+	require.NoError(t, a.Sample(ctx))
+	client.upload(95 * gb)
+	require.NoError(t, a.Sample(ctx))
+	require.NoError(t, a.Sample(ctx))
+	assert.Empty(t, client.calls)
+	assert.Equal(t, 2, a.state.Thresholds)
+	assert.NoFileExists(t, stateFile)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(a.metrics.actions.WithLabelValues(actionLimit, resultDryRun)))
+}
+
+func TestSince(t *testing.T) {
+	last := sample{
+		Current:    counters{Uploaded: 10, Downloaded: 20, SecondsActive: 100},
+		Cumulative: counters{Uploaded: 110, Downloaded: 220, SecondsActive: 1000},
+	}
+
+	for name, test := range map[string]struct {
+		sample    sample
+		used      usage
+		restarted bool
+	}{
+		"same session": {
+			sample: sample{
+				Current:    counters{Uploaded: 15, Downloaded: 22, SecondsActive: 160},
+				Cumulative: counters{Uploaded: 115, Downloaded: 222, SecondsActive: 1060},
+			},
+			used: usage{Uploaded: 5, Downloaded: 2},
+		},
+		"restart": {
+			sample: sample{
+				Current:    counters{Uploaded: 3, Downloaded: 4, SecondsActive: 10},
+				Cumulative: counters{Uploaded: 120, Downloaded: 229, SecondsActive: 1100},
+			},
+			used:      usage{Uploaded: 3 + 7, Downloaded: 4 + 5},
+			restarted: true,
+		},
+		"restart after a longer session than last's": {
+			sample: sample{
+				Current:    counters{Uploaded: 30, Downloaded: 40, SecondsActive: 500},
+				Cumulative: counters{Uploaded: 145, Downloaded: 260, SecondsActive: 1500},
+			},
+			used:      usage{Uploaded: 30 + 5, Downloaded: 40},
+			restarted: true,
+		},
+		"config wiped": {
+			sample: sample{
+				Current:    counters{Uploaded: 3, Downloaded: 4, SecondsActive: 10},
+				Cumulative: counters{Uploaded: 3, Downloaded: 4, SecondsActive: 10},
+			},
+			used:      usage{Uploaded: 3, Downloaded: 4},
+			restarted: true,
+		},
+	} {
+		used, restarted := test.sample.since(last)
+		assert.Equal(t, test.used, used, name)
+		assert.Equal(t, test.restarted, restarted, name)
+	}
+}
+
+func TestPeriodStart(t *testing.T) {
+	assert.Equal(t, time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC), periodStart(time.Date(2024, 6, 14, 23, 59, 0, 0, time.UTC), 15))
+	assert.Equal(t, time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC), periodStart(time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC), 15))
+	assert.Equal(t, time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC), periodStart(time.Date(2023, 12, 31, 12, 0, 0, 0, time.UTC), 1))
+}
+
+func TestConfig(t *testing.T) {
+	for config, expected := range map[string]string{
+		`{"stateFile": "s"}`: "allowance is required",
+		`{"allowance": "1GB", "stateFile": "s", "count": "both"}`: "count must be",
+		`{"allowance": "1GB", "stateFile": "s", "resetDay": 31}`:  "resetDay must be between 1 and 28",
+		`{"allowance": "1GB"}`: "stateFile is required",
+		`{"allowance": "1GB", "stateFile": "s", "thresholds": [{"percent": 50}]}`:                                                      "altSpeed or speedLimitUp is required",
+		`{"allowance": "1GB", "stateFile": "s", "thresholds": [{"percent": 50, "altSpeed": true}, {"percent": 50, "altSpeed": true}]}`: "percents must increase",
+	} {
+		var c Config
+		require.NoError(t, json.Unmarshal([]byte(config), &c))
+		assert.ErrorContains(t, c.validate(), expected, config)
+	}
+}
@@ -0,0 +1,95 @@
+package datacap
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	resultSuccess = "success"
+	resultError   = "error"
+	resultDryRun  = "dry_run"
+
+	actionLimit   = "limit"
+	actionRestore = "restore"
+)
+
+type metrics struct {
+	used        *prometheus.GaugeVec
+	allowance   prometheus.Gauge
+	remaining   prometheus.Gauge
+	periodStart prometheus.Gauge
+	thresholds  prometheus.Gauge
+	actions     *prometheus.CounterVec
+	restarts    prometheus.Counter
+	samples     *prometheus.CounterVec
+	lastSample  prometheus.Gauge
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		used: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "transmission_datacap_used_bytes",
+			Help: "Bytes uploaded or downloaded in the current period, by direction.",
+		}, []string{"direction"}),
+		allowance: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "transmission_datacap_allowance_bytes",
+			Help: "Bytes allowed in each period.",
+		}),
+		remaining: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "transmission_datacap_remaining_bytes",
+			Help: "Bytes left of the current period's allowance.",
+		}),
+		periodStart: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "transmission_datacap_period_start_timestamp_seconds",
+			Help: "Unix time the current period started.",
+		}),
+		thresholds: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "transmission_datacap_thresholds_reached",
+			Help: "Number of thresholds reached in the current period.",
+		}),
+		actions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transmission_datacap_actions_total",
+			Help: "Total number of times limits were applied or the previous settings restored, by action and result.",
+		}, []string{"action", "result"}),
+		restarts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "transmission_datacap_daemon_restarts_total",
+			Help: "Total number of Transmission restarts or stats resets seen between samples.",
+		}),
+		samples: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transmission_datacap_samples_total",
+			Help: "Total number of session stats samples, by result.",
+		}, []string{"result"}),
+		lastSample: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "transmission_datacap_last_sample_timestamp_seconds",
+			Help: "Unix time of the last successful session stats sample.",
+		}),
+	}
+	for _, direction := range []string{countUpload, countDownload} {
+		m.used.WithLabelValues(direction)
+	}
+	for _, action := range []string{actionLimit, actionRestore} {
+		for _, result := range []string{resultSuccess, resultError, resultDryRun} {
+			m.actions.WithLabelValues(action, result)
+		}
+	}
+	for _, result := range []string{resultSuccess, resultError} {
+		m.samples.WithLabelValues(result)
+	}
+	return m
+}
+
+func (m *metrics) register(reg prometheus.Registerer) error {
+	return errors.Join(
+		reg.Register(m.used),
+		reg.Register(m.allowance),
+		reg.Register(m.remaining),
+		reg.Register(m.periodStart),
+		reg.Register(m.thresholds),
+		reg.Register(m.actions),
+		reg.Register(m.restarts),
+		reg.Register(m.samples),
+		reg.Register(m.lastSample),
+	)
+}
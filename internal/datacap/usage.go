@@ -0,0 +1,102 @@
+package datacap
+
+import (
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+)
+
+// sample is a reading of the session stats.
+type sample struct {
+	Current    counters `json:"current"`
+	Cumulative counters `json:"cumulative"`
+}
+
+type counters struct {
+	Uploaded      int64 `json:"uploaded"`
+	Downloaded    int64 `json:"downloaded"`
+	SecondsActive int64 `json:"secondsActive"`
+}
+
+func newSample(stats *transmission.SessionStatsResult) sample {
+	read := func(s transmission.Stats) counters {
+		return counters{
+			Uploaded:      int64(s.UploadedBytes),
+			Downloaded:    int64(s.DownloadedBytes),
+			SecondsActive: int64(s.SecondsActive),
+		}
+	}
+	return sample{Current: read(stats.CurrentStats), Cumulative: read(stats.CumulativeStats)}
+}
+
+// usage is the data used in a period.
+type usage struct {
+	Uploaded   int64 `json:"uploaded"`
+	Downloaded int64 `json:"downloaded"`
+}
+
+// since returns the data used between last and s, and whether the daemon
+// restarted in between.
+//
+// Within a daemon session the current stats only grow, and the cumulative
+// stats are the previous sessions' total plus the current stats, so that the
+// difference between the two doesn't change. When the daemon restarts the
+// current stats start again from zero: what was used since then is the
+// current stats, and what was used between last and the restart is how far
+// the cumulative stats had got at the restart past last's. The cumulative
+// stats are only saved from time to time and are lost with the daemon's
+// config, so if they went backwards that part is unknown and left out.
+func (s sample) since(last sample) (usage, bool) {
+	atStart, lastAtStart := s.atSessionStart(), last.atSessionStart()
+	if atStart == lastAtStart &&
+		s.Current.SecondsActive >= last.Current.SecondsActive &&
+		s.Current.Uploaded >= last.Current.Uploaded &&
+		s.Current.Downloaded >= last.Current.Downloaded {
+		return usage{
+			Uploaded:   s.Current.Uploaded - last.Current.Uploaded,
+			Downloaded: s.Current.Downloaded - last.Current.Downloaded,
+		}, false
+	}
+
+	u := usage{Uploaded: s.Current.Uploaded, Downloaded: s.Current.Downloaded}
+	if atStart.Uploaded >= last.Cumulative.Uploaded && atStart.Downloaded >= last.Cumulative.Downloaded {
+		u.Uploaded += atStart.Uploaded - last.Cumulative.Uploaded
+		u.Downloaded += atStart.Downloaded - last.Cumulative.Downloaded
+	}
+	return u, true
+}
+
+// atSessionStart returns the cumulative stats when the daemon session
+// started.
+func (s sample) atSessionStart() usage {
+	return usage{
+		Uploaded:   s.Cumulative.Uploaded - s.Current.Uploaded,
+		Downloaded: s.Cumulative.Downloaded - s.Current.Downloaded,
+	}
+}
+
+func (u usage) add(other usage) usage {
+	return usage{Uploaded: u.Uploaded + other.Uploaded, Downloaded: u.Downloaded + other.Downloaded}
+}
+
+// counted returns the usage that counts towards the allowance.
+func (u usage) counted(count string) int64 {
+	switch count {
+	case countDownload:
+		return u.Downloaded
+	case countTotal:
+		return u.Uploaded + u.Downloaded
+	default:
+		return u.Uploaded
+	}
+}
+
+// periodStart returns the start of the period t is in, for periods starting
+// on resetDay of each month in t's location.
+func periodStart(t time.Time, resetDay int) time.Time {
+	start := time.Date(t.Year(), t.Month(), resetDay, 0, 0, 0, 0, t.Location())
+	if t.Before(start) {
+		start = start.AddDate(0, -1, 0)
+	}
+	return start
+}
@@ -0,0 +1,65 @@
+package diskguard
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/internal/daemon"
+)
+
+const defaultInterval = time.Minute
+
+// Config is the disk guard's config file.
+type Config struct {
+	// Interval is how often free space is checked. Defaults to 1m.
+	Interval daemon.Duration `json:"interval"`
+	// MinFree is the free space to keep on each filesystem once every
+	// downloading torrent has finished.
+	MinFree daemon.Size `json:"minFree"`
+	// ResumeFree is the free space to keep when resuming torrents the guard
+	// stopped. Setting it above MinFree stops torrents flapping between
+	// stopped and downloading. Defaults to MinFree.
+	ResumeFree daemon.Size `json:"resumeFree"`
+	// Filesystems are the mount points of the filesystems holding the
+	// download directories, such as /data. Directories under the same mount
+	// point share its free space. A directory under none of them is taken to
+	// be a filesystem of its own, so unlisted directories sharing a
+	// filesystem are each budgeted all of its free space.
+	Filesystems []string `json:"filesystems"`
+	// StateFile, if set, is where the guard remembers the torrents it
+	// stopped, so that they are still resumed after a restart.
+	StateFile string `json:"stateFile"`
+}
+
+// LoadConfig reads and validates the config file at path.
+func LoadConfig(path string) (*Config, error) {
+	var config Config
+	if err := daemon.LoadConfig(path, &config); err != nil {
+		return nil, err
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+func (c *Config) validate() error {
+	if c.MinFree <= 0 {
+		return errors.New("minFree is required")
+	}
+	if c.ResumeFree == 0 {
+		c.ResumeFree = c.MinFree
+	}
+	if c.ResumeFree < c.MinFree {
+		return errors.New("resumeFree must be at least minFree")
+	}
+	for i, mount := range c.Filesystems {
+		if !path.IsAbs(mount) {
+			return fmt.Errorf("filesystem %q must be an absolute path", mount)
+		}
+		c.Filesystems[i] = path.Clean(mount)
+	}
+	return nil
+}
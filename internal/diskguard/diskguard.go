@@ -0,0 +1,410 @@
+// Package diskguard stops downloads before they fill the disk, which
+// Transmission would happily do, corrupting incomplete data.
+//
+// Every interval the guard compares the free space on each filesystem
+// holding a download or incomplete directory with what the torrents
+// downloading onto it still need. Torrents are given space in priority
+// order, and those that don't fit while keeping the configured free space
+// are stopped. Once there is room again, they are resumed.
+package diskguard
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/internal/daemon"
+	"github.com/j-dumbell/go-qbittorrent/internal/humanize"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fields = []string{
+	"id", "hashString", "name", "status", "downloadDir", "leftUntilDone",
+	"queuePosition", "bandwidthPriority", "sizeWhenDone",
+}
+
+// Client is the subset of *transmission.Client used by the guard.
+type Client interface {
+	SessionGet(ctx context.Context) (*transmission.Session, error)
+	TorrentGet(ctx context.Context, args transmission.TorrentGetArgs) (*transmission.TorrentGetResult, error)
+	FreeSpace(ctx context.Context, args transmission.FreeSpaceArgs) (*transmission.FreeSpaceResult, error)
+	TorrentStart(ctx context.Context, ids *transmission.TorrentIDs) error
+	TorrentStop(ctx context.Context, ids *transmission.TorrentIDs) error
+}
+
+// Options are the guard's optional settings.
+type Options struct {
+	Logger *slog.Logger
+	// Registerer, if set, registers the guard's metrics.
+	Registerer prometheus.Registerer
+	// DryRun logs the torrents that would be stopped or resumed without
+	// stopping or resuming them.
+	DryRun bool
+}
+
+// Guard stops and resumes downloads according to free space.
+type Guard struct {
+	client      Client
+	interval    time.Duration
+	filesystems []string
+	minFree     int64
+	resumeFree  int64
+	stateFile   string
+	logger      *slog.Logger
+	metrics     *metrics
+	dryRun      bool
+	now         func() time.Time
+
+	// stopped holds the hashes of the torrents the guard stopped, which are
+	// the only ones it resumes.
+	stopped map[string]bool
+	// wouldStop holds, in dry run mode, the hashes of the torrents already
+	// logged as due to be stopped, so that they aren't logged on every check.
+	wouldStop map[string]bool
+}
+
+// state is the state file's content.
+type state struct {
+	Stopped []string `json:"stopped"`
+}
+
+// New returns a guard for config, which must have been validated by
+// LoadConfig. The torrents stopped by a previous run are read from the state
+// file, if any.
+func New(client Client, config Config, opts Options) (*Guard, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	m := newMetrics()
+	if opts.Registerer != nil {
+		if err := m.register(opts.Registerer); err != nil {
+			return nil, fmt.Errorf("error registering metrics: %w", err)
+		}
+	}
+
+	g := &Guard{
+		client:      client,
+		interval:    config.Interval.Or(defaultInterval),
+		filesystems: config.Filesystems,
+		minFree:     int64(config.MinFree),
+		resumeFree:  int64(config.ResumeFree),
+		stateFile:   config.StateFile,
+		logger:      logger,
+		metrics:     m,
+		dryRun:      opts.DryRun,
+		now:         time.Now,
+		stopped:     map[string]bool{},
+		wouldStop:   map[string]bool{},
+	}
+	if g.stateFile != "" {
+		var s state
+		if err := daemon.LoadState(g.stateFile, &s); err != nil {
+			return nil, err
+		}
+		for _, hash := range s.Stopped {
+			g.stopped[hash] = true
+		}
+	}
+	return g, nil
+}
+
+// Run checks free space every interval until ctx is done.
+func (g *Guard) Run(ctx context.Context) {
+	g.logger.Info("starting disk guard",
+		"minFree", humanize.Bytes(g.minFree),
+		"resumeFree", humanize.Bytes(g.resumeFree),
+		"interval", g.interval,
+		"dryRun", g.dryRun,
+	)
+	daemon.Every(ctx, g.interval, func(ctx context.Context) {
+		if err := g.Check(ctx); err != nil {
+			g.logger.Error("error checking free space", "err", err)
+		}
+	})
+}
+
+// Check compares the free space on each filesystem with what its torrents
+// need, stopping and resuming torrents as needed. Torrents on a filesystem
+// whose free space can't be read are left alone.
+func (g *Guard) Check(ctx context.Context) error {
+	err := g.check(ctx)
+	if err != nil {
+		g.metrics.checks.WithLabelValues(resultError).Inc()
+	} else {
+		g.metrics.checks.WithLabelValues(resultSuccess).Inc()
+		g.metrics.lastCheck.Set(float64(g.now().Unix()))
+	}
+	return err
+}
+
+func (g *Guard) check(ctx context.Context) error {
+	session, err := g.client.SessionGet(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting session: %w", err)
+	}
+	result, err := g.client.TorrentGet(ctx, transmission.TorrentGetArgs{IDs: transmission.AllTorrents, Fields: fields})
+	if err != nil {
+		return fmt.Errorf("error getting torrents: %w", err)
+	}
+
+	stoppedBefore := maps.Clone(g.stopped)
+	torrents := g.active(result.Torrents)
+	dirs := []string{session.DownloadDir}
+	if session.IncompleteDirEnabled && session.IncompleteDir != "" {
+		dirs = append(dirs, session.IncompleteDir)
+	}
+	for _, t := range torrents {
+		dirs = append(dirs, dataDir(session, t), downloadDir(session, t))
+	}
+	slices.Sort(dirs)
+
+	// Directories on the same filesystem should report the same free space,
+	// but it may change between reads, so the smallest is used.
+	free := map[string]int64{}
+	var errs []error
+	for _, dir := range slices.Compact(dirs) {
+		freeSpace, err := g.client.FreeSpace(ctx, transmission.FreeSpaceArgs{Path: dir})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error getting free space in %s: %w", dir, err))
+			continue
+		}
+		fs := g.filesystem(dir)
+		if known, exists := free[fs]; !exists || int64(freeSpace.SizeBytes) < known {
+			free[fs] = int64(freeSpace.SizeBytes)
+		}
+	}
+	g.balance(ctx, session, free, torrents)
+
+	if g.stateFile != "" && !g.dryRun && !maps.Equal(g.stopped, stoppedBefore) {
+		if err := daemon.SaveState(g.stateFile, state{Stopped: slices.Sorted(maps.Keys(g.stopped))}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// active returns the torrents downloading, or stopped by the guard. Torrents
+// the guard stopped that were started by someone else, finished or removed
+// are forgotten.
+func (g *Guard) active(torrents []transmission.Torrent) []transmission.Torrent {
+	present := make(map[string]bool, len(torrents))
+	var result []transmission.Torrent
+	for _, t := range torrents {
+		present[t.HashString] = true
+		if g.stopped[t.HashString] && (t.Status != transmission.TorrentStatusStopped || t.LeftUntilDone == 0) {
+			g.logger.Info("forgetting torrent stopped for lack of space", "id", t.ID, "name", t.Name, "status", t.Status)
+			delete(g.stopped, t.HashString)
+		}
+		downloading := t.Status == transmission.TorrentStatusDownload || t.Status == transmission.TorrentStatusDownloadWait
+		if !g.stopped[t.HashString] && (!downloading || t.LeftUntilDone == 0) {
+			delete(g.wouldStop, t.HashString)
+			continue
+		}
+		result = append(result, t)
+	}
+	for hash := range g.stopped {
+		if !present[hash] {
+			delete(g.stopped, hash)
+		}
+	}
+	return result
+}
+
+// dataDir returns the directory torrent's incomplete data is written to.
+func dataDir(session *transmission.Session, torrent transmission.Torrent) string {
+	if session.IncompleteDirEnabled && session.IncompleteDir != "" {
+		return session.IncompleteDir
+	}
+	return downloadDir(session, torrent)
+}
+
+// downloadDir returns the directory torrent's data ends up in once it's
+// complete.
+func downloadDir(session *transmission.Session, torrent transmission.Torrent) string {
+	if torrent.DownloadDir != "" {
+		return torrent.DownloadDir
+	}
+	return session.DownloadDir
+}
+
+// filesystem returns the configured mount point dir is under, or dir itself
+// if it isn't under any.
+func (g *Guard) filesystem(dir string) string {
+	result := ""
+	for _, mount := range g.filesystems {
+		if (dir == mount || strings.HasPrefix(dir, strings.TrimSuffix(mount, "/")+"/")) && len(mount) > len(result) {
+			result = mount
+		}
+	}
+	if result == "" {
+		return dir
+	}
+	return result
+}
+
+// needs returns the space torrent still needs on each filesystem. With an
+// incomplete directory, a complete torrent's data is moved to its download
+// directory, which needs room for all of it unless it's on the same
+// filesystem.
+func (g *Guard) needs(session *transmission.Session, torrent transmission.Torrent) map[string]int64 {
+	data := g.filesystem(dataDir(session, torrent))
+	needs := map[string]int64{data: torrent.LeftUntilDone}
+	if final := g.filesystem(downloadDir(session, torrent)); final != data {
+		needs[final] = torrent.SizeWhenDone
+	}
+	return needs
+}
+
+// byPriority orders torrents from the highest priority to the lowest: by
+// bandwidth priority, then by queue position.
+func byPriority(a, b transmission.Torrent) int {
+	return cmp.Or(
+		cmp.Compare(b.BandwidthPriority, a.BandwidthPriority),
+		cmp.Compare(a.QueuePosition, b.QueuePosition),
+	)
+}
+
+// balance gives the free space on each filesystem to torrents in priority
+// order, stopping the downloading torrents that don't fit on every filesystem
+// they need while keeping minFree and resuming the stopped ones that fit
+// while keeping resumeFree.
+func (g *Guard) balance(ctx context.Context, session *transmission.Session, free map[string]int64, torrents []transmission.Torrent) {
+	slices.SortStableFunc(torrents, byPriority)
+
+	allocated := map[string]int64{}
+	needed := map[string]int64{}
+	stopped := map[string]int{}
+	for _, t := range torrents {
+		needs := g.needs(session, t)
+		if !knownFree(free, needs) {
+			continue
+		}
+		fits := func(keep int64) bool {
+			for fs, n := range needs {
+				if allocated[fs]+n > free[fs]-keep {
+					return false
+				}
+			}
+			return true
+		}
+		for fs, n := range needs {
+			needed[fs] += n
+		}
+		allocate := func() {
+			for fs, n := range needs {
+				allocated[fs] += n
+			}
+		}
+		markStopped := func() {
+			for fs := range needs {
+				stopped[fs]++
+			}
+		}
+
+		dir := dataDir(session, t)
+		logger := g.logger.With(
+			"id", t.ID,
+			"name", t.Name,
+			"hash", t.HashString,
+			"dir", dir,
+			"free", humanize.Bytes(free[g.filesystem(dir)]),
+			"left", humanize.Bytes(t.LeftUntilDone),
+		)
+
+		switch {
+		case g.stopped[t.HashString] && fits(g.resumeFree):
+			if g.resume(ctx, logger, t) {
+				allocate()
+			} else {
+				markStopped()
+			}
+		case g.stopped[t.HashString]:
+			markStopped()
+		case fits(g.minFree):
+			allocate()
+			if g.wouldStop[t.HashString] {
+				logger.Info("torrent fits in free space again, not stopping it in dry run")
+				delete(g.wouldStop, t.HashString)
+			}
+		default:
+			if g.stop(ctx, logger, t) {
+				markStopped()
+			} else {
+				allocate()
+			}
+		}
+	}
+
+	g.metrics.free.Reset()
+	g.metrics.needed.Reset()
+	g.metrics.stopped.Reset()
+	for fs, f := range free {
+		g.metrics.free.WithLabelValues(fs).Set(float64(f))
+		g.metrics.needed.WithLabelValues(fs).Set(float64(needed[fs]))
+		g.metrics.stopped.WithLabelValues(fs).Set(float64(stopped[fs]))
+	}
+}
+
+// knownFree reports whether the free space on every filesystem in needs is
+// known.
+func knownFree(free map[string]int64, needs map[string]int64) bool {
+	for fs := range needs {
+		if _, exists := free[fs]; !exists {
+			return false
+		}
+	}
+	return true
+}
+
+// stop stops torrent for lack of space, returning whether it no longer uses
+// any. In dry run mode it's only logged, once, but counted as stopped so that
+// lower priority torrents are treated as they would be.
+func (g *Guard) stop(ctx context.Context, logger *slog.Logger, torrent transmission.Torrent) bool {
+	if g.dryRun {
+		if !g.wouldStop[torrent.HashString] {
+			logger.Warn("not enough free space for torrent, not stopping it in dry run")
+			g.metrics.actions.WithLabelValues(actionStop, resultDryRun).Inc()
+			g.wouldStop[torrent.HashString] = true
+		}
+		return true
+	}
+
+	if err := g.client.TorrentStop(ctx, transmission.NewTorrentIDs(torrent.ID)); err != nil {
+		logger.Error("error stopping torrent for lack of space", "err", err)
+		g.metrics.actions.WithLabelValues(actionStop, resultError).Inc()
+		return false
+	}
+	logger.Warn("stopped torrent for lack of space")
+	g.metrics.actions.WithLabelValues(actionStop, resultSuccess).Inc()
+	g.stopped[torrent.HashString] = true
+	return true
+}
+
+// resume starts a torrent the guard stopped, returning whether it was
+// started. The guard doesn't stop anything in dry run mode, but torrents
+// stopped by an earlier run may still be in the state file.
+func (g *Guard) resume(ctx context.Context, logger *slog.Logger, torrent transmission.Torrent) bool {
+	if g.dryRun {
+		logger.Info("enough free space for torrent stopped for lack of space, not resuming it in dry run")
+		g.metrics.actions.WithLabelValues(actionResume, resultDryRun).Inc()
+		return true
+	}
+
+	if err := g.client.TorrentStart(ctx, transmission.NewTorrentIDs(torrent.ID)); err != nil {
+		logger.Error("error resuming torrent stopped for lack of space", "err", err)
+		g.metrics.actions.WithLabelValues(actionResume, resultError).Inc()
+		return false
+	}
+	logger.Info("resumed torrent stopped for lack of space")
+	g.metrics.actions.WithLabelValues(actionResume, resultSuccess).Inc()
+	delete(g.stopped, torrent.HashString)
+	return true
+}
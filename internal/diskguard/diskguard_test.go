@@ -0,0 +1,252 @@
+package diskguard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gb = 1_000_000_000
+
+type fakeClient struct {
+	session  transmission.Session
+	torrents []transmission.Torrent
+	free     map[string]int64
+	calls    []string
+}
+
+func (f *fakeClient) SessionGet(context.Context) (*transmission.Session, error) {
+	return &f.session, nil
+}
+
+func (f *fakeClient) TorrentGet(context.Context, transmission.TorrentGetArgs) (*transmission.TorrentGetResult, error) {
+	return &transmission.TorrentGetResult{Torrents: append([]transmission.Torrent(nil), f.torrents...)}, nil
+}
+
+func (f *fakeClient) FreeSpace(_ context.Context, args transmission.FreeSpaceArgs) (*transmission.FreeSpaceResult, error) {
+	free, ok := f.free[args.Path]
+	if !ok {
+		return nil, errors.New("no such directory")
+	}
+	return &transmission.FreeSpaceResult{Path: args.Path, SizeBytes: int(free)}, nil
+}
+
+func (f *fakeClient) setStatus(call string, ids *transmission.TorrentIDs, status transmission.TorrentStatus) {
+	data, _ := json.Marshal(ids)
+	f.calls = append(f.calls, call+" "+string(data))
+	var list []int64
+	_ = json.Unmarshal(data, &list)
+	for i := range f.torrents {
+		for _, id := range list {
+			if f.torrents[i].ID == id {
+				f.torrents[i].Status = status
+			}
+		}
+	}
+}
+
+func (f *fakeClient) TorrentStart(_ context.Context, ids *transmission.TorrentIDs) error {
+	f.setStatus("start", ids, transmission.TorrentStatusDownload)
+	return nil
+}
+
+func (f *fakeClient) TorrentStop(_ context.Context, ids *transmission.TorrentIDs) error {
+	f.setStatus("stop", ids, transmission.TorrentStatusStopped)
+	return nil
+}
+
+func newTestClient() *fakeClient {
+	downloading := func(id int64, priority transmission.Priority, position, left int64) transmission.Torrent {
+		return transmission.Torrent{
+			ID: id, HashString: string(rune('a' + id)), DownloadDir: "/data", Status: transmission.TorrentStatusDownload,
+			BandwidthPriority: priority, QueuePosition: position, LeftUntilDone: left,
+		}
+	}
+	waiting := downloading(3, transmission.PriorityNormal, 1, 40*gb)
+	waiting.Status = transmission.TorrentStatusDownloadWait
+	seeding := downloading(5, transmission.PriorityHigh, 4, 0)
+	seeding.Status = transmission.TorrentStatusSeed
+	other := downloading(6, transmission.PriorityLow, 5, gb)
+	other.DownloadDir = "/other"
+
+	return &fakeClient{
+		session: transmission.Session{DownloadDir: "/data"},
+		torrents: []transmission.Torrent{
+			downloading(1, transmission.PriorityHigh, 3, 30*gb),
+			downloading(2, transmission.PriorityNormal, 0, 20*gb),
+			waiting,
+			downloading(4, transmission.PriorityLow, 2, 5*gb),
+			seeding,
+			other,
+		},
+		free: map[string]int64{"/data": 80 * gb, "/other": 20 * gb},
+	}
+}
+
+func testConfig(t *testing.T, config string) Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "diskguard.json")
+	require.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+	c, err := LoadConfig(path)
+	require.NoError(t, err)
+	return *c
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient()
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	config := testConfig(t, `{"minFree": "10GB", "resumeFree": "15GB", "stateFile": "`+stateFile+`"}`)
+	guard, err := New(client, config, Options{Registerer: prometheus.NewRegistry()})
+	require.NoError(t, err)
+
+	// 70GB can be used: torrents 1 and 2 fit, 3 doesn't and 4 still does.
+	require.NoError(t, guard.Check(ctx))
+	assert.Equal(t, []string{"stop [3]"}, client.calls)
+	assert.Equal(t, float64(80*gb), testutil.ToFloat64(guard.metrics.free.WithLabelValues("/data")))
+	assert.Equal(t, float64(95*gb), testutil.ToFloat64(guard.metrics.needed.WithLabelValues("/data")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(guard.metrics.stopped.WithLabelValues("/data")))
+
+	// Torrent 3 fits while keeping minFree, but not resumeFree.
+	client.calls = nil
+	client.free["/data"] = 100 * gb
+	require.NoError(t, guard.Check(ctx))
+	assert.Empty(t, client.calls)
+
+	// A restarted guard remembers it stopped torrent 3, and resumes it.
+	client.free["/data"] = 110 * gb
+	guard, err = New(client, config, Options{})
+	require.NoError(t, err)
+	require.NoError(t, guard.Check(ctx))
+	assert.Equal(t, []string{"start [3]"}, client.calls)
+	assert.Empty(t, guard.stopped)
+	data, err := os.ReadFile(stateFile)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"stopped": null}`, string(data))
+}
+
+func TestCheckForgetsTorrents(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient()
+	guard, err := New(client, testConfig(t, `{"minFree": "10GB"}`), Options{})
+	require.NoError(t, err)
+	require.NoError(t, guard.Check(ctx))
+	require.True(t, guard.stopped["d"])
+
+	// Torrent 3 was started by hand, so the guard stops it again rather than
+	// leaving the disk to fill.
+	client.calls = nil
+	client.torrents[2].Status = transmission.TorrentStatusDownload
+	require.NoError(t, guard.Check(ctx))
+	assert.Equal(t, []string{"stop [3]"}, client.calls)
+
+	// Torrent 3 was removed.
+	client.torrents = client.torrents[3:]
+	require.NoError(t, guard.Check(ctx))
+	assert.Empty(t, guard.stopped)
+}
+
+func TestCheckDryRunAndErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("dry run", func(t *testing.T) {
+		client := newTestClient()
+		guard, err := New(client, testConfig(t, `{"minFree": "10GB"}`), Options{DryRun: true})
+		require.NoError(t, err)
+		require.NoError(t, guard.Check(ctx))
+		require.NoError(t, guard.Check(ctx))
+		assert.Empty(t, client.calls)
+
+		assert.Equal(t, 1.0, testutil.ToFloat64(guard.metrics.actions.WithLabelValues(actionStop, resultDryRun)), "torrents due to be stopped are logged once")
+	})
+
+	t.Run("directories without free space are left alone", func(t *testing.T) {
+		client := newTestClient()
+		client.free = map[string]int64{"/other": 0}
+		guard, err := New(client, testConfig(t, `{"minFree": "10GB"}`), Options{})
+		require.NoError(t, err)
+		assert.ErrorContains(t, guard.Check(ctx), "error getting free space in /data")
+		assert.Equal(t, []string{"stop [6]"}, client.calls)
+	})
+}
+
+func TestCheckFilesystems(t *testing.T) {
+	ctx := context.Background()
+	torrent := func(id int64, dir string, left, size int64) transmission.Torrent {
+		return transmission.Torrent{
+			ID: id, HashString: string(rune('a' + id)), DownloadDir: dir, Status: transmission.TorrentStatusDownload,
+			QueuePosition: id, LeftUntilDone: left, SizeWhenDone: size,
+		}
+	}
+	check := func(t *testing.T, client *fakeClient, config string) *Guard {
+		t.Helper()
+		guard, err := New(client, testConfig(t, config), Options{})
+		require.NoError(t, err)
+		require.NoError(t, guard.Check(ctx))
+		return guard
+	}
+
+	t.Run("directories on one filesystem share its free space", func(t *testing.T) {
+		newClient := func() *fakeClient {
+			return &fakeClient{
+				session:  transmission.Session{DownloadDir: "/mnt/a"},
+				torrents: []transmission.Torrent{torrent(1, "/mnt/a", 20*gb, 20*gb), torrent(2, "/mnt/b/tv", 20*gb, 20*gb)},
+				free:     map[string]int64{"/mnt/a": 30 * gb, "/mnt/b/tv": 30 * gb},
+			}
+		}
+
+		client := newClient()
+		check(t, client, `{"minFree": "5GB"}`)
+		assert.Empty(t, client.calls, "unlisted directories are budgeted separately")
+
+		client = newClient()
+		guard := check(t, client, `{"minFree": "5GB", "filesystems": ["/mnt/"]}`)
+		assert.Equal(t, []string{"stop [2]"}, client.calls)
+		assert.Equal(t, float64(30*gb), testutil.ToFloat64(guard.metrics.free.WithLabelValues("/mnt")))
+		assert.Equal(t, float64(40*gb), testutil.ToFloat64(guard.metrics.needed.WithLabelValues("/mnt")))
+	})
+
+	t.Run("completed torrents are moved out of the incomplete directory", func(t *testing.T) {
+		newClient := func() *fakeClient {
+			return &fakeClient{
+				session:  transmission.Session{DownloadDir: "/data", IncompleteDirEnabled: true, IncompleteDir: "/scratch"},
+				torrents: []transmission.Torrent{torrent(1, "/data", 5*gb, 50*gb)},
+				free:     map[string]int64{"/data": 30 * gb, "/scratch": 100 * gb},
+			}
+		}
+
+		client := newClient()
+		guard := check(t, client, `{"minFree": "10GB"}`)
+		assert.Equal(t, []string{"stop [1]"}, client.calls, "the whole torrent must fit in its download directory")
+		assert.Equal(t, float64(50*gb), testutil.ToFloat64(guard.metrics.needed.WithLabelValues("/data")))
+		assert.Equal(t, float64(5*gb), testutil.ToFloat64(guard.metrics.needed.WithLabelValues("/scratch")))
+
+		client = newClient()
+		check(t, client, `{"minFree": "10GB", "filesystems": ["/"]}`)
+		assert.Empty(t, client.calls, "moving within a filesystem needs no space")
+	})
+}
+
+func TestConfig(t *testing.T) {
+	config := testConfig(t, `{"minFree": 1024}`)
+	assert.EqualValues(t, 1024, config.ResumeFree, "resumeFree defaults to minFree")
+
+	for config, expected := range map[string]string{
+		`{}`: "minFree is required",
+		`{"minFree": "10GB", "resumeFree": "5GB"}`:     "resumeFree must be at least minFree",
+		`{"minFree": "10GB", "filesystems": ["data"]}`: `filesystem "data" must be an absolute path`,
+	} {
+		var c Config
+		require.NoError(t, json.Unmarshal([]byte(config), &c))
+		assert.ErrorContains(t, c.validate(), expected, config)
+	}
+}
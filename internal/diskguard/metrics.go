@@ -0,0 +1,74 @@
+package diskguard
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	resultSuccess = "success"
+	resultError   = "error"
+	resultDryRun  = "dry_run"
+
+	actionStop   = "stop"
+	actionResume = "resume"
+)
+
+type metrics struct {
+	free      *prometheus.GaugeVec
+	needed    *prometheus.GaugeVec
+	stopped   *prometheus.GaugeVec
+	actions   *prometheus.CounterVec
+	checks    *prometheus.CounterVec
+	lastCheck prometheus.Gauge
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		free: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "transmission_diskguard_free_bytes",
+			Help: "Free space on each filesystem holding a download or incomplete directory.",
+		}, []string{"filesystem"}),
+		needed: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "transmission_diskguard_needed_bytes",
+			Help: "Bytes still needed on each filesystem by the torrents downloading, or stopped by the guard.",
+		}, []string{"filesystem"}),
+		stopped: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "transmission_diskguard_stopped_torrents",
+			Help: "Number of torrents needing space on each filesystem stopped by the guard for lack of space.",
+		}, []string{"filesystem"}),
+		actions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transmission_diskguard_actions_total",
+			Help: "Total number of torrents stopped or resumed by the guard, by action and result.",
+		}, []string{"action", "result"}),
+		checks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transmission_diskguard_checks_total",
+			Help: "Total number of free space checks, by result.",
+		}, []string{"result"}),
+		lastCheck: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "transmission_diskguard_last_check_timestamp_seconds",
+			Help: "Unix time of the last successful free space check.",
+		}),
+	}
+	for _, action := range []string{actionStop, actionResume} {
+		for _, result := range []string{resultSuccess, resultError, resultDryRun} {
+			m.actions.WithLabelValues(action, result)
+		}
+	}
+	for _, result := range []string{resultSuccess, resultError} {
+		m.checks.WithLabelValues(result)
+	}
+	return m
+}
+
+func (m *metrics) register(reg prometheus.Registerer) error {
+	return errors.Join(
+		reg.Register(m.free),
+		reg.Register(m.needed),
+		reg.Register(m.stopped),
+		reg.Register(m.actions),
+		reg.Register(m.checks),
+		reg.Register(m.lastCheck),
+	)
+}
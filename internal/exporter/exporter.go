@@ -3,6 +3,7 @@ package exporter
 import (
 	"context"
 	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
@@ -104,6 +105,8 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 		prometheus.GaugeValue,
 		1,
 		session.Version.Sem(),
+		session.Version.Hash(),
+		strconv.Itoa(session.RPCVersion),
 	)
 
 	torrentGetResult, err := e.transmissionClient.TorrentGet(ctx, transmission.TorrentGetArgs{
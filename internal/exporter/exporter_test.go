@@ -127,7 +127,7 @@ func assertGlobalMetrics(t *testing.T, mfs []*promclient.MetricFamily) {
 		{Labels: map[string]string{statusLabel: "unknown"}, Value: 0},
 	})
 	assertMetricValueWithLabels(t, mfs, metricNameVersion, prometheus.GaugeValue, []MetricValue{
-		{Labels: map[string]string{versionLabel: mockSession.Version.Sem()}, Value: 1},
+		{Labels: map[string]string{versionLabel: "4.0.5", commitLabel: "a6fe2a64aa", rpcVersionLabel: "17"}, Value: 1},
 	})
 }
 
@@ -243,7 +243,13 @@ var mockSessionStatsResult = transmission.SessionStatsResult{
 }
 
 var mockSession = transmission.Session{
-	Version: "4.0.0",
+	RPCVersion: 17,
+	Version: transmission.Version{
+		Major:     4,
+		Minor:     0,
+		Patch:     5,
+		BuildHash: "a6fe2a64aa",
+	},
 }
 
 var t1 = transmission.Torrent{
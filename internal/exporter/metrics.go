@@ -3,10 +3,12 @@ package exporter
 import "github.com/prometheus/client_golang/prometheus"
 
 const (
-	hashLabel    = "hash"
-	nameLabel    = "name"
-	statusLabel  = "status"
-	versionLabel = "version"
+	hashLabel       = "hash"
+	nameLabel       = "name"
+	statusLabel     = "status"
+	versionLabel    = "version"
+	commitLabel     = "commit"
+	rpcVersionLabel = "rpc_version"
 )
 
 type metricName string
@@ -84,8 +86,8 @@ var globalDescConfigs = []descConfig{
 	},
 	{
 		Metric:         metricNameVersion,
-		Help:           "Transmission version information. Always has value 1. Use this metric to identify the Transmission version using the version label.",
-		VariableLabels: []string{versionLabel},
+		Help:           "Transmission version information. Always has value 1. Use this metric to identify the Transmission version, build commit and RPC version using the version, commit and rpc_version labels.",
+		VariableLabels: []string{versionLabel, commitLabel, rpcVersionLabel},
 	},
 }
 
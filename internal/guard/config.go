@@ -0,0 +1,139 @@
+package guard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/internal/daemon"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+)
+
+const defaultInterval = time.Minute
+
+// anyTracker is the Rule.Tracker that matches every private torrent.
+const anyTracker = "*"
+
+// Config is the guard's config file.
+type Config struct {
+	// Interval is how often torrents are checked for early stops. Defaults
+	// to 1m.
+	Interval daemon.Duration `json:"interval"`
+	Rules    []Rule          `json:"rules"`
+}
+
+// Rule is a private tracker's seeding requirement. A torrent follows the
+// first rule matching one of its trackers.
+type Rule struct {
+	// Tracker is the tracker's host name, which also matches its
+	// subdomains, or "*" for any tracker.
+	Tracker string `json:"tracker"`
+
+	MinSeedTime daemon.Duration `json:"minSeedTime"`
+	MinRatio    float64         `json:"minRatio"`
+	// RequireBoth, if true, means both the seed time and the ratio must be
+	// reached. By default reaching either meets the obligation.
+	RequireBoth bool `json:"requireBoth"`
+}
+
+// LoadConfig reads and validates the config file at path.
+func LoadConfig(path string) (*Config, error) {
+	var config Config
+	if err := daemon.LoadConfig(path, &config); err != nil {
+		return nil, err
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+func (c *Config) validate() error {
+	if len(c.Rules) == 0 {
+		return errors.New("no rules")
+	}
+	for i := range c.Rules {
+		r := &c.Rules[i]
+		r.Tracker = strings.ToLower(r.Tracker)
+		switch {
+		case r.Tracker == "":
+			return fmt.Errorf("rules[%d]: tracker is required", i)
+		case r.MinSeedTime <= 0 && r.MinRatio <= 0:
+			return fmt.Errorf("rules[%d]: minSeedTime or minRatio is required", i)
+		case r.RequireBoth && (r.MinSeedTime <= 0 || r.MinRatio <= 0):
+			return fmt.Errorf("rules[%d]: requireBoth needs both minSeedTime and minRatio", i)
+		}
+	}
+	return nil
+}
+
+func (r Rule) matches(host string) bool {
+	return r.Tracker == anyTracker || host == r.Tracker || strings.HasSuffix(host, "."+r.Tracker)
+}
+
+// Obligation is a torrent's seeding obligation under a rule.
+type Obligation struct {
+	ID      int64
+	Hash    string
+	Name    string
+	Tracker string
+
+	// SeedTimeRemaining and RatioRemaining are how far the torrent is from
+	// the rule's requirements, or zero for requirements that are reached or
+	// not set.
+	SeedTimeRemaining time.Duration
+	RatioRemaining    float64
+	Met               bool
+
+	requireBoth bool
+}
+
+func (o Obligation) String() string {
+	var remaining []string
+	if o.SeedTimeRemaining > 0 {
+		remaining = append(remaining, fmt.Sprintf("%s more seeding", o.SeedTimeRemaining))
+	}
+	if o.RatioRemaining > 0 {
+		remaining = append(remaining, fmt.Sprintf("%.2f more ratio", o.RatioRemaining))
+	}
+	separator := " or "
+	if o.requireBoth {
+		separator = " and "
+	}
+	return fmt.Sprintf("torrent %d (%s) on %s needs %s", o.ID, o.Name, o.Tracker, strings.Join(remaining, separator))
+}
+
+// obligation returns torrent's obligation under r, for a torrent that has
+// finished downloading. tracker is the host r matched.
+func (r Rule) obligation(torrent transmission.Torrent, tracker string) Obligation {
+	o := Obligation{
+		ID:          torrent.ID,
+		Hash:        torrent.HashString,
+		Name:        torrent.Name,
+		Tracker:     tracker,
+		requireBoth: r.RequireBoth,
+	}
+
+	seedTimeMet, ratioMet := r.MinSeedTime <= 0, r.MinRatio <= 0
+	if !seedTimeMet {
+		seeded := time.Duration(torrent.SecondsSeeding) * time.Second
+		o.SeedTimeRemaining = max(0, time.Duration(r.MinSeedTime)-seeded)
+		seedTimeMet = o.SeedTimeRemaining == 0
+	}
+	if !ratioMet {
+		o.RatioRemaining = max(0, r.MinRatio-max(0, torrent.UploadRatio))
+		ratioMet = o.RatioRemaining == 0
+	}
+
+	if r.RequireBoth {
+		o.Met = seedTimeMet && ratioMet
+	} else {
+		// A requirement that isn't set doesn't meet the obligation alone.
+		o.Met = (r.MinSeedTime > 0 && seedTimeMet) || (r.MinRatio > 0 && ratioMet)
+	}
+	if o.Met {
+		o.SeedTimeRemaining, o.RatioRemaining = 0, 0
+	}
+	return o
+}
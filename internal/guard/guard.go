@@ -0,0 +1,202 @@
+// Package guard protects private torrents from hit-and-runs: stopping or
+// removing a torrent before it has seeded as long, or as much, as its tracker
+// requires.
+//
+// Transmission has no way to veto a request, so the guard works in two
+// ways. A reconcile loop restarts finished private torrents that were stopped
+// before meeting their obligation, and an RPC proxy in front of Transmission
+// rejects torrent-stop and torrent-remove requests for them. Clients that
+// must be stopped from removing torrents, since a removal can't be undone,
+// should connect through the proxy.
+package guard
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/internal/daemon"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fields = []string{
+	"id", "hashString", "name", "isPrivate", "status", "error", "percentDone",
+	"secondsSeeding", "uploadRatio", "trackers", "trackerList",
+}
+
+// Client is the subset of *transmission.Client used by the guard.
+type Client interface {
+	TorrentGet(ctx context.Context, args transmission.TorrentGetArgs) (*transmission.TorrentGetResult, error)
+	TorrentStart(ctx context.Context, ids *transmission.TorrentIDs) error
+}
+
+// Options are the guard's optional settings.
+type Options struct {
+	Logger *slog.Logger
+	// Registerer, if set, registers the guard's metrics.
+	Registerer prometheus.Registerer
+	// DryRun logs torrents that would be restarted and requests that would
+	// be rejected, without restarting or rejecting them.
+	DryRun bool
+}
+
+// Guard tracks private torrents' seeding obligations.
+type Guard struct {
+	client   Client
+	rules    []Rule
+	interval time.Duration
+	logger   *slog.Logger
+	metrics  *metrics
+	dryRun   bool
+}
+
+// New returns a guard for config, which must have been validated by
+// LoadConfig.
+func New(client Client, config Config, opts Options) (*Guard, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	m := newMetrics()
+	if opts.Registerer != nil {
+		if err := m.register(opts.Registerer); err != nil {
+			return nil, fmt.Errorf("error registering metrics: %w", err)
+		}
+	}
+	return &Guard{
+		client:   client,
+		rules:    config.Rules,
+		interval: config.Interval.Or(defaultInterval),
+		logger:   logger,
+		metrics:  m,
+		dryRun:   opts.DryRun,
+	}, nil
+}
+
+// Run reconciles every interval until ctx is done.
+func (g *Guard) Run(ctx context.Context) {
+	g.logger.Info("starting hit-and-run guard", "rules", len(g.rules), "interval", g.interval, "dryRun", g.dryRun)
+	daemon.Every(ctx, g.interval, func(ctx context.Context) {
+		if err := g.Reconcile(ctx); err != nil {
+			g.logger.Error("error reconciling", "err", err)
+		}
+	})
+}
+
+// guarded is a torrent with an obligation.
+type guarded struct {
+	torrent    transmission.Torrent
+	obligation Obligation
+	rule       Rule
+}
+
+// obligations returns the obligations of the given torrents, skipping those
+// without one: public torrents, torrents still downloading and torrents on
+// trackers without a rule.
+func (g *Guard) obligations(ctx context.Context, ids *transmission.TorrentIDs) ([]guarded, error) {
+	result, err := g.client.TorrentGet(ctx, transmission.TorrentGetArgs{IDs: ids, Fields: fields})
+	if err != nil {
+		return nil, fmt.Errorf("error getting torrents: %w", err)
+	}
+
+	var obligations []guarded
+	for _, torrent := range result.Torrents {
+		if !torrent.IsPrivate || torrent.PercentDone < 1 {
+			continue
+		}
+		rule, host, ok := g.rule(torrent)
+		if !ok {
+			continue
+		}
+		obligations = append(obligations, guarded{torrent: torrent, obligation: rule.obligation(torrent, host), rule: rule})
+	}
+	return obligations, nil
+}
+
+// rule returns the first rule matching one of torrent's trackers, and the
+// matching tracker's host.
+func (g *Guard) rule(torrent transmission.Torrent) (Rule, string, bool) {
+	var hosts []string
+	for _, announce := range torrent.TrackerTiers().Announces() {
+		if u, err := url.Parse(announce); err == nil {
+			hosts = append(hosts, strings.ToLower(u.Hostname()))
+		}
+	}
+	for _, rule := range g.rules {
+		for _, host := range hosts {
+			if rule.matches(host) {
+				return rule, host, true
+			}
+		}
+	}
+	return Rule{}, "", false
+}
+
+// Reconcile updates the obligation metrics and restarts torrents that were
+// stopped before meeting their obligation.
+func (g *Guard) Reconcile(ctx context.Context) error {
+	obligations, err := g.obligations(ctx, transmission.AllTorrents)
+	if err != nil {
+		g.metrics.reconciles.WithLabelValues(resultError).Inc()
+		return err
+	}
+
+	g.metrics.reset()
+	for _, o := range obligations {
+		g.metrics.observe(o.rule.Tracker, o.obligation)
+		if o.obligation.Met || o.torrent.Status != transmission.TorrentStatusStopped {
+			continue
+		}
+		g.restart(ctx, o)
+	}
+	g.metrics.reconciles.WithLabelValues(resultSuccess).Inc()
+	return nil
+}
+
+func (g *Guard) restart(ctx context.Context, o guarded) {
+	logger := g.logger.With(
+		"id", o.torrent.ID,
+		"name", o.torrent.Name,
+		"hash", o.torrent.HashString,
+		"tracker", o.obligation.Tracker,
+		"seedTimeRemaining", o.obligation.SeedTimeRemaining,
+		"ratioRemaining", o.obligation.RatioRemaining,
+	)
+	if o.torrent.Error != 0 {
+		// Starting it again wouldn't help, e.g. if its data is missing.
+		logger.Warn("torrent stopped with an error before meeting its obligation", "error", o.torrent.ErrorString)
+		return
+	}
+	if g.dryRun {
+		logger.Info("torrent stopped before meeting its obligation, not restarting in dry run")
+		g.metrics.restarts.WithLabelValues(o.rule.Tracker, resultDryRun).Inc()
+		return
+	}
+
+	if err := g.client.TorrentStart(ctx, transmission.NewTorrentIDs(o.torrent.ID)); err != nil {
+		logger.Error("error restarting torrent stopped before meeting its obligation", "err", err)
+		g.metrics.restarts.WithLabelValues(o.rule.Tracker, resultError).Inc()
+		return
+	}
+	logger.Info("restarted torrent stopped before meeting its obligation")
+	g.metrics.restarts.WithLabelValues(o.rule.Tracker, resultSuccess).Inc()
+}
+
+// Unmet returns the obligations not yet met among the given torrents.
+func (g *Guard) Unmet(ctx context.Context, ids *transmission.TorrentIDs) ([]Obligation, error) {
+	obligations, err := g.obligations(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	var unmet []Obligation
+	for _, o := range obligations {
+		if !o.obligation.Met {
+			unmet = append(unmet, o.obligation)
+		}
+	}
+	return unmet, nil
+}
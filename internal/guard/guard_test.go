@@ -0,0 +1,252 @@
+package guard
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const hour = 60 * 60
+
+type fakeClient struct {
+	mutex    sync.Mutex
+	torrents []transmission.Torrent
+	started  []int64
+}
+
+func (f *fakeClient) TorrentGet(_ context.Context, args transmission.TorrentGetArgs) (*transmission.TorrentGetResult, error) {
+	data, err := json.Marshal(args.IDs)
+	if err != nil {
+		return nil, err
+	}
+	var ids []any
+	_ = json.Unmarshal(data, &ids)
+
+	result := &transmission.TorrentGetResult{}
+	for _, t := range f.torrents {
+		if args.IDs == transmission.AllTorrents || slices.Contains(ids, any(float64(t.ID))) || slices.Contains(ids, any(t.HashString)) {
+			result.Torrents = append(result.Torrents, t)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeClient) TorrentStart(_ context.Context, ids *transmission.TorrentIDs) error {
+	data, _ := json.Marshal(ids)
+	var started []int64
+	_ = json.Unmarshal(data, &started)
+	f.mutex.Lock()
+	f.started = append(f.started, started...)
+	f.mutex.Unlock()
+	return nil
+}
+
+func testConfig(t *testing.T) Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "guard.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"rules": [
+			{"tracker": "strict.org", "minSeedTime": "72h", "minRatio": 1, "requireBoth": true},
+			{"tracker": "*", "minSeedTime": "48h", "minRatio": 1}
+		]
+	}`), 0o600))
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	return *config
+}
+
+func testTorrents() []transmission.Torrent {
+	private := func(id int64, tracker string, status transmission.TorrentStatus, seeded int64, ratio float64) transmission.Torrent {
+		return transmission.Torrent{
+			ID: id, HashString: string(rune('a' + id)), Name: "t" + string(rune('0'+id)), IsPrivate: true, PercentDone: 1,
+			Status: status, SecondsSeeding: seeded, UploadRatio: ratio,
+			TrackerList: transmission.TrackerList{{"https://" + tracker + "/announce"}},
+		}
+	}
+	public := private(6, "public.org", transmission.TorrentStatusStopped, 0, 0)
+	public.IsPrivate = false
+	downloading := private(7, "other.org", transmission.TorrentStatusStopped, 0, 0)
+	downloading.PercentDone = 0.5
+	broken := private(8, "other.org", transmission.TorrentStatusStopped, 0, 0)
+	broken.Error, broken.ErrorString = transmission.TorrentError(3), "No data found"
+
+	return []transmission.Torrent{
+		private(1, "tracker.strict.org", transmission.TorrentStatusStopped, 80*hour, 0.5), // needs ratio too
+		private(2, "strict.org", transmission.TorrentStatusStopped, 80*hour, 1.5),         // met
+		private(3, "other.org", transmission.TorrentStatusSeed, 10*hour, 0.2),             // unmet but seeding
+		private(4, "other.org", transmission.TorrentStatusStopped, 10*hour, 1.2),          // met by ratio
+		private(5, "other.org", transmission.TorrentStatusStopped, 10*hour, 0.2),          // stopped early
+		public, downloading, broken,
+	}
+}
+
+func TestObligation(t *testing.T) {
+	config := testConfig(t)
+	torrents := testTorrents()
+
+	o := config.Rules[0].obligation(torrents[0], "tracker.strict.org")
+	assert.False(t, o.Met)
+	assert.Zero(t, o.SeedTimeRemaining)
+	assert.Equal(t, 0.5, o.RatioRemaining)
+	assert.Equal(t, "torrent 1 (t1) on tracker.strict.org needs 0.50 more ratio", o.String())
+
+	o = config.Rules[1].obligation(torrents[4], "other.org")
+	assert.False(t, o.Met)
+	assert.Equal(t, 38*time.Hour, o.SeedTimeRemaining)
+	assert.Equal(t, "torrent 5 (t5) on other.org needs 38h0m0s more seeding or 0.80 more ratio", o.String())
+
+	assert.True(t, config.Rules[1].obligation(torrents[3], "other.org").Met)
+}
+
+func TestReconcile(t *testing.T) {
+	client := &fakeClient{torrents: testTorrents()}
+	guard, err := New(client, testConfig(t), Options{Registerer: prometheus.NewRegistry()})
+	require.NoError(t, err)
+
+	require.NoError(t, guard.Reconcile(context.Background()))
+	assert.Equal(t, []int64{1, 5}, client.started)
+
+	assert.Equal(t, 0.0, testutil.ToFloat64(guard.metrics.met.WithLabelValues("*", "f", "t5")))
+	assert.Equal(t, float64(38*hour), testutil.ToFloat64(guard.metrics.seedTimeRemaining.WithLabelValues("*", "f", "t5")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(guard.metrics.obligations.WithLabelValues("strict.org", "true")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(guard.metrics.obligations.WithLabelValues("*", "false")))
+
+	t.Run("dry run", func(t *testing.T) {
+		client := &fakeClient{torrents: testTorrents()}
+		guard, err := New(client, testConfig(t), Options{DryRun: true})
+		require.NoError(t, err)
+		require.NoError(t, guard.Reconcile(context.Background()))
+		assert.Empty(t, client.started)
+	})
+}
+
+func TestProxy(t *testing.T) {
+	var mutex sync.Mutex
+	var forwarded []string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "user" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Transmission"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("X-Transmission-Session-Id") != "sid" {
+			w.Header().Set("X-Transmission-Session-Id", "sid")
+			http.Error(w, "Conflict", http.StatusConflict)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"session-get"`) {
+			mutex.Lock()
+			forwarded = append(forwarded, r.Method+" "+r.URL.Path+" "+string(body))
+			mutex.Unlock()
+		}
+		_, _ = io.WriteString(w, `{"result":"success"}`)
+	}))
+	t.Cleanup(backend.Close)
+	target, err := url.Parse(backend.URL)
+	require.NoError(t, err)
+
+	client := &fakeClient{torrents: testTorrents()}
+	guard, err := New(client, testConfig(t), Options{})
+	require.NoError(t, err)
+	proxy := httptest.NewServer(guard.Proxy(target))
+	t.Cleanup(proxy.Close)
+
+	post := func(body, user, sessionID string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, proxy.URL+"/transmission/rpc", strings.NewReader(body))
+		require.NoError(t, err)
+		if user != "" {
+			req.SetBasicAuth(user, "secret")
+		}
+		if sessionID != "" {
+			req.Header.Set("X-Transmission-Session-Id", sessionID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	rpc := func(body string) rpcResponse {
+		t.Helper()
+		resp := post(body, "user", "sid")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var response rpcResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+		return response
+	}
+
+	response := rpc(`{"method": "torrent-remove", "arguments": {"ids": [2, "f"]}, "tag": 7}`)
+	assert.Equal(t, "hit-and-run protection: torrent 5 (t5) on other.org needs 38h0m0s more seeding or 0.80 more ratio", response.Result)
+	assert.JSONEq(t, "7", string(response.Tag))
+
+	response = rpc(`{"method": "torrent-stop"}`)
+	assert.Contains(t, response.Result, "torrent 1 (t1)", "no ids means all torrents")
+
+	response = rpc(`{"method": "torrent-set", "arguments": {"ids": ["f"], "seedRatioMode": 2}}`)
+	assert.Contains(t, response.Result, "torrent 5 (t5)", "seeding limits are guarded")
+
+	for _, body := range []string{
+		`{"method": "torrent-remove", "arguments": {"ids": [2, 4, 6]}}`,
+		`{"method": "torrent-stop", "arguments": {"ids": 7}}`,
+		`{"method": "torrent-set", "arguments": {"ids": ["f"], "labels": ["x"]}}`,
+		`{"method": "torrent-get", "arguments": {"fields": ["id"]}}`,
+	} {
+		assert.Equal(t, "success", rpc(body).Result, body)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Len(t, forwarded, 4)
+	assert.Equal(t, `POST /transmission/rpc {"method": "torrent-remove", "arguments": {"ids": [2, 4, 6]}}`, forwarded[0])
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(guard.metrics.blocked.WithLabelValues("torrent-remove")))
+
+	t.Run("callers the daemon refuses get its response", func(t *testing.T) {
+		resp := post(`{"method": "torrent-stop"}`, "", "")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.NotContains(t, string(body), "t1", "obligations aren't revealed")
+
+		resp = post(`{"method": "torrent-stop"}`, "user", "")
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		assert.Equal(t, "sid", resp.Header.Get("X-Transmission-Session-Id"))
+	})
+}
+
+func TestRequestIDs(t *testing.T) {
+	for input, expected := range map[string]string{
+		``:                           "null",
+		`{}`:                         "null",
+		`{"ids": "recently-active"}`: `"recently-active"`,
+		`{"ids": 3}`:                 "[3]",
+		`{"ids": [1, "abc"]}`:        `[1,"abc"]`,
+	} {
+		ids, err := requestIDs(json.RawMessage(input))
+		require.NoError(t, err, input)
+		data, err := json.Marshal(ids)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(data), input)
+	}
+
+	for _, input := range []string{`{"ids": [1.5]}`, `{"ids": [true]}`, `[]`} {
+		_, err := requestIDs(json.RawMessage(input))
+		assert.Error(t, err, input)
+	}
+}
@@ -0,0 +1,99 @@
+package guard
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	resultSuccess = "success"
+	resultError   = "error"
+	resultDryRun  = "dry_run"
+)
+
+var torrentLabels = []string{"tracker", "hash", "name"}
+
+type metrics struct {
+	met               *prometheus.GaugeVec
+	seedTimeRemaining *prometheus.GaugeVec
+	ratioRemaining    *prometheus.GaugeVec
+	obligations       *prometheus.GaugeVec
+	restarts          *prometheus.CounterVec
+	blocked           *prometheus.CounterVec
+	reconciles        *prometheus.CounterVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		met: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "transmission_guard_obligation_met",
+			Help: "Whether a private torrent has met its seeding obligation (1) or not (0).",
+		}, torrentLabels),
+		seedTimeRemaining: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "transmission_guard_seed_time_remaining_seconds",
+			Help: "Seconds a private torrent still has to seed to reach its tracker's minimum seed time.",
+		}, torrentLabels),
+		ratioRemaining: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "transmission_guard_ratio_remaining",
+			Help: "Upload ratio a private torrent still has to reach its tracker's minimum ratio.",
+		}, torrentLabels),
+		obligations: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "transmission_guard_obligations",
+			Help: "Number of finished private torrents with a seeding obligation, by tracker rule and whether it is met.",
+		}, []string{"tracker", "met"}),
+		restarts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transmission_guard_restarts_total",
+			Help: "Total number of torrents restarted after being stopped before meeting their obligation.",
+		}, []string{"tracker", "result"}),
+		blocked: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transmission_guard_blocked_requests_total",
+			Help: "Total number of RPC requests rejected by the proxy because of unmet obligations.",
+		}, []string{"method"}),
+		reconciles: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transmission_guard_reconciles_total",
+			Help: "Total number of reconcile runs, by result.",
+		}, []string{"result"}),
+	}
+	for _, result := range []string{resultSuccess, resultError} {
+		m.reconciles.WithLabelValues(result)
+	}
+	for _, method := range guardedMethods {
+		m.blocked.WithLabelValues(method)
+	}
+	return m
+}
+
+func (m *metrics) register(reg prometheus.Registerer) error {
+	return errors.Join(
+		reg.Register(m.met),
+		reg.Register(m.seedTimeRemaining),
+		reg.Register(m.ratioRemaining),
+		reg.Register(m.obligations),
+		reg.Register(m.restarts),
+		reg.Register(m.blocked),
+		reg.Register(m.reconciles),
+	)
+}
+
+// reset forgets the per-torrent series, so that removed torrents aren't
+// exported any more.
+func (m *metrics) reset() {
+	m.met.Reset()
+	m.seedTimeRemaining.Reset()
+	m.ratioRemaining.Reset()
+	m.obligations.Reset()
+}
+
+func (m *metrics) observe(tracker string, o Obligation) {
+	labels := prometheus.Labels{"tracker": tracker, "hash": o.Hash, "name": o.Name}
+	met := 0.0
+	if o.Met {
+		met = 1
+	}
+	m.met.With(labels).Set(met)
+	m.seedTimeRemaining.With(labels).Set(o.SeedTimeRemaining.Seconds())
+	m.ratioRemaining.With(labels).Set(o.RatioRemaining)
+	m.obligations.WithLabelValues(tracker, strconv.FormatBool(o.Met)).Inc()
+}
@@ -0,0 +1,224 @@
+package guard
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+)
+
+// guardedMethods are the RPC methods the proxy checks.
+var guardedMethods = []string{"torrent-stop", "torrent-remove", "torrent-set"}
+
+// seedingLimitArguments are the torrent-set arguments that can stop a torrent
+// seeding before its obligation is met. torrent-set requests without them
+// aren't checked.
+var seedingLimitArguments = []string{"seedRatioLimit", "seedRatioMode", "seedIdleLimit", "seedIdleMode"}
+
+// maxRequestSize limits the RPC requests the proxy reads. torrent-add
+// requests carry whole .torrent files, so it's generous.
+const maxRequestSize = 64 << 20
+
+type rpcRequest struct {
+	Method    string          `json:"method"`
+	Arguments json.RawMessage `json:"arguments"`
+	Tag       json.RawMessage `json:"tag,omitempty"`
+}
+
+type rpcResponse struct {
+	Result string          `json:"result"`
+	Tag    json.RawMessage `json:"tag,omitempty"`
+}
+
+// Proxy returns a handler that forwards requests to the Transmission daemon
+// at target, such as http://localhost:9091, rejecting torrent-stop,
+// torrent-remove and seeding limit torrent-set requests for any torrent with
+// an unmet obligation. If the obligations can't be checked, the request is
+// rejected too.
+//
+// Before a request is checked, the daemon is asked whether it accepts the
+// caller's credentials and session id, and if not its response is passed
+// back, so that the rejection's details about the guarded torrents are only
+// shown to callers the daemon would serve.
+//
+// Only requests sent through the proxy are checked; clients talking to the
+// daemon directly aren't protected.
+func (g *Guard) Proxy(target *url.URL) http.Handler {
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+		},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			proxy.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var request rpcRequest
+		if json.Unmarshal(body, &request) == nil && needsCheck(request) {
+			if !authorized(w, r, target) {
+				return
+			}
+			if reason := g.check(r, request); reason != "" {
+				g.metrics.blocked.WithLabelValues(request.Method).Inc()
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(rpcResponse{Result: reason, Tag: request.Tag})
+				return
+			}
+		}
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+// sessionIDHeader is Transmission's CSRF protection header.
+const sessionIDHeader = "X-Transmission-Session-Id"
+
+// authorized sends a session-get to the daemon with the caller's credentials
+// and session id, and reports whether the daemon accepted it. If it didn't,
+// its response, such as a 401 or a 409 with a new session id, is written to
+// w.
+func authorized(w http.ResponseWriter, r *http.Request, target *url.URL) bool {
+	request, err := http.NewRequestWithContext(r.Context(), http.MethodPost, target.JoinPath(r.URL.Path).String(),
+		strings.NewReader(`{"method":"session-get","arguments":{"fields":["version"]}}`))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	for _, header := range []string{"Authorization", sessionIDHeader} {
+		if value := r.Header.Get(header); value != "" {
+			request.Header.Set(header, value)
+		}
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error reaching Transmission: %s", err), http.StatusBadGateway)
+		return false
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusOK {
+		return true
+	}
+
+	maps.Copy(w.Header(), response.Header)
+	w.WriteHeader(response.StatusCode)
+	_, _ = io.Copy(w, response.Body)
+	return false
+}
+
+// needsCheck reports whether request must be checked. A torrent-set with
+// arguments that can't be decoded is checked, so that check rejects it.
+func needsCheck(request rpcRequest) bool {
+	if !slices.Contains(guardedMethods, request.Method) {
+		return false
+	}
+	if request.Method != "torrent-set" {
+		return true
+	}
+
+	var args map[string]json.RawMessage
+	if err := json.Unmarshal(request.Arguments, &args); err != nil {
+		return true
+	}
+	for _, key := range seedingLimitArguments {
+		if _, exists := args[key]; exists {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns why request must be rejected, or "" if it may go through.
+func (g *Guard) check(r *http.Request, request rpcRequest) string {
+	logger := g.logger.With("method", request.Method, "remoteAddr", r.RemoteAddr)
+
+	ids, err := requestIDs(request.Arguments)
+	if err != nil {
+		logger.Warn("rejecting request with invalid ids", "err", err)
+		return fmt.Sprintf("hit-and-run protection: %s", err)
+	}
+	unmet, err := g.Unmet(r.Context(), ids)
+	if err != nil {
+		logger.Error("rejecting request as obligations couldn't be checked", "err", err)
+		return fmt.Sprintf("hit-and-run protection: couldn't check seeding obligations: %s", err)
+	}
+	if len(unmet) == 0 {
+		return ""
+	}
+
+	var reasons []string
+	for _, o := range unmet {
+		reasons = append(reasons, o.String())
+	}
+	reason := "hit-and-run protection: " + strings.Join(reasons, "; ")
+	if g.dryRun {
+		logger.Info("request would be rejected, forwarding in dry run", "reason", reason)
+		return ""
+	}
+	logger.Info("rejected request", "reason", reason)
+	return reason
+}
+
+// requestIDs decodes the ids argument of a request, which applies to all
+// torrents if it's missing.
+func requestIDs(arguments json.RawMessage) (*transmission.TorrentIDs, error) {
+	var args struct {
+		IDs json.RawMessage `json:"ids"`
+	}
+	if len(arguments) > 0 {
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return nil, fmt.Errorf("invalid arguments: %w", err)
+		}
+	}
+	if len(args.IDs) == 0 || string(args.IDs) == "null" {
+		return transmission.AllTorrents, nil
+	}
+
+	var single any
+	decoder := json.NewDecoder(bytes.NewReader(args.IDs))
+	decoder.UseNumber()
+	if err := decoder.Decode(&single); err != nil {
+		return nil, fmt.Errorf("invalid ids: %w", err)
+	}
+
+	items, ok := single.([]any)
+	if !ok {
+		if single == "recently-active" {
+			return transmission.RecentlyActiveTorrents, nil
+		}
+		items = []any{single}
+	}
+	ids := make([]any, 0, len(items))
+	for _, item := range items {
+		switch item := item.(type) {
+		case json.Number:
+			id, err := item.Int64()
+			if err != nil {
+				return nil, fmt.Errorf("invalid torrent id %s", item)
+			}
+			ids = append(ids, id)
+		case string:
+			ids = append(ids, item)
+		default:
+			return nil, errors.New("ids must be torrent ids or hashes")
+		}
+	}
+	return transmission.NewTorrentIDs(ids...), nil
+}
@@ -0,0 +1,63 @@
+// Package humanize formats Transmission values for people to read.
+package humanize
+
+import (
+	"fmt"
+	"time"
+)
+
+// Bytes formats a size with binary units, e.g. 1.5 GiB.
+func Bytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 4 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTP"[exp])
+}
+
+// Rate formats a rate in bytes per second, or "-" if it's zero.
+func Rate(bytesPerSecond int64) string {
+	if bytesPerSecond == 0 {
+		return "-"
+	}
+	return Bytes(bytesPerSecond) + "/s"
+}
+
+// Percent formats a fraction between 0 and 1 as a percentage.
+func Percent(fraction float64) string {
+	return fmt.Sprintf("%.1f%%", fraction*100)
+}
+
+// Ratio formats an upload ratio. Transmission uses -1 for "not available"
+// and -2 for infinite.
+func Ratio(ratio float64) string {
+	switch ratio {
+	case -1:
+		return "-"
+	case -2:
+		return "inf"
+	}
+	return fmt.Sprintf("%.2f", ratio)
+}
+
+// ETA formats an ETA in seconds. Transmission uses negative values when it's
+// unknown or not applicable.
+func ETA(seconds int64) string {
+	if seconds < 0 {
+		return "-"
+	}
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+// Time formats a Unix timestamp, or "-" if it's unset.
+func Time(unix int64) string {
+	if unix <= 0 {
+		return "-"
+	}
+	return time.Unix(unix, 0).Format(time.RFC3339)
+}
@@ -0,0 +1,27 @@
+package humanize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBytes(t *testing.T) {
+	assert.Equal(t, "0 B", Bytes(0))
+	assert.Equal(t, "1023 B", Bytes(1023))
+	assert.Equal(t, "1.0 KiB", Bytes(1024))
+	assert.Equal(t, "1.5 GiB", Bytes(3<<29))
+	assert.Equal(t, "2048.0 PiB", Bytes(1<<61))
+}
+
+func TestRatesAndRatios(t *testing.T) {
+	assert.Equal(t, "-", Rate(0))
+	assert.Equal(t, "1.0 MiB/s", Rate(1<<20))
+	assert.Equal(t, "50.0%", Percent(0.5))
+	assert.Equal(t, "-", Ratio(-1))
+	assert.Equal(t, "inf", Ratio(-2))
+	assert.Equal(t, "1.25", Ratio(1.25))
+	assert.Equal(t, "-", ETA(-1))
+	assert.Equal(t, "1h1m0s", ETA(3660))
+	assert.Equal(t, "-", Time(0))
+}
@@ -0,0 +1,226 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/internal/daemon"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission/query"
+)
+
+const defaultInterval = 5 * time.Minute
+
+// Config is the policy engine's config file.
+type Config struct {
+	// Interval is how often rules are evaluated. Defaults to 5m.
+	Interval daemon.Duration `json:"interval"`
+	Rules    []Rule          `json:"rules"`
+}
+
+// Rule applies actions to the torrents it matches. A torrent is governed by
+// the first rule that matches it, so more specific rules go first.
+type Rule struct {
+	Name string `json:"name"`
+
+	// Trackers matches torrents with a tracker on one of these hosts or
+	// their subdomains.
+	Trackers []string `json:"trackers"`
+	// Labels matches torrents with at least one of these labels.
+	Labels []string `json:"labels"`
+	// Filter matches torrents with a query, e.g. `status == "seed"`.
+	Filter string `json:"filter"`
+
+	Actions []Action `json:"actions"`
+}
+
+// Action is something to do to a torrent once any of its conditions is met.
+type Action struct {
+	// Action is stop, remove, remove-data (remove and delete the downloaded
+	// files) or set (apply Set with TorrentSet).
+	Action string                       `json:"action"`
+	Set    *transmission.TorrentSetArgs `json:"set"`
+
+	// Ratio is met once the upload ratio reaches it.
+	Ratio float64 `json:"ratio"`
+	// SeedTime is met once the torrent has seeded for this long.
+	SeedTime daemon.Duration `json:"seedTime"`
+	// Idle is met once the torrent has had no activity for this long.
+	Idle daemon.Duration `json:"idle"`
+	// Done is met once the torrent has finished downloading.
+	Done bool `json:"done"`
+	// When is met when the query matches.
+	When string `json:"when"`
+}
+
+type actionKind int
+
+// Action kinds in increasing order of severity. Of the actions due for a
+// torrent, only the most severe of stop and the removals is taken.
+const (
+	actionSet actionKind = iota
+	actionStop
+	actionRemove
+	actionRemoveData
+)
+
+var actionKinds = map[string]actionKind{
+	"set":         actionSet,
+	"stop":        actionStop,
+	"remove":      actionRemove,
+	"remove-data": actionRemoveData,
+}
+
+func (k actionKind) String() string {
+	for name, kind := range actionKinds {
+		if kind == k {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// rule is a compiled Rule.
+type rule struct {
+	name     string
+	trackers []string
+	labels   []string
+	filter   *query.Query
+	actions  []action
+}
+
+type action struct {
+	index int
+	kind  actionKind
+	set   *transmission.TorrentSetArgs
+	when  *query.Query
+}
+
+// LoadConfig reads and validates the config file at path.
+func LoadConfig(path string) (*Config, error) {
+	var config Config
+	if err := daemon.LoadConfig(path, &config); err != nil {
+		return nil, err
+	}
+	if _, err := config.compile(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+func (c Config) compile() ([]rule, error) {
+	if len(c.Rules) == 0 {
+		return nil, errors.New("no rules")
+	}
+
+	var rules []rule
+	names := make(map[string]bool)
+	for i, r := range c.Rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("rules[%d]: name is required", i)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("rules[%d]: duplicate name %q", i, r.Name)
+		}
+		names[r.Name] = true
+
+		compiled, err := r.compile()
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+		rules = append(rules, compiled)
+	}
+	return rules, nil
+}
+
+func (r Rule) compile() (rule, error) {
+	filter, err := query.Compile(r.Filter)
+	if err != nil {
+		return rule{}, fmt.Errorf("filter: %w", err)
+	}
+	if len(r.Actions) == 0 {
+		return rule{}, errors.New("no actions")
+	}
+
+	compiled := rule{name: r.Name, filter: filter}
+	for _, tracker := range r.Trackers {
+		compiled.trackers = append(compiled.trackers, strings.ToLower(tracker))
+	}
+	compiled.labels = r.Labels
+
+	for i, a := range r.Actions {
+		action, err := a.compile()
+		if err != nil {
+			return rule{}, fmt.Errorf("actions[%d]: %w", i, err)
+		}
+		action.index = i
+		compiled.actions = append(compiled.actions, action)
+	}
+	return compiled, nil
+}
+
+func (a Action) compile() (action, error) {
+	kind, ok := actionKinds[a.Action]
+	if !ok {
+		return action{}, fmt.Errorf("unknown action %q", a.Action)
+	}
+	if (kind == actionSet) != (a.Set != nil) {
+		return action{}, errors.New("set must be given for set actions, and only for them")
+	}
+
+	// The conditions are turned into a query so that they're evaluated, and
+	// logged, the same way as filters.
+	var conditions []string
+	if a.Ratio > 0 {
+		conditions = append(conditions, "uploadRatio >= "+strconv.FormatFloat(a.Ratio, 'f', -1, 64))
+	}
+	if a.SeedTime > 0 {
+		conditions = append(conditions, "secondsSeeding >= "+seconds(a.SeedTime))
+	}
+	if a.Idle > 0 {
+		conditions = append(conditions, "idle >= "+seconds(a.Idle))
+	}
+	if a.Done {
+		conditions = append(conditions, "percentDone == 1")
+	}
+	if a.When != "" {
+		// Compiled alone first so that errors point into the user's query.
+		if _, err := query.Compile(a.When); err != nil {
+			return action{}, fmt.Errorf("when: %w", err)
+		}
+		conditions = append(conditions, "("+a.When+")")
+	}
+	if len(conditions) == 0 {
+		return action{}, errors.New("no conditions")
+	}
+
+	when, err := query.Compile(strings.Join(conditions, " || "))
+	if err != nil {
+		return action{}, err
+	}
+	return action{kind: kind, set: a.Set, when: when}, nil
+}
+
+func seconds(d daemon.Duration) string {
+	return strconv.FormatInt(int64(time.Duration(d)/time.Second), 10)
+}
+
+// fields returns the TorrentGet fields needed to evaluate the rules.
+func fields(rules []rule) []string {
+	result := []string{
+		"id", "hashString", "name", "status", "labels", "trackers", "trackerList",
+		"uploadRatio", "secondsSeeding",
+	}
+	for _, r := range rules {
+		result = append(result, r.filter.Fields()...)
+		for _, a := range r.actions {
+			result = append(result, a.when.Fields()...)
+		}
+	}
+	slices.Sort(result)
+	return slices.Compact(result)
+}
@@ -0,0 +1,65 @@
+package policy
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	resultSuccess = "success"
+	resultError   = "error"
+	resultDryRun  = "dry_run"
+)
+
+type metrics struct {
+	actions *prometheus.CounterVec
+	matched *prometheus.GaugeVec
+	runs    *prometheus.CounterVec
+	lastRun prometheus.Gauge
+}
+
+func newMetrics(rules []rule) *metrics {
+	m := &metrics{
+		actions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transmission_policy_actions_total",
+			Help: "Total number of policy actions taken, by rule, action and result.",
+		}, []string{"rule", "action", "result"}),
+		matched: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "transmission_policy_matched_torrents",
+			Help: "Number of torrents governed by each rule in the last run.",
+		}, []string{"rule"}),
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transmission_policy_runs_total",
+			Help: "Total number of times the rules were evaluated, by result.",
+		}, []string{"result"}),
+		lastRun: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "transmission_policy_last_run_timestamp_seconds",
+			Help: "Unix time of the last successful evaluation of the rules.",
+		}),
+	}
+
+	// Initialise the series so that they're exported before the first
+	// action is taken.
+	for _, r := range rules {
+		m.matched.WithLabelValues(r.name)
+		for _, a := range r.actions {
+			for _, result := range []string{resultSuccess, resultError, resultDryRun} {
+				m.actions.WithLabelValues(r.name, a.kind.String(), result)
+			}
+		}
+	}
+	for _, result := range []string{resultSuccess, resultError} {
+		m.runs.WithLabelValues(result)
+	}
+	return m
+}
+
+func (m *metrics) register(reg prometheus.Registerer) error {
+	return errors.Join(
+		reg.Register(m.actions),
+		reg.Register(m.matched),
+		reg.Register(m.runs),
+		reg.Register(m.lastRun),
+	)
+}
@@ -0,0 +1,258 @@
+// Package policy applies seeding rules, such as stopping torrents once they
+// reach a ratio or removing them after a while, on a schedule.
+package policy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/internal/daemon"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Client is the subset of *transmission.Client used by the engine.
+type Client interface {
+	TorrentGet(ctx context.Context, args transmission.TorrentGetArgs) (*transmission.TorrentGetResult, error)
+	TorrentStop(ctx context.Context, ids *transmission.TorrentIDs) error
+	TorrentRemove(ctx context.Context, args transmission.TorrentRemoveArgs) error
+	TorrentSet(ctx context.Context, args transmission.TorrentSetArgs) error
+}
+
+// Options are the engine's optional settings.
+type Options struct {
+	Logger *slog.Logger
+	// Registerer, if set, registers the engine's metrics.
+	Registerer prometheus.Registerer
+	// DryRun logs the actions that are due without taking them.
+	DryRun bool
+}
+
+// Engine evaluates rules against all torrents and takes the actions that are
+// due.
+type Engine struct {
+	client   Client
+	rules    []rule
+	fields   []string
+	interval time.Duration
+	logger   *slog.Logger
+	metrics  *metrics
+	dryRun   bool
+	now      func() time.Time
+
+	// applied records set actions already applied, and in dry run mode all
+	// actions already logged, so that they aren't repeated on every run.
+	applied map[appliedKey]bool
+}
+
+type appliedKey struct {
+	hash   string
+	rule   string
+	action int
+}
+
+// New returns an engine for config.
+func New(client Client, config Config, opts Options) (*Engine, error) {
+	rules, err := config.compile()
+	if err != nil {
+		return nil, err
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	m := newMetrics(rules)
+	if opts.Registerer != nil {
+		if err := m.register(opts.Registerer); err != nil {
+			return nil, fmt.Errorf("error registering metrics: %w", err)
+		}
+	}
+
+	return &Engine{
+		client:   client,
+		rules:    rules,
+		fields:   fields(rules),
+		interval: config.Interval.Or(defaultInterval),
+		logger:   logger,
+		metrics:  m,
+		dryRun:   opts.DryRun,
+		now:      time.Now,
+		applied:  make(map[appliedKey]bool),
+	}, nil
+}
+
+// Run evaluates the rules every interval until ctx is done.
+func (e *Engine) Run(ctx context.Context) {
+	e.logger.Info("starting policy engine", "rules", len(e.rules), "interval", e.interval, "dryRun", e.dryRun)
+	daemon.Every(ctx, e.interval, func(ctx context.Context) {
+		if err := e.Evaluate(ctx); err != nil {
+			e.logger.Error("error evaluating rules", "err", err)
+		}
+	})
+}
+
+// Evaluate evaluates the rules once. Errors acting on individual torrents
+// are logged and counted but don't stop the other torrents being handled.
+func (e *Engine) Evaluate(ctx context.Context) error {
+	result, err := e.client.TorrentGet(ctx, transmission.TorrentGetArgs{Fields: e.fields})
+	if err != nil {
+		e.metrics.runs.WithLabelValues(resultError).Inc()
+		return fmt.Errorf("error getting torrents: %w", err)
+	}
+
+	now := e.now()
+	matched := make(map[string]int)
+	hashes := make(map[string]bool, len(result.Torrents))
+	for _, torrent := range result.Torrents {
+		hashes[torrent.HashString] = true
+		r, ok := e.match(torrent, now)
+		if !ok {
+			continue
+		}
+		matched[r.name]++
+		e.apply(ctx, r, torrent, now)
+	}
+
+	for key := range e.applied {
+		if !hashes[key.hash] {
+			delete(e.applied, key)
+		}
+	}
+	for _, r := range e.rules {
+		e.metrics.matched.WithLabelValues(r.name).Set(float64(matched[r.name]))
+	}
+	e.metrics.runs.WithLabelValues(resultSuccess).Inc()
+	e.metrics.lastRun.Set(float64(now.Unix()))
+	return nil
+}
+
+// match returns the first rule matching torrent.
+func (e *Engine) match(torrent transmission.Torrent, now time.Time) (rule, bool) {
+	for _, r := range e.rules {
+		if r.matches(torrent, now) {
+			return r, true
+		}
+	}
+	return rule{}, false
+}
+
+func (r rule) matches(torrent transmission.Torrent, now time.Time) bool {
+	if len(r.trackers) > 0 && !slices.ContainsFunc(trackerHosts(torrent), r.hasTracker) {
+		return false
+	}
+	if len(r.labels) > 0 && !slices.ContainsFunc(torrent.Labels, func(label string) bool {
+		return slices.Contains(r.labels, label)
+	}) {
+		return false
+	}
+	return r.filter.MatchAt(torrent, now)
+}
+
+func (r rule) hasTracker(host string) bool {
+	for _, tracker := range r.trackers {
+		if host == tracker || strings.HasSuffix(host, "."+tracker) {
+			return true
+		}
+	}
+	return false
+}
+
+func trackerHosts(torrent transmission.Torrent) []string {
+	var hosts []string
+	for _, announce := range torrent.TrackerTiers().Announces() {
+		if u, err := url.Parse(announce); err == nil {
+			hosts = append(hosts, strings.ToLower(u.Hostname()))
+		}
+	}
+	return hosts
+}
+
+// apply takes the actions of r that are due for torrent.
+func (e *Engine) apply(ctx context.Context, r rule, torrent transmission.Torrent, now time.Time) {
+	logger := e.logger.With(
+		"rule", r.name,
+		"id", torrent.ID,
+		"name", torrent.Name,
+		"hash", torrent.HashString,
+	)
+
+	var sets []action
+	var strongest *action
+	for _, a := range r.actions {
+		if !a.when.MatchAt(torrent, now) {
+			continue
+		}
+		if a.kind == actionSet {
+			sets = append(sets, a)
+		} else if strongest == nil || a.kind > strongest.kind {
+			strongest = &a
+		}
+	}
+	if strongest != nil && strongest.kind == actionStop && torrent.Status == transmission.TorrentStatusStopped {
+		strongest = nil
+	}
+	if strongest == nil && len(sets) == 0 {
+		logger.Debug("no actions due")
+		return
+	}
+
+	if strongest != nil {
+		e.take(ctx, logger, r, *strongest, torrent)
+		if strongest.kind != actionStop {
+			// Changing settings of a removed torrent would fail.
+			return
+		}
+	}
+	for _, a := range sets {
+		e.take(ctx, logger, r, a, torrent)
+	}
+}
+
+func (e *Engine) take(ctx context.Context, logger *slog.Logger, r rule, a action, torrent transmission.Torrent) {
+	logger = logger.With(
+		"action", a.kind.String(),
+		"condition", a.when.String(),
+		"ratio", torrent.UploadRatio,
+		"seedingTime", time.Duration(torrent.SecondsSeeding)*time.Second,
+	)
+	key := appliedKey{hash: torrent.HashString, rule: r.name, action: a.index}
+	if e.applied[key] {
+		return
+	}
+
+	if e.dryRun {
+		logger.Info("policy action due, skipped in dry run")
+		e.metrics.actions.WithLabelValues(r.name, a.kind.String(), resultDryRun).Inc()
+		e.applied[key] = true
+		return
+	}
+
+	ids := transmission.NewTorrentIDs(torrent.ID)
+	var err error
+	switch a.kind {
+	case actionSet:
+		args := *a.set
+		args.Ids = []any{torrent.ID}
+		err = e.client.TorrentSet(ctx, args)
+	case actionStop:
+		err = e.client.TorrentStop(ctx, ids)
+	case actionRemove, actionRemoveData:
+		err = e.client.TorrentRemove(ctx, transmission.TorrentRemoveArgs{IDs: ids, DeleteLocalData: a.kind == actionRemoveData})
+	}
+	if err != nil {
+		logger.Error("error taking policy action", "err", err)
+		e.metrics.actions.WithLabelValues(r.name, a.kind.String(), resultError).Inc()
+		return
+	}
+
+	logger.Info("policy action taken")
+	e.metrics.actions.WithLabelValues(r.name, a.kind.String(), resultSuccess).Inc()
+	if a.kind == actionSet {
+		e.applied[key] = true
+	}
+}
@@ -0,0 +1,164 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const day = 24 * 60 * 60
+
+type fakeClient struct {
+	torrents []transmission.Torrent
+	calls    []string
+	err      error
+}
+
+func (f *fakeClient) TorrentGet(context.Context, transmission.TorrentGetArgs) (*transmission.TorrentGetResult, error) {
+	return &transmission.TorrentGetResult{Torrents: f.torrents}, nil
+}
+
+func (f *fakeClient) record(call string, args any) error {
+	data, _ := json.Marshal(args)
+	f.calls = append(f.calls, call+" "+string(data))
+	return f.err
+}
+
+func (f *fakeClient) TorrentStop(_ context.Context, ids *transmission.TorrentIDs) error {
+	return f.record("stop", ids)
+}
+
+func (f *fakeClient) TorrentRemove(_ context.Context, args transmission.TorrentRemoveArgs) error {
+	return f.record("remove", args)
+}
+
+func (f *fakeClient) TorrentSet(_ context.Context, args transmission.TorrentSetArgs) error {
+	return f.record("set", args)
+}
+
+func loadTestConfig(t *testing.T, config string) Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+	c, err := LoadConfig(path)
+	require.NoError(t, err)
+	return *c
+}
+
+const testConfig = `{
+	"rules": [
+		{
+			"name": "tmp",
+			"labels": ["tmp"],
+			"actions": [{"action": "remove-data", "done": true}]
+		},
+		{
+			"name": "tracker-x",
+			"trackers": ["tracker-x.org"],
+			"actions": [
+				{"action": "stop", "ratio": 2, "seedTime": "14d"},
+				{"action": "remove", "seedTime": "30d"},
+				{"action": "set", "set": {"uploadLimit": 100, "uploadLimited": true}, "when": "uploadRatio > 1"}
+			]
+		}
+	]
+}`
+
+func testTorrents() []transmission.Torrent {
+	trackerX := transmission.TrackerList{{"https://announce.tracker-x.org/a"}}
+	return []transmission.Torrent{
+		// Both rules match; tmp comes first.
+		{ID: 1, HashString: "a", Labels: []string{"tmp"}, PercentDone: 1, TrackerList: trackerX, UploadRatio: 5},
+		{ID: 2, HashString: "b", Labels: []string{"tmp"}, PercentDone: 0.5},
+		{ID: 3, HashString: "c", TrackerList: trackerX, UploadRatio: 2.5, Status: transmission.TorrentStatusSeed},
+		{ID: 4, HashString: "d", TrackerList: trackerX, UploadRatio: 3, SecondsSeeding: 31 * day, Status: transmission.TorrentStatusStopped},
+		{ID: 5, HashString: "e", TrackerList: trackerX, UploadRatio: 0.5, SecondsSeeding: 15 * day, Status: transmission.TorrentStatusStopped},
+		{ID: 6, HashString: "f", TrackerList: transmission.TrackerList{{"https://other.org/a"}}, UploadRatio: 10},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeClient{torrents: testTorrents()}
+	engine, err := New(client, loadTestConfig(t, testConfig), Options{Registerer: prometheus.NewRegistry()})
+	require.NoError(t, err)
+
+	require.NoError(t, engine.Evaluate(ctx))
+	assert.Equal(t, []string{
+		`remove {"ids":[1],"delete_local_data":true}`,
+		`stop [3]`,
+		`set {"ids":[3],"uploadLimit":100,"uploadLimited":true}`,
+		`remove {"ids":[4],"delete_local_data":false}`,
+	}, client.calls)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(engine.metrics.actions.WithLabelValues("tmp", "remove-data", resultSuccess)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(engine.metrics.actions.WithLabelValues("tracker-x", "stop", resultSuccess)))
+
+	// Set actions are only applied once.
+	client.calls = nil
+	client.torrents = testTorrents()[2:3]
+	client.torrents[0].Status = transmission.TorrentStatusStopped
+	require.NoError(t, engine.Evaluate(ctx))
+	assert.Empty(t, client.calls)
+}
+
+func TestEvaluateDryRunAndErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("dry run", func(t *testing.T) {
+		client := &fakeClient{torrents: testTorrents()}
+		engine, err := New(client, loadTestConfig(t, testConfig), Options{DryRun: true})
+		require.NoError(t, err)
+
+		require.NoError(t, engine.Evaluate(ctx))
+		require.NoError(t, engine.Evaluate(ctx))
+		assert.Empty(t, client.calls)
+		assert.Equal(t, 1.0, testutil.ToFloat64(engine.metrics.actions.WithLabelValues("tracker-x", "remove", resultDryRun)))
+	})
+
+	t.Run("errors are counted", func(t *testing.T) {
+		client := &fakeClient{torrents: testTorrents()[:1], err: errors.New("boom")}
+		engine, err := New(client, loadTestConfig(t, testConfig), Options{})
+		require.NoError(t, err)
+
+		require.NoError(t, engine.Evaluate(ctx))
+		assert.Equal(t, 1.0, testutil.ToFloat64(engine.metrics.actions.WithLabelValues("tmp", "remove-data", resultError)))
+	})
+}
+
+func TestConfig(t *testing.T) {
+	config := loadTestConfig(t, testConfig)
+	rules, err := config.compile()
+	require.NoError(t, err)
+	assert.Equal(t, "uploadRatio >= 2 || secondsSeeding >= 1209600", rules[1].actions[0].when.String())
+	assert.Subset(t, fields(rules), []string{"labels", "percentDone", "secondsSeeding", "trackerList", "uploadRatio"})
+
+	for config, expected := range map[string]string{
+		`{"rules": [{"name": "a", "actions": [{"action": "stop"}]}]}`:                              "no conditions",
+		`{"rules": [{"name": "a", "actions": [{"action": "pause", "done": true}]}]}`:               `unknown action "pause"`,
+		`{"rules": [{"name": "a", "actions": [{"action": "set", "done": true}]}]}`:                 "set must be given",
+		`{"rules": [{"name": "a", "actions": [{"action": "stop", "when": "ratio > 2"}]}]}`:         "when: query: unknown field",
+		`{"rules": [{"name": "a", "filter": "(", "actions": [{"action": "stop", "done": true}]}]}`: "filter: query:",
+	} {
+		var c Config
+		require.NoError(t, json.Unmarshal([]byte(config), &c))
+		_, err := c.compile()
+		assert.ErrorContains(t, err, expected, config)
+	}
+}
+
+func TestHasTracker(t *testing.T) {
+	r := rule{trackers: []string{"tracker-x.org"}}
+	assert.True(t, r.hasTracker("tracker-x.org"))
+	assert.True(t, r.hasTracker("announce.tracker-x.org"))
+	assert.False(t, r.hasTracker("nottracker-x.org"))
+}
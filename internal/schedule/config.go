@@ -0,0 +1,100 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/internal/daemon"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+)
+
+const defaultInterval = time.Minute
+
+// Config is the scheduler's config file.
+type Config struct {
+	// Interval is how often the active profile's settings are checked and
+	// re-applied if they were changed. Defaults to 1m.
+	Interval daemon.Duration `json:"interval"`
+	// Timezone is the IANA time zone the profiles' start times are in, such
+	// as "Europe/London". Defaults to the local time zone.
+	Timezone string    `json:"timezone"`
+	Profiles []Profile `json:"profiles"`
+}
+
+// Profile is a set of session and group settings, active from each of its
+// start times until another profile starts.
+type Profile struct {
+	Name string `json:"name"`
+	// Start is a cron expression, such as "0 9 * * mon-fri", for the times
+	// the profile becomes active.
+	Start   string                      `json:"start"`
+	Session transmission.SessionSetArgs `json:"session"`
+	// Groups are bandwidth groups' settings. Groups that don't exist are
+	// created.
+	Groups []transmission.GroupSetArgs `json:"groups"`
+
+	start *cron
+}
+
+// LoadConfig reads and validates the config file at path.
+func LoadConfig(path string) (*Config, error) {
+	var config Config
+	if err := daemon.LoadConfig(path, &config); err != nil {
+		return nil, err
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+func (c *Config) validate() error {
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("timezone: %w", err)
+	}
+	if len(c.Profiles) == 0 {
+		return errors.New("no profiles")
+	}
+	names := map[string]bool{}
+	for i := range c.Profiles {
+		p := &c.Profiles[i]
+		if p.Name == "" {
+			return fmt.Errorf("profiles[%d]: name is required", i)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("profiles[%d]: duplicate name %q", i, p.Name)
+		}
+		names[p.Name] = true
+
+		start, err := parseCron(p.Start)
+		if err != nil {
+			return fmt.Errorf("profile %s: start: %w", p.Name, err)
+		}
+		if _, ok := start.next(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)); !ok {
+			return fmt.Errorf("profile %s: start %q never happens", p.Name, p.Start)
+		}
+		p.start = start
+
+		if len(sessionSettings(p.Session)) == 0 && len(p.Groups) == 0 {
+			return fmt.Errorf("profile %s: no session or group settings", p.Name)
+		}
+		for j, g := range p.Groups {
+			if g.Name == "" {
+				return fmt.Errorf("profile %s: groups[%d]: name is required", p.Name, j)
+			}
+		}
+	}
+	return nil
+}
+
+// sessionSettings returns the settings args changes, by their RPC name.
+func sessionSettings(args transmission.SessionSetArgs) map[string]json.RawMessage {
+	// SessionSetArgs' fields are all omitempty pointers, so only the
+	// settings that are set are encoded.
+	data, _ := json.Marshal(args)
+	var settings map[string]json.RawMessage
+	_ = json.Unmarshal(data, &settings)
+	return settings
+}
@@ -0,0 +1,173 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSearchDays bounds the search for a cron expression's previous and next
+// times. An expression that fires at all fires at least once every eight
+// years, even if only on the 29th of February.
+const maxSearchDays = 8 * 366
+
+// cron is a standard five-field cron expression: minute, hour, day of month,
+// month and day of week. Fields accept *, numbers, ranges, lists and steps,
+// and months and days of the week also accept three-letter names.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	// As in cron, if both the day of month and day of week are restricted,
+	// a day matching either matches.
+	domStar, dowStar bool
+}
+
+var (
+	monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	dayNames   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+func parseCron(expr string) (*cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", expr)
+	}
+	c := cron{
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}
+	var err error
+	for i, f := range []struct {
+		bits     *uint64
+		min, max int
+		names    []string
+		nameBase int
+	}{
+		{&c.minute, 0, 59, nil, 0},
+		{&c.hour, 0, 23, nil, 0},
+		{&c.dom, 1, 31, nil, 0},
+		{&c.month, 1, 12, monthNames, 1},
+		{&c.dow, 0, 7, dayNames, 0},
+	} {
+		if *f.bits, err = parseField(fields[i], f.min, f.max, f.names, f.nameBase); err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+		}
+	}
+	// Sunday is both 0 and 7.
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return &c, nil
+}
+
+func parseField(field string, min, max int, names []string, nameBase int) (uint64, error) {
+	value := func(s string) (int, error) {
+		for i, name := range names {
+			if strings.EqualFold(s, name) {
+				return i + nameBase, nil
+			}
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < min || n > max {
+			return 0, fmt.Errorf("invalid value %q, must be between %d and %d", s, min, max)
+		}
+		return n, nil
+	}
+
+	var bits uint64
+	for part := range strings.SplitSeq(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		low, high := min, max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = value(from); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = value(to); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				high = max
+			}
+			if high < low {
+				return 0, fmt.Errorf("invalid range %q", rangePart)
+			}
+		}
+		for i := low; i <= high; i += step {
+			bits |= 1 << i
+		}
+	}
+	return bits, nil
+}
+
+func (c *cron) matchesDay(t time.Time) bool {
+	if c.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	dom, dow := c.dom&(1<<t.Day()) != 0, c.dow&(1<<int(t.Weekday())) != 0
+	switch {
+	case c.domStar && c.dowStar:
+		return true
+	case c.domStar:
+		return dow
+	case c.dowStar:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// prev returns the last time c fired at or before t, in t's location.
+func (c *cron) prev(t time.Time) (time.Time, bool) {
+	for i := range maxSearchDays {
+		day := time.Date(t.Year(), t.Month(), t.Day()-i, 0, 0, 0, 0, t.Location())
+		if !c.matchesDay(day) {
+			continue
+		}
+		for hour := 23; hour >= 0; hour-- {
+			for minute := 59; minute >= 0; minute-- {
+				if c.hour&(1<<hour) == 0 || c.minute&(1<<minute) == 0 {
+					continue
+				}
+				at := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, t.Location())
+				if !at.After(t) {
+					return at, true
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// next returns the first time c fires after t, in t's location.
+func (c *cron) next(t time.Time) (time.Time, bool) {
+	for i := range maxSearchDays {
+		day := time.Date(t.Year(), t.Month(), t.Day()+i, 0, 0, 0, 0, t.Location())
+		if !c.matchesDay(day) {
+			continue
+		}
+		for hour := range 24 {
+			for minute := range 60 {
+				if c.hour&(1<<hour) == 0 || c.minute&(1<<minute) == 0 {
+					continue
+				}
+				at := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, t.Location())
+				if at.After(t) {
+					return at, true
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
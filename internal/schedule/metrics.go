@@ -0,0 +1,80 @@
+package schedule
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	resultSuccess = "success"
+	resultError   = "error"
+	resultDryRun  = "dry_run"
+
+	// reasonSwitch is the reason for applying a profile that just became
+	// active, and reasonDrift for applying one whose settings were changed.
+	reasonSwitch = "switch"
+	reasonDrift  = "drift"
+)
+
+type metrics struct {
+	profiles  []string
+	active    *prometheus.GaugeVec
+	applies   *prometheus.CounterVec
+	checks    *prometheus.CounterVec
+	lastCheck prometheus.Gauge
+}
+
+func newMetrics(profiles []Profile) *metrics {
+	m := &metrics{
+		active: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "transmission_schedule_active_profile",
+			Help: "Whether each bandwidth profile is the active one (1) or not (0).",
+		}, []string{"profile"}),
+		applies: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transmission_schedule_applies_total",
+			Help: "Total number of times a profile's settings were applied, by profile, reason (switch or drift) and result.",
+		}, []string{"profile", "reason", "result"}),
+		checks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transmission_schedule_checks_total",
+			Help: "Total number of checks of the active profile's settings, by result.",
+		}, []string{"result"}),
+		lastCheck: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "transmission_schedule_last_check_timestamp_seconds",
+			Help: "Unix time of the last successful check of the active profile's settings.",
+		}),
+	}
+	for _, p := range profiles {
+		m.profiles = append(m.profiles, p.Name)
+		m.active.WithLabelValues(p.Name)
+		for _, reason := range []string{reasonSwitch, reasonDrift} {
+			for _, result := range []string{resultSuccess, resultError, resultDryRun} {
+				m.applies.WithLabelValues(p.Name, reason, result)
+			}
+		}
+	}
+	for _, result := range []string{resultSuccess, resultError} {
+		m.checks.WithLabelValues(result)
+	}
+	return m
+}
+
+func (m *metrics) register(reg prometheus.Registerer) error {
+	return errors.Join(
+		reg.Register(m.active),
+		reg.Register(m.applies),
+		reg.Register(m.checks),
+		reg.Register(m.lastCheck),
+	)
+}
+
+// setActive marks profile as the active one.
+func (m *metrics) setActive(profile string) {
+	for _, p := range m.profiles {
+		value := 0.0
+		if p == profile {
+			value = 1
+		}
+		m.active.WithLabelValues(p).Set(value)
+	}
+}
@@ -0,0 +1,316 @@
+// Package schedule switches between bandwidth profiles on a schedule.
+//
+// Transmission's own alt-speed schedule has a single alternative profile and
+// only sets speed limits. Here any number of profiles, each active from its
+// cron start times until another profile starts, set session settings such as
+// speed limits and queue sizes, and bandwidth groups' limits. Settings changed
+// by hand are put back on the next check.
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/internal/daemon"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Client is the subset of *transmission.Client used by the scheduler.
+type Client interface {
+	SessionGet(ctx context.Context) (*transmission.Session, error)
+	SessionSet(ctx context.Context, args transmission.SessionSetArgs) error
+	GroupGet(ctx context.Context, args *transmission.GroupGetArgs) (*transmission.GroupGetResult, error)
+	GroupSet(ctx context.Context, args transmission.GroupSetArgs) error
+}
+
+// Options are the scheduler's optional settings.
+type Options struct {
+	Logger *slog.Logger
+	// Registerer, if set, registers the scheduler's metrics.
+	Registerer prometheus.Registerer
+	// DryRun logs the settings that would be applied without applying them.
+	DryRun bool
+}
+
+// Scheduler applies the active profile's settings.
+type Scheduler struct {
+	client   Client
+	profiles []Profile
+	location *time.Location
+	interval time.Duration
+	logger   *slog.Logger
+	metrics  *metrics
+	dryRun   bool
+	now      func() time.Time
+
+	mutex  sync.Mutex
+	status Status
+}
+
+// Status is the scheduler's state, as served by its status endpoint.
+type Status struct {
+	// Profile is the active profile, active since Since.
+	Profile string    `json:"profile"`
+	Since   time.Time `json:"since"`
+	// Next is the next profile to become active, at NextAt.
+	Next   string    `json:"next,omitempty"`
+	NextAt time.Time `json:"nextAt,omitzero"`
+	// Applied is when the active profile's settings were last found changed
+	// and applied, and Checked when they were last checked.
+	Applied time.Time `json:"applied,omitzero"`
+	Checked time.Time `json:"checked,omitzero"`
+	// Error is the last check's error, if it failed.
+	Error  string `json:"error,omitempty"`
+	DryRun bool   `json:"dryRun,omitempty"`
+}
+
+// New returns a scheduler for config, which must have been validated by
+// LoadConfig.
+func New(client Client, config Config, opts Options) (*Scheduler, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("error loading time zone: %w", err)
+	}
+	m := newMetrics(config.Profiles)
+	if opts.Registerer != nil {
+		if err := m.register(opts.Registerer); err != nil {
+			return nil, fmt.Errorf("error registering metrics: %w", err)
+		}
+	}
+	return &Scheduler{
+		client:   client,
+		profiles: config.Profiles,
+		location: location,
+		interval: config.Interval.Or(defaultInterval),
+		logger:   logger,
+		metrics:  m,
+		dryRun:   opts.DryRun,
+		now:      time.Now,
+		status:   Status{DryRun: opts.DryRun},
+	}, nil
+}
+
+// Run applies the active profile every interval until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.logger.Info("starting bandwidth scheduler",
+		"profiles", len(s.profiles),
+		"timezone", s.location,
+		"interval", s.interval,
+		"dryRun", s.dryRun,
+	)
+	daemon.Every(ctx, s.interval, func(ctx context.Context) {
+		if err := s.Apply(ctx); err != nil {
+			s.logger.Error("error applying bandwidth profile", "err", err)
+		}
+	})
+}
+
+// Status returns the scheduler's current state.
+func (s *Scheduler) Status() Status {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.status
+}
+
+// active returns the profile active at now, the time it became active and
+// the next profile to become active.
+func (s *Scheduler) active(now time.Time) (profile *Profile, since time.Time, next *Profile, nextAt time.Time) {
+	now = now.In(s.location)
+	for i := range s.profiles {
+		p := &s.profiles[i]
+		// On a tie, the first profile listed wins.
+		if at, ok := p.start.prev(now); ok && (profile == nil || at.After(since)) {
+			profile, since = p, at
+		}
+	}
+	for i := range s.profiles {
+		p := &s.profiles[i]
+		if p == profile {
+			continue
+		}
+		if at, ok := p.start.next(now); ok && (next == nil || at.Before(nextAt)) {
+			next, nextAt = p, at
+		}
+	}
+	return profile, since, next, nextAt
+}
+
+// Apply applies the active profile's settings that differ from the current
+// ones.
+func (s *Scheduler) Apply(ctx context.Context) error {
+	now := s.now()
+	profile, since, next, nextAt := s.active(now)
+	if profile == nil {
+		// Validation makes sure every profile starts at some point, so this
+		// only happens before any has started for the first time.
+		return errors.New("no profile has started yet")
+	}
+
+	s.mutex.Lock()
+	switched := s.status.Profile != profile.Name
+	s.mutex.Unlock()
+
+	applied, err := s.apply(ctx, profile, switched)
+	if err != nil {
+		s.metrics.checks.WithLabelValues(resultError).Inc()
+	} else {
+		s.metrics.checks.WithLabelValues(resultSuccess).Inc()
+		s.metrics.lastCheck.Set(float64(now.Unix()))
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// Until the profile's settings were applied once, it isn't active.
+	if err == nil || !switched {
+		if switched {
+			s.status.Applied = time.Time{}
+			s.metrics.setActive(profile.Name)
+		}
+		s.status.Profile, s.status.Since = profile.Name, since
+	}
+	s.status.Next, s.status.NextAt = "", time.Time{}
+	if next != nil {
+		s.status.Next, s.status.NextAt = next.Name, nextAt
+	}
+	s.status.Checked = now
+	if applied {
+		s.status.Applied = now
+	}
+	s.status.Error = ""
+	if err != nil {
+		s.status.Error = err.Error()
+	}
+	return err
+}
+
+// apply applies profile's session and group settings that differ from the
+// current ones, returning whether any did. switched is whether the profile
+// just became active, rather than its settings having been changed by hand.
+func (s *Scheduler) apply(ctx context.Context, profile *Profile, switched bool) (bool, error) {
+	reason := reasonDrift
+	if switched {
+		reason = reasonSwitch
+	}
+	logger := s.logger.With("profile", profile.Name, "reason", reason)
+
+	session, err := s.client.SessionGet(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error getting session: %w", err)
+	}
+	var groups []transmission.Group
+	if len(profile.Groups) > 0 {
+		names := make([]string, len(profile.Groups))
+		for i, g := range profile.Groups {
+			names[i] = g.Name
+		}
+		result, err := s.client.GroupGet(ctx, &transmission.GroupGetArgs{Group: names})
+		if err != nil {
+			return false, fmt.Errorf("error getting bandwidth groups: %w", err)
+		}
+		groups = result.Group
+	}
+
+	var applied bool
+	var errs []error
+	if changed := sessionChanges(session, profile.Session); len(changed) > 0 {
+		applied = true
+		err := s.set(logger.With("settings", changed), func() error {
+			return s.client.SessionSet(ctx, profile.Session)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error setting session: %w", err))
+		}
+	}
+	for _, g := range profile.Groups {
+		i := slices.IndexFunc(groups, func(group transmission.Group) bool { return group.Name == g.Name })
+		if i >= 0 && !groupChanged(groups[i], g) {
+			continue
+		}
+		applied = true
+		err := s.set(logger.With("group", g.Name), func() error {
+			return s.client.GroupSet(ctx, g)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error setting bandwidth group %s: %w", g.Name, err))
+		}
+	}
+
+	if applied {
+		result := resultSuccess
+		switch {
+		case s.dryRun:
+			result = resultDryRun
+		case len(errs) > 0:
+			result = resultError
+		}
+		s.metrics.applies.WithLabelValues(profile.Name, reason, result).Inc()
+	}
+	return applied, errors.Join(errs...)
+}
+
+// set logs and calls fn, unless in dry run mode.
+func (s *Scheduler) set(logger *slog.Logger, fn func() error) error {
+	if s.dryRun {
+		logger.Info("not applying bandwidth profile in dry run")
+		return nil
+	}
+	if err := fn(); err != nil {
+		logger.Error("error applying bandwidth profile", "err", err)
+		return err
+	}
+	logger.Info("applied bandwidth profile")
+	return nil
+}
+
+// sessionChanges returns the RPC names of the settings in args that differ
+// from session's.
+func sessionChanges(session *transmission.Session, args transmission.SessionSetArgs) []string {
+	data, _ := json.Marshal(session)
+	var current map[string]json.RawMessage
+	_ = json.Unmarshal(data, &current)
+
+	var changed []string
+	for name, value := range sessionSettings(args) {
+		var want, got any
+		_ = json.Unmarshal(value, &want)
+		_ = json.Unmarshal(current[name], &got)
+		if !reflect.DeepEqual(want, got) {
+			changed = append(changed, name)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+// groupChanged returns whether any of the settings in args differ from
+// group's.
+func groupChanged(group transmission.Group, args transmission.GroupSetArgs) bool {
+	differs := func(want *int, got int64) bool { return want != nil && int64(*want) != got }
+	differsBool := func(want *bool, got bool) bool { return want != nil && *want != got }
+	return differsBool(args.HonorsSessionLimits, group.HonorsSessionLimits) ||
+		differs(args.SpeedLimitDown, group.SpeedLimitDown) ||
+		differsBool(args.SpeedLimitDownEnabled, group.SpeedLimitDownEnabled) ||
+		differs(args.SpeedLimitUp, group.SpeedLimitUp) ||
+		differsBool(args.SpeedLimitUpEnabled, group.SpeedLimitUpEnabled)
+}
+
+// StatusHandler returns a handler serving the scheduler's Status as JSON.
+func (s *Scheduler) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.Status())
+	})
+}
@@ -0,0 +1,239 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClient struct {
+	session transmission.Session
+	groups  []transmission.Group
+	calls   []string
+}
+
+func (f *fakeClient) SessionGet(context.Context) (*transmission.Session, error) {
+	session := f.session
+	return &session, nil
+}
+
+func (f *fakeClient) SessionSet(_ context.Context, args transmission.SessionSetArgs) error {
+	data, _ := json.Marshal(args)
+	f.calls = append(f.calls, "session-set "+string(data))
+	return json.Unmarshal(data, &f.session)
+}
+
+func (f *fakeClient) GroupGet(_ context.Context, args *transmission.GroupGetArgs) (*transmission.GroupGetResult, error) {
+	result := &transmission.GroupGetResult{}
+	for _, g := range f.groups {
+		if slices.Contains(args.Group, g.Name) {
+			result.Group = append(result.Group, g)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeClient) GroupSet(_ context.Context, args transmission.GroupSetArgs) error {
+	f.calls = append(f.calls, "group-set "+args.Name)
+	i := slices.IndexFunc(f.groups, func(g transmission.Group) bool { return g.Name == args.Name })
+	if i < 0 {
+		f.groups = append(f.groups, transmission.Group{Name: args.Name})
+		i = len(f.groups) - 1
+	}
+	if args.SpeedLimitUp != nil {
+		f.groups[i].SpeedLimitUp = int64(*args.SpeedLimitUp)
+	}
+	if args.SpeedLimitUpEnabled != nil {
+		f.groups[i].SpeedLimitUpEnabled = *args.SpeedLimitUpEnabled
+	}
+	return nil
+}
+
+const testConfig = `{
+	"timezone": "UTC",
+	"profiles": [
+		{
+			"name": "office",
+			"start": "0 9 * * mon-fri",
+			"session": {"speed-limit-down": 100, "speed-limit-down-enabled": true, "download-queue-size": 2}
+		},
+		{
+			"name": "evening",
+			"start": "0 18 * * mon-fri",
+			"session": {"speed-limit-down-enabled": false, "download-queue-size": 10},
+			"groups": [{"name": "seeding", "speed_limit_up": 50, "speed_limit_up_enabled": true}]
+		},
+		{
+			"name": "weekend",
+			"start": "0 0 * * sat,sun",
+			"session": {"speed-limit-down-enabled": false, "download-queue-size": 20}
+		}
+	]
+}`
+
+func loadTestConfig(t *testing.T, config string) Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "schedule.json")
+	require.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+	c, err := LoadConfig(path)
+	require.NoError(t, err)
+	return *c
+}
+
+func TestActive(t *testing.T) {
+	scheduler, err := New(&fakeClient{}, loadTestConfig(t, testConfig), Options{})
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		now, since, nextAt time.Time
+		profile, next      string
+	}{
+		{
+			// Wednesday.
+			now:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			profile: "office", since: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC),
+			next: "evening", nextAt: time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			now:     time.Date(2024, 5, 1, 8, 59, 0, 0, time.UTC),
+			profile: "evening", since: time.Date(2024, 4, 30, 18, 0, 0, 0, time.UTC),
+			next: "office", nextAt: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			// Monday morning, after the weekend profile started again on
+			// Sunday.
+			now:     time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC),
+			profile: "weekend", since: time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC),
+			next: "office", nextAt: time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC),
+		},
+	} {
+		profile, since, next, nextAt := scheduler.active(test.now)
+		assert.Equal(t, test.profile, profile.Name, test.now)
+		assert.Equal(t, test.since, since, test.now)
+		assert.Equal(t, test.next, next.Name, test.now)
+		assert.Equal(t, test.nextAt, nextAt, test.now)
+	}
+}
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeClient{}
+	scheduler, err := New(client, loadTestConfig(t, testConfig), Options{Registerer: prometheus.NewRegistry()})
+	require.NoError(t, err)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	scheduler.now = func() time.Time { return now }
+
+	require.NoError(t, scheduler.Apply(ctx))
+	assert.Equal(t, []string{`session-set {"download-queue-size":2,"speed-limit-down":100,"speed-limit-down-enabled":true}`}, client.calls)
+	assert.Equal(t, "office", scheduler.Status().Profile)
+
+	// Nothing changed.
+	client.calls = nil
+	require.NoError(t, scheduler.Apply(ctx))
+	assert.Empty(t, client.calls)
+
+	// Someone lifted the speed limit by hand.
+	client.session.SpeedLimitDownEnabled = false
+	require.NoError(t, scheduler.Apply(ctx))
+	assert.Len(t, client.calls, 1)
+	assert.Equal(t, 1.0, testutil.ToFloat64(scheduler.metrics.applies.WithLabelValues("office", reasonDrift, resultSuccess)))
+
+	// In the evening, the group is created.
+	client.calls = nil
+	now = time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC)
+	require.NoError(t, scheduler.Apply(ctx))
+	assert.Equal(t, []string{`session-set {"download-queue-size":10,"speed-limit-down-enabled":false}`, "group-set seeding"}, client.calls)
+	client.calls = nil
+	require.NoError(t, scheduler.Apply(ctx))
+	assert.Empty(t, client.calls)
+	client.groups[0].SpeedLimitUp = 500
+	require.NoError(t, scheduler.Apply(ctx))
+	assert.Equal(t, []string{"group-set seeding"}, client.calls)
+
+	assert.Equal(t, 0.0, testutil.ToFloat64(scheduler.metrics.active.WithLabelValues("office")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(scheduler.metrics.active.WithLabelValues("evening")))
+
+	recorder := httptest.NewRecorder()
+	scheduler.StatusHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/status", nil))
+	assert.JSONEq(t, `{
+		"profile": "evening",
+		"since": "2024-05-01T18:00:00Z",
+		"next": "office",
+		"nextAt": "2024-05-02T09:00:00Z",
+		"applied": "2024-05-01T18:00:00Z",
+		"checked": "2024-05-01T18:00:00Z"
+	}`, recorder.Body.String())
+}
+
+func TestApplyDryRun(t *testing.T) {
+	client := &fakeClient{}
+	scheduler, err := New(client, loadTestConfig(t, testConfig), Options{DryRun: true})
+	require.NoError(t, err)
+	require.NoError(t, scheduler.Apply(context.Background()))
+	assert.Empty(t, client.calls)
+	assert.True(t, scheduler.Status().DryRun)
+	assert.NotEmpty(t, scheduler.Status().Profile)
+}
+
+func TestCron(t *testing.T) {
+	c, err := parseCron("*/15 9-17 * jan,jul MON-fri")
+	require.NoError(t, err)
+	at := time.Date(2024, 7, 3, 12, 7, 0, 0, time.UTC) // Wednesday
+	prev, ok := c.prev(at)
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2024, 7, 3, 12, 0, 0, 0, time.UTC), prev)
+	next, ok := c.next(at)
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2024, 7, 3, 12, 15, 0, 0, time.UTC), next)
+	next, _ = c.next(time.Date(2024, 7, 31, 17, 45, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC), next, "continues in January")
+
+	// With both a day of month and a day of week, either matches.
+	c, err = parseCron("0 0 1 * 0")
+	require.NoError(t, err)
+	next, _ = c.next(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC), next, "Sunday")
+
+	// Sunday is 0 and 7.
+	c, err = parseCron("0 0 * * 7")
+	require.NoError(t, err)
+	prev, _ = c.prev(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2024, 4, 28, 0, 0, 0, 0, time.UTC), prev)
+
+	c, err = parseCron("0 0 29 feb *")
+	require.NoError(t, err)
+	next, ok = c.next(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC), next)
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "* * * * mon-sunday", "*/0 * * * *", "5-1 * * * *"} {
+		_, err := parseCron(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	for config, expected := range map[string]string{
+		`{"profiles": []}`: "no profiles",
+		`{"timezone": "Nowhere/Special", "profiles": [{"name": "a", "start": "* * * * *"}]}`:     "timezone",
+		`{"profiles": [{"name": "a", "start": "0 9 * *", "session": {"speed-limit-up": 1}}]}`:    "must have 5 fields",
+		`{"profiles": [{"name": "a", "start": "0 0 31 2 *", "session": {"speed-limit-up": 1}}]}`: "never happens",
+		`{"profiles": [{"name": "a", "start": "0 9 * * *"}]}`:                                    "no session or group settings",
+		`{"profiles": [{"name": "a", "start": "0 9 * * *", "groups": [{}]}]}`:                    "name is required",
+	} {
+		var c Config
+		require.NoError(t, json.Unmarshal([]byte(config), &c))
+		assert.ErrorContains(t, c.validate(), expected, config)
+	}
+}
@@ -0,0 +1,78 @@
+package stalled
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/internal/daemon"
+)
+
+const (
+	defaultInterval   = time.Minute
+	defaultStalledFor = 30 * time.Minute
+	defaultLabel      = "stalled"
+)
+
+// Config is the remediator's config file.
+type Config struct {
+	// Interval is how often torrents are checked. Defaults to 1m.
+	Interval daemon.Duration `json:"interval"`
+	// StalledFor is how long a torrent must be stuck before the first step
+	// is taken. Defaults to 30m.
+	StalledFor daemon.Duration `json:"stalledFor"`
+	// StepInterval is how long each step is given to work before the next
+	// one is taken. Defaults to StalledFor.
+	StepInterval daemon.Duration `json:"stepInterval"`
+	// Trackers are announce URLs added to stuck torrents, in a tier of their
+	// own. If there are none, the step is skipped.
+	Trackers []string `json:"trackers"`
+	// Label is added to torrents that are still stuck after every other
+	// step. Defaults to "stalled".
+	Label string `json:"label"`
+	// Webhook, if set, is a URL that is sent a JSON notification when a
+	// torrent is labelled.
+	Webhook string `json:"webhook"`
+	// StateFile, if set, is where each torrent's escalation is kept, so that
+	// it carries on where it was after a restart.
+	StateFile string `json:"stateFile"`
+}
+
+// LoadConfig reads and validates the config file at path.
+func LoadConfig(path string) (*Config, error) {
+	var config Config
+	if err := daemon.LoadConfig(path, &config); err != nil {
+		return nil, err
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+func (c *Config) validate() error {
+	if c.StalledFor < 0 || c.StepInterval < 0 {
+		return errors.New("stalledFor and stepInterval can't be negative")
+	}
+	c.StalledFor = daemon.Duration(c.StalledFor.Or(defaultStalledFor))
+	c.StepInterval = daemon.Duration(c.StepInterval.Or(time.Duration(c.StalledFor)))
+	if c.Label == "" {
+		c.Label = defaultLabel
+	}
+	if strings.Contains(c.Label, ",") {
+		return fmt.Errorf("label %q must not contain a comma", c.Label)
+	}
+	for i, tracker := range c.Trackers {
+		if u, err := url.Parse(tracker); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("trackers[%d]: %q isn't an announce URL", i, tracker)
+		}
+	}
+	if c.Webhook != "" {
+		if u, err := url.Parse(c.Webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("webhook %q isn't an http or https URL", c.Webhook)
+		}
+	}
+	return nil
+}
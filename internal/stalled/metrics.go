@@ -0,0 +1,64 @@
+package stalled
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	resultSuccess = "success"
+	resultError   = "error"
+	resultDryRun  = "dry_run"
+
+	// stepNone is the step label of stuck torrents no step was taken for yet.
+	stepNone = "none"
+)
+
+type metrics struct {
+	torrents  *prometheus.GaugeVec
+	steps     *prometheus.CounterVec
+	checks    *prometheus.CounterVec
+	lastCheck prometheus.Gauge
+}
+
+func newMetrics(steps []step) *metrics {
+	m := &metrics{
+		torrents: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "transmission_stalled_torrents",
+			Help: "Number of unfinished torrents that got stuck and haven't recovered, by the last step taken.",
+		}, []string{"step"}),
+		steps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transmission_stalled_steps_total",
+			Help: "Total number of remediation steps taken for stuck torrents, by step and result.",
+		}, []string{"step", "result"}),
+		checks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transmission_stalled_checks_total",
+			Help: "Total number of checks for stuck torrents, by result.",
+		}, []string{"result"}),
+		lastCheck: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "transmission_stalled_last_check_timestamp_seconds",
+			Help: "Unix time of the last successful check for stuck torrents.",
+		}),
+	}
+	m.torrents.WithLabelValues(stepNone)
+	for _, s := range steps {
+		m.torrents.WithLabelValues(s.name)
+		for _, result := range []string{resultSuccess, resultError, resultDryRun} {
+			m.steps.WithLabelValues(s.name, result)
+		}
+	}
+	for _, result := range []string{resultSuccess, resultError} {
+		m.checks.WithLabelValues(result)
+	}
+	return m
+}
+
+func (m *metrics) register(reg prometheus.Registerer) error {
+	return errors.Join(
+		reg.Register(m.torrents),
+		reg.Register(m.steps),
+		reg.Register(m.checks),
+		reg.Register(m.lastCheck),
+	)
+}
@@ -0,0 +1,50 @@
+package stalled
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// notification is the JSON body sent to the webhook. Text makes it readable
+// by chat services' incoming webhooks, such as Slack's and Mattermost's.
+type notification struct {
+	Text    string              `json:"text"`
+	Torrent notificationTorrent `json:"torrent"`
+}
+
+type notificationTorrent struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	Hash         string    `json:"hash"`
+	Reason       string    `json:"reason"`
+	StalledSince time.Time `json:"stalledSince"`
+}
+
+// notify posts n to the webhook. Any status other than 2xx is an error.
+func (r *Remediator) notify(ctx context.Context, n notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("error encoding notification: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.webhook, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending notification: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("error sending notification: webhook returned %s", resp.Status)
+	}
+	return nil
+}
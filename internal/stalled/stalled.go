@@ -0,0 +1,356 @@
+// Package stalled gets stuck torrents going again. Transmission reports when
+// a torrent is stalled or has no peers, but someone still has to notice.
+//
+// A torrent that stays stuck for long enough is taken through a series of
+// steps, each given time to work before the next: a reannounce, adding the
+// configured trackers, moving it to the bottom of the queue and finally
+// labelling it and sending a notification. Escalation stops as soon as the
+// torrent downloads again.
+package stalled
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/internal/daemon"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const webhookTimeout = 30 * time.Second
+
+var fields = []string{
+	"id", "hashString", "name", "status", "labels", "leftUntilDone", "isStalled",
+	"peersConnected", "desiredAvailable", "trackerStats",
+}
+
+// Client is the subset of *transmission.Client used by the remediator.
+type Client interface {
+	TorrentGet(ctx context.Context, args transmission.TorrentGetArgs) (*transmission.TorrentGetResult, error)
+	TorrentReannounce(ctx context.Context, ids *transmission.TorrentIDs) error
+	AddTrackerTier(ctx context.Context, ids *transmission.TorrentIDs, announces ...string) ([]transmission.TrackerChange, error)
+	QueueMoveBottom(ctx context.Context, ids *transmission.TorrentIDs) error
+	AddLabels(ctx context.Context, ids *transmission.TorrentIDs, labels ...string) ([]transmission.LabelChange, error)
+	RemoveLabels(ctx context.Context, ids *transmission.TorrentIDs, labels ...string) ([]transmission.LabelChange, error)
+}
+
+// Options are the remediator's optional settings.
+type Options struct {
+	Logger *slog.Logger
+	// Registerer, if set, registers the remediator's metrics.
+	Registerer prometheus.Registerer
+	// DryRun logs the steps that are due without taking them.
+	DryRun bool
+}
+
+// Remediator escalates stuck torrents.
+type Remediator struct {
+	client       Client
+	interval     time.Duration
+	stalledFor   time.Duration
+	stepInterval time.Duration
+	trackers     []string
+	label        string
+	webhook      string
+	stateFile    string
+	steps        []step
+	logger       *slog.Logger
+	metrics      *metrics
+	dryRun       bool
+	now          func() time.Time
+	httpClient   *http.Client
+
+	// torrents holds, by hash, the torrents that got stuck and haven't
+	// recovered.
+	torrents map[string]*entry
+	dirty    bool
+}
+
+// entry is a stuck torrent's escalation.
+type entry struct {
+	Name  string    `json:"name"`
+	Since time.Time `json:"since"`
+	// Steps is the number of steps taken, the last of them at LastStep.
+	Steps    int       `json:"steps"`
+	LastStep time.Time `json:"lastStep,omitzero"`
+}
+
+// state is the state file's content.
+type state struct {
+	Torrents map[string]*entry `json:"torrents"`
+}
+
+// step is a remediation step. run returns an error if the step must be
+// retried.
+type step struct {
+	name string
+	run  func(ctx context.Context, logger *slog.Logger, torrent transmission.Torrent, e *entry, reason string) error
+}
+
+// New returns a remediator for config, which must have been validated by
+// LoadConfig. The escalations of a previous run are read from the state
+// file, if any.
+func New(client Client, config Config, opts Options) (*Remediator, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	r := &Remediator{
+		client:       client,
+		interval:     config.Interval.Or(defaultInterval),
+		stalledFor:   time.Duration(config.StalledFor),
+		stepInterval: time.Duration(config.StepInterval),
+		trackers:     config.Trackers,
+		label:        config.Label,
+		webhook:      config.Webhook,
+		stateFile:    config.StateFile,
+		logger:       logger,
+		dryRun:       opts.DryRun,
+		now:          time.Now,
+		httpClient:   &http.Client{Timeout: webhookTimeout},
+		torrents:     map[string]*entry{},
+	}
+	r.steps = append(r.steps, step{"reannounce", r.reannounce})
+	if len(r.trackers) > 0 {
+		r.steps = append(r.steps, step{"add-trackers", r.addTrackers})
+	}
+	r.steps = append(r.steps, step{"queue-bottom", r.queueBottom}, step{"label", r.labelAndNotify})
+
+	r.metrics = newMetrics(r.steps)
+	if opts.Registerer != nil {
+		if err := r.metrics.register(opts.Registerer); err != nil {
+			return nil, fmt.Errorf("error registering metrics: %w", err)
+		}
+	}
+	if r.stateFile != "" {
+		s := state{Torrents: r.torrents}
+		if err := daemon.LoadState(r.stateFile, &s); err != nil {
+			return nil, err
+		}
+		if s.Torrents != nil {
+			r.torrents = s.Torrents
+		}
+	}
+	return r, nil
+}
+
+// Run checks for stuck torrents every interval until ctx is done.
+func (r *Remediator) Run(ctx context.Context) {
+	names := make([]string, len(r.steps))
+	for i, s := range r.steps {
+		names[i] = s.name
+	}
+	r.logger.Info("starting stalled torrent remediation",
+		"stalledFor", r.stalledFor,
+		"stepInterval", r.stepInterval,
+		"steps", names,
+		"interval", r.interval,
+		"dryRun", r.dryRun,
+	)
+	daemon.Every(ctx, r.interval, func(ctx context.Context) {
+		if err := r.Check(ctx); err != nil {
+			r.logger.Error("error checking for stalled torrents", "err", err)
+		}
+	})
+}
+
+// Check takes the steps that are due for stuck torrents and forgets those
+// that recovered.
+func (r *Remediator) Check(ctx context.Context) error {
+	if err := r.check(ctx); err != nil {
+		r.metrics.checks.WithLabelValues(resultError).Inc()
+		return err
+	}
+	r.metrics.checks.WithLabelValues(resultSuccess).Inc()
+	r.metrics.lastCheck.Set(float64(r.now().Unix()))
+	return nil
+}
+
+func (r *Remediator) check(ctx context.Context) error {
+	result, err := r.client.TorrentGet(ctx, transmission.TorrentGetArgs{IDs: transmission.AllTorrents, Fields: fields})
+	if err != nil {
+		return fmt.Errorf("error getting torrents: %w", err)
+	}
+
+	now := r.now()
+	present := make(map[string]bool, len(result.Torrents))
+	for _, torrent := range result.Torrents {
+		present[torrent.HashString] = true
+		e := r.torrents[torrent.HashString]
+		reason := stuckReason(torrent)
+		switch {
+		case reason != "":
+			if e == nil {
+				r.logger.Debug("torrent is stuck", "id", torrent.ID, "name", torrent.Name, "reason", reason)
+				e = &entry{Name: torrent.Name, Since: now}
+				r.torrents[torrent.HashString] = e
+				r.dirty = true
+			}
+			r.escalate(ctx, torrent, e, reason, now)
+		case e != nil && (torrent.LeftUntilDone == 0 || torrent.Status == transmission.TorrentStatusDownload):
+			r.recovered(ctx, torrent, e)
+		}
+		// Torrents that are queued, stopped or being verified keep their
+		// escalation until they download again.
+	}
+	for hash := range r.torrents {
+		if !present[hash] {
+			delete(r.torrents, hash)
+			r.dirty = true
+		}
+	}
+
+	r.metrics.torrents.Reset()
+	r.metrics.torrents.WithLabelValues(stepNone)
+	for _, s := range r.steps {
+		r.metrics.torrents.WithLabelValues(s.name)
+	}
+	for _, e := range r.torrents {
+		name := stepNone
+		if e.Steps > 0 {
+			name = r.steps[min(e.Steps, len(r.steps))-1].name
+		}
+		r.metrics.torrents.WithLabelValues(name).Inc()
+	}
+
+	if r.dirty && r.stateFile != "" && !r.dryRun {
+		if err := daemon.SaveState(r.stateFile, state{Torrents: r.torrents}); err != nil {
+			return err
+		}
+		r.dirty = false
+	}
+	return nil
+}
+
+// stuckReason returns why a downloading torrent is stuck, or "" if it isn't
+// downloading or isn't stuck.
+func stuckReason(torrent transmission.Torrent) string {
+	if torrent.Status != transmission.TorrentStatusDownload || torrent.LeftUntilDone == 0 {
+		return ""
+	}
+	switch {
+	case torrent.PeersConnected == 0:
+		return "no peers"
+	case torrent.DesiredAvailable == 0:
+		return "no peer has the missing data"
+	case torrent.IsStalled:
+		return "stalled"
+	}
+	return ""
+}
+
+// trackerErrors returns the last announce errors of torrent's trackers that
+// are failing, which often explain why it's stuck.
+func trackerErrors(torrent transmission.Torrent) []string {
+	var errs []string
+	for _, stat := range torrent.TrackerStats {
+		if stat.HasAnnounced && !stat.LastAnnounceSucceeded {
+			errs = append(errs, fmt.Sprintf("%s: %s", stat.Host, stat.LastAnnounceResult))
+		}
+	}
+	return errs
+}
+
+// escalate takes the next step for a stuck torrent, if it's due.
+func (r *Remediator) escalate(ctx context.Context, torrent transmission.Torrent, e *entry, reason string, now time.Time) {
+	if e.Steps >= len(r.steps) {
+		return
+	}
+	due := e.Since.Add(r.stalledFor)
+	if e.Steps > 0 {
+		due = e.LastStep.Add(r.stepInterval)
+	}
+	if now.Before(due) {
+		return
+	}
+
+	s := r.steps[e.Steps]
+	logger := r.logger.With(
+		"id", torrent.ID,
+		"name", torrent.Name,
+		"hash", torrent.HashString,
+		"reason", reason,
+		"stalledSince", e.Since,
+		"step", s.name,
+	)
+	if errs := trackerErrors(torrent); len(errs) > 0 {
+		logger = logger.With("trackerErrors", errs)
+	}
+
+	if r.dryRun {
+		logger.Info("torrent is stuck, not taking step in dry run")
+		r.metrics.steps.WithLabelValues(s.name, resultDryRun).Inc()
+	} else if err := s.run(ctx, logger, torrent, e, reason); err != nil {
+		logger.Error("error taking step for stuck torrent", "err", err)
+		r.metrics.steps.WithLabelValues(s.name, resultError).Inc()
+		return
+	} else {
+		logger.Warn("torrent is stuck, took step")
+		r.metrics.steps.WithLabelValues(s.name, resultSuccess).Inc()
+	}
+	e.Steps++
+	e.LastStep = now
+	r.dirty = true
+}
+
+// recovered forgets a torrent that downloads again or finished, removing
+// the label if it was added.
+func (r *Remediator) recovered(ctx context.Context, torrent transmission.Torrent, e *entry) {
+	logger := r.logger.With("id", torrent.ID, "name", torrent.Name, "hash", torrent.HashString, "steps", e.Steps)
+	labelled := e.Steps >= len(r.steps) && slices.Contains(torrent.Labels, r.label)
+	if labelled && !r.dryRun {
+		if _, err := r.client.RemoveLabels(ctx, transmission.NewTorrentIDs(torrent.ID), r.label); err != nil {
+			// Try again on the next check.
+			logger.Error("error removing label from recovered torrent", "label", r.label, "err", err)
+			return
+		}
+	}
+	if e.Steps > 0 {
+		logger.Info("stuck torrent recovered")
+	}
+	delete(r.torrents, torrent.HashString)
+	r.dirty = true
+}
+
+func (r *Remediator) reannounce(ctx context.Context, _ *slog.Logger, torrent transmission.Torrent, _ *entry, _ string) error {
+	return r.client.TorrentReannounce(ctx, transmission.NewTorrentIDs(torrent.ID))
+}
+
+func (r *Remediator) addTrackers(ctx context.Context, logger *slog.Logger, torrent transmission.Torrent, _ *entry, _ string) error {
+	changes, err := r.client.AddTrackerTier(ctx, transmission.NewTorrentIDs(torrent.ID), r.trackers...)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		logger.Info("torrent already has every configured tracker")
+	}
+	return nil
+}
+
+func (r *Remediator) queueBottom(ctx context.Context, _ *slog.Logger, torrent transmission.Torrent, _ *entry, _ string) error {
+	return r.client.QueueMoveBottom(ctx, transmission.NewTorrentIDs(torrent.ID))
+}
+
+func (r *Remediator) labelAndNotify(ctx context.Context, _ *slog.Logger, torrent transmission.Torrent, e *entry, reason string) error {
+	if _, err := r.client.AddLabels(ctx, transmission.NewTorrentIDs(torrent.ID), r.label); err != nil {
+		return err
+	}
+	if r.webhook == "" {
+		return nil
+	}
+	return r.notify(ctx, notification{
+		Text: fmt.Sprintf("Torrent %q has been stuck since %s (%s) and was labelled %q.",
+			torrent.Name, e.Since.Format(time.RFC3339), reason, r.label),
+		Torrent: notificationTorrent{
+			ID:           torrent.ID,
+			Name:         torrent.Name,
+			Hash:         torrent.HashString,
+			Reason:       reason,
+			StalledSince: e.Since,
+		},
+	})
+}
@@ -0,0 +1,244 @@
+package stalled
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClient struct {
+	torrents []transmission.Torrent
+	calls    []string
+	err      error
+}
+
+func (f *fakeClient) TorrentGet(context.Context, transmission.TorrentGetArgs) (*transmission.TorrentGetResult, error) {
+	return &transmission.TorrentGetResult{Torrents: f.torrents}, nil
+}
+
+func (f *fakeClient) record(call string, args any) error {
+	data, _ := json.Marshal(args)
+	f.calls = append(f.calls, call+" "+string(data))
+	return f.err
+}
+
+func (f *fakeClient) TorrentReannounce(_ context.Context, ids *transmission.TorrentIDs) error {
+	return f.record("reannounce", ids)
+}
+
+func (f *fakeClient) AddTrackerTier(_ context.Context, ids *transmission.TorrentIDs, announces ...string) ([]transmission.TrackerChange, error) {
+	return nil, f.record("add-tracker-tier", []any{ids, announces})
+}
+
+func (f *fakeClient) QueueMoveBottom(_ context.Context, ids *transmission.TorrentIDs) error {
+	return f.record("queue-bottom", ids)
+}
+
+func (f *fakeClient) AddLabels(_ context.Context, ids *transmission.TorrentIDs, labels ...string) ([]transmission.LabelChange, error) {
+	return nil, f.record("add-labels", []any{ids, labels})
+}
+
+func (f *fakeClient) RemoveLabels(_ context.Context, ids *transmission.TorrentIDs, labels ...string) ([]transmission.LabelChange, error) {
+	return nil, f.record("remove-labels", []any{ids, labels})
+}
+
+func testTorrents() []transmission.Torrent {
+	return []transmission.Torrent{
+		{
+			ID: 1, HashString: "a", Name: "stuck", Status: transmission.TorrentStatusDownload, LeftUntilDone: 100,
+			TrackerList:  transmission.TrackerList{{"https://tracker.org/announce"}},
+			TrackerStats: []transmission.TrackerStat{{Host: "tracker.org", HasAnnounced: true, LastAnnounceResult: "Connection failed"}},
+		},
+		{ID: 2, HashString: "b", Name: "fine", Status: transmission.TorrentStatusDownload, LeftUntilDone: 100, PeersConnected: 5, DesiredAvailable: 100},
+		{ID: 3, HashString: "c", Name: "done", Status: transmission.TorrentStatusSeed},
+	}
+}
+
+func testConfig(t *testing.T, config string) Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stalled.json")
+	require.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+	c, err := LoadConfig(path)
+	require.NoError(t, err)
+	return *c
+}
+
+type clock struct{ now time.Time }
+
+func (c *clock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestRemediator(t *testing.T, client Client, config Config, opts Options, c *clock) *Remediator {
+	t.Helper()
+	r, err := New(client, config, opts)
+	require.NoError(t, err)
+	r.now = func() time.Time { return c.now }
+	return r
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	notifications := make(chan notification, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n notification
+		_ = json.NewDecoder(r.Body).Decode(&n)
+		notifications <- n
+	}))
+	t.Cleanup(webhook.Close)
+
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	config := testConfig(t, `{
+		"stalledFor": "30m",
+		"stepInterval": "10m",
+		"trackers": ["https://tracker.org/announce", "udp://backup.org:1337"],
+		"webhook": "`+webhook.URL+`",
+		"stateFile": "`+stateFile+`"
+	}`)
+	client := &fakeClient{torrents: testTorrents()}
+	c := &clock{now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	r := newTestRemediator(t, client, config, Options{Registerer: prometheus.NewRegistry()}, c)
+
+	steps := []string{
+		`reannounce [1]`,
+		`add-tracker-tier [[1],["https://tracker.org/announce","udp://backup.org:1337"]]`,
+		`queue-bottom [1]`,
+		`add-labels [[1],["stalled"]]`,
+	}
+	require.NoError(t, r.Check(ctx))
+	c.advance(29 * time.Minute)
+	require.NoError(t, r.Check(ctx))
+	assert.Empty(t, client.calls, "not stuck for long enough")
+
+	for i, expected := range steps {
+		c.advance(10 * time.Minute)
+		// A restarted remediator carries on where the last one was.
+		r = newTestRemediator(t, client, config, Options{}, c)
+		require.NoError(t, r.Check(ctx))
+		require.NoError(t, r.Check(ctx))
+		assert.Equal(t, steps[:i+1], client.calls, expected)
+	}
+
+	n := <-notifications
+	assert.Equal(t, "stuck", n.Torrent.Name)
+	assert.Equal(t, "no peers", n.Torrent.Reason)
+	assert.Equal(t, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), n.Torrent.StalledSince.UTC())
+
+	// Nothing more happens once every step was taken.
+	client.calls = nil
+	c.advance(time.Hour)
+	require.NoError(t, r.Check(ctx))
+	assert.Empty(t, client.calls)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.metrics.torrents.WithLabelValues("label")))
+
+	// The torrent downloads again: the label is removed and it's forgotten.
+	client.torrents[0].PeersConnected, client.torrents[0].DesiredAvailable = 3, 100
+	client.torrents[0].Labels = []string{"stalled"}
+	require.NoError(t, r.Check(ctx))
+	assert.Equal(t, []string{`remove-labels [[1],["stalled"]]`}, client.calls)
+	assert.Empty(t, r.torrents)
+	data, err := os.ReadFile(stateFile)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"torrents": {}}`, string(data))
+}
+
+func TestCheckDryRunAndErrors(t *testing.T) {
+	ctx := context.Background()
+	config := testConfig(t, `{"stalledFor": "1m"}`)
+
+	t.Run("dry run", func(t *testing.T) {
+		client := &fakeClient{torrents: testTorrents()}
+		c := &clock{now: time.Now()}
+		r := newTestRemediator(t, client, config, Options{DryRun: true}, c)
+		for range 4 {
+			require.NoError(t, r.Check(ctx))
+			c.advance(time.Hour)
+		}
+		assert.Empty(t, client.calls)
+		assert.Equal(t, 3, r.torrents["a"].Steps)
+	})
+
+	t.Run("failed steps are retried", func(t *testing.T) {
+		client := &fakeClient{torrents: testTorrents(), err: errors.New("boom")}
+		c := &clock{now: time.Now()}
+		r := newTestRemediator(t, client, config, Options{}, c)
+		require.NoError(t, r.Check(ctx))
+		c.advance(time.Hour)
+		require.NoError(t, r.Check(ctx))
+		require.NoError(t, r.Check(ctx))
+		assert.Equal(t, []string{"reannounce [1]", "reannounce [1]"}, client.calls)
+		assert.Zero(t, r.torrents["a"].Steps)
+	})
+
+	t.Run("queued torrents keep their escalation", func(t *testing.T) {
+		client := &fakeClient{torrents: testTorrents()}
+		c := &clock{now: time.Now()}
+		r := newTestRemediator(t, client, config, Options{}, c)
+		require.NoError(t, r.Check(ctx))
+		client.torrents[0].Status = transmission.TorrentStatusDownloadWait
+		require.NoError(t, r.Check(ctx))
+		assert.Contains(t, r.torrents, "a")
+
+		client.torrents = nil
+		require.NoError(t, r.Check(ctx))
+		assert.Empty(t, r.torrents, "removed torrents are forgotten")
+	})
+}
+
+func TestNotifyError(t *testing.T) {
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(webhook.Close)
+
+	r, err := New(&fakeClient{}, testConfig(t, `{"webhook": "`+webhook.URL+`"}`), Options{})
+	require.NoError(t, err)
+	assert.ErrorContains(t, r.notify(context.Background(), notification{Text: "hi"}), "502 Bad Gateway")
+}
+
+func TestStuckReason(t *testing.T) {
+	downloading := transmission.Torrent{Status: transmission.TorrentStatusDownload, LeftUntilDone: 1, PeersConnected: 1, DesiredAvailable: 1}
+	assert.Empty(t, stuckReason(downloading))
+
+	stalled := downloading
+	stalled.IsStalled = true
+	assert.Equal(t, "stalled", stuckReason(stalled))
+
+	unavailable := downloading
+	unavailable.DesiredAvailable = 0
+	assert.Equal(t, "no peer has the missing data", stuckReason(unavailable))
+
+	queued := downloading
+	queued.Status, queued.PeersConnected = transmission.TorrentStatusDownloadWait, 0
+	assert.Empty(t, stuckReason(queued))
+}
+
+func TestConfig(t *testing.T) {
+	config := testConfig(t, `{"stalledFor": "1h"}`)
+	assert.Equal(t, time.Hour, time.Duration(config.StepInterval), "stepInterval defaults to stalledFor")
+	assert.Equal(t, "stalled", config.Label)
+
+	for config, expected := range map[string]string{
+		`{"stalledFor": "-1m"}`:          "can't be negative",
+		`{"trackers": ["tracker.org"]}`:  "isn't an announce URL",
+		`{"webhook": "ftp://hooks.org"}`: "isn't an http or https URL",
+		`{"label": "stalled,again"}`:     "must not contain a comma",
+	} {
+		var c Config
+		require.NoError(t, json.Unmarshal([]byte(config), &c))
+		assert.ErrorContains(t, c.validate(), expected, config)
+	}
+}
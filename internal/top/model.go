@@ -0,0 +1,346 @@
+// Package top implements a top-style live view of a Transmission daemon.
+package top
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+)
+
+// Client is the subset of *transmission.Client used by the live view.
+type Client interface {
+	SessionGet(ctx context.Context) (*transmission.Session, error)
+	SessionStats(ctx context.Context) (*transmission.SessionStatsResult, error)
+	FreeSpace(ctx context.Context, args transmission.FreeSpaceArgs) (*transmission.FreeSpaceResult, error)
+	TorrentGet(ctx context.Context, args transmission.TorrentGetArgs) (*transmission.TorrentGetResult, error)
+	TorrentStart(ctx context.Context, ids *transmission.TorrentIDs) error
+	TorrentStop(ctx context.Context, ids *transmission.TorrentIDs) error
+	TorrentVerify(ctx context.Context, ids *transmission.TorrentIDs) error
+	QueueMoveTop(ctx context.Context, ids *transmission.TorrentIDs) error
+	QueueMoveUp(ctx context.Context, ids *transmission.TorrentIDs) error
+	QueueMoveDown(ctx context.Context, ids *transmission.TorrentIDs) error
+	QueueMoveBottom(ctx context.Context, ids *transmission.TorrentIDs) error
+}
+
+const (
+	// fullRefreshEvery is how often, in refreshes, every torrent is fetched.
+	// In between, only recently active torrents are, which is much cheaper
+	// with many idle torrents.
+	fullRefreshEvery = 30
+	// sessionRefreshEvery is how often, in refreshes, session settings and
+	// free space are fetched. They rarely change.
+	sessionRefreshEvery = 15
+)
+
+var listFields = []string{
+	"id", "name", "status", "error", "errorString", "percentDone", "sizeWhenDone",
+	"rateDownload", "rateUpload", "uploadRatio", "eta", "queuePosition",
+}
+
+var detailFields = map[view][]string{
+	viewPeers:    {"id", "name", "peers"},
+	viewTrackers: {"id", "name", "trackerStats"},
+	viewFiles:    {"id", "name", "files", "fileStats"},
+}
+
+type view int
+
+const (
+	viewList view = iota
+	viewPeers
+	viewTrackers
+	viewFiles
+)
+
+func (v view) String() string {
+	return [...]string{"list", "peers", "trackers", "files"}[v]
+}
+
+// sortKey orders torrents, most interesting first.
+type sortKey struct {
+	name    string
+	compare func(a, b transmission.Torrent) int
+}
+
+var sortKeys = []sortKey{
+	{"down rate", func(a, b transmission.Torrent) int { return cmp.Compare(b.RateDownload, a.RateDownload) }},
+	{"up rate", func(a, b transmission.Torrent) int { return cmp.Compare(b.RateUpload, a.RateUpload) }},
+	{"ratio", func(a, b transmission.Torrent) int { return cmp.Compare(b.UploadRatio, a.UploadRatio) }},
+	{"eta", func(a, b transmission.Torrent) int { return cmp.Compare(etaOrder(a.ETA), etaOrder(b.ETA)) }},
+	{"status", func(a, b transmission.Torrent) int { return cmp.Compare(statusOrder(a.Status), statusOrder(b.Status)) }},
+	{"name", func(a, b transmission.Torrent) int { return strings.Compare(a.Name, b.Name) }},
+	{"queue", func(a, b transmission.Torrent) int { return cmp.Compare(a.QueuePosition, b.QueuePosition) }},
+}
+
+// etaOrder sorts unknown ETAs last.
+func etaOrder(eta int64) int64 {
+	if eta < 0 {
+		return 1<<63 - 1
+	}
+	return eta
+}
+
+// statusOrder sorts active torrents before waiting and stopped ones.
+func statusOrder(status transmission.TorrentStatus) int {
+	switch status {
+	case transmission.TorrentStatusDownload:
+		return 0
+	case transmission.TorrentStatusSeed:
+		return 1
+	case transmission.TorrentStatusCheck:
+		return 2
+	case transmission.TorrentStatusDownloadWait, transmission.TorrentStatusSeedWait, transmission.TorrentStatusCheckWait:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// Model is the state of the live view. It isn't safe for concurrent use.
+type Model struct {
+	client Client
+
+	torrents map[int64]transmission.Torrent
+	order    []int64
+	cursor   int
+	selected int64
+	offset   int
+
+	sort    int
+	reverse bool
+
+	view         view
+	detail       *transmission.Torrent
+	detailOffset int
+
+	session   *transmission.Session
+	stats     *transmission.SessionStatsResult
+	freeSpace int64
+
+	refreshes int
+	message   string
+}
+
+// NewModel returns a model showing the torrent list sorted by download rate.
+func NewModel(client Client) *Model {
+	return &Model{client: client, freeSpace: -1}
+}
+
+// Refresh fetches the latest state from Transmission.
+func (m *Model) Refresh(ctx context.Context) error {
+	if err := m.refreshTorrents(ctx); err != nil {
+		return err
+	}
+
+	stats, err := m.client.SessionStats(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting session stats: %w", err)
+	}
+	m.stats = stats
+
+	if m.session == nil || m.refreshes%sessionRefreshEvery == 0 {
+		if err := m.refreshSession(ctx); err != nil {
+			return err
+		}
+	}
+
+	if m.view != viewList {
+		if err := m.refreshDetail(ctx); err != nil {
+			return err
+		}
+	}
+
+	m.refreshes++
+	return nil
+}
+
+func (m *Model) refreshTorrents(ctx context.Context) error {
+	full := m.torrents == nil || m.refreshes%fullRefreshEvery == 0
+	ids := transmission.RecentlyActiveTorrents
+	if full {
+		ids = transmission.AllTorrents
+	}
+
+	result, err := m.client.TorrentGet(ctx, transmission.TorrentGetArgs{IDs: ids, Fields: listFields})
+	if err != nil {
+		return fmt.Errorf("error getting torrents: %w", err)
+	}
+
+	if full {
+		m.torrents = make(map[int64]transmission.Torrent, len(result.Torrents))
+	}
+	for _, torrent := range result.Torrents {
+		m.torrents[torrent.ID] = torrent
+	}
+	for _, id := range result.Removed {
+		delete(m.torrents, id)
+	}
+	m.sortTorrents()
+	return nil
+}
+
+func (m *Model) refreshSession(ctx context.Context) error {
+	session, err := m.client.SessionGet(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting session: %w", err)
+	}
+	m.session = session
+
+	// Free space is only informative, and some daemons don't allow it for
+	// every path, so failing to get it isn't fatal.
+	m.freeSpace = -1
+	if session.DownloadDir != "" {
+		free, err := m.client.FreeSpace(ctx, transmission.FreeSpaceArgs{Path: session.DownloadDir})
+		if err == nil {
+			m.freeSpace = int64(free.SizeBytes)
+		}
+	}
+	return nil
+}
+
+func (m *Model) refreshDetail(ctx context.Context) error {
+	id := m.detail.ID
+	result, err := m.client.TorrentGet(ctx, transmission.TorrentGetArgs{
+		IDs:    transmission.NewTorrentIDs(id),
+		Fields: detailFields[m.view],
+	})
+	if err != nil {
+		return fmt.Errorf("error getting torrent %d: %w", id, err)
+	}
+	if len(result.Torrents) == 0 {
+		m.view = viewList
+		m.detail = nil
+		m.message = fmt.Sprintf("torrent %d was removed", id)
+		return nil
+	}
+	m.detail = &result.Torrents[0]
+	return nil
+}
+
+func (m *Model) sortTorrents() {
+	m.order = m.order[:0]
+	for id := range m.torrents {
+		m.order = append(m.order, id)
+	}
+
+	compare := sortKeys[m.sort].compare
+	slices.SortFunc(m.order, func(a, b int64) int {
+		c := compare(m.torrents[a], m.torrents[b])
+		if m.reverse {
+			c = -c
+		}
+		return cmp.Or(c, cmp.Compare(a, b))
+	})
+
+	// Keep the same torrent selected if it's still there, otherwise the one
+	// that took its place.
+	if i := slices.Index(m.order, m.selected); i >= 0 {
+		m.cursor = i
+	}
+	m.moveCursor(0)
+}
+
+func (m *Model) moveCursor(delta int) {
+	m.cursor = max(0, min(m.cursor+delta, len(m.order)-1))
+	m.selected = 0
+	if len(m.order) > 0 {
+		m.selected = m.order[m.cursor]
+	}
+}
+
+// Selected returns the ID of the selected torrent, or false if there are no
+// torrents.
+func (m *Model) Selected() (int64, bool) {
+	if m.view != viewList {
+		return m.detail.ID, true
+	}
+	return m.selected, len(m.order) > 0
+}
+
+// errQuit is returned by HandleKey when the user asks to quit.
+var errQuit = errors.New("quit")
+
+// HandleKey handles a key press, as returned by parseKeys. Actions on
+// torrents are sent straight away; the result shows on the next refresh.
+func (m *Model) HandleKey(ctx context.Context, key string) error {
+	m.message = ""
+
+	switch key {
+	case "q", "ctrl-c":
+		return errQuit
+	case "up", "k":
+		m.scroll(-1)
+	case "down", "j":
+		m.scroll(1)
+	case "pgup":
+		m.scroll(-10)
+	case "pgdown":
+		m.scroll(10)
+	case "s":
+		m.sort = (m.sort + 1) % len(sortKeys)
+		m.sortTorrents()
+	case "r":
+		m.reverse = !m.reverse
+		m.sortTorrents()
+	case "enter":
+		if m.view == viewList && len(m.order) > 0 {
+			torrent := m.torrents[m.selected]
+			m.detail = &torrent
+			m.view = viewPeers
+			m.detailOffset = 0
+			return m.refreshDetail(ctx)
+		}
+	case "esc":
+		m.view = viewList
+		m.detail = nil
+	case "tab":
+		if m.view != viewList {
+			m.view = m.view%viewFiles + 1
+			m.detailOffset = 0
+			return m.refreshDetail(ctx)
+		}
+	case "S":
+		return m.act(ctx, "started", m.client.TorrentStart)
+	case "p":
+		return m.act(ctx, "stopped", m.client.TorrentStop)
+	case "v":
+		return m.act(ctx, "verifying", m.client.TorrentVerify)
+	case "t":
+		return m.act(ctx, "moved to top of queue", m.client.QueueMoveTop)
+	case "u":
+		return m.act(ctx, "moved up queue", m.client.QueueMoveUp)
+	case "d":
+		return m.act(ctx, "moved down queue", m.client.QueueMoveDown)
+	case "b":
+		return m.act(ctx, "moved to bottom of queue", m.client.QueueMoveBottom)
+	}
+	return nil
+}
+
+func (m *Model) scroll(delta int) {
+	if m.view == viewList {
+		m.moveCursor(delta)
+		return
+	}
+	m.detailOffset = max(0, min(m.detailOffset+delta, m.detailRowCount()-1))
+}
+
+func (m *Model) act(ctx context.Context, done string, action func(context.Context, *transmission.TorrentIDs) error) error {
+	id, ok := m.Selected()
+	if !ok {
+		return nil
+	}
+	if err := action(ctx, transmission.NewTorrentIDs(id)); err != nil {
+		return fmt.Errorf("error changing torrent %d: %w", id, err)
+	}
+	m.message = fmt.Sprintf("torrent %d %s", id, done)
+	// Queue moves change other torrents' positions too, and those torrents
+	// aren't necessarily recently active, so fetch everything next time.
+	m.refreshes = 0
+	return nil
+}
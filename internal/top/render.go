@@ -0,0 +1,265 @@
+package top
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/j-dumbell/go-qbittorrent/internal/humanize"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+)
+
+const (
+	bold    = "\x1b[1m"
+	reverse = "\x1b[7m"
+	reset   = "\x1b[0m"
+)
+
+const help = "q quit  enter details  tab next view  esc back  s sort  r reverse  S start  p stop  v verify  t/u/d/b queue"
+
+// column is a table column. A width of 0 takes the space left over.
+type column struct {
+	header string
+	width  int
+	right  bool
+}
+
+// Render draws the view into at most height lines of width columns.
+func (m *Model) Render(width, height int) []string {
+	lines := m.header(width)
+
+	var columns []column
+	var rows [][]string
+	selected := -1
+	if m.view == viewList {
+		columns, rows = m.listTable()
+		selected = m.cursor
+	} else {
+		lines = append(lines, bold+truncate(m.detailTitle(), width)+reset)
+		columns, rows = m.detailTable()
+	}
+
+	// Leave room for the table header and the message line.
+	visible := max(0, height-len(lines)-2)
+	offset := m.detailOffset
+	if m.view == viewList {
+		m.offset = scrollOffset(m.offset, m.cursor, visible)
+		offset = m.offset
+	}
+
+	lines = append(lines, bold+formatRow(columns, headers(columns), width)+reset)
+	for i := offset; i < len(rows) && i < offset+visible; i++ {
+		line := formatRow(columns, rows[i], width)
+		if i == selected {
+			line = reverse + line + reset
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+
+	message := m.message
+	if message == "" {
+		message = help
+	}
+	lines = append(lines, truncate(message, width))
+	return lines[:min(len(lines), height)]
+}
+
+func (m *Model) header(width int) []string {
+	var first, second strings.Builder
+	first.WriteString("transmission")
+	if m.session != nil {
+		first.WriteString(" " + m.session.Version.String())
+	}
+	if m.stats != nil {
+		fmt.Fprintf(&first, "  down %s%s  up %s%s",
+			humanize.Rate(int64(m.stats.DownloadSpeed)), m.limit(true),
+			humanize.Rate(int64(m.stats.UploadSpeed)), m.limit(false))
+		fmt.Fprintf(&second, "%d torrents, %d active, %d paused",
+			m.stats.TorrentCount, m.stats.ActiveTorrentCount, m.stats.PausedTorrentCount)
+	}
+	if m.freeSpace >= 0 {
+		fmt.Fprintf(&first, "  free %s", humanize.Bytes(m.freeSpace))
+	}
+	direction := ""
+	if m.reverse {
+		direction = " (reversed)"
+	}
+	fmt.Fprintf(&second, "  sort: %s%s", sortKeys[m.sort].name, direction)
+	return []string{truncate(first.String(), width), truncate(second.String(), width), ""}
+}
+
+// limit describes the session's speed limit in one direction.
+func (m *Model) limit(down bool) string {
+	s := m.session
+	if s == nil {
+		return ""
+	}
+	speedBytes := int64(s.Units.SpeedBytes)
+	if speedBytes == 0 {
+		speedBytes = 1000
+	}
+	switch {
+	case s.AltSpeedEnabled && down:
+		return fmt.Sprintf(" (alt limit %s)", humanize.Rate(int64(s.AltSpeedDown)*speedBytes))
+	case s.AltSpeedEnabled:
+		return fmt.Sprintf(" (alt limit %s)", humanize.Rate(int64(s.AltSpeedUp)*speedBytes))
+	case down && s.SpeedLimitDownEnabled:
+		return fmt.Sprintf(" (limit %s)", humanize.Rate(int64(s.SpeedLimitDown)*speedBytes))
+	case !down && s.SpeedLimitUpEnabled:
+		return fmt.Sprintf(" (limit %s)", humanize.Rate(int64(s.SpeedLimitUp)*speedBytes))
+	}
+	return ""
+}
+
+func (m *Model) listTable() ([]column, [][]string) {
+	columns := []column{
+		{"ID", 5, true}, {"NAME", 0, false}, {"STATUS", 13, false}, {"DONE", 6, true},
+		{"SIZE", 10, true}, {"DOWN", 12, true}, {"UP", 12, true}, {"RATIO", 6, true}, {"ETA", 10, true},
+	}
+	rows := make([][]string, 0, len(m.order))
+	for _, id := range m.order {
+		t := m.torrents[id]
+		status := t.Status.String()
+		if t.ErrorString != "" {
+			status = "error"
+		}
+		rows = append(rows, []string{
+			strconv.FormatInt(t.ID, 10), t.Name, status, humanize.Percent(t.PercentDone),
+			humanize.Bytes(t.SizeWhenDone), humanize.Rate(t.RateDownload), humanize.Rate(t.RateUpload),
+			humanize.Ratio(t.UploadRatio), humanize.ETA(t.ETA),
+		})
+	}
+	return columns, rows
+}
+
+func (m *Model) detailTitle() string {
+	var tabs []string
+	for v := viewPeers; v <= viewFiles; v++ {
+		if v == m.view {
+			tabs = append(tabs, "["+v.String()+"]")
+		} else {
+			tabs = append(tabs, v.String())
+		}
+	}
+	return fmt.Sprintf("%d %s  %s", m.detail.ID, m.detail.Name, strings.Join(tabs, " "))
+}
+
+func (m *Model) detailRowCount() int {
+	_, rows := m.detailTable()
+	return len(rows)
+}
+
+func (m *Model) detailTable() ([]column, [][]string) {
+	var rows [][]string
+	switch m.view {
+	case viewPeers:
+		for _, p := range m.detail.Peers {
+			rows = append(rows, []string{
+				p.Address, p.ClientName, p.FlagStr, humanize.Percent(p.Progress),
+				humanize.Rate(p.RateToClient), humanize.Rate(p.RateToPeer),
+			})
+		}
+		return []column{
+			{"ADDRESS", 24, false}, {"CLIENT", 0, false}, {"FLAGS", 10, false},
+			{"DONE", 6, true}, {"DOWN", 12, true}, {"UP", 12, true},
+		}, rows
+	case viewTrackers:
+		for _, ts := range m.detail.TrackerStats {
+			rows = append(rows, []string{
+				strconv.FormatInt(ts.Tier, 10), ts.Host, ts.AnnounceState.String(),
+				strconv.FormatInt(ts.SeederCount, 10), strconv.FormatInt(ts.LeecherCount, 10),
+				humanize.Time(ts.LastAnnounceTime), ts.LastAnnounceResult,
+			})
+		}
+		return []column{
+			{"TIER", 4, true}, {"HOST", 30, false}, {"STATE", 8, false}, {"SEEDS", 6, true},
+			{"PEERS", 6, true}, {"LAST ANNOUNCE", 25, false}, {"RESULT", 0, false},
+		}, rows
+	default:
+		for i, f := range m.detail.Files {
+			var stat transmission.TorrentFileStat
+			if i < len(m.detail.FileStats) {
+				stat = m.detail.FileStats[i]
+			}
+			done := 0.0
+			if f.Length > 0 {
+				done = float64(f.BytesCompleted) / float64(f.Length)
+			}
+			wanted := "no"
+			if stat.Wanted {
+				wanted = "yes"
+			}
+			rows = append(rows, []string{
+				strconv.Itoa(i), f.Name, humanize.Percent(done), humanize.Bytes(f.Length),
+				stat.Priority.String(), wanted,
+			})
+		}
+		return []column{
+			{"#", 4, true}, {"NAME", 0, false}, {"DONE", 6, true}, {"SIZE", 10, true},
+			{"PRIORITY", 8, false}, {"WANTED", 6, false},
+		}, rows
+	}
+}
+
+// scrollOffset returns the first row to show so that cursor is visible.
+func scrollOffset(offset, cursor, visible int) int {
+	switch {
+	case visible <= 0:
+		return 0
+	case cursor < offset:
+		return cursor
+	case cursor >= offset+visible:
+		return cursor - visible + 1
+	}
+	return offset
+}
+
+func headers(columns []column) []string {
+	result := make([]string, len(columns))
+	for i, c := range columns {
+		result[i] = c.header
+	}
+	return result
+}
+
+// formatRow lays out cells in columns separated by a space, giving the
+// flexible column whatever width is left.
+func formatRow(columns []column, cells []string, width int) string {
+	fixed := len(columns) - 1
+	for _, c := range columns {
+		fixed += c.width
+	}
+	flexible := max(8, width-fixed)
+
+	parts := make([]string, len(columns))
+	for i, c := range columns {
+		w := c.width
+		if w == 0 {
+			w = flexible
+		}
+		cell := truncate(cells[i], w)
+		padding := strings.Repeat(" ", w-utf8.RuneCountInString(cell))
+		if c.right {
+			parts[i] = padding + cell
+		} else {
+			parts[i] = cell + padding
+		}
+	}
+	return truncate(strings.Join(parts, " "), width)
+}
+
+// truncate shortens s to at most width runes, marking the cut with "~".
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	if width <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	return string(runes[:width-1]) + "~"
+}
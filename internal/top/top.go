@@ -0,0 +1,172 @@
+package top
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	enterAltScreen = "\x1b[?1049h\x1b[?25l"
+	exitAltScreen  = "\x1b[?25h\x1b[?1049l"
+	home           = "\x1b[H"
+	clearLine      = "\x1b[K"
+	clearBelow     = "\x1b[J"
+)
+
+// Run shows the live view on a terminal until the user quits or ctx is
+// done, refreshing every interval.
+func Run(ctx context.Context, client Client, in, out *os.File, interval time.Duration) error {
+	if !term.IsTerminal(int(in.Fd())) || !term.IsTerminal(int(out.Fd())) {
+		return errors.New("top needs a terminal")
+	}
+
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return fmt.Errorf("error setting up terminal: %w", err)
+	}
+	defer term.Restore(int(in.Fd()), state)
+	fmt.Fprint(out, enterAltScreen)
+	defer fmt.Fprint(out, exitAltScreen)
+
+	// The reader is left blocked on stdin when the view exits, which is fine
+	// as the process is about to exit too.
+	keys := make(chan string)
+	go readKeys(in, keys)
+
+	m := NewModel(client)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	refresh := func() {
+		if err := m.Refresh(ctx); err != nil {
+			m.message = err.Error()
+		}
+	}
+	refresh()
+	for {
+		if err := draw(out, m); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			refresh()
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			err := m.HandleKey(ctx, key)
+			switch {
+			case errors.Is(err, errQuit):
+				return nil
+			case err != nil:
+				m.message = err.Error()
+			}
+		}
+	}
+}
+
+func draw(out *os.File, m *Model) error {
+	width, height, err := term.GetSize(int(out.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+	lines := m.Render(width, height)
+
+	var b strings.Builder
+	b.WriteString(home)
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line + clearLine)
+	}
+	b.WriteString(clearBelow)
+	_, err = io.WriteString(out, b.String())
+	return err
+}
+
+// readKeys sends key presses read from r until it fails.
+func readKeys(r io.Reader, keys chan<- string) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		for _, key := range parseKeys(buf[:n]) {
+			keys <- key
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+var escapeSequences = map[string]string{
+	"\x1b[A":  "up",
+	"\x1b[B":  "down",
+	"\x1bOA":  "up",
+	"\x1bOB":  "down",
+	"\x1b[5~": "pgup",
+	"\x1b[6~": "pgdown",
+}
+
+// parseKeys splits raw terminal input into key names. Printable characters
+// are their own names.
+func parseKeys(data []byte) []string {
+	var keys []string
+	for s := string(data); s != ""; {
+		if s[0] == '\x1b' {
+			key, rest := parseEscape(s)
+			if key != "" {
+				keys = append(keys, key)
+			}
+			s = rest
+			continue
+		}
+
+		switch s[0] {
+		case '\r', '\n':
+			keys = append(keys, "enter")
+		case '\t':
+			keys = append(keys, "tab")
+		case 3:
+			keys = append(keys, "ctrl-c")
+		default:
+			if s[0] >= ' ' && s[0] < 0x7f {
+				keys = append(keys, s[:1])
+			}
+		}
+		s = s[1:]
+	}
+	return keys
+}
+
+// parseEscape parses an escape sequence at the start of s. A lone escape is
+// the escape key; unknown sequences are skipped.
+func parseEscape(s string) (key, rest string) {
+	for seq, name := range escapeSequences {
+		if strings.HasPrefix(s, seq) {
+			return name, s[len(seq):]
+		}
+	}
+	if len(s) == 1 || (s[1] != '[' && s[1] != 'O') {
+		return "esc", s[1:]
+	}
+
+	// Skip a CSI sequence up to its final byte.
+	for i := 2; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return "", s[i+1:]
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,212 @@
+package top
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClient struct {
+	torrents map[int64]transmission.Torrent
+	removed  []int64
+	gets     []string
+	actions  []string
+}
+
+func (f *fakeClient) SessionGet(context.Context) (*transmission.Session, error) {
+	return &transmission.Session{DownloadDir: "/downloads", SpeedLimitDown: 500, SpeedLimitDownEnabled: true,
+		Units: transmission.Units{SpeedBytes: 1024}}, nil
+}
+
+func (f *fakeClient) SessionStats(context.Context) (*transmission.SessionStatsResult, error) {
+	return &transmission.SessionStatsResult{TorrentCount: len(f.torrents), DownloadSpeed: 2048}, nil
+}
+
+func (f *fakeClient) FreeSpace(context.Context, transmission.FreeSpaceArgs) (*transmission.FreeSpaceResult, error) {
+	return &transmission.FreeSpaceResult{SizeBytes: 1 << 30}, nil
+}
+
+func (f *fakeClient) TorrentGet(_ context.Context, args transmission.TorrentGetArgs) (*transmission.TorrentGetResult, error) {
+	ids, err := json.Marshal(args.IDs)
+	if err != nil {
+		return nil, err
+	}
+	f.gets = append(f.gets, string(ids))
+
+	result := &transmission.TorrentGetResult{}
+	switch string(ids) {
+	case "null":
+		for _, t := range f.torrents {
+			result.Torrents = append(result.Torrents, t)
+		}
+	case `"recently-active"`:
+		for _, t := range f.torrents {
+			if t.RateDownload > 0 {
+				result.Torrents = append(result.Torrents, t)
+			}
+		}
+		result.Removed = f.removed
+	default:
+		var selected []int64
+		if err := json.Unmarshal(ids, &selected); err != nil {
+			return nil, err
+		}
+		if t, ok := f.torrents[selected[0]]; ok {
+			result.Torrents = append(result.Torrents, t)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeClient) action(name string) func(context.Context, *transmission.TorrentIDs) error {
+	return func(_ context.Context, ids *transmission.TorrentIDs) error {
+		data, _ := json.Marshal(ids)
+		f.actions = append(f.actions, name+" "+string(data))
+		return nil
+	}
+}
+
+func (f *fakeClient) TorrentStart(ctx context.Context, ids *transmission.TorrentIDs) error {
+	return f.action("start")(ctx, ids)
+}
+
+func (f *fakeClient) TorrentStop(ctx context.Context, ids *transmission.TorrentIDs) error {
+	return f.action("stop")(ctx, ids)
+}
+
+func (f *fakeClient) TorrentVerify(ctx context.Context, ids *transmission.TorrentIDs) error {
+	return f.action("verify")(ctx, ids)
+}
+
+func (f *fakeClient) QueueMoveTop(ctx context.Context, ids *transmission.TorrentIDs) error {
+	return f.action("top")(ctx, ids)
+}
+
+func (f *fakeClient) QueueMoveUp(ctx context.Context, ids *transmission.TorrentIDs) error {
+	return f.action("up")(ctx, ids)
+}
+
+func (f *fakeClient) QueueMoveDown(ctx context.Context, ids *transmission.TorrentIDs) error {
+	return f.action("down")(ctx, ids)
+}
+
+func (f *fakeClient) QueueMoveBottom(ctx context.Context, ids *transmission.TorrentIDs) error {
+	return f.action("bottom")(ctx, ids)
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{torrents: map[int64]transmission.Torrent{
+		1: {ID: 1, Name: "slow", RateDownload: 100, UploadRatio: 3, ETA: 60},
+		2: {ID: 2, Name: "fast", RateDownload: 5000, UploadRatio: 1, ETA: -1},
+		3: {ID: 3, Name: "idle", UploadRatio: 2, ETA: 10, Status: transmission.TorrentStatusStopped,
+			Peers: []transmission.Peer{{Address: "10.0.0.1", ClientName: "qBittorrent"}}},
+	}}
+}
+
+func TestRefresh(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+	m := NewModel(client)
+
+	require.NoError(t, m.Refresh(ctx))
+	assert.Equal(t, []int64{2, 1, 3}, m.order, "sorted by download rate")
+	assert.Equal(t, int64(1<<30), m.freeSpace)
+
+	// Later refreshes only fetch recently active torrents, keeping the rest.
+	client.removed = []int64{1}
+	delete(client.torrents, 1)
+	require.NoError(t, m.Refresh(ctx))
+	assert.Equal(t, []string{"null", `"recently-active"`}, client.gets)
+	assert.Equal(t, []int64{2, 3}, m.order)
+}
+
+func TestHandleKey(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("sorting keeps the selection", func(t *testing.T) {
+		m := NewModel(newFakeClient())
+		require.NoError(t, m.Refresh(ctx))
+		require.NoError(t, m.HandleKey(ctx, "down"))
+		assert.Equal(t, int64(1), m.selected)
+
+		require.NoError(t, m.HandleKey(ctx, "s"))
+		require.NoError(t, m.HandleKey(ctx, "s"))
+		assert.Equal(t, "ratio", sortKeys[m.sort].name)
+		assert.Equal(t, []int64{1, 3, 2}, m.order)
+		assert.Equal(t, 0, m.cursor)
+
+		require.NoError(t, m.HandleKey(ctx, "s"))
+		assert.Equal(t, []int64{3, 1, 2}, m.order, "unknown ETAs last")
+
+		require.NoError(t, m.HandleKey(ctx, "r"))
+		assert.Equal(t, []int64{2, 1, 3}, m.order)
+	})
+
+	t.Run("actions apply to the selected torrent", func(t *testing.T) {
+		client := newFakeClient()
+		m := NewModel(client)
+		require.NoError(t, m.Refresh(ctx))
+
+		for _, key := range []string{"S", "p", "v", "t", "u", "d", "b"} {
+			require.NoError(t, m.HandleKey(ctx, key))
+		}
+		assert.Equal(t, []string{"start [2]", "stop [2]", "verify [2]", "top [2]", "up [2]", "down [2]", "bottom [2]"}, client.actions)
+		assert.Equal(t, "torrent 2 moved to bottom of queue", m.message)
+		assert.ErrorIs(t, m.HandleKey(ctx, "q"), errQuit)
+	})
+
+	t.Run("drill down", func(t *testing.T) {
+		m := NewModel(newFakeClient())
+		require.NoError(t, m.Refresh(ctx))
+		require.NoError(t, m.HandleKey(ctx, "j"))
+		require.NoError(t, m.HandleKey(ctx, "j"))
+		require.NoError(t, m.HandleKey(ctx, "enter"))
+		assert.Equal(t, viewPeers, m.view)
+		assert.Equal(t, "10.0.0.1", m.detail.Peers[0].Address)
+
+		require.NoError(t, m.HandleKey(ctx, "tab"))
+		assert.Equal(t, viewTrackers, m.view)
+		require.NoError(t, m.HandleKey(ctx, "esc"))
+		assert.Equal(t, viewList, m.view)
+	})
+}
+
+func TestRender(t *testing.T) {
+	ctx := context.Background()
+	m := NewModel(newFakeClient())
+	require.NoError(t, m.Refresh(ctx))
+
+	lines := m.Render(120, 10)
+	require.Len(t, lines, 10)
+	assert.Contains(t, lines[0], "down 2.0 KiB/s (limit 500.0 KiB/s)")
+	assert.Contains(t, lines[0], "free 1.0 GiB")
+	assert.Contains(t, lines[1], "3 torrents")
+	assert.Contains(t, lines[3], "NAME")
+	assert.True(t, strings.HasPrefix(lines[4], reverse), "selected row is highlighted")
+	assert.Contains(t, lines[4], "fast")
+	assert.Equal(t, help, lines[9])
+
+	// Rows scroll to keep the selection visible.
+	require.NoError(t, m.HandleKey(ctx, "down"))
+	require.NoError(t, m.HandleKey(ctx, "down"))
+	lines = m.Render(100, 6)
+	assert.Contains(t, lines[4], "idle")
+
+	require.NoError(t, m.HandleKey(ctx, "enter"))
+	lines = m.Render(100, 10)
+	assert.Contains(t, lines[3], "3 idle  [peers] trackers files")
+	assert.Contains(t, lines[5], "qBittorrent")
+}
+
+func TestParseKeys(t *testing.T) {
+	assert.Equal(t,
+		[]string{"j", "up", "down", "enter", "tab", "esc", "pgdown", "ctrl-c", "q"},
+		parseKeys([]byte("j\x1b[A\x1bOB\r\t\x1b\x1b[6~\x03\x1b[1;5Cq")),
+	)
+	assert.Equal(t, []string{"esc"}, parseKeys([]byte("\x1b")))
+}
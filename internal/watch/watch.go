@@ -0,0 +1,288 @@
+// Package watch adds .torrent and .magnet files dropped into local
+// directories to Transmission.
+//
+// Unlike Transmission's own watch directory, the directories only need to be
+// visible to the watcher, not to the daemon, and each can apply its own
+// download directory and labels. Directories are polled rather than watched
+// with inotify, which doesn't see changes made through bind or network
+// mounts from other hosts and containers.
+package watch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/internal/daemon"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission/magnet"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission/metainfo"
+)
+
+const (
+	defaultInterval    = 5 * time.Second
+	defaultSettle      = 2 * time.Second
+	defaultMaxAttempts = 5
+)
+
+// Config is the watcher's config file.
+type Config struct {
+	// Interval is how often directories are scanned. Defaults to 5s.
+	Interval daemon.Duration `json:"interval"`
+	// Settle is how long a file must go unmodified before it's added, so
+	// that files still being written aren't picked up. Defaults to 2s.
+	Settle daemon.Duration `json:"settle"`
+	// MaxAttempts is how many times adding a file is tried before it's moved
+	// to the failed directory. Defaults to 5.
+	MaxAttempts int `json:"maxAttempts"`
+
+	Dirs []Dir `json:"dirs"`
+}
+
+// Dir is a watched directory and the options for torrents added from it.
+type Dir struct {
+	Path        string   `json:"path"`
+	DownloadDir string   `json:"downloadDir"`
+	Labels      []string `json:"labels"`
+	// Paused, if set, overrides the daemon's start-added-torrents setting.
+	Paused *bool `json:"paused"`
+
+	// DoneDir and FailedDir are where processed files are moved. They
+	// default to "done" and "failed" inside Path.
+	DoneDir   string `json:"doneDir"`
+	FailedDir string `json:"failedDir"`
+}
+
+// LoadConfig reads and validates the config file at path.
+func LoadConfig(path string) (*Config, error) {
+	var config Config
+	if err := daemon.LoadConfig(path, &config); err != nil {
+		return nil, err
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+// validate checks the config and fills in defaults.
+func (c *Config) validate() error {
+	if len(c.Dirs) == 0 {
+		return errors.New("no dirs to watch")
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaultMaxAttempts
+	}
+
+	seen := make(map[string]bool)
+	for i := range c.Dirs {
+		dir := &c.Dirs[i]
+		if dir.Path == "" {
+			return fmt.Errorf("dirs[%d]: path is required", i)
+		}
+		dir.Path = filepath.Clean(dir.Path)
+		if seen[dir.Path] {
+			return fmt.Errorf("dirs[%d]: %s is watched twice", i, dir.Path)
+		}
+		seen[dir.Path] = true
+
+		if dir.DoneDir == "" {
+			dir.DoneDir = filepath.Join(dir.Path, "done")
+		}
+		if dir.FailedDir == "" {
+			dir.FailedDir = filepath.Join(dir.Path, "failed")
+		}
+	}
+	return nil
+}
+
+// Client is the subset of *transmission.Client used by the watcher.
+type Client interface {
+	TorrentAddReader(ctx context.Context, r io.Reader, opts transmission.AddOptions) (*transmission.AddResult, error)
+	TorrentAddMagnet(ctx context.Context, uri string, opts transmission.AddOptions) (*transmission.AddResult, error)
+}
+
+// Watcher adds torrent files from the configured directories.
+type Watcher struct {
+	client Client
+	config Config
+	logger *slog.Logger
+	now    func() time.Time
+
+	// attempts counts failed attempts to add each file.
+	attempts map[string]int
+	// dryRun holds the files added in dry run mode, which are left in place
+	// and so would otherwise be added again on every scan.
+	dryRun map[string]bool
+}
+
+// New returns a watcher for config, which must have been validated by
+// LoadConfig.
+func New(client Client, config Config, logger *slog.Logger) *Watcher {
+	return &Watcher{
+		client:   client,
+		config:   config,
+		logger:   logger,
+		now:      time.Now,
+		attempts: make(map[string]int),
+		dryRun:   make(map[string]bool),
+	}
+}
+
+// Run scans the directories until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	for _, dir := range w.config.Dirs {
+		w.logger.Info("watching directory", "path", dir.Path, "downloadDir", dir.DownloadDir, "labels", dir.Labels)
+	}
+	daemon.Every(ctx, w.config.Interval.Or(defaultInterval), w.Scan)
+}
+
+// Scan adds the files currently in the directories.
+func (w *Watcher) Scan(ctx context.Context) {
+	for _, dir := range w.config.Dirs {
+		entries, err := os.ReadDir(dir.Path)
+		if err != nil {
+			w.logger.Error("error reading watched directory", "path", dir.Path, "err", err)
+			continue
+		}
+		for _, entry := range entries {
+			if ctx.Err() != nil {
+				return
+			}
+			if w.ready(dir, entry) {
+				w.process(ctx, dir, filepath.Join(dir.Path, entry.Name()))
+			}
+		}
+	}
+}
+
+// ready reports whether entry is a torrent file that has stopped changing.
+// Hidden files are skipped as they're usually partial uploads.
+func (w *Watcher) ready(dir Dir, entry fs.DirEntry) bool {
+	name := entry.Name()
+	if !entry.Type().IsRegular() || strings.HasPrefix(name, ".") {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".torrent", ".magnet":
+	default:
+		return false
+	}
+	if w.dryRun[filepath.Join(dir.Path, name)] {
+		return false
+	}
+
+	info, err := entry.Info()
+	if err != nil {
+		// Most likely removed since the directory was read.
+		return false
+	}
+	return w.now().Sub(info.ModTime()) >= w.config.Settle.Or(defaultSettle)
+}
+
+// errInvalid marks files that can never be added, which are moved to the
+// failed directory without retrying.
+var errInvalid = errors.New("invalid file")
+
+func (w *Watcher) process(ctx context.Context, dir Dir, path string) {
+	logger := w.logger.With("file", path)
+	opts := transmission.AddOptions{
+		Labels:      dir.Labels,
+		DownloadDir: dir.DownloadDir,
+		Paused:      dir.Paused,
+	}
+
+	result, err := w.add(ctx, path, opts)
+	switch {
+	case errors.Is(err, errInvalid):
+		logger.Error("invalid torrent file", "err", err)
+		w.finish(logger, path, dir.FailedDir, err)
+		return
+	case err != nil:
+		w.attempts[path]++
+		attempts := w.attempts[path]
+		if attempts < w.config.MaxAttempts {
+			logger.Warn("error adding torrent, will retry", "attempt", attempts, "err", err)
+			return
+		}
+		logger.Error("error adding torrent, giving up", "attempts", attempts, "err", err)
+		w.finish(logger, path, dir.FailedDir, err)
+		return
+	}
+
+	logger = logger.With("id", result.Torrent.ID, "name", result.Torrent.Name, "hash", result.Torrent.HashString)
+	switch {
+	case result.DryRun:
+		logger.Info("torrent would be added, leaving file in place in dry run")
+		w.dryRun[path] = true
+		return
+	case result.Duplicate:
+		logger.Info("torrent already added")
+	default:
+		logger.Info("torrent added")
+	}
+	w.finish(logger, path, dir.DoneDir, nil)
+}
+
+func (w *Watcher) add(ctx context.Context, path string, opts transmission.AddOptions) (*transmission.AddResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".magnet") {
+		uri := strings.TrimSpace(string(data))
+		if _, err := magnet.Parse(uri); err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalid, err)
+		}
+		return w.client.TorrentAddMagnet(ctx, uri, opts)
+	}
+
+	if _, err := metainfo.Parse(data); err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalid, err)
+	}
+	return w.client.TorrentAddReader(ctx, bytes.NewReader(data), opts)
+}
+
+// finish moves a processed file to dest. For failures, the error is written
+// next to it in a file with an .error suffix.
+func (w *Watcher) finish(logger *slog.Logger, path, dest string, failure error) {
+	delete(w.attempts, path)
+
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		logger.Error("error creating directory for processed files", "dir", dest, "err", err)
+		return
+	}
+	target := uniquePath(filepath.Join(dest, filepath.Base(path)))
+	if err := os.Rename(path, target); err != nil {
+		logger.Error("error moving processed file", "to", target, "err", err)
+		return
+	}
+	if failure != nil {
+		if err := os.WriteFile(target+".error", []byte(failure.Error()+"\n"), 0o644); err != nil {
+			logger.Error("error writing failure reason", "err", err)
+		}
+	}
+}
+
+// uniquePath returns path, or if it exists, path with a numeric suffix
+// before the extension that doesn't.
+func uniquePath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(path); errors.Is(err, fs.ErrNotExist) {
+			return path
+		}
+		path = base + "." + strconv.Itoa(i) + ext
+	}
+}
@@ -0,0 +1,167 @@
+package watch
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission/bencode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMagnet = "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&dn=Show"
+
+type addCall struct {
+	Source string
+	Opts   transmission.AddOptions
+}
+
+type fakeClient struct {
+	calls  []addCall
+	result func(source string) (*transmission.AddResult, error)
+}
+
+func (f *fakeClient) TorrentAddReader(_ context.Context, r io.Reader, opts transmission.AddOptions) (*transmission.AddResult, error) {
+	if _, err := io.ReadAll(r); err != nil {
+		return nil, err
+	}
+	f.calls = append(f.calls, addCall{Source: "metainfo", Opts: opts})
+	return f.result("metainfo")
+}
+
+func (f *fakeClient) TorrentAddMagnet(_ context.Context, uri string, opts transmission.AddOptions) (*transmission.AddResult, error) {
+	f.calls = append(f.calls, addCall{Source: uri, Opts: opts})
+	return f.result(uri)
+}
+
+func testTorrentFile(t *testing.T) []byte {
+	t.Helper()
+	data, err := bencode.Encode(map[string]any{
+		"info": map[string]any{
+			"name":         "Movie",
+			"piece length": 16384,
+			"pieces":       make([]byte, sha1.Size),
+			"length":       100,
+		},
+	})
+	require.NoError(t, err)
+	return data
+}
+
+func newTestWatcher(t *testing.T, client Client, dir Dir) (*Watcher, Dir) {
+	t.Helper()
+	config := Config{Dirs: []Dir{dir}}
+	require.NoError(t, config.validate())
+	w := New(client, config, slog.New(slog.DiscardHandler))
+	// Files written by the test are old enough to be picked up straight away.
+	w.now = func() time.Time { return time.Now().Add(time.Minute) }
+	return w, config.Dirs[0]
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+}
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+	paused := true
+	client := &fakeClient{result: func(source string) (*transmission.AddResult, error) {
+		if source == testMagnet {
+			return &transmission.AddResult{Torrent: transmission.TorrentInfo{ID: 2}, Duplicate: true}, nil
+		}
+		return &transmission.AddResult{Torrent: transmission.TorrentInfo{ID: 1}}, nil
+	}}
+	w, dir := newTestWatcher(t, client, Dir{Path: root, DownloadDir: "/data/movies", Labels: []string{"movies"}, Paused: &paused})
+
+	writeFile(t, filepath.Join(root, "movie.torrent"), testTorrentFile(t))
+	writeFile(t, filepath.Join(root, "show.magnet"), []byte(testMagnet+"\n"))
+	writeFile(t, filepath.Join(root, "broken.torrent"), []byte("<html>"))
+	writeFile(t, filepath.Join(root, "notes.txt"), []byte("ignored"))
+	writeFile(t, filepath.Join(root, ".partial.torrent"), []byte("ignored"))
+	// A file with the same name was processed before.
+	require.NoError(t, os.MkdirAll(dir.DoneDir, 0o755))
+	writeFile(t, filepath.Join(dir.DoneDir, "movie.torrent"), []byte("old"))
+
+	w.Scan(context.Background())
+
+	require.Len(t, client.calls, 2)
+	assert.Equal(t, "metainfo", client.calls[0].Source)
+	assert.Equal(t, testMagnet, client.calls[1].Source)
+	for _, call := range client.calls {
+		assert.Equal(t, transmission.AddOptions{DownloadDir: "/data/movies", Labels: []string{"movies"}, Paused: &paused}, call.Opts)
+	}
+
+	assert.FileExists(t, filepath.Join(dir.DoneDir, "movie.1.torrent"))
+	assert.FileExists(t, filepath.Join(dir.DoneDir, "show.magnet"), "duplicates count as done")
+	assert.FileExists(t, filepath.Join(dir.FailedDir, "broken.torrent"))
+	assert.FileExists(t, filepath.Join(dir.FailedDir, "broken.torrent.error"))
+	assert.FileExists(t, filepath.Join(root, "notes.txt"))
+	assert.FileExists(t, filepath.Join(root, ".partial.torrent"))
+	assert.NoFileExists(t, filepath.Join(root, "movie.torrent"))
+}
+
+func TestScanRetries(t *testing.T) {
+	root := t.TempDir()
+	client := &fakeClient{result: func(string) (*transmission.AddResult, error) {
+		return nil, errors.New("connection refused")
+	}}
+	w, dir := newTestWatcher(t, client, Dir{Path: root})
+	path := filepath.Join(root, "show.magnet")
+	writeFile(t, path, []byte(testMagnet))
+
+	for range defaultMaxAttempts - 1 {
+		w.Scan(context.Background())
+		assert.FileExists(t, path, "transient errors are retried")
+	}
+	w.Scan(context.Background())
+	assert.Len(t, client.calls, defaultMaxAttempts)
+	assert.FileExists(t, filepath.Join(dir.FailedDir, "show.magnet"))
+
+	errorMessage, err := os.ReadFile(filepath.Join(dir.FailedDir, "show.magnet.error"))
+	require.NoError(t, err)
+	assert.Equal(t, "connection refused\n", string(errorMessage))
+}
+
+func TestScanSettleAndDryRun(t *testing.T) {
+	root := t.TempDir()
+	client := &fakeClient{result: func(string) (*transmission.AddResult, error) {
+		return &transmission.AddResult{DryRun: true}, nil
+	}}
+	w, _ := newTestWatcher(t, client, Dir{Path: root})
+	path := filepath.Join(root, "show.magnet")
+	writeFile(t, path, []byte(testMagnet))
+
+	w.now = time.Now
+	w.Scan(context.Background())
+	assert.Empty(t, client.calls, "files still being written are skipped")
+
+	w.now = func() time.Time { return time.Now().Add(time.Minute) }
+	w.Scan(context.Background())
+	w.Scan(context.Background())
+	assert.Len(t, client.calls, 1, "dry run files are only added once")
+	assert.FileExists(t, path)
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watch.json")
+	writeFile(t, path, []byte(`{"interval": "10s", "dirs": [{"path": "/watch/movies/", "labels": ["movies"]}]}`))
+
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, config.Interval.Or(0))
+	assert.Equal(t, defaultMaxAttempts, config.MaxAttempts)
+	assert.Equal(t, Dir{Path: "/watch/movies", Labels: []string{"movies"}, DoneDir: "/watch/movies/done", FailedDir: "/watch/movies/failed"}, config.Dirs[0])
+
+	writeFile(t, path, []byte(`{"dirs": [{"path": "/a"}, {"path": "/a/"}]}`))
+	_, err = LoadConfig(path)
+	assert.ErrorContains(t, err, "watched twice")
+}
@@ -0,0 +1,280 @@
+package transmission
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission/magnet"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission/metainfo"
+)
+
+// AddOptions are the options accepted by the TorrentAdd* helpers. Zero values
+// leave Transmission's defaults in place. Options are ignored if the torrent
+// is a duplicate of one Transmission already has.
+type AddOptions struct {
+	Labels            []string
+	DownloadDir       string
+	Paused            *bool
+	BandwidthPriority *Priority
+
+	// FilesWanted, if not empty, downloads only files matching at least one
+	// of these patterns.
+	FilesWanted []string
+	// FilesUnwanted skips files matching any of these patterns, even if they
+	// also match FilesWanted.
+	//
+	// Patterns without a slash match file names at any depth, e.g. "*.nfo".
+	// Patterns with a slash match paths relative to the torrent's root
+	// directory, and "**" matches any number of directories, e.g.
+	// "Sample/**".
+	FilesUnwanted []string
+}
+
+func (o AddOptions) hasFileSelection() bool {
+	return len(o.FilesWanted) > 0 || len(o.FilesUnwanted) > 0
+}
+
+func (o AddOptions) args() TorrentAddArgs {
+	args := TorrentAddArgs{
+		Labels:            o.Labels,
+		Paused:            o.Paused,
+		BandwidthPriority: o.BandwidthPriority,
+	}
+	if o.DownloadDir != "" {
+		args.DownloadDir = &o.DownloadDir
+	}
+	return args
+}
+
+// selectFiles returns the indices of names that are wanted and unwanted
+// according to the options' file patterns.
+func (o AddOptions) selectFiles(names []string) (wanted, unwanted []int) {
+	for i, name := range names {
+		isWanted := len(o.FilesWanted) == 0 || matchAnyFileGlob(o.FilesWanted, name)
+		if matchAnyFileGlob(o.FilesUnwanted, name) {
+			isWanted = false
+		}
+
+		if isWanted {
+			wanted = append(wanted, i)
+		} else {
+			unwanted = append(unwanted, i)
+		}
+	}
+	return wanted, unwanted
+}
+
+// AddResult is the outcome of one of the TorrentAdd* helpers.
+type AddResult struct {
+	Torrent TorrentInfo
+
+	// Duplicate is true if Transmission already had the torrent, in which
+	// case it was left unchanged.
+	Duplicate bool
+
+	// FileSelectionPending is true if file patterns were given but the
+	// torrent's file list isn't known yet, e.g. for a magnet link whose
+	// metadata hasn't been fetched. Use TorrentSet once it is.
+	FileSelectionPending bool
+
+	// DryRun is true if the client is in dry run mode, so the torrent wasn't
+	// added. Torrent only has the name and hash when they are known locally.
+	DryRun bool
+}
+
+func newAddResult(result *TorrentAddResult) (*AddResult, error) {
+	switch {
+	case result.TorrentAdded != nil:
+		return &AddResult{Torrent: *result.TorrentAdded}, nil
+	case result.TorrentDuplicated != nil:
+		return &AddResult{Torrent: *result.TorrentDuplicated, Duplicate: true}, nil
+	default:
+		return nil, fmt.Errorf("torrent-add returned neither an added nor a duplicate torrent")
+	}
+}
+
+// TorrentAddReader adds the .torrent file read from r. The file is parsed
+// before being uploaded, so invalid files are rejected without a round trip
+// to Transmission.
+func (c *Client) TorrentAddReader(ctx context.Context, r io.Reader, opts AddOptions) (*AddResult, error) {
+	m, err := metainfo.ParseReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return c.torrentAddMetainfo(ctx, m, opts)
+}
+
+// TorrentAddFile adds the .torrent file at path.
+func (c *Client) TorrentAddFile(ctx context.Context, path string, opts AddOptions) (*AddResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening torrent file: %w", err)
+	}
+	defer f.Close()
+
+	return c.TorrentAddReader(ctx, f, opts)
+}
+
+func (c *Client) torrentAddMetainfo(ctx context.Context, m *metainfo.Metainfo, opts AddOptions) (*AddResult, error) {
+	if err := validateAddOptions(opts); err != nil {
+		return nil, err
+	}
+
+	args := opts.args()
+	metaInfo := m.Base64()
+	args.MetaInfo = &metaInfo
+
+	if opts.hasFileSelection() {
+		var names []string
+		for _, file := range m.Files() {
+			names = append(names, strings.Join(file.Path, "/"))
+		}
+		wanted, unwanted := opts.selectFiles(names)
+		args.FilesWanted = toInt64s(wanted)
+		args.FilesUnwanted = toInt64s(unwanted)
+	}
+
+	result, err := c.TorrentAdd(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	if c.dryRun {
+		return &AddResult{Torrent: TorrentInfo{Name: m.Info.Name, HashString: m.HashString()}, DryRun: true}, nil
+	}
+	return newAddResult(result)
+}
+
+// TorrentAddMagnet adds a magnet link. Because the torrent's file list is
+// only known once Transmission has fetched its metadata, file patterns are
+// applied after adding if possible; otherwise the result reports
+// FileSelectionPending.
+func (c *Client) TorrentAddMagnet(ctx context.Context, uri string, opts AddOptions) (*AddResult, error) {
+	if _, err := magnet.Parse(uri); err != nil {
+		return nil, err
+	}
+	return c.torrentAddFilename(ctx, uri, opts)
+}
+
+// TorrentAddURL adds the .torrent file at url, which Transmission downloads
+// itself. File patterns are applied after adding.
+func (c *Client) TorrentAddURL(ctx context.Context, url string, opts AddOptions) (*AddResult, error) {
+	return c.torrentAddFilename(ctx, url, opts)
+}
+
+// torrentAddFilename adds a magnet link or URL. With file patterns, the
+// torrent is added paused so that unwanted files don't start downloading
+// before the selection is applied, and started afterwards if it would have
+// been started otherwise. If its file list isn't known yet, it's started
+// straight away instead, since Transmission doesn't fetch a paused magnet's
+// metadata.
+func (c *Client) torrentAddFilename(ctx context.Context, filename string, opts AddOptions) (*AddResult, error) {
+	if err := validateAddOptions(opts); err != nil {
+		return nil, err
+	}
+
+	args := opts.args()
+	args.Filename = &filename
+	var start bool
+	if opts.hasFileSelection() && !c.dryRun {
+		var err error
+		if start, err = c.startsAdded(ctx, opts); err != nil {
+			return nil, err
+		}
+		paused := true
+		args.Paused = &paused
+	}
+
+	result, err := c.TorrentAdd(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	if c.dryRun {
+		return &AddResult{DryRun: true, FileSelectionPending: opts.hasFileSelection()}, nil
+	}
+	addResult, err := newAddResult(result)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.hasFileSelection() || addResult.Duplicate {
+		return addResult, nil
+	}
+
+	torrents, err := c.TorrentGet(ctx, TorrentGetArgs{
+		IDs:    NewTorrentIDs(addResult.Torrent.ID),
+		Fields: []string{"id", "files"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("torrent added paused but error getting its files: %w", err)
+	}
+	if len(torrents.Torrents) == 0 || len(torrents.Torrents[0].Files) == 0 {
+		addResult.FileSelectionPending = true
+		if err := c.startAdded(ctx, addResult, start); err != nil {
+			return nil, err
+		}
+		return addResult, nil
+	}
+
+	var names []string
+	for _, file := range torrents.Torrents[0].Files {
+		names = append(names, file.Name)
+	}
+	wanted, unwanted := opts.selectFiles(names)
+	err = c.TorrentSet(ctx, TorrentSetArgs{
+		Ids:           []interface{}{addResult.Torrent.ID},
+		FilesWanted:   wanted,
+		FilesUnwanted: unwanted,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("torrent added paused but error selecting its files: %w", err)
+	}
+	if err := c.startAdded(ctx, addResult, start); err != nil {
+		return nil, err
+	}
+	return addResult, nil
+}
+
+// startsAdded returns whether Transmission would start a torrent added with
+// opts: as asked, or by default as its session settings say.
+func (c *Client) startsAdded(ctx context.Context, opts AddOptions) (bool, error) {
+	if opts.Paused != nil {
+		return !*opts.Paused, nil
+	}
+	session, err := c.SessionGet(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error getting session: %w", err)
+	}
+	return session.StartAddedTorrents, nil
+}
+
+// startAdded starts a torrent added paused, if start is true.
+func (c *Client) startAdded(ctx context.Context, result *AddResult, start bool) error {
+	if !start {
+		return nil
+	}
+	if err := c.TorrentStart(ctx, NewTorrentIDs(result.Torrent.ID)); err != nil {
+		return fmt.Errorf("torrent added paused but error starting it: %w", err)
+	}
+	return nil
+}
+
+func validateAddOptions(opts AddOptions) error {
+	if err := validateFileGlobs(opts.FilesWanted); err != nil {
+		return err
+	}
+	return validateFileGlobs(opts.FilesUnwanted)
+}
+
+func toInt64s(ints []int) []int64 {
+	if ints == nil {
+		return nil
+	}
+	result := make([]int64, len(ints))
+	for i, n := range ints {
+		result[i] = int64(n)
+	}
+	return result
+}
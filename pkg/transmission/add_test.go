@@ -0,0 +1,227 @@
+package transmission
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission/bencode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTorrentFile(t *testing.T) []byte {
+	t.Helper()
+	data, err := bencode.Encode(map[string]any{
+		"announce": "http://tracker/announce",
+		"info": map[string]any{
+			"name":         "Movie",
+			"piece length": 16384,
+			"pieces":       make([]byte, sha1.Size),
+			"files": []any{
+				map[string]any{"length": 100, "path": []string{"movie.mkv"}},
+				map[string]any{"length": 10, "path": []string{"info.nfo"}},
+				map[string]any{"length": 50, "path": []string{"Sample", "clip.mkv"}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	return data
+}
+
+func TestTorrentAddReader(t *testing.T) {
+	tests := []struct {
+		rpcVersion int
+		keys       []string
+	}{
+		{17, []string{"download-dir", "bandwidthPriority", "files-wanted", "files-unwanted"}},
+		{18, []string{"download_dir", "bandwidth_priority", "files_wanted", "files_unwanted"}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("rpc version %d", test.rpcVersion), func(t *testing.T) {
+			client, server := newFakeServer(t, func(call rpcCall) (any, error) {
+				if call.Method == "session-get" {
+					return Session{RPCVersion: test.rpcVersion}, nil
+				}
+				return TorrentAddResult{TorrentAdded: &TorrentInfo{ID: 1, Name: "Movie", HashString: "abc"}}, nil
+			})
+
+			paused := true
+			priority := PriorityHigh
+			result, err := client.TorrentAddReader(context.Background(), bytes.NewReader(testTorrentFile(t)), AddOptions{
+				Labels:            []string{"movies"},
+				DownloadDir:       "/downloads/movies",
+				Paused:            &paused,
+				BandwidthPriority: &priority,
+				FilesUnwanted:     []string{"*.nfo", "Sample/**"},
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, &AddResult{Torrent: TorrentInfo{ID: 1, Name: "Movie", HashString: "abc"}}, result)
+
+			calls := server.CallsTo("torrent-add")
+			require.Len(t, calls, 1)
+			args := decodeArgs[map[string]any](t, calls[0])
+			assert.Equal(t, []any{"movies"}, args["labels"])
+			assert.Equal(t, true, args["paused"])
+			assert.NotEmpty(t, args["metainfo"])
+			downloadDir, priorityKey, wantedKey, unwantedKey := test.keys[0], test.keys[1], test.keys[2], test.keys[3]
+			assert.Equal(t, "/downloads/movies", args[downloadDir])
+			assert.Equal(t, 1.0, args[priorityKey])
+			assert.Equal(t, []any{0.0}, args[wantedKey])
+			assert.Equal(t, []any{1.0, 2.0}, args[unwantedKey])
+			assert.Len(t, args, 7, "no other keys are sent")
+
+			_, err = client.TorrentAddReader(context.Background(), bytes.NewReader(testTorrentFile(t)), AddOptions{})
+			require.NoError(t, err)
+			assert.Len(t, server.CallsTo("session-get"), 1, "the RPC version is only read once")
+		})
+	}
+}
+
+func TestTorrentAddReaderInvalid(t *testing.T) {
+	client, server := newFakeServer(t, func(call rpcCall) (any, error) {
+		return nil, nil
+	})
+
+	_, err := client.TorrentAddReader(context.Background(), bytes.NewReader([]byte("<html>")), AddOptions{})
+	assert.Error(t, err)
+
+	_, err = client.TorrentAddReader(context.Background(), bytes.NewReader(testTorrentFile(t)), AddOptions{FilesWanted: []string{"[a"}})
+	assert.Error(t, err)
+
+	assert.Empty(t, server.Calls(), "invalid torrents should not be uploaded")
+}
+
+func TestTorrentAddDuplicate(t *testing.T) {
+	client, _ := newFakeServer(t, func(call rpcCall) (any, error) {
+		return TorrentAddResult{TorrentDuplicated: &TorrentInfo{ID: 7, Name: "Movie", HashString: "abc"}}, nil
+	})
+
+	result, err := client.TorrentAddReader(context.Background(), bytes.NewReader(testTorrentFile(t)), AddOptions{})
+	require.NoError(t, err)
+
+	assert.True(t, result.Duplicate)
+	assert.Equal(t, int64(7), result.Torrent.ID)
+}
+
+func TestTorrentAddMagnet(t *testing.T) {
+	const uri = "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a"
+
+	t.Run("selects files once metadata is known", func(t *testing.T) {
+		client, server := newFakeServer(t, func(call rpcCall) (any, error) {
+			switch call.Method {
+			case "session-get":
+				return Session{RPCVersion: 18, StartAddedTorrents: true}, nil
+			case "torrent-add":
+				return TorrentAddResult{TorrentAdded: &TorrentInfo{ID: 3}}, nil
+			case "torrent-get":
+				return TorrentGetResult{Torrents: []Torrent{{ID: 3, Files: []TorrentFile{
+					{Name: "Movie/movie.mkv"},
+					{Name: "Movie/info.nfo"},
+				}}}}, nil
+			default:
+				return nil, nil
+			}
+		})
+
+		result, err := client.TorrentAddMagnet(context.Background(), uri, AddOptions{FilesWanted: []string{"*.mkv"}})
+		require.NoError(t, err)
+		assert.False(t, result.FileSelectionPending)
+
+		addArgs := decodeArgs[TorrentAddArgs](t, server.CallsTo("torrent-add")[0])
+		assert.Equal(t, uri, *addArgs.Filename)
+		assert.True(t, *addArgs.Paused, "paused until the files are selected")
+
+		setCalls := server.CallsTo("torrent-set")
+		require.Len(t, setCalls, 1)
+		setArgs := decodeArgs[TorrentSetArgs](t, setCalls[0])
+		assert.Equal(t, []int{0}, setArgs.FilesWanted)
+		assert.Equal(t, []int{1}, setArgs.FilesUnwanted)
+
+		var methods []string
+		for _, call := range server.Calls() {
+			methods = append(methods, call.Method)
+		}
+		assert.Equal(t, []string{"session-get", "torrent-add", "torrent-get", "torrent-set", "torrent-start"}, methods)
+		assert.JSONEq(t, `{"ids":[3]}`, string(server.CallsTo("torrent-start")[0].Arguments))
+	})
+
+	t.Run("stays paused if asked to", func(t *testing.T) {
+		client, server := newFakeServer(t, func(call rpcCall) (any, error) {
+			switch call.Method {
+			case "torrent-add":
+				return TorrentAddResult{TorrentAdded: &TorrentInfo{ID: 3}}, nil
+			case "torrent-get":
+				return TorrentGetResult{Torrents: []Torrent{{ID: 3, Files: []TorrentFile{{Name: "Movie/movie.mkv"}}}}}, nil
+			default:
+				return nil, nil
+			}
+		})
+
+		paused := true
+		_, err := client.TorrentAddMagnet(context.Background(), uri, AddOptions{Paused: &paused, FilesWanted: []string{"*.mkv"}})
+		require.NoError(t, err)
+		assert.Len(t, server.CallsTo("torrent-set"), 1)
+		assert.Empty(t, server.CallsTo("torrent-start"))
+	})
+
+	t.Run("stays paused if selecting files fails", func(t *testing.T) {
+		client, server := newFakeServer(t, func(call rpcCall) (any, error) {
+			switch call.Method {
+			case "session-get":
+				return Session{RPCVersion: 18, StartAddedTorrents: true}, nil
+			case "torrent-add":
+				return TorrentAddResult{TorrentAdded: &TorrentInfo{ID: 3}}, nil
+			case "torrent-get":
+				return TorrentGetResult{Torrents: []Torrent{{ID: 3, Files: []TorrentFile{{Name: "Movie/movie.mkv"}}}}}, nil
+			default:
+				return nil, errors.New("boom")
+			}
+		})
+
+		_, err := client.TorrentAddMagnet(context.Background(), uri, AddOptions{FilesWanted: []string{"*.mkv"}})
+		assert.ErrorContains(t, err, "torrent added paused but error selecting its files")
+		assert.Empty(t, server.CallsTo("torrent-start"))
+	})
+
+	t.Run("metadata not yet known", func(t *testing.T) {
+		client, server := newFakeServer(t, func(call rpcCall) (any, error) {
+			switch call.Method {
+			case "torrent-add":
+				return TorrentAddResult{TorrentAdded: &TorrentInfo{ID: 3}}, nil
+			default:
+				return TorrentGetResult{Torrents: []Torrent{{ID: 3}}}, nil
+			}
+		})
+
+		started := false
+		result, err := client.TorrentAddMagnet(context.Background(), uri, AddOptions{Paused: &started, FilesWanted: []string{"*.mkv"}})
+		require.NoError(t, err)
+		assert.True(t, result.FileSelectionPending)
+		assert.Empty(t, server.CallsTo("torrent-set"))
+		assert.Len(t, server.CallsTo("torrent-start"), 1, "started to fetch its metadata")
+	})
+
+	t.Run("invalid magnet", func(t *testing.T) {
+		client, _ := newFakeServer(t, func(call rpcCall) (any, error) { return nil, nil })
+		_, err := client.TorrentAddMagnet(context.Background(), "magnet:?dn=foo", AddOptions{})
+		assert.Error(t, err)
+	})
+}
+
+func TestTorrentAddURLError(t *testing.T) {
+	client, _ := newFakeServer(t, func(call rpcCall) (any, error) {
+		if call.Method == "session-get" {
+			return Session{RPCVersion: 18}, nil
+		}
+		return nil, errors.New("http error 404: Not Found")
+	})
+
+	_, err := client.TorrentAddURL(context.Background(), "https://example.com/a.torrent", AddOptions{})
+	assert.EqualError(t, err, "http error 404: Not Found")
+}
@@ -0,0 +1,338 @@
+// Package bencode encodes and decodes the bencoding used by .torrent files
+// (BEP 3).
+//
+// Decoded values are represented with the following Go types:
+//   - integers: int64
+//   - byte strings: string (which may hold arbitrary bytes)
+//   - lists: []any
+//   - dictionaries: map[string]any
+package bencode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxDepth limits how deeply lists and dictionaries may be nested, so that
+// malicious input can't exhaust the stack.
+const maxDepth = 512
+
+var ErrTrailingData = errors.New("bencode: trailing data after value")
+
+// SyntaxError describes malformed bencoded input.
+type SyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("bencode: %s at offset %d", e.Msg, e.Offset)
+}
+
+// Decode decodes a single bencoded value. It is an error for data to contain
+// anything after the value.
+func Decode(data []byte) (any, error) {
+	d := decoder{data: data}
+	value, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(data) {
+		return nil, ErrTrailingData
+	}
+	return value, nil
+}
+
+// DecodeRawDict decodes a dictionary without decoding its values, returning
+// the exact encoded bytes of each value. This is needed to hash a torrent's
+// "info" dictionary exactly as it appears in the file.
+func DecodeRawDict(data []byte) (map[string][]byte, error) {
+	d := decoder{data: data}
+	if err := d.expect('d'); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]byte)
+	for {
+		if d.peek() == 'e' {
+			d.pos++
+			break
+		}
+		key, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		start := d.pos
+		if err := d.skip(1); err != nil {
+			return nil, err
+		}
+		result[key] = data[start:d.pos]
+	}
+
+	if d.pos != len(data) {
+		return nil, ErrTrailingData
+	}
+	return result, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) errorf(format string, args ...any) error {
+	return &SyntaxError{Offset: d.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (d *decoder) peek() byte {
+	if d.pos >= len(d.data) {
+		return 0
+	}
+	return d.data[d.pos]
+}
+
+func (d *decoder) expect(c byte) error {
+	if d.peek() != c {
+		if d.pos >= len(d.data) {
+			return d.errorf("unexpected end of input, expected %q", c)
+		}
+		return d.errorf("unexpected %q, expected %q", d.peek(), c)
+	}
+	d.pos++
+	return nil
+}
+
+func (d *decoder) value(depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, d.errorf("nesting exceeds %d levels", maxDepth)
+	}
+
+	switch c := d.peek(); {
+	case c == 'i':
+		return d.int()
+	case c >= '0' && c <= '9':
+		return d.string()
+	case c == 'l':
+		d.pos++
+		list := []any{}
+		for d.peek() != 'e' {
+			item, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		d.pos++
+		return list, nil
+	case c == 'd':
+		d.pos++
+		dict := make(map[string]any)
+		for d.peek() != 'e' {
+			key, err := d.string()
+			if err != nil {
+				return nil, err
+			}
+			item, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			dict[key] = item
+		}
+		d.pos++
+		return dict, nil
+	case d.pos >= len(d.data):
+		return nil, d.errorf("unexpected end of input")
+	default:
+		return nil, d.errorf("unexpected %q", c)
+	}
+}
+
+// skip advances past one value without allocating it.
+func (d *decoder) skip(depth int) error {
+	if depth > maxDepth {
+		return d.errorf("nesting exceeds %d levels", maxDepth)
+	}
+
+	switch c := d.peek(); {
+	case c == 'i':
+		_, err := d.int()
+		return err
+	case c >= '0' && c <= '9':
+		_, err := d.string()
+		return err
+	case c == 'l' || c == 'd':
+		d.pos++
+		for d.peek() != 'e' {
+			if c == 'd' {
+				if _, err := d.string(); err != nil {
+					return err
+				}
+			}
+			if err := d.skip(depth + 1); err != nil {
+				return err
+			}
+		}
+		d.pos++
+		return nil
+	case d.pos >= len(d.data):
+		return d.errorf("unexpected end of input")
+	default:
+		return d.errorf("unexpected %q", c)
+	}
+}
+
+func (d *decoder) int() (int64, error) {
+	if err := d.expect('i'); err != nil {
+		return 0, err
+	}
+
+	end := bytes.IndexByte(d.data[d.pos:], 'e')
+	if end < 0 {
+		return 0, d.errorf("unterminated integer")
+	}
+	digits := string(d.data[d.pos : d.pos+end])
+
+	if abs, negative := strings.CutPrefix(digits, "-"); !canonicalDigits(abs) || (negative && abs == "0") {
+		return 0, d.errorf("invalid integer %q", digits)
+	}
+
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, d.errorf("invalid integer %q", digits)
+	}
+	d.pos += end + 1
+	return n, nil
+}
+
+func (d *decoder) string() (string, error) {
+	colon := bytes.IndexByte(d.data[d.pos:], ':')
+	if colon < 0 {
+		return "", d.errorf("invalid string length")
+	}
+	lengthDigits := string(d.data[d.pos : d.pos+colon])
+	if !canonicalDigits(lengthDigits) {
+		return "", d.errorf("invalid string length %q", lengthDigits)
+	}
+
+	length, err := strconv.Atoi(lengthDigits)
+	if err != nil || length < 0 {
+		return "", d.errorf("invalid string length %q", lengthDigits)
+	}
+
+	start := d.pos + colon + 1
+	if length > len(d.data)-start {
+		return "", d.errorf("string length %d exceeds input", length)
+	}
+	d.pos = start + length
+	return string(d.data[start:d.pos]), nil
+}
+
+// canonicalDigits reports whether s is a non-empty run of ASCII digits
+// without leading zeros. strconv also accepts a sign, which BEP 3 doesn't.
+func canonicalDigits(s string) bool {
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return false
+	}
+	for i := range len(s) {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Encode bencodes v. Supported types are strings, byte slices, signed and
+// unsigned integers, bools (encoded as 0 or 1), slices and arrays of
+// supported types, and maps with string keys. Dictionary keys are written in
+// sorted order, as required by BEP 3. RawMessage values are written as is.
+func Encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encode(&buf, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RawMessage is an already bencoded value. It is written verbatim by Encode.
+type RawMessage []byte
+
+var rawMessageType = reflect.TypeOf(RawMessage(nil))
+
+func encode(buf *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		return errors.New("bencode: cannot encode nil value")
+	}
+
+	if v.Type() == rawMessageType {
+		buf.Write(v.Bytes())
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return errors.New("bencode: cannot encode nil value")
+		}
+		return encode(buf, v.Elem())
+	case reflect.String:
+		writeString(buf, v.String())
+	case reflect.Bool:
+		if v.Bool() {
+			buf.WriteString("i1e")
+		} else {
+			buf.WriteString("i0e")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buf.WriteByte('i')
+		buf.WriteString(strconv.FormatInt(v.Int(), 10))
+		buf.WriteByte('e')
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		buf.WriteByte('i')
+		buf.WriteString(strconv.FormatUint(v.Uint(), 10))
+		buf.WriteByte('e')
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			writeString(buf, string(b))
+			return nil
+		}
+		buf.WriteByte('l')
+		for i := 0; i < v.Len(); i++ {
+			if err := encode(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("bencode: unsupported map key type %s", v.Type().Key())
+		}
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			return bytes.Compare([]byte(a.String()), []byte(b.String()))
+		})
+		buf.WriteByte('d')
+		for _, key := range keys {
+			writeString(buf, key.String())
+			if err := encode(buf, v.MapIndex(key)); err != nil {
+				return fmt.Errorf("key %q: %w", key.String(), err)
+			}
+		}
+		buf.WriteByte('e')
+	default:
+		return fmt.Errorf("bencode: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteString(strconv.Itoa(len(s)))
+	buf.WriteByte(':')
+	buf.WriteString(s)
+}
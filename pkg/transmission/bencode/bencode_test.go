@@ -0,0 +1,127 @@
+package bencode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{input: "i42e", expected: int64(42)},
+		{input: "i-7e", expected: int64(-7)},
+		{input: "i0e", expected: int64(0)},
+		{input: "4:spam", expected: "spam"},
+		{input: "0:", expected: ""},
+		{input: "le", expected: []any{}},
+		{input: "l4:spami3ee", expected: []any{"spam", int64(3)}},
+		{input: "d3:cow3:moo4:spaml1:a1:bee", expected: map[string]any{"cow": "moo", "spam": []any{"a", "b"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			actual, err := Decode([]byte(tt.input))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"i-0e",
+		"i03e",
+		"i+5e",
+		"i-+5e",
+		"i--5e",
+		"i-e",
+		"i 5e",
+		"ie",
+		"i12",
+		"5:spam",
+		"03:abc",
+		"+3:abc",
+		"-1:a",
+		" 3:abc",
+		"l4:spam",
+		"d3:cowe",
+		"di1e3:mooe",
+		"x",
+		"i1ei2e",
+	} {
+		t.Run(input, func(t *testing.T) {
+			_, err := Decode([]byte(input))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDecodeRawDict(t *testing.T) {
+	raw, err := DecodeRawDict([]byte("d8:announce3:url4:infod4:name3:fooee"))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string][]byte{
+		"announce": []byte("3:url"),
+		"info":     []byte("d4:name3:fooe"),
+	}, raw)
+
+	_, err = DecodeRawDict([]byte("l4:spame"))
+	assert.Error(t, err)
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    any
+		expected string
+	}{
+		{name: "int", input: 42, expected: "i42e"},
+		{name: "negative int", input: int64(-3), expected: "i-3e"},
+		{name: "uint", input: uint32(7), expected: "i7e"},
+		{name: "bool", input: true, expected: "i1e"},
+		{name: "string", input: "spam", expected: "4:spam"},
+		{name: "bytes", input: []byte{0x00, 0xff}, expected: "2:\x00\xff"},
+		{name: "string list", input: []string{"a", "bc"}, expected: "l1:a2:bce"},
+		{name: "nested list", input: [][]string{{"a"}, {"b"}}, expected: "ll1:ael1:bee"},
+		{
+			name:     "sorted dict keys",
+			input:    map[string]any{"zebra": 1, "apple": "x", "Mango": []any{}},
+			expected: "d5:Mangole5:apple1:x5:zebrai1ee",
+		},
+		{name: "raw message", input: map[string]any{"info": RawMessage("d1:ai1ee")}, expected: "d4:infod1:ai1eee"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := Encode(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(actual))
+		})
+	}
+
+	t.Run("unsupported type", func(t *testing.T) {
+		_, err := Encode(1.5)
+		assert.Error(t, err)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		_, err := Encode(map[string]any{"a": nil})
+		assert.Error(t, err)
+	})
+}
+
+func TestRoundTrip(t *testing.T) {
+	input := "d4:infod6:lengthi12e4:name5:a.txt12:piece lengthi16384eee"
+
+	decoded, err := Decode([]byte(input))
+	require.NoError(t, err)
+
+	encoded, err := Encode(decoded)
+	require.NoError(t, err)
+	assert.Equal(t, input, string(encoded))
+}
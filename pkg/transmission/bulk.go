@@ -0,0 +1,206 @@
+package transmission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	defaultBulkChunkSize   = 100
+	defaultBulkParallelism = 4
+)
+
+// BulkOptions configures how bulk operations split up their torrents.
+type BulkOptions struct {
+	// ChunkSize is the maximum number of torrents per request. Defaults to
+	// 100.
+	ChunkSize int
+
+	// Parallelism is the maximum number of concurrent requests. Defaults
+	// to 4.
+	Parallelism int
+
+	// Progress is optional. It is called after each chunk, one call at a
+	// time, with the number of torrents processed so far.
+	Progress func(done, total int)
+}
+
+// BulkItemResult is the outcome of a bulk operation for one torrent.
+type BulkItemResult struct {
+	// ID is the torrent ID or hash as given to the bulk operation.
+	ID  any
+	Err error
+}
+
+// BulkResult is the outcome of a bulk operation, with one item per torrent
+// in the order they were given.
+type BulkResult struct {
+	Items []BulkItemResult
+}
+
+// Failed returns the items that failed.
+func (r *BulkResult) Failed() []BulkItemResult {
+	var result []BulkItemResult
+	for _, item := range r.Items {
+		if item.Err != nil {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// Succeeded returns the IDs of the torrents that succeeded.
+func (r *BulkResult) Succeeded() []any {
+	var result []any
+	for _, item := range r.Items {
+		if item.Err == nil {
+			result = append(result, item.ID)
+		}
+	}
+	return result
+}
+
+// Err returns an error describing every failed torrent, or nil if they all
+// succeeded.
+func (r *BulkResult) Err() error {
+	var errs []error
+	for _, item := range r.Failed() {
+		errs = append(errs, fmt.Errorf("torrent %v: %w", item.ID, item.Err))
+	}
+	return errors.Join(errs...)
+}
+
+// Bulk calls action for the torrents with ids in chunks of at most
+// opts.ChunkSize, running up to opts.Parallelism chunks at once.
+//
+// When the daemon rejects a chunk with a *ResultError, the chunk is split in
+// half and each half is retried, down to single torrents, so that the
+// failures are attributed to the torrents that caused them. Any other error,
+// such as a timeout or a dropped connection, leaves it unknown whether the
+// daemon applied the action, so the chunk isn't resent and all of its
+// torrents fail with that error. Chunks that haven't started when ctx is
+// cancelled fail with the context's error.
+func Bulk(ctx context.Context, ids []any, opts BulkOptions, action func(ctx context.Context, ids *TorrentIDs) error) *BulkResult {
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultBulkChunkSize
+	}
+	parallelism := opts.Parallelism
+	if parallelism <= 0 {
+		parallelism = defaultBulkParallelism
+	}
+
+	result := &BulkResult{Items: make([]BulkItemResult, len(ids))}
+	for i, id := range ids {
+		result.Items[i].ID = id
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mutex     sync.Mutex
+		done      int
+		semaphore = make(chan struct{}, parallelism)
+	)
+	for start := 0; start < len(ids); start += chunkSize {
+		items := result.Items[start:min(start+chunkSize, len(ids))]
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+				runBulkChunk(ctx, items, action)
+				<-semaphore
+			case <-ctx.Done():
+				for i := range items {
+					items[i].Err = ctx.Err()
+				}
+			}
+
+			if opts.Progress != nil {
+				mutex.Lock()
+				done += len(items)
+				opts.Progress(done, len(ids))
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return result
+}
+
+// runBulkChunk runs action for items, bisecting when the daemon rejects them.
+func runBulkChunk(ctx context.Context, items []BulkItemResult, action func(ctx context.Context, ids *TorrentIDs) error) {
+	if err := ctx.Err(); err != nil {
+		for i := range items {
+			items[i].Err = err
+		}
+		return
+	}
+
+	ids := make([]any, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+
+	err := action(ctx, NewTorrentIDs(ids...))
+	if err == nil {
+		return
+	}
+	var resultErr *ResultError
+	if len(items) == 1 || !errors.As(err, &resultErr) {
+		for i := range items {
+			items[i].Err = err
+		}
+		return
+	}
+
+	half := len(items) / 2
+	runBulkChunk(ctx, items[:half], action)
+	runBulkChunk(ctx, items[half:], action)
+}
+
+func (c *Client) BulkStart(ctx context.Context, ids []any, opts BulkOptions) *BulkResult {
+	return Bulk(ctx, ids, opts, c.TorrentStart)
+}
+
+func (c *Client) BulkStartNow(ctx context.Context, ids []any, opts BulkOptions) *BulkResult {
+	return Bulk(ctx, ids, opts, c.TorrentStartNow)
+}
+
+func (c *Client) BulkStop(ctx context.Context, ids []any, opts BulkOptions) *BulkResult {
+	return Bulk(ctx, ids, opts, c.TorrentStop)
+}
+
+func (c *Client) BulkVerify(ctx context.Context, ids []any, opts BulkOptions) *BulkResult {
+	return Bulk(ctx, ids, opts, c.TorrentVerify)
+}
+
+func (c *Client) BulkReannounce(ctx context.Context, ids []any, opts BulkOptions) *BulkResult {
+	return Bulk(ctx, ids, opts, c.TorrentReannounce)
+}
+
+func (c *Client) BulkRemove(ctx context.Context, ids []any, deleteLocalData bool, opts BulkOptions) *BulkResult {
+	return Bulk(ctx, ids, opts, func(ctx context.Context, ids *TorrentIDs) error {
+		return c.TorrentRemove(ctx, TorrentRemoveArgs{IDs: ids, DeleteLocalData: deleteLocalData})
+	})
+}
+
+func (c *Client) BulkSetLocation(ctx context.Context, ids []any, location string, move bool, opts BulkOptions) *BulkResult {
+	return Bulk(ctx, ids, opts, func(ctx context.Context, ids *TorrentIDs) error {
+		return c.TorrentSetLocation(ctx, TorrentSetLocationArgs{IDs: ids, Location: location, Move: move})
+	})
+}
+
+// BulkSet applies args to the torrents. Any Ids in args are ignored.
+func (c *Client) BulkSet(ctx context.Context, ids []any, args TorrentSetArgs, opts BulkOptions) *BulkResult {
+	return Bulk(ctx, ids, opts, func(ctx context.Context, ids *TorrentIDs) error {
+		args := args
+		args.Ids = ids.ids.([]any)
+		return c.TorrentSet(ctx, args)
+	})
+}
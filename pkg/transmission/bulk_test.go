@@ -0,0 +1,172 @@
+package transmission
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func bulkIDs(n int) []any {
+	ids := make([]any, n)
+	for i := range ids {
+		ids[i] = int64(i + 1)
+	}
+	return ids
+}
+
+func TestBulk(t *testing.T) {
+	t.Run("chunks and progress", func(t *testing.T) {
+		var (
+			mutex    sync.Mutex
+			chunks   [][]any
+			progress []int
+		)
+		result := Bulk(context.Background(), bulkIDs(25), BulkOptions{
+			ChunkSize:   10,
+			Parallelism: 2,
+			Progress: func(done, total int) {
+				assert.Equal(t, 25, total)
+				progress = append(progress, done)
+			},
+		}, func(ctx context.Context, ids *TorrentIDs) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			chunks = append(chunks, ids.ids.([]any))
+			return nil
+		})
+
+		require.NoError(t, result.Err())
+		assert.Len(t, result.Items, 25)
+		assert.Equal(t, bulkIDs(25), result.Succeeded())
+		assert.Empty(t, result.Failed())
+
+		slices.SortFunc(chunks, func(a, b []any) int { return int(a[0].(int64) - b[0].(int64)) })
+		assert.Equal(t, [][]any{bulkIDs(25)[:10], bulkIDs(25)[10:20], bulkIDs(25)[20:]}, chunks)
+		assert.Len(t, progress, 3)
+		assert.Equal(t, 25, progress[2])
+	})
+
+	t.Run("isolates failures", func(t *testing.T) {
+		errBad := &ResultError{Method: "torrent-start", Result: "bad torrent"}
+		var calls atomic.Int32
+		result := Bulk(context.Background(), bulkIDs(8), BulkOptions{ChunkSize: 8}, func(ctx context.Context, ids *TorrentIDs) error {
+			calls.Add(1)
+			if slices.Contains(ids.ids.([]any), any(int64(3))) {
+				return errBad
+			}
+			return nil
+		})
+
+		failed := result.Failed()
+		require.Len(t, failed, 1)
+		assert.Equal(t, int64(3), failed[0].ID)
+		assert.ErrorIs(t, failed[0].Err, errBad)
+		assert.Len(t, result.Succeeded(), 7)
+		assert.EqualError(t, result.Err(), "torrent 3: bad torrent")
+		// [1..8] fails, [1..4] fails, [5..8], [1,2], [3,4] fails, [3], [4]
+		assert.Equal(t, int32(7), calls.Load())
+	})
+
+	t.Run("doesn't resend after other errors", func(t *testing.T) {
+		errTimeout := errors.New("timeout")
+		var calls atomic.Int32
+		result := Bulk(context.Background(), bulkIDs(8), BulkOptions{ChunkSize: 8}, func(ctx context.Context, ids *TorrentIDs) error {
+			calls.Add(1)
+			return errTimeout
+		})
+
+		assert.Len(t, result.Failed(), 8)
+		assert.ErrorIs(t, result.Err(), errTimeout)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("bounded parallelism", func(t *testing.T) {
+		var running, maxRunning atomic.Int32
+		release := make(chan struct{})
+		go func() {
+			for range 10 {
+				release <- struct{}{}
+			}
+		}()
+
+		result := Bulk(context.Background(), bulkIDs(10), BulkOptions{ChunkSize: 1, Parallelism: 3}, func(ctx context.Context, ids *TorrentIDs) error {
+			n := running.Add(1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			<-release
+			running.Add(-1)
+			return nil
+		})
+
+		require.NoError(t, result.Err())
+		assert.LessOrEqual(t, maxRunning.Load(), int32(3))
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		result := Bulk(ctx, bulkIDs(10), BulkOptions{ChunkSize: 2, Parallelism: 1}, func(ctx context.Context, ids *TorrentIDs) error {
+			cancel()
+			return nil
+		})
+
+		assert.Len(t, result.Succeeded(), 2)
+		assert.Len(t, result.Failed(), 8)
+		assert.ErrorIs(t, result.Err(), context.Canceled)
+	})
+}
+
+func TestClientBulk(t *testing.T) {
+	client, server := newFakeServer(t, func(call rpcCall) (any, error) {
+		args := decodeArgs[struct {
+			IDs []int64 `json:"ids"`
+		}](t, call)
+		if slices.Contains(args.IDs, 2) {
+			return nil, errors.New("invalid or corrupt torrent file")
+		}
+		return nil, nil
+	})
+
+	result := client.BulkRemove(context.Background(), bulkIDs(3), true, BulkOptions{ChunkSize: 2})
+	require.Len(t, result.Failed(), 1)
+	assert.Equal(t, int64(2), result.Failed()[0].ID)
+
+	calls := server.CallsTo("torrent-remove")
+	assert.Len(t, calls, 4)
+	for _, call := range calls {
+		assert.Equal(t, true, decodeArgs[map[string]any](t, call)["delete_local_data"])
+	}
+
+	result = client.BulkSet(context.Background(), []any{int64(1), int64(3)}, TorrentSetArgs{Labels: &[]string{"x"}}, BulkOptions{})
+	require.NoError(t, result.Err())
+	assert.JSONEq(t, `{"ids":[1,3],"labels":["x"]}`, string(server.CallsTo("torrent-set")[0].Arguments))
+}
+
+func TestClientBulkDroppedConnection(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		conn.Close()
+	}))
+	t.Cleanup(server.Close)
+	client, err := New(ClientParams{Host: server.URL})
+	require.NoError(t, err)
+
+	result := client.BulkRemove(context.Background(), bulkIDs(4), true, BulkOptions{})
+
+	assert.Len(t, result.Failed(), 4)
+	assert.Equal(t, int32(1), requests.Load(), "a remove that may have been applied mustn't be resent")
+}
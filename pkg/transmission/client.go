@@ -4,9 +4,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"sync"
@@ -19,13 +19,29 @@ type Client struct {
 	user       string
 	password   string
 	sessionID  string
+	// rpcVersion is the daemon's RPC version, once a session-get has
+	// returned it.
+	rpcVersion int
 	mutex      sync.RWMutex
+	logger     *slog.Logger
+	dryRun     bool
+	plan       []PlannedOperation
 }
 
 type ClientParams struct {
 	Host     string
 	User     string
 	Password string
+
+	// Logger is optional. When set, every RPC call is logged with its method,
+	// duration and status code. Redacted request and response bodies are
+	// included when the logger has debug level enabled.
+	Logger *slog.Logger
+
+	// DryRun makes mutating methods record their requests instead of
+	// sending them, and return success. Reads are still sent. Use DryRunPlan
+	// to get the recorded requests.
+	DryRun bool
 }
 
 type Request struct {
@@ -52,6 +68,17 @@ func (re ResponseError[T]) Error() string {
 	return fmt.Sprintf("response code: %d, message: %s", re.Code, re.Message)
 }
 
+// ResultError is returned when the daemon handled a request but its result
+// wasn't "success", for example because a torrent ID was invalid.
+type ResultError struct {
+	Method string
+	Result string
+}
+
+func (e *ResultError) Error() string {
+	return e.Result
+}
+
 type Response[R any] struct {
 	Arguments R      `json:"arguments"`
 	Result    string `json:"result"`
@@ -76,6 +103,8 @@ func New(params ClientParams) (*Client, error) {
 		},
 		user:     params.User,
 		password: params.Password,
+		logger:   params.Logger,
+		dryRun:   params.DryRun,
 	}, nil
 }
 
@@ -91,11 +120,11 @@ func (c *Client) doRequest(ctx context.Context, body io.Reader) (*http.Response,
 
 	req.Header.Set("Accept", "application/json")
 	req.SetBasicAuth(c.user, c.password)
+	c.mutex.RLock()
 	if c.sessionID != "" {
-		c.mutex.RLock()
 		req.Header.Set(sessionIDHeader, c.sessionID)
-		c.mutex.RUnlock()
 	}
+	c.mutex.RUnlock()
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -105,19 +134,42 @@ func (c *Client) doRequest(ctx context.Context, body io.Reader) (*http.Response,
 	return resp, nil
 }
 
-func (c *Client) post(ctx context.Context, body any, dst any) error {
+func (c *Client) post(ctx context.Context, method string, body any, dst any) (err error) {
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
 		return fmt.Errorf("error marshalling body: %w", err)
 	}
 
-	// fmt.Println("===> body", string(jsonBody))
+	if c.dryRun && mutatingMethods[method] {
+		return c.planOperation(ctx, method, jsonBody, dst)
+	}
+
+	var (
+		start        = time.Now()
+		statusCode   int
+		header       rpcHeaders
+		responseBody []byte
+	)
+	if c.logger != nil {
+		defer func() {
+			c.logRPC(ctx, rpcLogEntry{
+				method:       method,
+				duration:     time.Since(start),
+				statusCode:   statusCode,
+				headers:      header,
+				requestBody:  jsonBody,
+				responseBody: responseBody,
+				err:          err,
+			})
+		}()
+	}
 
 	resp, err := c.doRequest(ctx, bytes.NewBuffer(jsonBody))
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
+	statusCode, header = resp.StatusCode, newRPCHeaders(resp)
 
 	if resp.StatusCode == http.StatusConflict {
 		sessionID := resp.Header.Get(sessionIDHeader)
@@ -134,28 +186,23 @@ func (c *Client) post(ctx context.Context, body any, dst any) error {
 			return err
 		}
 		defer resp.Body.Close()
+		statusCode, header = resp.StatusCode, newRPCHeaders(resp)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		bytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-
-		return fmt.Errorf("unexpected %d status returned: %s", resp.StatusCode, bytes)
+	responseBody, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response body: %w", err)
 	}
 
-	// bytes, err := io.ReadAll(resp.Body)
-	// if err != nil {
-	// 	return err
-	// }
-	// fmt.Println(string(bytes))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected %d status returned: %s", resp.StatusCode, responseBody)
+	}
 
 	if dst == nil {
 		return nil
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(dst); err != nil {
+	if err := json.Unmarshal(responseBody, dst); err != nil {
 		return fmt.Errorf("error decoding response body: %w", err)
 	}
 
@@ -164,22 +211,22 @@ func (c *Client) post(ctx context.Context, body any, dst any) error {
 
 func post[R any](ctx context.Context, client *Client, method string) (*R, error) {
 	var response Response[R]
-	if err := client.post(ctx, Request{Method: method}, &response); err != nil {
+	if err := client.post(ctx, method, Request{Method: method}, &response); err != nil {
 		return nil, err
 	}
 	if !response.isSuccess() {
-		return nil, errors.New(response.Result)
+		return nil, &ResultError{Method: method, Result: response.Result}
 	}
 	return &response.Arguments, nil
 }
 
 func postWithArgs[P any, R any](ctx context.Context, client *Client, method string, params P) (*R, error) {
 	var response Response[R]
-	if err := client.post(ctx, RequestWithParams[P]{Method: method, Arguments: params}, &response); err != nil {
+	if err := client.post(ctx, method, RequestWithParams[P]{Method: method, Arguments: params}, &response); err != nil {
 		return nil, err
 	}
 	if !response.isSuccess() {
-		return nil, errors.New(response.Result)
+		return nil, &ResultError{Method: method, Result: response.Result}
 	}
 	return &response.Arguments, nil
 }
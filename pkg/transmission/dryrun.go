@@ -0,0 +1,81 @@
+package transmission
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// mutatingMethods are the RPC methods that change the daemon's state. In dry
+// run mode they are recorded instead of sent.
+var mutatingMethods = map[string]bool{
+	"blocklist-update":     true,
+	"group-set":            true,
+	"queue-move-bottom":    true,
+	"queue-move-down":      true,
+	"queue-move-top":       true,
+	"queue-move-up":        true,
+	"session-close":        true,
+	"session-set":          true,
+	"torrent-add":          true,
+	"torrent-reannounce":   true,
+	"torrent-remove":       true,
+	"torrent-rename-path":  true,
+	"torrent-set":          true,
+	"torrent-set-location": true,
+	"torrent-start":        true,
+	"torrent-start-now":    true,
+	"torrent-stop":         true,
+	"torrent-verify":       true,
+}
+
+// PlannedOperation is a mutating RPC request recorded in dry run mode.
+type PlannedOperation struct {
+	Method    string          `json:"method"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Time      time.Time       `json:"time"`
+}
+
+// DryRun reports whether the client was created in dry run mode.
+func (c *Client) DryRun() bool {
+	return c.dryRun
+}
+
+// DryRunPlan returns the operations recorded in dry run mode, oldest first.
+func (c *Client) DryRunPlan() []PlannedOperation {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return append([]PlannedOperation(nil), c.plan...)
+}
+
+// ResetDryRunPlan forgets the recorded operations.
+func (c *Client) ResetDryRunPlan() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.plan = nil
+}
+
+// planOperation records a request instead of sending it and responds with
+// a success result without arguments.
+func (c *Client) planOperation(ctx context.Context, method string, jsonBody []byte, dst any) error {
+	var request struct {
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(jsonBody, &request); err != nil {
+		return fmt.Errorf("error decoding planned request: %w", err)
+	}
+
+	c.mutex.Lock()
+	c.plan = append(c.plan, PlannedOperation{Method: method, Arguments: request.Arguments, Time: time.Now()})
+	c.mutex.Unlock()
+
+	if c.logger != nil {
+		c.logger.InfoContext(ctx, "transmission RPC request skipped in dry run", "method", method)
+	}
+
+	if dst == nil {
+		return nil
+	}
+	return json.Unmarshal([]byte(`{"result":"success"}`), dst)
+}
@@ -0,0 +1,86 @@
+package transmission
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDryRunClient(t *testing.T) (*Client, *fakeServer) {
+	t.Helper()
+
+	client, server := newFakeServer(t, func(call rpcCall) (any, error) {
+		switch call.Method {
+		case "torrent-get":
+			return TorrentGetResult{Torrents: []Torrent{{ID: 1, Labels: []string{"a"}}}}, nil
+		case "session-get":
+			return Session{RPCVersion: 17}, nil
+		}
+		return nil, nil
+	})
+	client.dryRun = true
+	return client, server
+}
+
+func TestDryRun(t *testing.T) {
+	dryRunClient, err := New(ClientParams{Host: "http://localhost:9091", DryRun: true})
+	require.NoError(t, err)
+	assert.True(t, dryRunClient.DryRun())
+
+	ctx := context.Background()
+	client, server := newDryRunClient(t)
+
+	require.NoError(t, client.TorrentStop(ctx, NewTorrentIDs(1, 2)))
+	require.NoError(t, client.TorrentRemove(ctx, TorrentRemoveArgs{IDs: NewTorrentIDs("abc"), DeleteLocalData: true}))
+	require.NoError(t, client.SessionClose(ctx))
+	require.NoError(t, client.QueueMoveTop(ctx, NewTorrentIDs(3)))
+
+	changes, err := client.AddLabels(ctx, NewTorrentIDs(1), "b")
+	require.NoError(t, err)
+	assert.Len(t, changes, 1)
+
+	session, err := client.SessionGet(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 17, session.RPCVersion, "reads are sent")
+
+	var sent []string
+	for _, call := range server.Calls() {
+		sent = append(sent, call.Method)
+	}
+	assert.Equal(t, []string{"torrent-get", "torrent-get", "session-get"}, sent)
+
+	plan := client.DryRunPlan()
+	var planned []string
+	for _, operation := range plan {
+		planned = append(planned, operation.Method)
+		assert.False(t, operation.Time.IsZero())
+	}
+	assert.Equal(t, []string{"torrent-stop", "torrent-remove", "session-close", "queue-move-top", "torrent-set"}, planned)
+	assert.JSONEq(t, `{"ids":[1,2]}`, string(plan[0].Arguments))
+	assert.JSONEq(t, `{"ids":["abc"],"delete_local_data":true}`, string(plan[1].Arguments))
+	assert.Empty(t, plan[2].Arguments)
+	assert.JSONEq(t, `{"ids":[1],"labels":["a","b"]}`, string(plan[4].Arguments))
+
+	client.ResetDryRunPlan()
+	assert.Empty(t, client.DryRunPlan())
+}
+
+func TestDryRunAdd(t *testing.T) {
+	ctx := context.Background()
+	client, server := newDryRunClient(t)
+
+	result, err := client.TorrentAddReader(ctx, bytes.NewReader(testTorrentFile(t)), AddOptions{})
+	require.NoError(t, err)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, "Movie", result.Torrent.Name)
+	assert.Len(t, result.Torrent.HashString, 40)
+
+	result, err = client.TorrentAddMagnet(ctx, "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a", AddOptions{FilesWanted: []string{"*.mkv"}})
+	require.NoError(t, err)
+	assert.Equal(t, &AddResult{DryRun: true, FileSelectionPending: true}, result)
+	assert.Len(t, server.Calls(), 1, "only the session is read, for the RPC version")
+	assert.Len(t, client.DryRunPlan(), 2)
+}
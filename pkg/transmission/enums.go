@@ -0,0 +1,231 @@
+package transmission
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Priority is a bandwidth or file priority (tr_priority_t).
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+var PriorityByLabel = map[Priority]string{
+	PriorityLow:    "low",
+	PriorityNormal: "normal",
+	PriorityHigh:   "high",
+}
+
+func (p Priority) String() string {
+	return enumString(p, PriorityByLabel)
+}
+
+func (p Priority) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int(p))
+}
+
+func (p *Priority) UnmarshalJSON(data []byte) error {
+	return unmarshalEnum(data, p, PriorityByLabel)
+}
+
+// RatioMode controls which seed ratio limit applies to a torrent
+// (tr_ratiolimit).
+type RatioMode int
+
+const (
+	// RatioModeGlobal follows the session-wide seed ratio limit.
+	RatioModeGlobal RatioMode = 0
+	// RatioModeSingle follows the torrent's own seed ratio limit.
+	RatioModeSingle RatioMode = 1
+	// RatioModeUnlimited seeds regardless of ratio.
+	RatioModeUnlimited RatioMode = 2
+)
+
+var RatioModeByLabel = map[RatioMode]string{
+	RatioModeGlobal:    "global",
+	RatioModeSingle:    "single",
+	RatioModeUnlimited: "unlimited",
+}
+
+func (m RatioMode) String() string {
+	return enumString(m, RatioModeByLabel)
+}
+
+func (m RatioMode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int(m))
+}
+
+func (m *RatioMode) UnmarshalJSON(data []byte) error {
+	return unmarshalEnum(data, m, RatioModeByLabel)
+}
+
+// IdleMode controls which idle seeding limit applies to a torrent
+// (tr_idlelimit).
+type IdleMode int
+
+const (
+	// IdleModeGlobal follows the session-wide idle seeding limit.
+	IdleModeGlobal IdleMode = 0
+	// IdleModeSingle follows the torrent's own idle seeding limit.
+	IdleModeSingle IdleMode = 1
+	// IdleModeUnlimited seeds regardless of how long the torrent is idle.
+	IdleModeUnlimited IdleMode = 2
+)
+
+var IdleModeByLabel = map[IdleMode]string{
+	IdleModeGlobal:    "global",
+	IdleModeSingle:    "single",
+	IdleModeUnlimited: "unlimited",
+}
+
+func (m IdleMode) String() string {
+	return enumString(m, IdleModeByLabel)
+}
+
+func (m IdleMode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int(m))
+}
+
+func (m *IdleMode) UnmarshalJSON(data []byte) error {
+	return unmarshalEnum(data, m, IdleModeByLabel)
+}
+
+// TrackerState is the announce or scrape state of a tracker
+// (tr_tracker_state).
+type TrackerState int
+
+const (
+	// TrackerStateInactive means no announce or scrape is pending.
+	TrackerStateInactive TrackerState = 0
+	// TrackerStateWaiting means an announce or scrape is scheduled.
+	TrackerStateWaiting TrackerState = 1
+	// TrackerStateQueued means an announce or scrape is about to be sent.
+	TrackerStateQueued TrackerState = 2
+	// TrackerStateActive means an announce or scrape is in progress.
+	TrackerStateActive TrackerState = 3
+)
+
+var TrackerStateByLabel = map[TrackerState]string{
+	TrackerStateInactive: "inactive",
+	TrackerStateWaiting:  "waiting",
+	TrackerStateQueued:   "queued",
+	TrackerStateActive:   "active",
+}
+
+func (s TrackerState) String() string {
+	return enumString(s, TrackerStateByLabel)
+}
+
+func (s TrackerState) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int(s))
+}
+
+func (s *TrackerState) UnmarshalJSON(data []byte) error {
+	return unmarshalEnum(data, s, TrackerStateByLabel)
+}
+
+// TorrentError is the kind of error a torrent is in (tr_stat_errtype). The
+// accompanying message is in Torrent.ErrorString.
+type TorrentError int
+
+const (
+	TorrentErrorNone TorrentError = 0
+	// TorrentErrorTrackerWarning means a tracker returned a warning.
+	TorrentErrorTrackerWarning TorrentError = 1
+	// TorrentErrorTrackerError means a tracker returned an error.
+	TorrentErrorTrackerError TorrentError = 2
+	// TorrentErrorLocalError means a local problem, e.g. a missing file or a
+	// full disk, stopped the torrent.
+	TorrentErrorLocalError TorrentError = 3
+)
+
+var TorrentErrorByLabel = map[TorrentError]string{
+	TorrentErrorNone:           "ok",
+	TorrentErrorTrackerWarning: "tracker_warning",
+	TorrentErrorTrackerError:   "tracker_error",
+	TorrentErrorLocalError:     "local_error",
+}
+
+func (e TorrentError) String() string {
+	return enumString(e, TorrentErrorByLabel)
+}
+
+func (e TorrentError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int(e))
+}
+
+func (e *TorrentError) UnmarshalJSON(data []byte) error {
+	return unmarshalEnum(data, e, TorrentErrorByLabel)
+}
+
+// Encryption is the session's peer encryption preference.
+type Encryption string
+
+const (
+	// EncryptionRequired only connects to peers that use encryption.
+	EncryptionRequired Encryption = "required"
+	// EncryptionPreferred prefers encrypted connections but allows plaintext.
+	EncryptionPreferred Encryption = "preferred"
+	// EncryptionTolerated prefers plaintext connections but allows encryption.
+	EncryptionTolerated Encryption = "tolerated"
+)
+
+var EncryptionByLabel = map[Encryption]string{
+	EncryptionRequired:  string(EncryptionRequired),
+	EncryptionPreferred: string(EncryptionPreferred),
+	EncryptionTolerated: string(EncryptionTolerated),
+}
+
+func (e Encryption) String() string {
+	return enumString(e, EncryptionByLabel)
+}
+
+func (e Encryption) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(e))
+}
+
+// UnmarshalJSON keeps values it doesn't recognise, so that a newer daemon
+// doesn't break session-get. String reports them as "unknown".
+func (e *Encryption) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid encryption %s: %w", data, err)
+	}
+	*e = Encryption(s)
+	return nil
+}
+
+func enumString[T comparable](value T, labels map[T]string) string {
+	label, exists := labels[value]
+	if !exists {
+		return "unknown"
+	}
+	return label
+}
+
+// unmarshalEnum accepts either the integer value used by the RPC API or one
+// of the labels, so that the same types can be used in config files.
+// Unrecognised integers are kept as is; unrecognised labels are an error.
+func unmarshalEnum[T ~int](data []byte, dst *T, labels map[T]string) error {
+	var i int
+	if err := json.Unmarshal(data, &i); err == nil {
+		*dst = T(i)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid value %s: must be an integer or a string", data)
+	}
+	for value, label := range labels {
+		if label == s {
+			*dst = value
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value %q", s)
+}
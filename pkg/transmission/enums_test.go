@@ -0,0 +1,82 @@
+package transmission
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnumStrings(t *testing.T) {
+	assert.Equal(t, "high", PriorityHigh.String())
+	assert.Equal(t, "low", PriorityLow.String())
+	assert.Equal(t, "unlimited", RatioModeUnlimited.String())
+	assert.Equal(t, "single", IdleModeSingle.String())
+	assert.Equal(t, "active", TrackerStateActive.String())
+	assert.Equal(t, "local_error", TorrentErrorLocalError.String())
+	assert.Equal(t, "preferred", EncryptionPreferred.String())
+
+	assert.Equal(t, "unknown", Priority(7).String())
+	assert.Equal(t, "unknown", Encryption("sometimes").String())
+}
+
+func TestEnumJSON(t *testing.T) {
+	t.Run("unmarshal RPC response", func(t *testing.T) {
+		body := `{
+			"bandwidthPriority": -1,
+			"error": 2,
+			"seedIdleMode": 1,
+			"seedRatioMode": 2,
+			"status": 6,
+			"priorities": [1, 0, -1],
+			"trackerStats": [{"announceState": 3, "scrapeState": 1}]
+		}`
+
+		var torrent Torrent
+		require.NoError(t, json.Unmarshal([]byte(body), &torrent))
+
+		assert.Equal(t, PriorityLow, torrent.BandwidthPriority)
+		assert.Equal(t, TorrentErrorTrackerError, torrent.Error)
+		assert.Equal(t, IdleModeSingle, torrent.SeedIdleMode)
+		assert.Equal(t, RatioModeUnlimited, torrent.SeedRatioMode)
+		assert.Equal(t, TorrentStatusSeed, torrent.Status)
+		assert.Equal(t, []Priority{PriorityHigh, PriorityNormal, PriorityLow}, torrent.Priorities)
+		assert.Equal(t, TrackerStateActive, torrent.TrackerStats[0].AnnounceState)
+		assert.Equal(t, TrackerStateWaiting, torrent.TrackerStats[0].ScrapeState)
+	})
+
+	t.Run("marshal as integers", func(t *testing.T) {
+		priority := PriorityHigh
+		ratioMode := RatioModeSingle
+		args := TorrentSetArgs{BandwidthPriority: &priority, SeedRatioMode: &ratioMode}
+
+		body, err := json.Marshal(args)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"bandwidthPriority":1,"seedRatioMode":1}`, string(body))
+	})
+
+	t.Run("unmarshal labels", func(t *testing.T) {
+		var args TorrentSetArgs
+		require.NoError(t, json.Unmarshal([]byte(`{"bandwidthPriority":"low","seedIdleMode":"unlimited"}`), &args))
+		assert.Equal(t, PriorityLow, *args.BandwidthPriority)
+		assert.Equal(t, IdleModeUnlimited, *args.SeedIdleMode)
+	})
+
+	t.Run("unknown label", func(t *testing.T) {
+		var p Priority
+		assert.Error(t, json.Unmarshal([]byte(`"urgent"`), &p))
+	})
+
+	t.Run("unknown integer", func(t *testing.T) {
+		var s TorrentStatus
+		require.NoError(t, json.Unmarshal([]byte(`9`), &s))
+		assert.Equal(t, "unknown", s.String())
+	})
+
+	t.Run("encryption", func(t *testing.T) {
+		var session Session
+		require.NoError(t, json.Unmarshal([]byte(`{"encryption":"required"}`), &session))
+		assert.Equal(t, EncryptionRequired, session.Encryption)
+	})
+}
@@ -0,0 +1,183 @@
+package transmission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// FileRule changes the wanted state and/or priority of the files matching
+// either Glob or Regexp. See AddOptions for the glob syntax. Regexp is matched
+// against the file's path relative to the torrent's root directory.
+type FileRule struct {
+	Glob   string
+	Regexp string
+
+	Wanted   *bool
+	Priority *Priority
+}
+
+func (r FileRule) matcher() (func(name string) bool, error) {
+	switch {
+	case r.Glob != "" && r.Regexp != "":
+		return nil, errors.New("file rule must have either a glob or a regexp, not both")
+	case r.Glob != "":
+		if err := validateFileGlobs([]string{r.Glob}); err != nil {
+			return nil, err
+		}
+		return func(name string) bool {
+			return matchAnyFileGlob([]string{r.Glob}, name)
+		}, nil
+	case r.Regexp != "":
+		re, err := regexp.Compile(r.Regexp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file regexp %q: %w", r.Regexp, err)
+		}
+		return func(name string) bool {
+			if _, rel, found := strings.Cut(name, "/"); found {
+				name = rel
+			}
+			return re.MatchString(name)
+		}, nil
+	default:
+		return nil, errors.New("file rule must have a glob or a regexp")
+	}
+}
+
+// FileSelectionArgs are the arguments to SelectFiles.
+type FileSelectionArgs struct {
+	// ID is a torrent ID or hash.
+	ID any
+
+	// Rules are applied in order, so later rules override earlier ones for
+	// files they both match.
+	Rules []FileRule
+
+	// DryRun resolves the rules without changing the torrent.
+	DryRun bool
+}
+
+// FileSelectionPlan is the result of resolving file rules against a torrent.
+type FileSelectionPlan struct {
+	TorrentID int64
+	Files     []PlannedFile
+
+	// SetArgs is the torrent-set request that applies the plan. It is only
+	// sent if the plan changes at least one file and DryRun is false.
+	SetArgs TorrentSetArgs
+}
+
+type PlannedFile struct {
+	Index       int
+	Name        string
+	WasWanted   bool
+	Wanted      bool
+	WasPriority Priority
+	Priority    Priority
+}
+
+func (f PlannedFile) Changed() bool {
+	return f.WasWanted != f.Wanted || f.WasPriority != f.Priority
+}
+
+// Changed reports whether the plan changes any file.
+func (p FileSelectionPlan) Changed() bool {
+	for _, file := range p.Files {
+		if file.Changed() {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectFiles resolves rules against a torrent's files and applies the result
+// with a single torrent-set call.
+func (c *Client) SelectFiles(ctx context.Context, args FileSelectionArgs) (*FileSelectionPlan, error) {
+	matchers := make([]func(string) bool, len(args.Rules))
+	for i, rule := range args.Rules {
+		matcher, err := rule.matcher()
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		matchers[i] = matcher
+	}
+
+	result, err := c.TorrentGet(ctx, TorrentGetArgs{
+		IDs:    NewTorrentIDs(args.ID),
+		Fields: []string{"id", "files", "fileStats"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Torrents) != 1 {
+		return nil, fmt.Errorf("torrent %v not found", args.ID)
+	}
+	torrent := result.Torrents[0]
+	if len(torrent.Files) != len(torrent.FileStats) {
+		return nil, fmt.Errorf("torrent %v has %d files but %d file stats", args.ID, len(torrent.Files), len(torrent.FileStats))
+	}
+
+	plan := planFileSelection(torrent, args.Rules, matchers)
+	if args.DryRun || !plan.Changed() {
+		return plan, nil
+	}
+
+	if err := c.TorrentSet(ctx, plan.SetArgs); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func planFileSelection(torrent Torrent, rules []FileRule, matchers []func(string) bool) *FileSelectionPlan {
+	plan := &FileSelectionPlan{
+		TorrentID: torrent.ID,
+		SetArgs:   TorrentSetArgs{Ids: []interface{}{torrent.ID}},
+	}
+
+	for i, file := range torrent.Files {
+		stat := torrent.FileStats[i]
+		planned := PlannedFile{
+			Index:       i,
+			Name:        file.Name,
+			WasWanted:   stat.Wanted,
+			Wanted:      stat.Wanted,
+			WasPriority: stat.Priority,
+			Priority:    stat.Priority,
+		}
+
+		for j, rule := range rules {
+			if !matchers[j](file.Name) {
+				continue
+			}
+			if rule.Wanted != nil {
+				planned.Wanted = *rule.Wanted
+			}
+			if rule.Priority != nil {
+				planned.Priority = *rule.Priority
+			}
+		}
+		plan.Files = append(plan.Files, planned)
+
+		if planned.Wanted != planned.WasWanted {
+			if planned.Wanted {
+				plan.SetArgs.FilesWanted = append(plan.SetArgs.FilesWanted, i)
+			} else {
+				plan.SetArgs.FilesUnwanted = append(plan.SetArgs.FilesUnwanted, i)
+			}
+		}
+		if planned.Priority != planned.WasPriority {
+			switch planned.Priority {
+			case PriorityHigh:
+				plan.SetArgs.PriorityHigh = append(plan.SetArgs.PriorityHigh, i)
+			case PriorityLow:
+				plan.SetArgs.PriorityLow = append(plan.SetArgs.PriorityLow, i)
+			default:
+				plan.SetArgs.PriorityNormal = append(plan.SetArgs.PriorityNormal, i)
+			}
+		}
+	}
+
+	return plan
+}
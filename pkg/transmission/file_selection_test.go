@@ -0,0 +1,117 @@
+package transmission
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fileSelectionTorrent() Torrent {
+	return Torrent{
+		ID: 5,
+		Files: []TorrentFile{
+			{Name: "Movie/movie.mkv"},
+			{Name: "Movie/info.nfo"},
+			{Name: "Movie/Sample/clip.mkv"},
+			{Name: "Movie/Subs/en.srt"},
+		},
+		FileStats: []TorrentFileStat{
+			{Wanted: true, Priority: PriorityNormal},
+			{Wanted: true, Priority: PriorityNormal},
+			{Wanted: true, Priority: PriorityNormal},
+			{Wanted: false, Priority: PriorityNormal},
+		},
+	}
+}
+
+var (
+	wanted   = true
+	unwanted = false
+	high     = PriorityHigh
+)
+
+var movieRules = []FileRule{
+	{Glob: "*.nfo", Wanted: &unwanted},
+	{Glob: "Sample/**", Wanted: &unwanted},
+	{Glob: "*.mkv", Priority: &high},
+	{Regexp: `^Subs/.*\.srt$`, Wanted: &wanted},
+}
+
+func TestSelectFiles(t *testing.T) {
+	client, server := newFakeServer(t, func(call rpcCall) (any, error) {
+		return TorrentGetResult{Torrents: []Torrent{fileSelectionTorrent()}}, nil
+	})
+
+	plan, err := client.SelectFiles(context.Background(), FileSelectionArgs{ID: 5, Rules: movieRules})
+	require.NoError(t, err)
+
+	assert.Equal(t, []PlannedFile{
+		{Index: 0, Name: "Movie/movie.mkv", WasWanted: true, Wanted: true, WasPriority: PriorityNormal, Priority: PriorityHigh},
+		{Index: 1, Name: "Movie/info.nfo", WasWanted: true, Wanted: false, WasPriority: PriorityNormal, Priority: PriorityNormal},
+		{Index: 2, Name: "Movie/Sample/clip.mkv", WasWanted: true, Wanted: false, WasPriority: PriorityNormal, Priority: PriorityHigh},
+		{Index: 3, Name: "Movie/Subs/en.srt", WasWanted: false, Wanted: true, WasPriority: PriorityNormal, Priority: PriorityNormal},
+	}, plan.Files)
+
+	setCalls := server.CallsTo("torrent-set")
+	require.Len(t, setCalls, 1)
+	args := decodeArgs[TorrentSetArgs](t, setCalls[0])
+	assert.Equal(t, []interface{}{float64(5)}, args.Ids)
+	assert.Equal(t, []int{3}, args.FilesWanted)
+	assert.Equal(t, []int{1, 2}, args.FilesUnwanted)
+	assert.Equal(t, []int{0, 2}, args.PriorityHigh)
+	assert.Empty(t, args.PriorityNormal)
+}
+
+func TestSelectFilesDryRun(t *testing.T) {
+	client, server := newFakeServer(t, func(call rpcCall) (any, error) {
+		return TorrentGetResult{Torrents: []Torrent{fileSelectionTorrent()}}, nil
+	})
+
+	plan, err := client.SelectFiles(context.Background(), FileSelectionArgs{ID: 5, Rules: movieRules, DryRun: true})
+	require.NoError(t, err)
+
+	assert.True(t, plan.Changed())
+	assert.Equal(t, []int{1, 2}, plan.SetArgs.FilesUnwanted)
+	assert.Empty(t, server.CallsTo("torrent-set"))
+}
+
+func TestSelectFilesNoChanges(t *testing.T) {
+	client, server := newFakeServer(t, func(call rpcCall) (any, error) {
+		return TorrentGetResult{Torrents: []Torrent{fileSelectionTorrent()}}, nil
+	})
+
+	plan, err := client.SelectFiles(context.Background(), FileSelectionArgs{
+		ID:    5,
+		Rules: []FileRule{{Glob: "movie.mkv", Wanted: &wanted}},
+	})
+	require.NoError(t, err)
+
+	assert.False(t, plan.Changed())
+	assert.Empty(t, server.CallsTo("torrent-set"))
+}
+
+func TestSelectFilesErrors(t *testing.T) {
+	client, server := newFakeServer(t, func(call rpcCall) (any, error) {
+		return TorrentGetResult{}, nil
+	})
+
+	for name, rule := range map[string]FileRule{
+		"no pattern": {Wanted: &wanted},
+		"both":       {Glob: "*", Regexp: ".*", Wanted: &wanted},
+		"bad glob":   {Glob: "[a", Wanted: &wanted},
+		"bad regexp": {Regexp: "(", Wanted: &wanted},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := client.SelectFiles(context.Background(), FileSelectionArgs{ID: 5, Rules: []FileRule{rule}})
+			assert.Error(t, err)
+		})
+	}
+	assert.Empty(t, server.Calls(), "invalid rules should be rejected before fetching the torrent")
+
+	t.Run("torrent not found", func(t *testing.T) {
+		_, err := client.SelectFiles(context.Background(), FileSelectionArgs{ID: 5, Rules: movieRules})
+		assert.EqualError(t, err, "torrent 5 not found")
+	})
+}
@@ -0,0 +1,73 @@
+package transmission
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// matchFileGlob reports whether a torrent file name matches pattern.
+//
+// name is a TorrentFile.Name, which for multi file torrents starts with the
+// torrent's root directory. Patterns are matched against the path relative to
+// that directory, so "Sample/**" matches "Movie/Sample/clip.mkv". Patterns
+// without a slash match the base name at any depth, so "*.nfo" matches
+// "Movie/Extras/info.nfo". "**" matches any number of directories; other
+// syntax is as for path.Match.
+func matchFileGlob(pattern, name string) (bool, error) {
+	if !strings.Contains(pattern, "/") {
+		return path.Match(pattern, path.Base(name))
+	}
+
+	if _, rel, found := strings.Cut(name, "/"); found {
+		name = rel
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skip := 0; skip <= len(name); skip++ {
+				matched, err := matchSegments(pattern[1:], name[skip:])
+				if err != nil || matched {
+					return matched, err
+				}
+			}
+			return false, nil
+		}
+
+		if len(name) == 0 {
+			return false, nil
+		}
+		matched, err := path.Match(pattern[0], name[0])
+		if err != nil || !matched {
+			return false, err
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0, nil
+}
+
+// validateFileGlobs returns an error for the first malformed pattern.
+func validateFileGlobs(patterns []string) error {
+	for _, pattern := range patterns {
+		for _, segment := range strings.Split(pattern, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid file pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// matchAnyFileGlob reports whether name matches any of patterns, which must
+// have been validated.
+func matchAnyFileGlob(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := matchFileGlob(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
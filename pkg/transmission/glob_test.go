@@ -0,0 +1,41 @@
+package transmission
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchFileGlob(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		name     string
+		expected bool
+	}{
+		{pattern: "*.nfo", name: "Movie/info.nfo", expected: true},
+		{pattern: "*.nfo", name: "Movie/Extras/info.nfo", expected: true},
+		{pattern: "*.nfo", name: "info.nfo", expected: true},
+		{pattern: "*.nfo", name: "Movie/info.nfo.mkv", expected: false},
+		{pattern: "Sample/**", name: "Movie/Sample/clip.mkv", expected: true},
+		{pattern: "Sample/**", name: "Movie/Sample/a/b/clip.mkv", expected: true},
+		{pattern: "Sample/**", name: "Movie/Extras/Sample/clip.mkv", expected: false},
+		{pattern: "**/Sample/*", name: "Movie/Extras/Sample/clip.mkv", expected: true},
+		{pattern: "**/*.mkv", name: "Movie/movie.mkv", expected: true},
+		{pattern: "Extras/*.mkv", name: "Movie/Extras/a/clip.mkv", expected: false},
+		{pattern: "[Ss]ample/*", name: "Movie/sample/clip.mkv", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			matched, err := matchFileGlob(tt.pattern, tt.name)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, matched)
+		})
+	}
+}
+
+func TestValidateFileGlobs(t *testing.T) {
+	assert.NoError(t, validateFileGlobs([]string{"*.nfo", "Sample/**"}))
+	assert.Error(t, validateFileGlobs([]string{"*.nfo", "Sample/[a"}))
+}
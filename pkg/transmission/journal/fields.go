@@ -0,0 +1,150 @@
+package journal
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+)
+
+// torrentSetFields maps torrent-set arguments to the Torrent fields that
+// they change.
+var torrentSetFields = map[string]string{
+	"bandwidthPriority":   "bandwidthPriority",
+	"downloadLimit":       "downloadLimit",
+	"downloadLimited":     "downloadLimited",
+	"files-unwanted":      "wanted",
+	"files-wanted":        "wanted",
+	"honorsSessionLimits": "honorsSessionLimits",
+	"labels":              "labels",
+	"location":            "downloadDir",
+	"peer-limit":          "peer-limit",
+	"priority-high":       "priorities",
+	"priority-low":        "priorities",
+	"priority-normal":     "priorities",
+	"queuePosition":       "queuePosition",
+	"seedIdleLimit":       "seedIdleLimit",
+	"seedIdleMode":        "seedIdleMode",
+	"seedRatioLimit":      "seedRatioLimit",
+	"seedRatioMode":       "seedRatioMode",
+	"trackerAdd":          "trackerList",
+	"trackerList":         "trackerList",
+	"trackerRemove":       "trackerList",
+	"trackerReplace":      "trackerList",
+	"uploadLimit":         "uploadLimit",
+	"uploadLimited":       "uploadLimited",
+}
+
+// changedTorrentFields returns the Torrent fields changed by the encoded
+// torrent-set arguments.
+func changedTorrentFields(arguments []byte) ([]string, error) {
+	var set map[string]json.RawMessage
+	if err := json.Unmarshal(arguments, &set); err != nil {
+		return nil, fmt.Errorf("error decoding arguments: %w", err)
+	}
+
+	var fields []string
+	for name := range set {
+		if name == "ids" {
+			continue
+		}
+		field, exists := torrentSetFields[name]
+		if !exists {
+			return nil, fmt.Errorf("can't journal torrent-set argument %q", name)
+		}
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+	return slices.Compact(fields), nil
+}
+
+// inverseTorrentSet returns the torrent-set arguments that restore a
+// snapshot.
+func inverseTorrentSet(snapshot TorrentSnapshot) (map[string]json.RawMessage, error) {
+	args := make(map[string]json.RawMessage)
+	for field, old := range snapshot.Old {
+		switch field {
+		case "wanted":
+			var wanted []int64
+			if err := json.Unmarshal(old, &wanted); err != nil {
+				return nil, fmt.Errorf("error decoding wanted files of torrent %d: %w", snapshot.ID, err)
+			}
+			groups := map[string][]int{}
+			for i, w := range wanted {
+				if w != 0 {
+					groups["files-wanted"] = append(groups["files-wanted"], i)
+				} else {
+					groups["files-unwanted"] = append(groups["files-unwanted"], i)
+				}
+			}
+			if err := setIndexes(args, groups); err != nil {
+				return nil, err
+			}
+		case "priorities":
+			var priorities []transmission.Priority
+			if err := json.Unmarshal(old, &priorities); err != nil {
+				return nil, fmt.Errorf("error decoding file priorities of torrent %d: %w", snapshot.ID, err)
+			}
+			groups := map[string][]int{}
+			for i, priority := range priorities {
+				switch priority {
+				case transmission.PriorityLow:
+					groups["priority-low"] = append(groups["priority-low"], i)
+				case transmission.PriorityHigh:
+					groups["priority-high"] = append(groups["priority-high"], i)
+				default:
+					groups["priority-normal"] = append(groups["priority-normal"], i)
+				}
+			}
+			if err := setIndexes(args, groups); err != nil {
+				return nil, err
+			}
+		case "downloadDir":
+			args["location"] = old
+		default:
+			args[field] = old
+		}
+	}
+	return args, nil
+}
+
+func setIndexes(args map[string]json.RawMessage, groups map[string][]int) error {
+	for name, indexes := range groups {
+		encoded, err := json.Marshal(indexes)
+		if err != nil {
+			return err
+		}
+		args[name] = encoded
+	}
+	return nil
+}
+
+var textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+
+// jsonField returns the JSON encoding of the field of v with the given JSON
+// name, ignoring omitempty. Nil slices are encoded as empty lists so that
+// restoring them clears the field.
+func jsonField(v any, name string) (json.RawMessage, error) {
+	value := reflect.ValueOf(v)
+	for _, field := range reflect.VisibleFields(value.Type()) {
+		tagName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tagName != name {
+			continue
+		}
+
+		fieldValue := value.FieldByIndex(field.Index)
+		if fieldValue.Kind() == reflect.Slice && fieldValue.IsNil() && !field.Type.Implements(textMarshalerType) {
+			return json.RawMessage("[]"), nil
+		}
+		encoded, err := json.Marshal(fieldValue.Interface())
+		if err != nil {
+			return nil, fmt.Errorf("error encoding %s: %w", name, err)
+		}
+		return encoded, nil
+	}
+	return nil, fmt.Errorf("unknown field %s", name)
+}
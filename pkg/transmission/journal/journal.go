@@ -0,0 +1,439 @@
+// Package journal records the previous values of the torrents and session
+// settings changed through it, so that changes can be audited and undone.
+//
+// The journal is a file with one JSON entry per line. Each entry holds the
+// request that was sent and a snapshot of the values it changed, taken just
+// before sending it.
+package journal
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+)
+
+const (
+	methodTorrentSet         = "torrent-set"
+	methodTorrentSetLocation = "torrent-set-location"
+	methodSessionSet         = "session-set"
+)
+
+var (
+	// ErrNotFound is returned by Undo for an unknown entry ID.
+	ErrNotFound = errors.New("journal entry not found")
+	// ErrAlreadyUndone is returned by Undo for an entry that has already
+	// been undone.
+	ErrAlreadyUndone = errors.New("journal entry already undone")
+)
+
+// Client is the subset of *transmission.Client used by the journal.
+type Client interface {
+	TorrentGet(ctx context.Context, args transmission.TorrentGetArgs) (*transmission.TorrentGetResult, error)
+	TorrentSet(ctx context.Context, args transmission.TorrentSetArgs) error
+	TorrentSetLocation(ctx context.Context, args transmission.TorrentSetLocationArgs) error
+	SessionGet(ctx context.Context) (*transmission.Session, error)
+	SessionSet(ctx context.Context, args transmission.SessionSetArgs) error
+}
+
+// Entry is one journaled operation.
+type Entry struct {
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+
+	// Requests are the arguments of each request sent, i.e. the new values.
+	// Undoing a torrent-set or torrent-set-location takes a request per
+	// torrent.
+	Requests []json.RawMessage `json:"requests"`
+
+	// Torrents are the previous values of the torrents changed by
+	// torrent-set and torrent-set-location.
+	Torrents []TorrentSnapshot `json:"torrents,omitempty"`
+
+	// Session is the previous values of the settings changed by
+	// session-set, keyed by their JSON name.
+	Session map[string]json.RawMessage `json:"session,omitempty"`
+
+	// UndoOf is the ID of the entry that this operation undid.
+	UndoOf string `json:"undoOf,omitempty"`
+
+	// Error is set if the request failed. It may still have been partially
+	// applied, so such entries can be undone too.
+	Error string `json:"error,omitempty"`
+}
+
+// TorrentSnapshot is the previous values of a torrent's changed fields,
+// keyed by their JSON name in transmission.Torrent.
+type TorrentSnapshot struct {
+	ID         int64                      `json:"id"`
+	HashString string                     `json:"hashString"`
+	Old        map[string]json.RawMessage `json:"old"`
+}
+
+// Journal wraps a client, journaling the changes made through it. It is safe
+// for concurrent use.
+type Journal struct {
+	client Client
+	path   string
+	mutex  sync.Mutex
+	now    func() time.Time
+}
+
+// Open returns a journal that appends to the file at path, creating it if
+// needed.
+func Open(client Client, path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening journal: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("error opening journal: %w", err)
+	}
+	return &Journal{client: client, path: path, now: time.Now}, nil
+}
+
+// TorrentSet snapshots the fields that args changes on each torrent, then
+// sends it.
+func (j *Journal) TorrentSet(ctx context.Context, args transmission.TorrentSetArgs) (*Entry, error) {
+	return j.torrentSet(ctx, args, "")
+}
+
+func (j *Journal) torrentSet(ctx context.Context, args transmission.TorrentSetArgs, undoOf string) (*Entry, error) {
+	arguments, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding arguments: %w", err)
+	}
+	fields, err := changedTorrentFields(arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	// torrent-set without ids applies to every torrent.
+	ids := transmission.AllTorrents
+	if len(args.Ids) > 0 {
+		ids = transmission.NewTorrentIDs(args.Ids...)
+	}
+	snapshots, err := j.snapshotTorrents(ctx, ids, fields)
+	if err != nil {
+		return nil, err
+	}
+	return j.record(methodTorrentSet, []json.RawMessage{arguments}, snapshots, nil, undoOf, j.client.TorrentSet(ctx, args))
+}
+
+// TorrentSetLocation snapshots each torrent's download directory, then sends
+// args.
+func (j *Journal) TorrentSetLocation(ctx context.Context, args transmission.TorrentSetLocationArgs) (*Entry, error) {
+	return j.torrentSetLocation(ctx, args, "")
+}
+
+func (j *Journal) torrentSetLocation(ctx context.Context, args transmission.TorrentSetLocationArgs, undoOf string) (*Entry, error) {
+	arguments, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding arguments: %w", err)
+	}
+
+	snapshots, err := j.snapshotTorrents(ctx, args.IDs, []string{"downloadDir"})
+	if err != nil {
+		return nil, err
+	}
+	return j.record(methodTorrentSetLocation, []json.RawMessage{arguments}, snapshots, nil, undoOf, j.client.TorrentSetLocation(ctx, args))
+}
+
+// SessionSet snapshots the settings that args changes, then sends it.
+func (j *Journal) SessionSet(ctx context.Context, args transmission.SessionSetArgs) (*Entry, error) {
+	return j.sessionSet(ctx, args, "")
+}
+
+func (j *Journal) sessionSet(ctx context.Context, args transmission.SessionSetArgs, undoOf string) (*Entry, error) {
+	arguments, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding arguments: %w", err)
+	}
+	var set map[string]json.RawMessage
+	if err := json.Unmarshal(arguments, &set); err != nil {
+		return nil, fmt.Errorf("error decoding arguments: %w", err)
+	}
+
+	session, err := j.client.SessionGet(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting session: %w", err)
+	}
+	old := make(map[string]json.RawMessage, len(set))
+	for name := range set {
+		value, err := jsonField(*session, name)
+		if err != nil {
+			return nil, err
+		}
+		old[name] = value
+	}
+	return j.record(methodSessionSet, []json.RawMessage{arguments}, nil, old, undoOf, j.client.SessionSet(ctx, args))
+}
+
+func (j *Journal) snapshotTorrents(ctx context.Context, ids *transmission.TorrentIDs, fields []string) ([]TorrentSnapshot, error) {
+	result, err := j.client.TorrentGet(ctx, transmission.TorrentGetArgs{
+		IDs:    ids,
+		Fields: append([]string{"id", "hashString"}, fields...),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting torrents: %w", err)
+	}
+
+	snapshots := make([]TorrentSnapshot, 0, len(result.Torrents))
+	for _, torrent := range result.Torrents {
+		snapshot := TorrentSnapshot{
+			ID:         torrent.ID,
+			HashString: torrent.HashString,
+			Old:        make(map[string]json.RawMessage, len(fields)),
+		}
+		for _, field := range fields {
+			value, err := jsonField(torrent, field)
+			if err != nil {
+				return nil, err
+			}
+			snapshot.Old[field] = value
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// record appends an entry for a request that returned callErr, and returns
+// the entry together with callErr.
+func (j *Journal) record(method string, requests []json.RawMessage, torrents []TorrentSnapshot, session map[string]json.RawMessage, undoOf string, callErr error) (*Entry, error) {
+	entry := &Entry{
+		ID:       newID(),
+		Time:     j.now().UTC(),
+		Method:   method,
+		Requests: requests,
+		Torrents: torrents,
+		Session:  session,
+		UndoOf:   undoOf,
+	}
+	if callErr != nil {
+		entry.Error = callErr.Error()
+	}
+
+	if err := j.append(entry); err != nil {
+		return entry, errors.Join(callErr, err)
+	}
+	return entry, callErr
+}
+
+func (j *Journal) append(entry *Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding journal entry: %w", err)
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	f, err := os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("error opening journal: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("error writing journal entry: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("error writing journal entry: %w", err)
+	}
+	return nil
+}
+
+// Entries returns all journal entries, oldest first.
+func (j *Journal) Entries() ([]Entry, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	f, err := os.Open(j.path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening journal: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("error decoding journal line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading journal: %w", err)
+	}
+	return entries, nil
+}
+
+// Undo restores the values recorded by the entry with id. The undo is itself
+// journaled, so it can be undone too.
+func (j *Journal) Undo(ctx context.Context, id string) (*Entry, error) {
+	entries, err := j.Entries()
+	if err != nil {
+		return nil, err
+	}
+	if slices.ContainsFunc(entries, func(e Entry) bool { return e.UndoOf == id && e.Error == "" }) {
+		return nil, fmt.Errorf("%w: %s", ErrAlreadyUndone, id)
+	}
+	i := slices.IndexFunc(entries, func(e Entry) bool { return e.ID == id })
+	if i < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	entry := entries[i]
+
+	switch entry.Method {
+	case methodTorrentSet:
+		return j.undoTorrentSet(ctx, entry)
+	case methodTorrentSetLocation:
+		return j.undoTorrentSetLocation(ctx, entry)
+	case methodSessionSet:
+		args, err := decodeArgs[transmission.SessionSetArgs](entry.Session)
+		if err != nil {
+			return nil, err
+		}
+		return j.sessionSet(ctx, args, entry.ID)
+	}
+	return nil, fmt.Errorf("can't undo %s", entry.Method)
+}
+
+// undoTorrentSet restores each torrent with its own torrent-set, since their
+// previous values may differ. They are journaled together as one entry.
+func (j *Journal) undoTorrentSet(ctx context.Context, entry Entry) (*Entry, error) {
+	var (
+		requests  []json.RawMessage
+		snapshots []TorrentSnapshot
+		errs      []error
+	)
+	for _, snapshot := range entry.Torrents {
+		args, fields, err := undoTorrentSetArgs(snapshot)
+		if err != nil {
+			return nil, err
+		}
+		current, err := j.snapshotTorrents(ctx, transmission.NewTorrentIDs(snapshot.ID), fields)
+		if err != nil {
+			// Record the torrents restored so far.
+			errs = append(errs, err)
+			break
+		}
+
+		if err := j.client.TorrentSet(ctx, args); err != nil {
+			errs = append(errs, fmt.Errorf("torrent %d: %w", snapshot.ID, err))
+		}
+		encoded, err := json.Marshal(args)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding arguments: %w", err)
+		}
+		requests = append(requests, encoded)
+		snapshots = append(snapshots, current...)
+	}
+
+	return j.record(methodTorrentSet, requests, snapshots, nil, entry.ID, errors.Join(errs...))
+}
+
+// undoTorrentSetArgs returns the torrent-set that restores snapshot, and the
+// fields that it changes.
+func undoTorrentSetArgs(snapshot TorrentSnapshot) (transmission.TorrentSetArgs, []string, error) {
+	inverse, err := inverseTorrentSet(snapshot)
+	if err != nil {
+		return transmission.TorrentSetArgs{}, nil, err
+	}
+	args, err := decodeArgs[transmission.TorrentSetArgs](inverse)
+	if err != nil {
+		return transmission.TorrentSetArgs{}, nil, err
+	}
+	args.Ids = []any{snapshot.ID}
+
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return transmission.TorrentSetArgs{}, nil, fmt.Errorf("error encoding arguments: %w", err)
+	}
+	fields, err := changedTorrentFields(encoded)
+	if err != nil {
+		return transmission.TorrentSetArgs{}, nil, err
+	}
+	return args, fields, nil
+}
+
+func (j *Journal) undoTorrentSetLocation(ctx context.Context, entry Entry) (*Entry, error) {
+	// Move the data back only if the original request moved it.
+	var original struct {
+		Move bool `json:"move"`
+	}
+	if len(entry.Requests) > 0 {
+		if err := json.Unmarshal(entry.Requests[0], &original); err != nil {
+			return nil, fmt.Errorf("error decoding arguments: %w", err)
+		}
+	}
+
+	var (
+		requests  []json.RawMessage
+		snapshots []TorrentSnapshot
+		errs      []error
+	)
+	for _, snapshot := range entry.Torrents {
+		var location string
+		if err := json.Unmarshal(snapshot.Old["downloadDir"], &location); err != nil {
+			return nil, fmt.Errorf("error decoding previous location of torrent %d: %w", snapshot.ID, err)
+		}
+		args := transmission.TorrentSetLocationArgs{
+			IDs:      transmission.NewTorrentIDs(snapshot.ID),
+			Location: location,
+			Move:     original.Move,
+		}
+
+		current, err := j.snapshotTorrents(ctx, args.IDs, []string{"downloadDir"})
+		if err != nil {
+			// Record the torrents restored so far.
+			errs = append(errs, err)
+			break
+		}
+		if err := j.client.TorrentSetLocation(ctx, args); err != nil {
+			errs = append(errs, fmt.Errorf("torrent %d: %w", snapshot.ID, err))
+		}
+
+		encoded, err := json.Marshal(args)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding arguments: %w", err)
+		}
+		requests = append(requests, encoded)
+		snapshots = append(snapshots, current...)
+	}
+
+	return j.record(methodTorrentSetLocation, requests, snapshots, nil, entry.ID, errors.Join(errs...))
+}
+
+func decodeArgs[T any](values map[string]json.RawMessage) (T, error) {
+	var args T
+	data, err := json.Marshal(values)
+	if err != nil {
+		return args, fmt.Errorf("error encoding arguments: %w", err)
+	}
+	if err := json.Unmarshal(data, &args); err != nil {
+		return args, fmt.Errorf("error decoding arguments: %w", err)
+	}
+	return args, nil
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,306 @@
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+)
+
+// fakeClient applies requests to in-memory torrents and session.
+type fakeClient struct {
+	torrents map[int64]*transmission.Torrent
+	session  transmission.Session
+	setErr   error
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		torrents: map[int64]*transmission.Torrent{
+			1: {ID: 1, HashString: "aa", SeedRatioLimit: 2, PeerLimit: 50, Labels: []string{"movies"}, DownloadDir: "/a", Wanted: []int64{1, 1, 0}},
+			2: {ID: 2, HashString: "bb", SeedRatioLimit: 3, DownloadDir: "/b", Wanted: []int64{1}},
+		},
+		session: transmission.Session{DownloadDir: "/downloads", SpeedLimitDown: 100},
+	}
+}
+
+// selected returns the torrents with ids, or all of them if all is true.
+func (c *fakeClient) selected(ids []any, all bool) []*transmission.Torrent {
+	var result []*transmission.Torrent
+	for _, id := range []int64{1, 2} {
+		if all || containsID(ids, id) {
+			result = append(result, c.torrents[id])
+		}
+	}
+	return result
+}
+
+func containsID(ids []any, id int64) bool {
+	for _, i := range ids {
+		if n, ok := i.(int64); ok && n == id {
+			return true
+		}
+		if f, ok := i.(float64); ok && int64(f) == id {
+			return true
+		}
+	}
+	return false
+}
+
+func torrentIDs(ids *transmission.TorrentIDs) []any {
+	if ids == nil {
+		return nil
+	}
+	data, _ := json.Marshal(ids)
+	var result []any
+	_ = json.Unmarshal(data, &result)
+	return result
+}
+
+func (c *fakeClient) TorrentGet(_ context.Context, args transmission.TorrentGetArgs) (*transmission.TorrentGetResult, error) {
+	var result transmission.TorrentGetResult
+	// Like the daemon, return only the requested fields by their wire names.
+	for _, torrent := range c.selected(torrentIDs(args.IDs), args.IDs == transmission.AllTorrents) {
+		data, err := json.Marshal(torrent)
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		for name := range fields {
+			if !slices.Contains(args.Fields, name) {
+				delete(fields, name)
+			}
+		}
+		data, err = json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		var requested transmission.Torrent
+		if err := json.Unmarshal(data, &requested); err != nil {
+			return nil, err
+		}
+		result.Torrents = append(result.Torrents, requested)
+	}
+	return &result, nil
+}
+
+func (c *fakeClient) TorrentSet(_ context.Context, args transmission.TorrentSetArgs) error {
+	if c.setErr != nil {
+		return c.setErr
+	}
+	for _, torrent := range c.selected(args.Ids, len(args.Ids) == 0) {
+		if args.SeedRatioLimit != nil {
+			torrent.SeedRatioLimit = *args.SeedRatioLimit
+		}
+		if args.Labels != nil {
+			torrent.Labels = *args.Labels
+		}
+		if args.Location != nil {
+			torrent.DownloadDir = *args.Location
+		}
+		if args.PeerLimit != nil {
+			torrent.PeerLimit = int64(*args.PeerLimit)
+		}
+		for _, i := range args.FilesWanted {
+			torrent.Wanted[i] = 1
+		}
+		for _, i := range args.FilesUnwanted {
+			torrent.Wanted[i] = 0
+		}
+	}
+	return nil
+}
+
+func (c *fakeClient) TorrentSetLocation(_ context.Context, args transmission.TorrentSetLocationArgs) error {
+	for _, torrent := range c.selected(torrentIDs(args.IDs), args.IDs == transmission.AllTorrents) {
+		torrent.DownloadDir = args.Location
+	}
+	return nil
+}
+
+func (c *fakeClient) SessionGet(context.Context) (*transmission.Session, error) {
+	session := c.session
+	return &session, nil
+}
+
+func (c *fakeClient) SessionSet(_ context.Context, args transmission.SessionSetArgs) error {
+	if args.DownloadDir != nil {
+		c.session.DownloadDir = *args.DownloadDir
+	}
+	if args.SpeedLimitDown != nil {
+		c.session.SpeedLimitDown = *args.SpeedLimitDown
+	}
+	return nil
+}
+
+func openJournal(t *testing.T) (*Journal, *fakeClient) {
+	t.Helper()
+	client := newFakeClient()
+	j, err := Open(client, t.TempDir()+"/journal.jsonl")
+	require.NoError(t, err)
+	return j, client
+}
+
+func TestTorrentSetUndo(t *testing.T) {
+	ctx := context.Background()
+	j, client := openJournal(t)
+
+	// Without ids, the change applies to every torrent, so they must all be
+	// snapshotted.
+	ratio := 0.5
+	labels := []string{"tv"}
+	entry, err := j.TorrentSet(ctx, transmission.TorrentSetArgs{SeedRatioLimit: &ratio, Labels: &labels})
+	require.NoError(t, err)
+
+	assert.Equal(t, "torrent-set", entry.Method)
+	assert.JSONEq(t, `{"seedRatioLimit":0.5,"labels":["tv"]}`, string(entry.Requests[0]))
+	require.Len(t, entry.Torrents, 2)
+	assert.Equal(t, TorrentSnapshot{ID: 1, HashString: "aa", Old: map[string]json.RawMessage{
+		"seedRatioLimit": json.RawMessage(`2`),
+		"labels":         json.RawMessage(`["movies"]`),
+	}}, entry.Torrents[0])
+	assert.JSONEq(t, `[]`, string(entry.Torrents[1].Old["labels"]))
+	assert.Equal(t, 0.5, client.torrents[2].SeedRatioLimit)
+
+	entries, err := j.Entries()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, entry.ID, entries[0].ID)
+	assert.Equal(t, entry.Torrents, entries[0].Torrents)
+
+	undo, err := j.Undo(ctx, entry.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entry.ID, undo.UndoOf)
+	assert.Len(t, undo.Requests, 2)
+	assert.Equal(t, 2.0, client.torrents[1].SeedRatioLimit)
+	assert.Equal(t, []string{"movies"}, client.torrents[1].Labels)
+	assert.Equal(t, 3.0, client.torrents[2].SeedRatioLimit)
+	assert.Equal(t, []string{}, client.torrents[2].Labels)
+
+	_, err = j.Undo(ctx, entry.ID)
+	assert.ErrorIs(t, err, ErrAlreadyUndone)
+
+	// Undoing the undo reapplies the original change.
+	_, err = j.Undo(ctx, undo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, client.torrents[1].SeedRatioLimit)
+	assert.Equal(t, []string{"tv"}, client.torrents[1].Labels)
+
+	_, err = j.Undo(ctx, "nope")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestFileSelectionUndo(t *testing.T) {
+	ctx := context.Background()
+	j, client := openJournal(t)
+
+	entry, err := j.TorrentSet(ctx, transmission.TorrentSetArgs{Ids: []any{int64(1)}, FilesUnwanted: []int{0}, FilesWanted: []int{2}})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, 1, 1}, client.torrents[1].Wanted)
+	require.Len(t, entry.Torrents, 1)
+
+	_, err = j.Undo(ctx, entry.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 1, 0}, client.torrents[1].Wanted)
+}
+
+func TestPeerLimitUndo(t *testing.T) {
+	ctx := context.Background()
+	j, client := openJournal(t)
+
+	limit := 10
+	entry, err := j.TorrentSet(ctx, transmission.TorrentSetArgs{Ids: []any{int64(1)}, PeerLimit: &limit})
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), client.torrents[1].PeerLimit)
+	assert.JSONEq(t, `50`, string(entry.Torrents[0].Old["peer-limit"]))
+
+	undo, err := j.Undo(ctx, entry.ID)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"ids":[1],"peer-limit":50}`, string(undo.Requests[0]))
+	assert.Equal(t, int64(50), client.torrents[1].PeerLimit)
+}
+
+func TestTorrentSetLocationUndo(t *testing.T) {
+	ctx := context.Background()
+	j, client := openJournal(t)
+
+	entry, err := j.TorrentSetLocation(ctx, transmission.TorrentSetLocationArgs{IDs: transmission.AllTorrents, Location: "/new", Move: true})
+	require.NoError(t, err)
+	assert.Equal(t, "/new", client.torrents[1].DownloadDir)
+
+	undo, err := j.Undo(ctx, entry.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "/a", client.torrents[1].DownloadDir)
+	assert.Equal(t, "/b", client.torrents[2].DownloadDir)
+	assert.JSONEq(t, `{"ids":[1],"location":"/a","move":true}`, string(undo.Requests[0]))
+
+	_, err = j.Undo(ctx, undo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "/new", client.torrents[2].DownloadDir)
+}
+
+func TestSessionSetUndo(t *testing.T) {
+	ctx := context.Background()
+	j, client := openJournal(t)
+
+	dir, limit := "/elsewhere", 5
+	entry, err := j.SessionSet(ctx, transmission.SessionSetArgs{DownloadDir: &dir, SpeedLimitDown: &limit})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]json.RawMessage{
+		"download-dir":     json.RawMessage(`"/downloads"`),
+		"speed-limit-down": json.RawMessage(`100`),
+	}, entry.Session)
+	assert.Equal(t, "/elsewhere", client.session.DownloadDir)
+
+	_, err = j.Undo(ctx, entry.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "/downloads", client.session.DownloadDir)
+	assert.Equal(t, 100, client.session.SpeedLimitDown)
+}
+
+func TestFailedRequest(t *testing.T) {
+	ctx := context.Background()
+	j, client := openJournal(t)
+	client.setErr = errors.New("boom")
+
+	ratio := 1.0
+	entry, err := j.TorrentSet(ctx, transmission.TorrentSetArgs{SeedRatioLimit: &ratio})
+	assert.ErrorIs(t, err, client.setErr)
+	assert.Equal(t, "boom", entry.Error)
+
+	entries, err := j.Entries()
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+// Every torrent-set argument must be journaled, so new TorrentSetArgs fields
+// need adding to torrentSetFields.
+func TestTorrentSetFieldsComplete(t *testing.T) {
+	torrentFields := map[string]bool{}
+	for _, field := range reflect.VisibleFields(reflect.TypeFor[transmission.Torrent]()) {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		torrentFields[name] = true
+	}
+
+	for _, field := range reflect.VisibleFields(reflect.TypeFor[transmission.TorrentSetArgs]()) {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "ids" {
+			continue
+		}
+		torrentField, exists := torrentSetFields[name]
+		if assert.True(t, exists, name) {
+			assert.True(t, torrentFields[torrentField], torrentField)
+		}
+	}
+}
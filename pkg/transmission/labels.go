@@ -0,0 +1,192 @@
+package transmission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxLabelAttempts is how many times a label change is retried when the
+// labels are modified by someone else between reading and writing them.
+const maxLabelAttempts = 3
+
+// LabelConflictError is returned when torrents' labels keep changing while
+// they are being updated.
+type LabelConflictError struct {
+	// IDs are the torrents whose labels changed in the last attempt.
+	IDs []int64
+}
+
+func (e *LabelConflictError) Error() string {
+	ids := make([]string, len(e.IDs))
+	for i, id := range e.IDs {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	return fmt.Sprintf("labels of torrents %s were modified concurrently", strings.Join(ids, ", "))
+}
+
+// LabelChange records the labels of a torrent before and after an update.
+type LabelChange struct {
+	ID  int64
+	Old []string
+	New []string
+}
+
+// AddLabels adds labels to each torrent, keeping its existing labels.
+func (c *Client) AddLabels(ctx context.Context, ids *TorrentIDs, labels ...string) ([]LabelChange, error) {
+	if err := validateLabels(labels); err != nil {
+		return nil, err
+	}
+	return c.modifyLabels(ctx, ids, func(current []string) []string {
+		return dedupeLabels(append(slices.Clone(current), labels...))
+	})
+}
+
+// RemoveLabels removes labels from each torrent, keeping its other labels.
+func (c *Client) RemoveLabels(ctx context.Context, ids *TorrentIDs, labels ...string) ([]LabelChange, error) {
+	return c.modifyLabels(ctx, ids, func(current []string) []string {
+		return slices.DeleteFunc(slices.Clone(current), func(label string) bool {
+			return slices.Contains(labels, label)
+		})
+	})
+}
+
+// ReplaceLabels sets each torrent's labels to exactly labels. Passing no
+// labels removes all labels.
+func (c *Client) ReplaceLabels(ctx context.Context, ids *TorrentIDs, labels ...string) ([]LabelChange, error) {
+	if err := validateLabels(labels); err != nil {
+		return nil, err
+	}
+	return c.modifyLabels(ctx, ids, func([]string) []string {
+		return dedupeLabels(labels)
+	})
+}
+
+// modifyLabels applies update to the labels of each torrent. torrent-set
+// replaces the whole label list, so labels are read, updated and then
+// re-read just before writing; if they changed in between, the whole update
+// is retried with the new labels. This catches another writer that changed
+// the labels while the update was computed, but the RPC has no
+// compare-and-set, so a change made between the re-read and the write is
+// still lost.
+//
+// Only torrents whose labels change are returned.
+func (c *Client) modifyLabels(ctx context.Context, ids *TorrentIDs, update func([]string) []string) ([]LabelChange, error) {
+	var conflict []int64
+	for attempt := 0; attempt < maxLabelAttempts; attempt++ {
+		current, err := c.getLabels(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+
+		var changes []LabelChange
+		var changedIDs []any
+		for _, torrent := range current {
+			newLabels := update(torrent.Labels)
+			if slices.Equal(torrent.Labels, newLabels) {
+				continue
+			}
+			changes = append(changes, LabelChange{ID: torrent.ID, Old: torrent.Labels, New: newLabels})
+			changedIDs = append(changedIDs, torrent.ID)
+		}
+		if len(changes) == 0 {
+			return nil, nil
+		}
+
+		reread, err := c.getLabels(ctx, NewTorrentIDs(changedIDs...))
+		if err != nil {
+			return nil, err
+		}
+		if conflict = labelsModified(changes, reread); len(conflict) > 0 {
+			continue
+		}
+
+		if err := c.setLabels(ctx, changes); err != nil {
+			return nil, err
+		}
+		return changes, nil
+	}
+
+	return nil, fmt.Errorf("error updating labels after %d attempts: %w", maxLabelAttempts, &LabelConflictError{IDs: conflict})
+}
+
+func (c *Client) getLabels(ctx context.Context, ids *TorrentIDs) ([]Torrent, error) {
+	result, err := c.TorrentGet(ctx, TorrentGetArgs{IDs: ids, Fields: []string{"id", "labels"}})
+	if err != nil {
+		return nil, fmt.Errorf("error getting labels: %w", err)
+	}
+	return result.Torrents, nil
+}
+
+// labelsModified returns the IDs of the torrents whose labels in reread
+// differ from the ones changes were computed from.
+func labelsModified(changes []LabelChange, reread []Torrent) []int64 {
+	labelsByID := make(map[int64][]string, len(reread))
+	for _, torrent := range reread {
+		labelsByID[torrent.ID] = torrent.Labels
+	}
+
+	var ids []int64
+	for _, change := range changes {
+		labels, exists := labelsByID[change.ID]
+		if !exists || !slices.Equal(labels, change.Old) {
+			ids = append(ids, change.ID)
+		}
+	}
+	return ids
+}
+
+// setLabels writes the changes with one torrent-set per distinct label list.
+func (c *Client) setLabels(ctx context.Context, changes []LabelChange) error {
+	var groups [][]LabelChange
+	for _, change := range changes {
+		i := slices.IndexFunc(groups, func(group []LabelChange) bool {
+			return slices.Equal(group[0].New, change.New)
+		})
+		if i < 0 {
+			groups = append(groups, []LabelChange{change})
+			continue
+		}
+		groups[i] = append(groups[i], change)
+	}
+
+	for _, group := range groups {
+		labels := group[0].New
+		if labels == nil {
+			labels = []string{}
+		}
+		args := TorrentSetArgs{Labels: &labels}
+		for _, change := range group {
+			args.Ids = append(args.Ids, change.ID)
+		}
+		if err := c.TorrentSet(ctx, args); err != nil {
+			return fmt.Errorf("error setting labels: %w", err)
+		}
+	}
+	return nil
+}
+
+func validateLabels(labels []string) error {
+	for _, label := range labels {
+		if strings.TrimSpace(label) == "" {
+			return errors.New("labels must not be empty")
+		}
+		if strings.Contains(label, ",") {
+			return fmt.Errorf("label %q must not contain a comma", label)
+		}
+	}
+	return nil
+}
+
+func dedupeLabels(labels []string) []string {
+	var result []string
+	for _, label := range labels {
+		if !slices.Contains(result, label) {
+			result = append(result, label)
+		}
+	}
+	return result
+}
@@ -0,0 +1,178 @@
+package transmission
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// labelStore is a fake daemon's torrent labels.
+type labelStore struct {
+	mutex  sync.Mutex
+	labels map[int64][]string
+	// onGet is called before each torrent-get, with the number of previous
+	// torrent-get calls.
+	onGet func(call int, labels map[int64][]string)
+	gets  int
+}
+
+func (s *labelStore) handle(t *testing.T) rpcHandler {
+	return func(call rpcCall) (any, error) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		switch call.Method {
+		case "torrent-get":
+			if s.onGet != nil {
+				s.onGet(s.gets, s.labels)
+			}
+			s.gets++
+
+			args := decodeArgs[struct {
+				IDs []int64 `json:"ids"`
+			}](t, call)
+			var torrents []Torrent
+			for _, id := range []int64{1, 2, 3} {
+				if args.IDs == nil || containsID(args.IDs, id) {
+					torrents = append(torrents, Torrent{ID: id, Labels: s.labels[id]})
+				}
+			}
+			return TorrentGetResult{Torrents: torrents}, nil
+		case "torrent-set":
+			var args struct {
+				Ids    []int64  `json:"ids"`
+				Labels []string `json:"labels"`
+			}
+			require.NoError(t, json.Unmarshal(call.Arguments, &args))
+			for _, id := range args.Ids {
+				s.labels[id] = args.Labels
+			}
+		}
+		return nil, nil
+	}
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAddLabels(t *testing.T) {
+	store := &labelStore{labels: map[int64][]string{
+		1: {"movies"},
+		2: nil,
+		3: {"movies", "hd"},
+	}}
+	client, server := newFakeServer(t, store.handle(t))
+
+	changes, err := client.AddLabels(context.Background(), AllTorrents, "hd", "hd")
+	require.NoError(t, err)
+
+	assert.Equal(t, []LabelChange{
+		{ID: 1, Old: []string{"movies"}, New: []string{"movies", "hd"}},
+		{ID: 2, Old: nil, New: []string{"hd"}},
+	}, changes)
+	assert.Equal(t, map[int64][]string{
+		1: {"movies", "hd"},
+		2: {"hd"},
+		3: {"movies", "hd"},
+	}, store.labels)
+	assert.Len(t, server.CallsTo("torrent-set"), 2, "one torrent-set per distinct label list")
+}
+
+func TestRemoveLabels(t *testing.T) {
+	store := &labelStore{labels: map[int64][]string{
+		1: {"movies", "tmp"},
+		2: {"tmp"},
+		3: {"tv"},
+	}}
+	client, server := newFakeServer(t, store.handle(t))
+
+	changes, err := client.RemoveLabels(context.Background(), NewTorrentIDs(1, 2, 3), "tmp")
+	require.NoError(t, err)
+
+	assert.Len(t, changes, 2)
+	assert.Equal(t, map[int64][]string{
+		1: {"movies"},
+		2: {},
+		3: {"tv"},
+	}, store.labels)
+
+	var args map[string]any
+	require.NoError(t, json.Unmarshal(server.CallsTo("torrent-set")[1].Arguments, &args))
+	assert.Equal(t, []any{}, args["labels"], "removing the last label should send an empty list")
+}
+
+func TestReplaceLabels(t *testing.T) {
+	store := &labelStore{labels: map[int64][]string{
+		1: {"a"},
+		2: {"b", "c"},
+		3: {"x"},
+	}}
+	client, server := newFakeServer(t, store.handle(t))
+
+	changes, err := client.ReplaceLabels(context.Background(), NewTorrentIDs(1, 2, 3), "x")
+	require.NoError(t, err)
+
+	assert.Len(t, changes, 2)
+	assert.Equal(t, []string{"x"}, store.labels[1])
+	assert.Equal(t, []string{"x"}, store.labels[2])
+	assert.Len(t, server.CallsTo("torrent-set"), 1)
+
+	t.Run("invalid labels", func(t *testing.T) {
+		_, err := client.ReplaceLabels(context.Background(), AllTorrents, "a,b")
+		assert.Error(t, err)
+
+		_, err = client.AddLabels(context.Background(), AllTorrents, " ")
+		assert.Error(t, err)
+	})
+}
+
+func TestLabelsConcurrentModification(t *testing.T) {
+	t.Run("retries", func(t *testing.T) {
+		store := &labelStore{
+			labels: map[int64][]string{1: {"a"}},
+			onGet: func(call int, labels map[int64][]string) {
+				// Another writer adds a label between our first read and
+				// the re-read.
+				if call == 1 {
+					labels[1] = []string{"a", "other"}
+				}
+			},
+		}
+		client, _ := newFakeServer(t, store.handle(t))
+
+		changes, err := client.AddLabels(context.Background(), NewTorrentIDs(1), "mine")
+		require.NoError(t, err)
+
+		assert.Equal(t, []LabelChange{{ID: 1, Old: []string{"a", "other"}, New: []string{"a", "other", "mine"}}}, changes)
+		assert.Equal(t, []string{"a", "other", "mine"}, store.labels[1])
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		store := &labelStore{
+			labels: map[int64][]string{1: {"a"}},
+			onGet: func(call int, labels map[int64][]string) {
+				if call%2 == 1 {
+					labels[1] = append(labels[1], "other")
+				}
+			},
+		}
+		client, server := newFakeServer(t, store.handle(t))
+
+		_, err := client.AddLabels(context.Background(), NewTorrentIDs(1), "mine")
+		var conflict *LabelConflictError
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, []int64{1}, conflict.IDs)
+		assert.EqualError(t, err, "error updating labels after 3 attempts: labels of torrents 1 were modified concurrently")
+		assert.Empty(t, server.CallsTo("torrent-set"))
+	})
+}
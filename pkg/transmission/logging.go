@@ -0,0 +1,125 @@
+package transmission
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// maxLoggedBodyBytes caps the size of request and response bodies written
+	// to debug logs.
+	maxLoggedBodyBytes = 2048
+
+	redacted = "[REDACTED]"
+)
+
+// redactedHeaders are never written to logs verbatim.
+var redactedHeaders = []string{"Authorization", sessionIDHeader}
+
+// redactedBodyKeys are JSON object keys whose values are never written to logs
+// verbatim, at any depth of a request or response body.
+var redactedBodyKeys = map[string]bool{
+	"metainfo":   true,
+	"session-id": true,
+}
+
+type rpcHeaders struct {
+	request  http.Header
+	response http.Header
+}
+
+func newRPCHeaders(resp *http.Response) rpcHeaders {
+	headers := rpcHeaders{response: resp.Header}
+	if resp.Request != nil {
+		headers.request = resp.Request.Header
+	}
+	return headers
+}
+
+type rpcLogEntry struct {
+	method       string
+	duration     time.Duration
+	statusCode   int
+	headers      rpcHeaders
+	requestBody  []byte
+	responseBody []byte
+	err          error
+}
+
+func (c *Client) logRPC(ctx context.Context, entry rpcLogEntry) {
+	attrs := []slog.Attr{
+		slog.String("method", entry.method),
+		slog.Duration("duration", entry.duration),
+		slog.Int("statusCode", entry.statusCode),
+	}
+
+	if c.logger.Enabled(ctx, slog.LevelDebug) {
+		attrs = append(attrs,
+			slog.Any("requestHeaders", redactHeaders(entry.headers.request)),
+			slog.Any("responseHeaders", redactHeaders(entry.headers.response)),
+			slog.String("requestBody", redactBody(entry.requestBody)),
+			slog.String("responseBody", redactBody(entry.responseBody)),
+		)
+	}
+
+	if entry.err != nil {
+		attrs = append(attrs, slog.Any("err", entry.err))
+		c.logger.LogAttrs(ctx, slog.LevelWarn, "transmission RPC request failed", attrs...)
+		return
+	}
+	c.logger.LogAttrs(ctx, slog.LevelInfo, "transmission RPC request", attrs...)
+}
+
+func redactHeaders(header http.Header) http.Header {
+	if header == nil {
+		return nil
+	}
+
+	result := header.Clone()
+	for _, name := range redactedHeaders {
+		if result.Get(name) != "" {
+			result.Set(name, redacted)
+		}
+	}
+	return result
+}
+
+// redactBody replaces sensitive values in a JSON body and truncates the result
+// to maxLoggedBodyBytes. Bodies that are not valid JSON are truncated only.
+func redactBody(body []byte) string {
+	var decoded any
+	if err := json.Unmarshal(body, &decoded); err == nil {
+		if redactedJSON, err := json.Marshal(redactValue(decoded)); err == nil {
+			body = redactedJSON
+		}
+	}
+
+	if len(body) > maxLoggedBodyBytes {
+		return string(body[:maxLoggedBodyBytes]) + "...(truncated)"
+	}
+	return string(body)
+}
+
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if redactedBodyKeys[key] {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redactValue(child)
+		}
+		return v
+	case []any:
+		for i, child := range v {
+			v[i] = redactValue(child)
+		}
+		return v
+	default:
+		return v
+	}
+}
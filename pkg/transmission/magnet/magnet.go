@@ -0,0 +1,214 @@
+// Package magnet parses and builds magnet URIs (BEP 9, BEP 53).
+package magnet
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	btihPrefix = "urn:btih:"
+	btmhPrefix = "urn:btmh:"
+
+	// sha256MultihashPrefix is the multihash header for a 32 byte SHA-256
+	// digest, which is the only multihash BitTorrent v2 uses.
+	sha256MultihashPrefix = "1220"
+)
+
+// Magnet is a parsed magnet URI.
+type Magnet struct {
+	// InfoHashV1 is the lower case hex encoded v1 info hash (xt=urn:btih).
+	InfoHashV1 string
+	// InfoHashV2 is the lower case hex encoded v2 info hash, without the
+	// multihash header (xt=urn:btmh).
+	InfoHashV2 string
+	// DisplayName is the suggested torrent name (dn).
+	DisplayName string
+	// Trackers are announce URLs (tr).
+	Trackers []string
+	// WebSeeds are web seed URLs (ws).
+	WebSeeds []string
+	// ExactLength is the total size in bytes (xl), or 0 if not given.
+	ExactLength int64
+}
+
+// Parse parses a magnet URI. At least one of the v1 or v2 info hashes must
+// be present. v1 hashes may be hex or base32 encoded and are normalised to
+// lower case hex.
+func Parse(uri string) (*Magnet, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing magnet URI: %w", err)
+	}
+	if u.Scheme != "magnet" {
+		return nil, fmt.Errorf("invalid magnet URI scheme %q", u.Scheme)
+	}
+
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing magnet URI query: %w", err)
+	}
+
+	m := &Magnet{
+		DisplayName: query.Get("dn"),
+		Trackers:    dedupe(collectIndexed(query, "tr")),
+		WebSeeds:    dedupe(collectIndexed(query, "ws")),
+	}
+
+	for _, xt := range collectIndexed(query, "xt") {
+		lower := strings.ToLower(xt)
+		switch {
+		case strings.HasPrefix(lower, btihPrefix):
+			hash, err := parseBTIH(xt[len(btihPrefix):])
+			if err != nil {
+				return nil, err
+			}
+			m.InfoHashV1 = hash
+		case strings.HasPrefix(lower, btmhPrefix):
+			hash, err := parseBTMH(xt[len(btmhPrefix):])
+			if err != nil {
+				return nil, err
+			}
+			m.InfoHashV2 = hash
+		}
+	}
+
+	if m.InfoHashV1 == "" && m.InfoHashV2 == "" {
+		return nil, errors.New("magnet URI has no BitTorrent info hash")
+	}
+
+	if xl := query.Get("xl"); xl != "" {
+		m.ExactLength, err = strconv.ParseInt(xl, 10, 64)
+		if err != nil || m.ExactLength < 0 {
+			return nil, fmt.Errorf("invalid exact length %q", xl)
+		}
+	}
+
+	return m, nil
+}
+
+// collectIndexed returns the values of key and of its indexed variants
+// (key.1, key.2, ...), which some clients use for multiple trackers.
+func collectIndexed(query url.Values, key string) []string {
+	values := slices.Clone(query[key])
+
+	var indexedKeys []string
+	for k := range query {
+		if strings.HasPrefix(k, key+".") {
+			indexedKeys = append(indexedKeys, k)
+		}
+	}
+	slices.Sort(indexedKeys)
+	for _, k := range indexedKeys {
+		values = append(values, query[k]...)
+	}
+	return values
+}
+
+func parseBTIH(s string) (string, error) {
+	switch len(s) {
+	case 40:
+		if _, err := hex.DecodeString(s); err != nil {
+			return "", fmt.Errorf("invalid hex info hash %q", s)
+		}
+		return strings.ToLower(s), nil
+	case 32:
+		decoded, err := base32.StdEncoding.DecodeString(strings.ToUpper(s))
+		if err != nil {
+			return "", fmt.Errorf("invalid base32 info hash %q", s)
+		}
+		return hex.EncodeToString(decoded), nil
+	default:
+		return "", fmt.Errorf("invalid info hash length %d", len(s))
+	}
+}
+
+func parseBTMH(s string) (string, error) {
+	s = strings.ToLower(s)
+	if !strings.HasPrefix(s, sha256MultihashPrefix) || len(s) != len(sha256MultihashPrefix)+64 {
+		return "", fmt.Errorf("unsupported v2 info hash %q", s)
+	}
+	if _, err := hex.DecodeString(s); err != nil {
+		return "", fmt.Errorf("invalid v2 info hash %q", s)
+	}
+	return s[len(sha256MultihashPrefix):], nil
+}
+
+// HashString returns the hash Transmission uses to identify the torrent, as
+// in transmission.Torrent.HashString: the v1 info hash, or the v2 info hash
+// truncated to 20 bytes. It's empty if the magnet has neither hash, which
+// Parse never returns but a Magnet built by hand may have.
+func (m *Magnet) HashString() string {
+	if m.InfoHashV1 != "" {
+		return m.InfoHashV1
+	}
+	if len(m.InfoHashV2) < 40 {
+		return ""
+	}
+	return m.InfoHashV2[:40]
+}
+
+// String builds the magnet URI. Parameters are written in a fixed order so
+// that equal magnets produce equal URIs.
+func (m *Magnet) String() string {
+	var params []string
+	if m.InfoHashV1 != "" {
+		params = append(params, "xt="+btihPrefix+m.InfoHashV1)
+	}
+	if m.InfoHashV2 != "" {
+		params = append(params, "xt="+btmhPrefix+sha256MultihashPrefix+m.InfoHashV2)
+	}
+	if m.DisplayName != "" {
+		params = append(params, "dn="+url.QueryEscape(m.DisplayName))
+	}
+	if m.ExactLength > 0 {
+		params = append(params, "xl="+strconv.FormatInt(m.ExactLength, 10))
+	}
+	for _, tracker := range m.Trackers {
+		params = append(params, "tr="+url.QueryEscape(tracker))
+	}
+	for _, webSeed := range m.WebSeeds {
+		params = append(params, "ws="+url.QueryEscape(webSeed))
+	}
+	return "magnet:?" + strings.Join(params, "&")
+}
+
+// WithTrackers returns a copy of the magnet with trackers appended, skipping
+// any that are already present.
+func (m *Magnet) WithTrackers(trackers ...string) *Magnet {
+	result := m.clone()
+	result.Trackers = dedupe(append(result.Trackers, trackers...))
+	return result
+}
+
+// WithoutTrackers returns a copy of the magnet without the given trackers.
+func (m *Magnet) WithoutTrackers(trackers ...string) *Magnet {
+	result := m.clone()
+	result.Trackers = slices.DeleteFunc(result.Trackers, func(tracker string) bool {
+		return slices.Contains(trackers, tracker)
+	})
+	return result
+}
+
+func (m *Magnet) clone() *Magnet {
+	result := *m
+	result.Trackers = slices.Clone(m.Trackers)
+	result.WebSeeds = slices.Clone(m.WebSeeds)
+	return &result
+}
+
+func dedupe(values []string) []string {
+	var result []string
+	for _, value := range values {
+		if value != "" && !slices.Contains(result, value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
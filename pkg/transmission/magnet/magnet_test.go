@@ -0,0 +1,99 @@
+package magnet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const hashV1 = "c12fe1c06bba254a9dc9f519b335aa7c1367a88a"
+
+func TestParse(t *testing.T) {
+	t.Run("hex info hash", func(t *testing.T) {
+		m, err := Parse("magnet:?xt=urn:btih:C12FE1C06BBA254A9DC9F519B335AA7C1367A88A&dn=Big+Buck+Bunny&tr=udp%3A%2F%2Ftracker.example%3A80&tr=udp%3A%2F%2Ftracker.example%3A80&ws=https%3A%2F%2Fseed.example%2F&xl=276445467")
+		require.NoError(t, err)
+
+		assert.Equal(t, &Magnet{
+			InfoHashV1:  hashV1,
+			DisplayName: "Big Buck Bunny",
+			Trackers:    []string{"udp://tracker.example:80"},
+			WebSeeds:    []string{"https://seed.example/"},
+			ExactLength: 276445467,
+		}, m)
+		assert.Equal(t, hashV1, m.HashString())
+	})
+
+	t.Run("base32 info hash", func(t *testing.T) {
+		m, err := Parse("magnet:?xt=urn:btih:YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK")
+		require.NoError(t, err)
+		assert.Equal(t, hashV1, m.InfoHashV1)
+	})
+
+	t.Run("hybrid", func(t *testing.T) {
+		m, err := Parse("magnet:?xt=urn:btih:" + hashV1 + "&xt=urn:btmh:1220caf1e1c30e81cb361b9ee167c4aa64228a7fa4fa9f6105232b28ad099f3a302e")
+		require.NoError(t, err)
+		assert.Equal(t, hashV1, m.InfoHashV1)
+		assert.Equal(t, "caf1e1c30e81cb361b9ee167c4aa64228a7fa4fa9f6105232b28ad099f3a302e", m.InfoHashV2)
+		assert.Equal(t, hashV1, m.HashString())
+	})
+
+	t.Run("v2 only", func(t *testing.T) {
+		m, err := Parse("magnet:?xt=urn:btmh:1220caf1e1c30e81cb361b9ee167c4aa64228a7fa4fa9f6105232b28ad099f3a302e")
+		require.NoError(t, err)
+		assert.Equal(t, "caf1e1c30e81cb361b9ee167c4aa64228a7fa4fa", m.HashString())
+	})
+
+	t.Run("indexed trackers", func(t *testing.T) {
+		m, err := Parse("magnet:?xt=urn:btih:" + hashV1 + "&tr.1=http%3A%2F%2Fa%2Fann&tr.2=http%3A%2F%2Fb%2Fann")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"http://a/ann", "http://b/ann"}, m.Trackers)
+	})
+
+	for name, uri := range map[string]string{
+		"wrong scheme":      "http://example.com/?xt=urn:btih:" + hashV1,
+		"no info hash":      "magnet:?dn=foo",
+		"short info hash":   "magnet:?xt=urn:btih:abc",
+		"invalid hex":       "magnet:?xt=urn:btih:zz2fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		"invalid multihash": "magnet:?xt=urn:btmh:1114caf1e1c30e81cb361b9ee167c4aa64228a7f",
+		"negative length":   "magnet:?xt=urn:btih:" + hashV1 + "&xl=-1",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(uri)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	m := &Magnet{
+		InfoHashV1:  hashV1,
+		DisplayName: "Big Buck Bunny",
+		Trackers:    []string{"udp://tracker.example:80/announce"},
+		ExactLength: 10,
+	}
+
+	uri := m.String()
+	assert.Equal(t, "magnet:?xt=urn:btih:"+hashV1+"&dn=Big+Buck+Bunny&xl=10&tr=udp%3A%2F%2Ftracker.example%3A80%2Fannounce", uri)
+
+	parsed, err := Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, m, parsed)
+}
+
+func TestHashStringWithoutHash(t *testing.T) {
+	assert.Empty(t, (&Magnet{}).HashString())
+	assert.Empty(t, (&Magnet{InfoHashV2: "caf1e1"}).HashString())
+}
+
+func TestTrackers(t *testing.T) {
+	m := &Magnet{InfoHashV1: hashV1, Trackers: []string{"http://a/ann", "http://b/ann"}}
+
+	added := m.WithTrackers("http://b/ann", "http://c/ann")
+	assert.Equal(t, []string{"http://a/ann", "http://b/ann", "http://c/ann"}, added.Trackers)
+
+	removed := added.WithoutTrackers("http://a/ann")
+	assert.Equal(t, []string{"http://b/ann", "http://c/ann"}, removed.Trackers)
+
+	assert.Equal(t, []string{"http://a/ann", "http://b/ann"}, m.Trackers, "original should not be modified")
+}
@@ -0,0 +1,589 @@
+package metainfo
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission/bencode"
+)
+
+const (
+	// blockSize is the size of the leaves of a v2 merkle tree.
+	blockSize = 16 * 1024
+
+	minPieceLength = blockSize
+	maxPieceLength = 16 * 1024 * 1024
+
+	// targetPieceCount is the number of pieces an automatic piece length
+	// aims for: fewer pieces means a smaller .torrent file, more pieces means
+	// finer grained verification and sharing.
+	targetPieceCount = 1500
+)
+
+// CreateOptions configures Create.
+type CreateOptions struct {
+	// Path is the file or directory to create a torrent for. The torrent is
+	// named after its base name.
+	Path string
+
+	// Version defaults to VersionV1, which every Transmission version
+	// supports. VersionHybrid requires Transmission 4 for v2 features but
+	// remains downloadable by v1 clients.
+	Version Version
+
+	// PieceLength must be a power of two of at least 16 KiB. Zero picks one
+	// based on the total size.
+	PieceLength int64
+
+	Private   bool
+	Trackers  [][]string
+	WebSeeds  []string
+	Comment   string
+	CreatedBy string
+	Source    string
+
+	// CreationDate defaults to the current time.
+	CreationDate time.Time
+
+	// Workers is the number of pieces hashed in parallel. It defaults to the
+	// number of CPUs.
+	Workers int
+
+	// Progress, if set, is called after each piece is hashed with the number
+	// of bytes hashed so far and the total. It is called from worker
+	// goroutines, but never concurrently.
+	Progress func(hashed, total int64)
+}
+
+// Create builds a torrent for the files at opts.Path. Pieces are hashed in
+// parallel; hashing stops early if ctx is cancelled.
+func Create(ctx context.Context, opts CreateOptions) (*Metainfo, error) {
+	if opts.Version == 0 {
+		opts.Version = VersionV1
+	}
+	if opts.Version < VersionV1 || opts.Version > VersionHybrid {
+		return nil, fmt.Errorf("invalid version %d", opts.Version)
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+
+	name, files, singleFile, err := collectFiles(opts.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, file := range files {
+		total += file.Length
+	}
+	if total == 0 {
+		return nil, errors.New("cannot create a torrent with no data")
+	}
+
+	if opts.PieceLength == 0 {
+		opts.PieceLength = autoPieceLength(total)
+	}
+	if opts.PieceLength < minPieceLength || opts.PieceLength&(opts.PieceLength-1) != 0 {
+		return nil, fmt.Errorf("piece length %d must be a power of two of at least %d", opts.PieceLength, minPieceLength)
+	}
+
+	h := hasher{
+		opts:  opts,
+		files: files,
+		total: total,
+	}
+	if err := h.hash(ctx); err != nil {
+		return nil, err
+	}
+
+	info := map[string]any{
+		"name":         name,
+		"piece length": opts.PieceLength,
+	}
+	if opts.Private {
+		info["private"] = 1
+	}
+	if opts.Source != "" {
+		info["source"] = opts.Source
+	}
+
+	top := map[string]any{"info": info}
+	if opts.Version != VersionV2 {
+		info["pieces"] = h.v1Pieces()
+		if singleFile {
+			info["length"] = files[0].Length
+		} else {
+			info["files"] = h.v1Files()
+		}
+	}
+	if opts.Version != VersionV1 {
+		info["meta version"] = 2
+		info["file tree"] = h.v2FileTree(name, singleFile)
+		if layers := h.v2PieceLayers(); len(layers) > 0 {
+			top["piece layers"] = layers
+		}
+	}
+
+	addTopLevelFields(top, opts)
+
+	data, err := bencode.Encode(top)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding torrent: %w", err)
+	}
+	return Parse(data)
+}
+
+func addTopLevelFields(top map[string]any, opts CreateOptions) {
+	var trackers [][]string
+	for _, tier := range opts.Trackers {
+		if len(tier) > 0 {
+			trackers = append(trackers, tier)
+		}
+	}
+	if len(trackers) > 0 {
+		top["announce"] = trackers[0][0]
+		if len(trackers) > 1 || len(trackers[0]) > 1 {
+			top["announce-list"] = trackers
+		}
+	}
+
+	if len(opts.WebSeeds) > 0 {
+		top["url-list"] = opts.WebSeeds
+	}
+	if opts.Comment != "" {
+		top["comment"] = opts.Comment
+	}
+	if opts.CreatedBy != "" {
+		top["created by"] = opts.CreatedBy
+	}
+
+	creationDate := opts.CreationDate
+	if creationDate.IsZero() {
+		creationDate = time.Now()
+	}
+	top["creation date"] = creationDate.Unix()
+}
+
+func autoPieceLength(total int64) int64 {
+	pieceLength := int64(minPieceLength)
+	for pieceLength < maxPieceLength && total/pieceLength > targetPieceCount {
+		pieceLength *= 2
+	}
+	return pieceLength
+}
+
+type sourceFile struct {
+	// Path is relative to the torrent's root directory. It is empty for a
+	// single file torrent.
+	Path     []string
+	diskPath string
+	Length   int64
+}
+
+// collectFiles returns the regular files at root sorted by path, as required
+// by v2 file trees. Symlinks and other special files are skipped.
+func collectFiles(root string) (string, []sourceFile, bool, error) {
+	root = filepath.Clean(root)
+	stat, err := os.Stat(root)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("error reading %s: %w", root, err)
+	}
+	name := filepath.Base(root)
+
+	if stat.Mode().IsRegular() {
+		return name, []sourceFile{{diskPath: root, Length: stat.Size()}}, true, nil
+	}
+	if !stat.IsDir() {
+		return "", nil, false, fmt.Errorf("%s is not a file or directory", root)
+	}
+
+	var files []sourceFile
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, sourceFile{
+			Path:     strings.Split(filepath.ToSlash(rel), "/"),
+			diskPath: path,
+			Length:   info.Size(),
+		})
+		return nil
+	})
+	if err != nil {
+		return "", nil, false, fmt.Errorf("error walking %s: %w", root, err)
+	}
+	if len(files) == 0 {
+		return "", nil, false, fmt.Errorf("%s contains no files", root)
+	}
+
+	slices.SortFunc(files, func(a, b sourceFile) int {
+		return slices.Compare(a.Path, b.Path)
+	})
+	return name, files, false, nil
+}
+
+type hasher struct {
+	opts  CreateOptions
+	files []sourceFile
+	total int64
+
+	// v1 piece hashes, indexed by v1 piece.
+	v1Hashes [][sha1.Size]byte
+
+	// v2 per file results, indexed like files.
+	v2Roots  [][sha256.Size]byte
+	v2Layers [][][sha256.Size]byte
+	// v2Leaves holds the block hashes of files no larger than one piece,
+	// whose root is computed from the blocks rather than from piece hashes.
+	v2Leaves [][][sha256.Size]byte
+
+	progressMutex sync.Mutex
+	hashed        int64
+}
+
+// pieceJob is a piece to hash. For v1 torrents pieces span file boundaries
+// and segments may cover several files. For v2 and hybrid torrents every
+// file starts on a piece boundary, so a piece belongs to exactly one file.
+type pieceJob struct {
+	v1Index   int
+	fileIndex int
+	filePiece int
+	segments  []segment
+	// v1Padding is the number of zero bytes that follow the data in the v1
+	// piece layout of a hybrid torrent.
+	v1Padding int64
+}
+
+type segment struct {
+	fileIndex int
+	offset    int64
+	length    int64
+}
+
+func (h *hasher) jobs() []pieceJob {
+	pieceLength := h.opts.PieceLength
+	var jobs []pieceJob
+
+	if h.opts.Version == VersionV1 {
+		pieceCount := int((h.total + pieceLength - 1) / pieceLength)
+		fileIndex, fileOffset := 0, int64(0)
+		for piece := 0; piece < pieceCount; piece++ {
+			job := pieceJob{v1Index: piece}
+			remaining := min(pieceLength, h.total-int64(piece)*pieceLength)
+			for remaining > 0 {
+				available := h.files[fileIndex].Length - fileOffset
+				if available == 0 {
+					fileIndex, fileOffset = fileIndex+1, 0
+					continue
+				}
+				n := min(available, remaining)
+				job.segments = append(job.segments, segment{fileIndex: fileIndex, offset: fileOffset, length: n})
+				fileOffset += n
+				remaining -= n
+			}
+			jobs = append(jobs, job)
+		}
+		return jobs
+	}
+
+	v1Index := 0
+	for i, file := range h.files {
+		pieceCount := int((file.Length + pieceLength - 1) / pieceLength)
+		for piece := 0; piece < pieceCount; piece++ {
+			offset := int64(piece) * pieceLength
+			length := min(pieceLength, file.Length-offset)
+			job := pieceJob{
+				v1Index:   v1Index,
+				fileIndex: i,
+				filePiece: piece,
+				segments:  []segment{{fileIndex: i, offset: offset, length: length}},
+			}
+			if i != len(h.files)-1 {
+				job.v1Padding = pieceLength - length
+			}
+			jobs = append(jobs, job)
+			v1Index++
+		}
+	}
+	return jobs
+}
+
+func (h *hasher) hash(ctx context.Context) error {
+	jobs := h.jobs()
+	h.v1Hashes = make([][sha1.Size]byte, len(jobs))
+	h.v2Roots = make([][sha256.Size]byte, len(h.files))
+	h.v2Layers = make([][][sha256.Size]byte, len(h.files))
+	h.v2Leaves = make([][][sha256.Size]byte, len(h.files))
+	for i, file := range h.files {
+		h.v2Layers[i] = make([][sha256.Size]byte, (file.Length+h.opts.PieceLength-1)/h.opts.PieceLength)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobsCh := make(chan pieceJob)
+	errCh := make(chan error, h.opts.Workers)
+	var wg sync.WaitGroup
+	for range h.opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := h.worker(ctx, jobsCh); err != nil {
+				errCh <- err
+				cancel()
+			}
+		}()
+	}
+
+dispatch:
+	for _, job := range jobs {
+		select {
+		case jobsCh <- job:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobsCh)
+	wg.Wait()
+	close(errCh)
+
+	if err := <-errCh; err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	h.finishV2()
+	return nil
+}
+
+func (h *hasher) worker(ctx context.Context, jobs <-chan pieceJob) error {
+	openFiles := make(map[int]*os.File)
+	defer func() {
+		for _, f := range openFiles {
+			f.Close()
+		}
+	}()
+
+	buf := make([]byte, h.opts.PieceLength)
+	for job := range jobs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		data := buf[:0]
+		for _, seg := range job.segments {
+			f, ok := openFiles[seg.fileIndex]
+			if !ok {
+				var err error
+				if f, err = os.Open(h.files[seg.fileIndex].diskPath); err != nil {
+					return fmt.Errorf("error opening file: %w", err)
+				}
+				openFiles[seg.fileIndex] = f
+			}
+
+			start := len(data)
+			data = data[:start+int(seg.length)]
+			if _, err := f.ReadAt(data[start:], seg.offset); err != nil {
+				if errors.Is(err, io.EOF) {
+					return fmt.Errorf("%s changed size while hashing", f.Name())
+				}
+				return fmt.Errorf("error reading %s: %w", f.Name(), err)
+			}
+		}
+
+		h.hashPiece(job, data)
+		h.reportProgress(int64(len(data)))
+	}
+	return nil
+}
+
+func (h *hasher) hashPiece(job pieceJob, data []byte) {
+	if h.opts.Version != VersionV2 {
+		v1 := sha1.New()
+		v1.Write(data)
+		if job.v1Padding > 0 {
+			v1.Write(make([]byte, job.v1Padding))
+		}
+		copy(h.v1Hashes[job.v1Index][:], v1.Sum(nil))
+	}
+
+	if h.opts.Version == VersionV1 {
+		return
+	}
+
+	var leaves [][sha256.Size]byte
+	for offset := 0; offset < len(data); offset += blockSize {
+		leaves = append(leaves, sha256.Sum256(data[offset:min(offset+blockSize, len(data))]))
+	}
+
+	// Files no larger than a piece have no piece layer; their root is
+	// computed from their blocks once all pieces are hashed.
+	if h.files[job.fileIndex].Length <= h.opts.PieceLength {
+		h.v2Leaves[job.fileIndex] = leaves
+		return
+	}
+	h.v2Layers[job.fileIndex][job.filePiece] = merkleRoot(leaves, int(h.opts.PieceLength/blockSize), [sha256.Size]byte{})
+}
+
+func (h *hasher) reportProgress(n int64) {
+	h.progressMutex.Lock()
+	defer h.progressMutex.Unlock()
+
+	h.hashed += n
+	if h.opts.Progress != nil {
+		h.opts.Progress(h.hashed, h.total)
+	}
+}
+
+func (h *hasher) finishV2() {
+	if h.opts.Version == VersionV1 {
+		return
+	}
+
+	blocksPerPiece := int(h.opts.PieceLength / blockSize)
+	emptyPiece := merkleRoot(nil, blocksPerPiece, [sha256.Size]byte{})
+	for i, file := range h.files {
+		switch {
+		case file.Length == 0:
+			continue
+		case file.Length <= h.opts.PieceLength:
+			leaves := h.v2Leaves[i]
+			h.v2Roots[i] = merkleRoot(leaves, nextPowerOfTwo(len(leaves)), [sha256.Size]byte{})
+		default:
+			layer := h.v2Layers[i]
+			h.v2Roots[i] = merkleRoot(layer, nextPowerOfTwo(len(layer)), emptyPiece)
+		}
+	}
+}
+
+func (h *hasher) v1Pieces() []byte {
+	pieces := make([]byte, 0, len(h.v1Hashes)*sha1.Size)
+	for _, hash := range h.v1Hashes {
+		pieces = append(pieces, hash[:]...)
+	}
+	return pieces
+}
+
+func (h *hasher) v1Files() []any {
+	var files []any
+	for i, file := range h.files {
+		files = append(files, map[string]any{
+			"length": file.Length,
+			"path":   file.Path,
+		})
+
+		// Hybrid torrents pad every file but the last to a piece boundary,
+		// so that v1 and v2 pieces line up.
+		if h.opts.Version == VersionHybrid && i != len(h.files)-1 {
+			if padding := (h.opts.PieceLength - file.Length%h.opts.PieceLength) % h.opts.PieceLength; padding > 0 {
+				files = append(files, map[string]any{
+					"attr":   "p",
+					"length": padding,
+					"path":   []string{".pad", strconv.FormatInt(padding, 10)},
+				})
+			}
+		}
+	}
+	return files
+}
+
+func (h *hasher) v2FileTree(name string, singleFile bool) map[string]any {
+	tree := make(map[string]any)
+	for i, file := range h.files {
+		leaf := map[string]any{"length": file.Length}
+		if file.Length > 0 {
+			leaf["pieces root"] = h.v2Roots[i][:]
+		}
+
+		path := file.Path
+		if singleFile {
+			path = []string{name}
+		}
+
+		node := tree
+		for _, component := range path {
+			child, ok := node[component].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				node[component] = child
+			}
+			node = child
+		}
+		node[""] = leaf
+	}
+	return tree
+}
+
+func (h *hasher) v2PieceLayers() map[string]any {
+	layers := make(map[string]any)
+	for i, file := range h.files {
+		if file.Length <= h.opts.PieceLength {
+			continue
+		}
+		var layer bytes.Buffer
+		for _, hash := range h.v2Layers[i] {
+			layer.Write(hash[:])
+		}
+		layers[string(h.v2Roots[i][:])] = layer.Bytes()
+	}
+	return layers
+}
+
+// merkleRoot returns the root of a binary SHA-256 merkle tree whose leaves
+// are hashes, padded to width leaves with pad. width must be a power of two
+// no smaller than len(hashes).
+func merkleRoot(hashes [][sha256.Size]byte, width int, pad [sha256.Size]byte) [sha256.Size]byte {
+	layer := make([][sha256.Size]byte, width)
+	copy(layer, hashes)
+	for i := len(hashes); i < width; i++ {
+		layer[i] = pad
+	}
+
+	for len(layer) > 1 {
+		next := make([][sha256.Size]byte, len(layer)/2)
+		for i := range next {
+			var pair [2 * sha256.Size]byte
+			copy(pair[:sha256.Size], layer[2*i][:])
+			copy(pair[sha256.Size:], layer[2*i+1][:])
+			next[i] = sha256.Sum256(pair[:])
+		}
+		layer = next
+	}
+	return layer[0]
+}
+
+func nextPowerOfTwo(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(n-1))
+}
@@ -0,0 +1,245 @@
+package metainfo
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPieceLength = 32 * 1024
+
+// writeTestFiles creates a directory of files with deterministic contents and
+// returns the directory and the files' contents in v2 file tree order.
+func writeTestFiles(t *testing.T) (string, map[string][]byte) {
+	t.Helper()
+
+	root := filepath.Join(t.TempDir(), "dataset")
+	files := map[string][]byte{
+		"a/big.bin":   testData(100000, 1),
+		"a/empty.txt": {},
+		"b.txt":       testData(20000, 2),
+		"c.txt":       testData(5, 3),
+	}
+	for name, contents := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, contents, 0o644))
+	}
+	return root, files
+}
+
+func testData(n int, seed byte) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i*7) ^ seed
+	}
+	return data
+}
+
+func TestCreateV1(t *testing.T) {
+	root, files := writeTestFiles(t)
+
+	var progressCalls int
+	var lastHashed, lastTotal int64
+	m, err := Create(context.Background(), CreateOptions{
+		Path:         root,
+		PieceLength:  testPieceLength,
+		Private:      true,
+		Trackers:     [][]string{{"http://a/ann"}, {"http://b/ann"}},
+		WebSeeds:     []string{"http://seed/"},
+		Comment:      "test",
+		CreatedBy:    "tests",
+		CreationDate: time.Unix(1700000000, 0),
+		Workers:      3,
+		Progress: func(hashed, total int64) {
+			progressCalls++
+			lastHashed, lastTotal = hashed, total
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, VersionV1, m.Version())
+	assert.Equal(t, "dataset", m.Info.Name)
+	assert.True(t, m.Info.Private)
+	assert.Equal(t, "http://a/ann", m.Announce)
+	assert.Equal(t, [][]string{{"http://a/ann"}, {"http://b/ann"}}, m.Trackers())
+	assert.Equal(t, []string{"http://seed/"}, m.URLList)
+	assert.Equal(t, "test", m.Comment)
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), m.CreationDate)
+
+	var paths []string
+	for _, file := range m.Files() {
+		paths = append(paths, filepath.Join(file.Path...))
+	}
+	assert.Equal(t, []string{
+		filepath.Join("dataset", "a", "big.bin"),
+		filepath.Join("dataset", "a", "empty.txt"),
+		filepath.Join("dataset", "b.txt"),
+		filepath.Join("dataset", "c.txt"),
+	}, paths)
+
+	var stream []byte
+	for _, name := range []string{"a/big.bin", "a/empty.txt", "b.txt", "c.txt"} {
+		stream = append(stream, files[name]...)
+	}
+	var expectedPieces []byte
+	for offset := 0; offset < len(stream); offset += testPieceLength {
+		sum := sha1.Sum(stream[offset:min(offset+testPieceLength, len(stream))])
+		expectedPieces = append(expectedPieces, sum[:]...)
+	}
+	assert.Equal(t, expectedPieces, m.Info.Pieces)
+
+	assert.Equal(t, int64(len(stream)), lastTotal)
+	assert.Equal(t, int64(len(stream)), lastHashed)
+	assert.Equal(t, len(expectedPieces)/sha1.Size, progressCalls)
+}
+
+func TestCreateHybrid(t *testing.T) {
+	root, files := writeTestFiles(t)
+
+	m, err := Create(context.Background(), CreateOptions{
+		Path:        root,
+		Version:     VersionHybrid,
+		PieceLength: testPieceLength,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, VersionHybrid, m.Version())
+	assert.NotEmpty(t, m.InfoHashV1())
+	assert.NotEmpty(t, m.InfoHashV2())
+
+	t.Run("v1 files are padded to piece boundaries", func(t *testing.T) {
+		var lengths []int64
+		var padding []bool
+		for _, file := range m.Info.Files {
+			lengths = append(lengths, file.Length)
+			padding = append(padding, file.Padding)
+		}
+		assert.Equal(t, []int64{100000, 31072, 0, 20000, 12768, 5}, lengths)
+		assert.Equal(t, []bool{false, true, false, false, true, false}, padding)
+		assert.Len(t, m.Files(), 4, "padding files should be hidden")
+	})
+
+	t.Run("v1 pieces include padding", func(t *testing.T) {
+		var stream []byte
+		for _, file := range m.Info.Files {
+			name := filepath.ToSlash(filepath.Join(file.Path...))
+			if file.Padding {
+				stream = append(stream, make([]byte, file.Length)...)
+				continue
+			}
+			stream = append(stream, files[name]...)
+		}
+		var expectedPieces []byte
+		for offset := 0; offset < len(stream); offset += testPieceLength {
+			sum := sha1.Sum(stream[offset:min(offset+testPieceLength, len(stream))])
+			expectedPieces = append(expectedPieces, sum[:]...)
+		}
+		assert.Equal(t, expectedPieces, m.Info.Pieces)
+	})
+
+	t.Run("v2 pieces roots", func(t *testing.T) {
+		for _, file := range m.Info.FileTree {
+			name := filepath.ToSlash(filepath.Join(file.Path...))
+			if file.Length == 0 {
+				assert.Empty(t, file.PiecesRoot, name)
+				continue
+			}
+			expected := referenceMerkleRoot(files[name])
+			assert.Equal(t, expected[:], file.PiecesRoot, name)
+		}
+	})
+
+	t.Run("v2 piece layers", func(t *testing.T) {
+		require.Len(t, m.PieceLayers, 1, "only files larger than a piece have piece layers")
+
+		big := files["a/big.bin"]
+		var expected []byte
+		for offset := 0; offset < len(big); offset += testPieceLength {
+			piece := big[offset:min(offset+testPieceLength, len(big))]
+			var leaves [][sha256.Size]byte
+			for b := 0; b < len(piece); b += blockSize {
+				leaves = append(leaves, sha256.Sum256(piece[b:min(b+blockSize, len(piece))]))
+			}
+			root := merkleRoot(leaves, testPieceLength/blockSize, [sha256.Size]byte{})
+			expected = append(expected, root[:]...)
+		}
+
+		bigRoot := referenceMerkleRoot(big)
+		assert.Equal(t, expected, m.PieceLayers[string(bigRoot[:])])
+	})
+}
+
+// referenceMerkleRoot computes a file's v2 pieces root from the full tree of
+// 16 KiB blocks, independently of the piece layer shortcut used by Create.
+func referenceMerkleRoot(data []byte) [sha256.Size]byte {
+	var layer [][sha256.Size]byte
+	for offset := 0; offset < len(data); offset += blockSize {
+		layer = append(layer, sha256.Sum256(data[offset:min(offset+blockSize, len(data))]))
+	}
+	for len(layer)&(len(layer)-1) != 0 {
+		layer = append(layer, [sha256.Size]byte{})
+	}
+	for len(layer) > 1 {
+		var next [][sha256.Size]byte
+		for i := 0; i < len(layer); i += 2 {
+			next = append(next, sha256.Sum256(append(layer[i][:], layer[i+1][:]...)))
+		}
+		layer = next
+	}
+	return layer[0]
+}
+
+func TestCreateV2SingleFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "single.bin")
+	data := testData(50000, 4)
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	m, err := Create(context.Background(), CreateOptions{Path: path, Version: VersionV2, PieceLength: testPieceLength})
+	require.NoError(t, err)
+
+	assert.Equal(t, VersionV2, m.Version())
+	assert.False(t, m.IsMultiFile())
+	assert.Empty(t, m.Info.Pieces)
+	expectedRoot := referenceMerkleRoot(data)
+	assert.Equal(t, []File{{Path: []string{"single.bin"}, Length: 50000, PiecesRoot: expectedRoot[:]}}, m.Files())
+}
+
+func TestCreateAutoPieceLength(t *testing.T) {
+	assert.Equal(t, int64(minPieceLength), autoPieceLength(1000))
+	assert.Equal(t, int64(1024*1024), autoPieceLength(1024*1024*1024))
+	assert.Equal(t, int64(maxPieceLength), autoPieceLength(1<<50))
+}
+
+func TestCreateErrors(t *testing.T) {
+	root, _ := writeTestFiles(t)
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := Create(ctx, CreateOptions{Path: root, PieceLength: testPieceLength})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("invalid piece length", func(t *testing.T) {
+		_, err := Create(context.Background(), CreateOptions{Path: root, PieceLength: 20000})
+		assert.Error(t, err)
+	})
+
+	t.Run("missing path", func(t *testing.T) {
+		_, err := Create(context.Background(), CreateOptions{Path: filepath.Join(root, "missing")})
+		assert.Error(t, err)
+	})
+
+	t.Run("empty directory", func(t *testing.T) {
+		_, err := Create(context.Background(), CreateOptions{Path: t.TempDir()})
+		assert.Error(t, err)
+	})
+}
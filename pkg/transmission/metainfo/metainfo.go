@@ -0,0 +1,528 @@
+// Package metainfo parses .torrent files (BEP 3, BEP 52) and computes their
+// info hashes, so that torrents can be validated and deduplicated before they
+// are added to Transmission.
+package metainfo
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission/bencode"
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission/magnet"
+)
+
+// Version is the BitTorrent protocol version a torrent was created for.
+type Version int
+
+const (
+	VersionV1 Version = iota + 1
+	VersionV2
+	// VersionHybrid torrents carry both v1 and v2 metadata and can be
+	// downloaded by clients that support either.
+	VersionHybrid
+)
+
+func (v Version) String() string {
+	switch v {
+	case VersionV1:
+		return "v1"
+	case VersionV2:
+		return "v2"
+	case VersionHybrid:
+		return "hybrid"
+	default:
+		return "unknown"
+	}
+}
+
+// Metainfo is the parsed contents of a .torrent file.
+type Metainfo struct {
+	Announce     string
+	AnnounceList [][]string
+	Comment      string
+	CreatedBy    string
+	CreationDate time.Time
+	// URLList holds the web seeds (BEP 19).
+	URLList []string
+	Info    Info
+	// PieceLayers maps a v2 file's pieces root to the concatenated SHA-256
+	// hashes of its pieces. Only files larger than one piece have an entry.
+	PieceLayers map[string][]byte
+
+	raw     []byte
+	rawInfo []byte
+}
+
+// Info is the "info" dictionary of a .torrent file.
+type Info struct {
+	Name        string
+	PieceLength int64
+	Private     bool
+	Source      string
+
+	// Pieces is the concatenation of the 20 byte SHA-1 hashes of every piece.
+	// It is empty for v2 only torrents.
+	Pieces []byte
+
+	// MetaVersion is 2 for v2 and hybrid torrents, and 0 otherwise.
+	MetaVersion int
+
+	// Length is the size of a single file torrent's only file.
+	Length int64
+
+	// Files lists the files of a multi file torrent, in the order used by
+	// the v1 piece layout, including padding files.
+	Files []File
+
+	// FileTree lists the files of a v2 or hybrid torrent, sorted by path.
+	FileTree []File
+}
+
+// File is a file in a torrent. Path is relative to the torrent's root
+// directory, which is named after Info.Name.
+type File struct {
+	Path   []string
+	Length int64
+	// Padding files only exist to align the next file to a piece boundary.
+	Padding bool
+	// PiecesRoot is the root of the file's v2 merkle tree.
+	PiecesRoot []byte
+}
+
+// Parse parses a bencoded .torrent file.
+func Parse(data []byte) (*Metainfo, error) {
+	raw, err := bencode.DecodeRawDict(data)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding torrent: %w", err)
+	}
+
+	rawInfo, ok := raw["info"]
+	if !ok {
+		return nil, errors.New("torrent has no info dictionary")
+	}
+
+	decoded, err := bencode.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding torrent: %w", err)
+	}
+	top := dict(decoded.(map[string]any))
+
+	infoDict, ok := top["info"].(map[string]any)
+	if !ok {
+		return nil, errors.New("info must be a dictionary")
+	}
+
+	m := &Metainfo{
+		Announce:     top.string("announce"),
+		AnnounceList: top.stringLists("announce-list"),
+		Comment:      top.string("comment"),
+		CreatedBy:    top.string("created by"),
+		URLList:      top.stringOrList("url-list"),
+		raw:          slices.Clone(data),
+		rawInfo:      slices.Clone(rawInfo),
+	}
+	if creationDate, ok := top["creation date"].(int64); ok {
+		m.CreationDate = time.Unix(creationDate, 0).UTC()
+	}
+
+	if m.Info, err = parseInfo(dict(infoDict)); err != nil {
+		return nil, err
+	}
+
+	if layers, ok := top["piece layers"].(map[string]any); ok {
+		m.PieceLayers = make(map[string][]byte, len(layers))
+		for root, layer := range layers {
+			if s, ok := layer.(string); ok {
+				m.PieceLayers[root] = []byte(s)
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// ParseReader parses a .torrent file from r.
+func ParseReader(r io.Reader) (*Metainfo, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading torrent: %w", err)
+	}
+	return Parse(data)
+}
+
+// ParseFile parses the .torrent file at path.
+func ParseFile(path string) (*Metainfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading torrent file: %w", err)
+	}
+	return Parse(data)
+}
+
+func parseInfo(info dict) (Info, error) {
+	result := Info{
+		Name:        info.string("name"),
+		PieceLength: info.int("piece length"),
+		Private:     info.int("private") == 1,
+		Source:      info.string("source"),
+		Pieces:      []byte(info.string("pieces")),
+		MetaVersion: int(info.int("meta version")),
+		Length:      info.int("length"),
+	}
+
+	if result.Name == "" {
+		return Info{}, errors.New("info has no name")
+	}
+	if err := validatePathComponent(result.Name); err != nil {
+		return Info{}, fmt.Errorf("invalid name: %w", err)
+	}
+	if result.PieceLength <= 0 {
+		return Info{}, fmt.Errorf("invalid piece length %d", result.PieceLength)
+	}
+
+	if files, ok := info["files"].([]any); ok {
+		for i, item := range files {
+			file, err := parseV1File(item)
+			if err != nil {
+				return Info{}, fmt.Errorf("invalid file %d: %w", i, err)
+			}
+			result.Files = append(result.Files, file)
+		}
+	}
+
+	if tree, ok := info["file tree"].(map[string]any); ok {
+		if err := parseFileTree(tree, nil, &result.FileTree); err != nil {
+			return Info{}, fmt.Errorf("invalid file tree: %w", err)
+		}
+	}
+
+	hasV1 := len(result.Pieces) > 0 || result.Files != nil || info["length"] != nil
+	hasV2 := result.MetaVersion == 2 && result.FileTree != nil
+	if !hasV1 && !hasV2 {
+		return Info{}, errors.New("info has neither v1 nor v2 file metadata")
+	}
+
+	if hasV1 {
+		if len(result.Pieces)%sha1.Size != 0 {
+			return Info{}, fmt.Errorf("pieces length %d is not a multiple of %d", len(result.Pieces), sha1.Size)
+		}
+		totalLength := result.Length
+		for _, file := range result.Files {
+			totalLength += file.Length
+		}
+		if expected := (totalLength + result.PieceLength - 1) / result.PieceLength; int64(len(result.Pieces)/sha1.Size) != expected {
+			return Info{}, fmt.Errorf("torrent has %d piece hashes, expected %d", len(result.Pieces)/sha1.Size, expected)
+		}
+	}
+
+	if hasV2 && result.PieceLength&(result.PieceLength-1) != 0 {
+		return Info{}, fmt.Errorf("v2 piece length %d is not a power of two", result.PieceLength)
+	}
+
+	return result, nil
+}
+
+func parseV1File(item any) (File, error) {
+	fileDict, ok := item.(map[string]any)
+	if !ok {
+		return File{}, errors.New("file must be a dictionary")
+	}
+	d := dict(fileDict)
+
+	file := File{
+		Length:  d.int("length"),
+		Padding: strings.Contains(d.string("attr"), "p"),
+	}
+	if file.Length < 0 {
+		return File{}, fmt.Errorf("invalid length %d", file.Length)
+	}
+
+	pathItems, ok := d["path"].([]any)
+	if !ok || len(pathItems) == 0 {
+		return File{}, errors.New("file has no path")
+	}
+	for _, item := range pathItems {
+		component, ok := item.(string)
+		if !ok {
+			return File{}, errors.New("path components must be strings")
+		}
+		if err := validatePathComponent(component); err != nil {
+			return File{}, err
+		}
+		file.Path = append(file.Path, component)
+	}
+	return file, nil
+}
+
+func parseFileTree(tree map[string]any, prefix []string, out *[]File) error {
+	names := make([]string, 0, len(tree))
+	for name := range tree {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		node, ok := tree[name].(map[string]any)
+		if !ok {
+			return fmt.Errorf("node %q must be a dictionary", name)
+		}
+
+		// A key of "" marks a file rather than a directory.
+		if name == "" {
+			if len(prefix) == 0 {
+				return errors.New("file tree has a file without a name")
+			}
+			d := dict(node)
+			file := File{
+				Path:       slices.Clone(prefix),
+				Length:     d.int("length"),
+				PiecesRoot: []byte(d.string("pieces root")),
+			}
+			if file.Length < 0 {
+				return fmt.Errorf("file %q has invalid length %d", strings.Join(prefix, "/"), file.Length)
+			}
+			if file.Length > 0 && len(file.PiecesRoot) != sha256.Size {
+				return fmt.Errorf("file %q has invalid pieces root", strings.Join(prefix, "/"))
+			}
+			*out = append(*out, file)
+			continue
+		}
+
+		if err := validatePathComponent(name); err != nil {
+			return err
+		}
+		if err := parseFileTree(node, append(prefix, name), out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validatePathComponent rejects names that could escape the download
+// directory.
+func validatePathComponent(name string) error {
+	switch {
+	case name == "":
+		return errors.New("empty path component")
+	case name == "." || name == "..":
+		return fmt.Errorf("invalid path component %q", name)
+	case strings.ContainsAny(name, "/\\\x00"):
+		return fmt.Errorf("path component %q contains a separator", name)
+	}
+	return nil
+}
+
+// Version reports whether the torrent is v1, v2 or hybrid.
+func (m *Metainfo) Version() Version {
+	hasV1 := len(m.Info.Pieces) > 0 || m.Info.Files != nil || m.Info.FileTree == nil
+	hasV2 := m.Info.MetaVersion == 2 && m.Info.FileTree != nil
+	switch {
+	case hasV1 && hasV2:
+		return VersionHybrid
+	case hasV2:
+		return VersionV2
+	default:
+		return VersionV1
+	}
+}
+
+// InfoHashV1 returns the hex encoded SHA-1 hash of the info dictionary, or ""
+// for a v2 only torrent.
+func (m *Metainfo) InfoHashV1() string {
+	if m.Version() == VersionV2 {
+		return ""
+	}
+	sum := sha1.Sum(m.rawInfo)
+	return hex.EncodeToString(sum[:])
+}
+
+// InfoHashV2 returns the hex encoded SHA-256 hash of the info dictionary, or
+// "" for a v1 only torrent.
+func (m *Metainfo) InfoHashV2() string {
+	if m.Version() == VersionV1 {
+		return ""
+	}
+	sum := sha256.Sum256(m.rawInfo)
+	return hex.EncodeToString(sum[:])
+}
+
+// HashString returns the hash Transmission uses to identify the torrent, as
+// in Torrent.HashString: the v1 info hash, or the v2 info hash truncated to
+// 20 bytes for v2 only torrents.
+func (m *Metainfo) HashString() string {
+	if v1 := m.InfoHashV1(); v1 != "" {
+		return v1
+	}
+	return m.InfoHashV2()[:2*sha1.Size]
+}
+
+// IsMultiFile reports whether the torrent's files are in a directory named
+// after Info.Name rather than being a single file named Info.Name.
+func (m *Metainfo) IsMultiFile() bool {
+	if m.Info.Files != nil {
+		return true
+	}
+	if m.Info.FileTree != nil {
+		return len(m.Info.FileTree) != 1 || len(m.Info.FileTree[0].Path) != 1 || m.Info.FileTree[0].Path[0] != m.Info.Name
+	}
+	return false
+}
+
+// Files returns the torrent's files in the order Transmission indexes them,
+// excluding padding files. Each path includes the torrent's root directory
+// for multi file torrents, so that strings.Join(f.Path, "/") matches
+// transmission.TorrentFile.Name.
+func (m *Metainfo) Files() []File {
+	var files []File
+	switch {
+	case m.Info.Files != nil:
+		for _, file := range m.Info.Files {
+			if file.Padding {
+				continue
+			}
+			file.Path = append([]string{m.Info.Name}, file.Path...)
+			files = append(files, file)
+		}
+	case m.Info.FileTree != nil && m.IsMultiFile():
+		for _, file := range m.Info.FileTree {
+			file.Path = append([]string{m.Info.Name}, file.Path...)
+			files = append(files, file)
+		}
+	case m.Info.FileTree != nil:
+		files = append(files, m.Info.FileTree[0])
+	default:
+		files = append(files, File{Path: []string{m.Info.Name}, Length: m.Info.Length})
+	}
+	return files
+}
+
+// TotalLength returns the combined size of the torrent's files, excluding
+// padding.
+func (m *Metainfo) TotalLength() int64 {
+	var total int64
+	for _, file := range m.Files() {
+		total += file.Length
+	}
+	return total
+}
+
+// PieceCount returns the number of pieces in the torrent's v1 piece layout,
+// or the sum of every file's pieces for a v2 only torrent.
+func (m *Metainfo) PieceCount() int64 {
+	if len(m.Info.Pieces) > 0 {
+		return int64(len(m.Info.Pieces) / sha1.Size)
+	}
+
+	var count int64
+	for _, file := range m.Info.FileTree {
+		count += (file.Length + m.Info.PieceLength - 1) / m.Info.PieceLength
+	}
+	return count
+}
+
+// Trackers returns the torrent's announce URLs grouped into tiers, using
+// announce-list (BEP 12) when present and announce otherwise.
+func (m *Metainfo) Trackers() [][]string {
+	if len(m.AnnounceList) > 0 {
+		return m.AnnounceList
+	}
+	if m.Announce != "" {
+		return [][]string{{m.Announce}}
+	}
+	return nil
+}
+
+// Bytes returns the bencoded torrent exactly as parsed.
+func (m *Metainfo) Bytes() []byte {
+	return m.raw
+}
+
+// Base64 returns the bencoded torrent encoded as base64, ready to be passed
+// as transmission.TorrentAddArgs.MetaInfo.
+func (m *Metainfo) Base64() string {
+	return base64.StdEncoding.EncodeToString(m.raw)
+}
+
+// dict wraps a decoded bencode dictionary with lenient typed getters: values
+// of the wrong type are treated as missing.
+type dict map[string]any
+
+func (d dict) string(key string) string {
+	s, _ := d[key].(string)
+	return s
+}
+
+func (d dict) int(key string) int64 {
+	i, _ := d[key].(int64)
+	return i
+}
+
+func (d dict) stringOrList(key string) []string {
+	switch v := d[key].(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []any:
+		return toStrings(v)
+	default:
+		return nil
+	}
+}
+
+func (d dict) stringLists(key string) [][]string {
+	lists, ok := d[key].([]any)
+	if !ok {
+		return nil
+	}
+
+	var result [][]string
+	for _, item := range lists {
+		list, ok := item.([]any)
+		if !ok {
+			continue
+		}
+		if strs := toStrings(list); len(strs) > 0 {
+			result = append(result, strs)
+		}
+	}
+	return result
+}
+
+func toStrings(items []any) []string {
+	var result []string
+	for _, item := range items {
+		if s, ok := item.(string); ok && s != "" {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// Magnet returns a magnet link for the torrent, including its trackers and
+// web seeds.
+func (m *Metainfo) Magnet() *magnet.Magnet {
+	var trackers []string
+	for _, tier := range m.Trackers() {
+		trackers = append(trackers, tier...)
+	}
+
+	return &magnet.Magnet{
+		InfoHashV1:  m.InfoHashV1(),
+		InfoHashV2:  m.InfoHashV2(),
+		DisplayName: m.Info.Name,
+		Trackers:    trackers,
+		WebSeeds:    m.URLList,
+		ExactLength: m.TotalLength(),
+	}
+}
@@ -0,0 +1,151 @@
+package metainfo
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission/bencode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSingleFile(t *testing.T) {
+	pieceHash := sha1.Sum([]byte("hello"))
+	data := "d8:announce18:http://tracker/ann7:comment4:test13:creation datei1700000000e" +
+		"4:infod6:lengthi5e4:name5:a.txt12:piece lengthi16384e6:pieces20:" + string(pieceHash[:]) + "ee"
+
+	m, err := Parse([]byte(data))
+	require.NoError(t, err)
+
+	assert.Equal(t, VersionV1, m.Version())
+	assert.Equal(t, "de3edc1dfa1958affac1dbdc8f34d4d6dac43f00", m.InfoHashV1())
+	assert.Equal(t, "de3edc1dfa1958affac1dbdc8f34d4d6dac43f00", m.HashString())
+	assert.Equal(t, "", m.InfoHashV2())
+	assert.Equal(t, "a.txt", m.Info.Name)
+	assert.Equal(t, "test", m.Comment)
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), m.CreationDate)
+	assert.Equal(t, [][]string{{"http://tracker/ann"}}, m.Trackers())
+	assert.False(t, m.IsMultiFile())
+	assert.Equal(t, []File{{Path: []string{"a.txt"}, Length: 5}}, m.Files())
+	assert.Equal(t, int64(5), m.TotalLength())
+	assert.Equal(t, int64(1), m.PieceCount())
+	assert.Equal(t, []byte(data), m.Bytes())
+}
+
+func TestParseMultiFile(t *testing.T) {
+	info := map[string]any{
+		"name":         "dataset",
+		"piece length": 16384,
+		"pieces":       make([]byte, 2*sha1.Size),
+		"private":      1,
+		"files": []any{
+			map[string]any{"length": 20000, "path": []string{"data", "a.bin"}},
+			map[string]any{"length": 384, "path": []string{".pad", "384"}, "attr": "p"},
+			map[string]any{"length": 100, "path": []string{"README"}},
+		},
+	}
+	data, err := bencode.Encode(map[string]any{
+		"announce-list": [][]string{{"http://a/ann", "http://b/ann"}, {"udp://c:80"}},
+		"url-list":      "http://seed/",
+		"info":          info,
+	})
+	require.NoError(t, err)
+
+	m, err := Parse(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, VersionV1, m.Version())
+	assert.True(t, m.Info.Private)
+	assert.True(t, m.IsMultiFile())
+	assert.Equal(t, [][]string{{"http://a/ann", "http://b/ann"}, {"udp://c:80"}}, m.Trackers())
+	assert.Equal(t, []string{"http://seed/"}, m.URLList)
+	assert.Equal(t, []File{
+		{Path: []string{"dataset", "data", "a.bin"}, Length: 20000},
+		{Path: []string{"dataset", "README"}, Length: 100},
+	}, m.Files())
+	assert.Equal(t, int64(20100), m.TotalLength())
+
+	encodedInfo, err := bencode.Encode(info)
+	require.NoError(t, err)
+	expectedHash := sha1.Sum(encodedInfo)
+	assert.Equal(t, hex.EncodeToString(expectedHash[:]), m.InfoHashV1())
+}
+
+func TestParseV2(t *testing.T) {
+	root := make([]byte, sha256.Size)
+	root[0] = 1
+	info := map[string]any{
+		"name":         "dataset",
+		"piece length": 16384,
+		"meta version": 2,
+		"file tree": map[string]any{
+			"b.txt": map[string]any{"": map[string]any{"length": 10, "pieces root": root}},
+			"a": map[string]any{
+				"c.txt": map[string]any{"": map[string]any{"length": 0}},
+			},
+		},
+	}
+	data, err := bencode.Encode(map[string]any{"info": info})
+	require.NoError(t, err)
+
+	m, err := Parse(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, VersionV2, m.Version())
+	assert.Equal(t, "", m.InfoHashV1())
+
+	encodedInfo, err := bencode.Encode(info)
+	require.NoError(t, err)
+	expectedHash := sha256.Sum256(encodedInfo)
+	assert.Equal(t, hex.EncodeToString(expectedHash[:]), m.InfoHashV2())
+	assert.Equal(t, hex.EncodeToString(expectedHash[:20]), m.HashString())
+
+	assert.Equal(t, []File{
+		{Path: []string{"dataset", "a", "c.txt"}, Length: 0, PiecesRoot: []byte{}},
+		{Path: []string{"dataset", "b.txt"}, Length: 10, PiecesRoot: root},
+	}, m.Files())
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := map[string]any{
+		"no info": map[string]any{"announce": "x"},
+		"no name": map[string]any{"info": map[string]any{"piece length": 1, "length": 1, "pieces": make([]byte, 20)}},
+		"wrong piece count": map[string]any{"info": map[string]any{
+			"name": "a", "piece length": 1, "length": 3, "pieces": make([]byte, 20),
+		}},
+		"path traversal": map[string]any{"info": map[string]any{
+			"name": "a", "piece length": 16384, "pieces": make([]byte, 20),
+			"files": []any{map[string]any{"length": 1, "path": []string{"..", "etc", "passwd"}}},
+		}},
+		"no files": map[string]any{"info": map[string]any{"name": "a", "piece length": 16384}},
+	}
+
+	for name, torrent := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := bencode.Encode(torrent)
+			require.NoError(t, err)
+
+			_, err = Parse(data)
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("not bencoded", func(t *testing.T) {
+		_, err := Parse([]byte("<html>"))
+		assert.Error(t, err)
+	})
+}
+
+func TestMagnet(t *testing.T) {
+	pieceHash := sha1.Sum([]byte("hello"))
+	data := "d8:announce18:http://tracker/ann" +
+		"4:infod6:lengthi5e4:name5:a.txt12:piece lengthi16384e6:pieces20:" + string(pieceHash[:]) + "ee"
+
+	m, err := Parse([]byte(data))
+	require.NoError(t, err)
+
+	assert.Equal(t, "magnet:?xt=urn:btih:de3edc1dfa1958affac1dbdc8f34d4d6dac43f00&dn=a.txt&xl=5&tr=http%3A%2F%2Ftracker%2Fann", m.Magnet().String())
+}
@@ -0,0 +1,205 @@
+package transmission
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// PeerFlags is the decoded form of Peer.FlagStr.
+type PeerFlags struct {
+	OptimisticUnchoke   bool // O
+	DownloadingFrom     bool // D
+	WouldDownloadFrom   bool // d: we'd download from this peer if they'd let us
+	UploadingTo         bool // U
+	WouldUploadTo       bool // u: we'd upload to this peer if they'd ask
+	ClientNotInterested bool // K: peer has unchoked us, but we're not interested
+	PeerNotInterested   bool // ?: we unchoked this peer, but they're not interested
+	Encrypted           bool // E
+	FromDHT             bool // H
+	FromPEX             bool // X
+	Incoming            bool // I
+	UTP                 bool // T
+	UnknownFlags        string
+}
+
+// peerFlagChars is the order in which Transmission writes the flags.
+const peerFlagChars = "ODdUuK?EHXIT"
+
+// ParsePeerFlags decodes a Transmission peer flag string, e.g. "DEX". Flags
+// that aren't recognised are kept in UnknownFlags.
+func ParsePeerFlags(s string) PeerFlags {
+	var flags PeerFlags
+	var unknown strings.Builder
+	for _, r := range s {
+		if field := flags.field(r); field != nil {
+			*field = true
+			continue
+		}
+		unknown.WriteRune(r)
+	}
+	flags.UnknownFlags = unknown.String()
+	return flags
+}
+
+// String encodes the flags in the same form as Peer.FlagStr.
+func (f PeerFlags) String() string {
+	var sb strings.Builder
+	for _, r := range peerFlagChars {
+		if *f.field(r) {
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteString(f.UnknownFlags)
+	return sb.String()
+}
+
+func (f *PeerFlags) field(r rune) *bool {
+	switch r {
+	case 'O':
+		return &f.OptimisticUnchoke
+	case 'D':
+		return &f.DownloadingFrom
+	case 'd':
+		return &f.WouldDownloadFrom
+	case 'U':
+		return &f.UploadingTo
+	case 'u':
+		return &f.WouldUploadTo
+	case 'K':
+		return &f.ClientNotInterested
+	case '?':
+		return &f.PeerNotInterested
+	case 'E':
+		return &f.Encrypted
+	case 'H':
+		return &f.FromDHT
+	case 'X':
+		return &f.FromPEX
+	case 'I':
+		return &f.Incoming
+	case 'T':
+		return &f.UTP
+	default:
+		return nil
+	}
+}
+
+// Flags decodes the peer's FlagStr.
+func (p Peer) Flags() PeerFlags {
+	return ParsePeerFlags(p.FlagStr)
+}
+
+// PeerSummary aggregates a torrent's connected peers.
+type PeerSummary struct {
+	Total     int
+	Encrypted int
+	UTP       int
+	Incoming  int
+
+	// ByClient counts peers per client name, highest count first.
+	ByClient []ClientCount
+}
+
+type ClientCount struct {
+	ClientName string
+	Count      int
+}
+
+// EncryptedShare returns the share of peers using an encrypted connection,
+// from 0 to 1.
+func (s PeerSummary) EncryptedShare() float64 {
+	return share(s.Encrypted, s.Total)
+}
+
+// UTPShare returns the share of peers connected over uTP, from 0 to 1.
+func (s PeerSummary) UTPShare() float64 {
+	return share(s.UTP, s.Total)
+}
+
+// IncomingShare returns the share of peers that connected to us, from 0 to 1.
+func (s PeerSummary) IncomingShare() float64 {
+	return share(s.Incoming, s.Total)
+}
+
+func share(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total)
+}
+
+// SummarizePeers aggregates peers, e.g. a torrent fetched with the "peers"
+// field. Peers without a client name are counted under "unknown".
+func SummarizePeers(peers []Peer) PeerSummary {
+	summary := PeerSummary{Total: len(peers)}
+	clientCounts := make(map[string]int)
+
+	for _, peer := range peers {
+		flags := peer.Flags()
+		if peer.IsEncrypted || flags.Encrypted {
+			summary.Encrypted++
+		}
+		if peer.IsUTP || flags.UTP {
+			summary.UTP++
+		}
+		if peer.IsIncoming || flags.Incoming {
+			summary.Incoming++
+		}
+
+		clientName := peer.ClientName
+		if clientName == "" {
+			clientName = "unknown"
+		}
+		clientCounts[clientName]++
+	}
+
+	for clientName, count := range clientCounts {
+		summary.ByClient = append(summary.ByClient, ClientCount{ClientName: clientName, Count: count})
+	}
+	slices.SortFunc(summary.ByClient, func(a, b ClientCount) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ClientName, b.ClientName)
+	})
+
+	return summary
+}
+
+// PeerRate selects which transfer rate TopPeersByRate sorts by.
+type PeerRate int
+
+const (
+	// PeerRateToClient sorts by how fast the peer is sending data to us.
+	PeerRateToClient PeerRate = iota
+	// PeerRateToPeer sorts by how fast we are sending data to the peer.
+	PeerRateToPeer
+	// PeerRateTotal sorts by the sum of both directions.
+	PeerRateTotal
+)
+
+func (r PeerRate) of(p Peer) int64 {
+	switch r {
+	case PeerRateToClient:
+		return p.RateToClient
+	case PeerRateToPeer:
+		return p.RateToPeer
+	default:
+		return p.RateToClient + p.RateToPeer
+	}
+}
+
+// TopPeersByRate returns up to n peers with the highest rate, highest first.
+// Ties are broken by address so the result is stable between calls. peers is
+// not modified.
+func TopPeersByRate(peers []Peer, rate PeerRate, n int) []Peer {
+	sorted := slices.Clone(peers)
+	slices.SortFunc(sorted, func(a, b Peer) int {
+		if c := cmp.Compare(rate.of(b), rate.of(a)); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Address, b.Address)
+	})
+	return sorted[:min(max(n, 0), len(sorted))]
+}
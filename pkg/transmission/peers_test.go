@@ -0,0 +1,83 @@
+package transmission
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePeerFlags(t *testing.T) {
+	flags := ParsePeerFlags("DUKEXIT")
+
+	assert.Equal(t, PeerFlags{
+		DownloadingFrom:     true,
+		UploadingTo:         true,
+		ClientNotInterested: true,
+		Encrypted:           true,
+		FromPEX:             true,
+		Incoming:            true,
+		UTP:                 true,
+	}, flags)
+	assert.Equal(t, "DUKEXIT", flags.String())
+
+	t.Run("unknown flags", func(t *testing.T) {
+		flags := ParsePeerFlags("?dZ")
+		assert.True(t, flags.PeerNotInterested)
+		assert.True(t, flags.WouldDownloadFrom)
+		assert.Equal(t, "Z", flags.UnknownFlags)
+		assert.Equal(t, "d?Z", flags.String())
+	})
+}
+
+func TestSummarizePeers(t *testing.T) {
+	peers := []Peer{
+		{Address: "10.0.0.1", ClientName: "Transmission 4.0.5", FlagStr: "DEI"},
+		{Address: "10.0.0.2", ClientName: "qBittorrent 4.6.2", FlagStr: "UT", IsUTP: true},
+		{Address: "10.0.0.3", ClientName: "Transmission 4.0.5", FlagStr: "E"},
+		{Address: "10.0.0.4"},
+	}
+
+	summary := SummarizePeers(peers)
+
+	assert.Equal(t, 4, summary.Total)
+	assert.Equal(t, 2, summary.Encrypted)
+	assert.Equal(t, 1, summary.UTP)
+	assert.Equal(t, 1, summary.Incoming)
+	assert.Equal(t, 0.5, summary.EncryptedShare())
+	assert.Equal(t, 0.25, summary.UTPShare())
+	assert.Equal(t, 0.25, summary.IncomingShare())
+	assert.Equal(t, []ClientCount{
+		{ClientName: "Transmission 4.0.5", Count: 2},
+		{ClientName: "qBittorrent 4.6.2", Count: 1},
+		{ClientName: "unknown", Count: 1},
+	}, summary.ByClient)
+
+	assert.Equal(t, float64(0), SummarizePeers(nil).EncryptedShare())
+}
+
+func TestTopPeersByRate(t *testing.T) {
+	peers := []Peer{
+		{Address: "a", RateToClient: 10, RateToPeer: 500},
+		{Address: "b", RateToClient: 300, RateToPeer: 0},
+		{Address: "c", RateToClient: 300, RateToPeer: 50},
+	}
+
+	top := TopPeersByRate(peers, PeerRateToClient, 2)
+	assert.Equal(t, []string{"b", "c"}, addresses(top))
+
+	top = TopPeersByRate(peers, PeerRateToPeer, 1)
+	assert.Equal(t, []string{"a"}, addresses(top))
+
+	top = TopPeersByRate(peers, PeerRateTotal, 10)
+	assert.Equal(t, []string{"a", "c", "b"}, addresses(top))
+
+	assert.Equal(t, "a", peers[0].Address, "input should not be reordered")
+}
+
+func addresses(peers []Peer) []string {
+	var result []string
+	for _, p := range peers {
+		result = append(result, p.Address)
+	}
+	return result
+}
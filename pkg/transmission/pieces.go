@@ -0,0 +1,254 @@
+package transmission
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// Bitfield records which pieces of a torrent have been downloaded and
+// verified. Piece 0 is the most significant bit of the first byte, as in the
+// BitTorrent protocol.
+type Bitfield struct {
+	bytes  []byte
+	length int
+}
+
+// DecodeBitfield decodes the base64 encoded "pieces" field of a torrent.
+// pieceCount is the torrent's "pieceCount" field.
+func DecodeBitfield(encoded string, pieceCount int) (Bitfield, error) {
+	if pieceCount < 0 {
+		return Bitfield{}, fmt.Errorf("invalid piece count %d", pieceCount)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return Bitfield{}, fmt.Errorf("error decoding pieces bitfield: %w", err)
+	}
+
+	if minBytes := (pieceCount + 7) / 8; len(decoded) < minBytes {
+		return Bitfield{}, fmt.Errorf("pieces bitfield has %d bytes, need %d for %d pieces", len(decoded), minBytes, pieceCount)
+	}
+
+	return Bitfield{bytes: decoded, length: pieceCount}, nil
+}
+
+// NewBitfield returns a bitfield of length pieces with the given pieces set.
+func NewBitfield(length int, have ...int) Bitfield {
+	b := Bitfield{bytes: make([]byte, (length+7)/8), length: length}
+	for _, piece := range have {
+		if piece >= 0 && piece < length {
+			b.bytes[piece/8] |= 0x80 >> (piece % 8)
+		}
+	}
+	return b
+}
+
+// Encode returns the bitfield in the same base64 form as the "pieces" field.
+func (b Bitfield) Encode() string {
+	return base64.StdEncoding.EncodeToString(b.bytes)
+}
+
+// Len returns the number of pieces in the bitfield.
+func (b Bitfield) Len() int {
+	return b.length
+}
+
+// Has reports whether piece has been downloaded. Out of range pieces are
+// reported as missing.
+func (b Bitfield) Has(piece int) bool {
+	if piece < 0 || piece >= b.length {
+		return false
+	}
+	return b.bytes[piece/8]&(0x80>>(piece%8)) != 0
+}
+
+// Count returns the number of pieces that have been downloaded.
+func (b Bitfield) Count() int {
+	return b.CountRange(0, b.length)
+}
+
+// CountRange returns the number of downloaded pieces in [begin, end).
+func (b Bitfield) CountRange(begin, end int) int {
+	begin, end = max(begin, 0), min(end, b.length)
+	if begin >= end {
+		return 0
+	}
+
+	count := 0
+	for piece := begin; piece < end; {
+		if piece%8 == 0 && piece+8 <= end {
+			count += bits.OnesCount8(b.bytes[piece/8])
+			piece += 8
+			continue
+		}
+		if b.Has(piece) {
+			count++
+		}
+		piece++
+	}
+	return count
+}
+
+// HasRange reports whether every piece in [begin, end) has been downloaded.
+func (b Bitfield) HasRange(begin, end int) bool {
+	begin, end = max(begin, 0), min(end, b.length)
+	return b.CountRange(begin, end) == max(end-begin, 0)
+}
+
+// Missing returns the indices of pieces that have not been downloaded.
+func (b Bitfield) Missing() []int {
+	var missing []int
+	for piece := 0; piece < b.length; piece++ {
+		if !b.Has(piece) {
+			missing = append(missing, piece)
+		}
+	}
+	return missing
+}
+
+// PieceBitfield decodes the torrent's pieces bitfield. The torrent must have
+// been fetched with the "pieces" and "pieceCount" fields.
+func (t Torrent) PieceBitfield() (Bitfield, error) {
+	return DecodeBitfield(t.Pieces, int(t.PieceCount))
+}
+
+// FilePieceCoverage describes how many of the pieces overlapping a file have
+// been downloaded. Pieces at the edges of a file are shared with neighbouring
+// files, so a file may be fully covered before it is "done" in Transmission's
+// eyes, and vice versa.
+type FilePieceCoverage struct {
+	Index       int
+	Name        string
+	BeginPiece  int
+	EndPiece    int // exclusive
+	PiecesHave  int
+	PiecesTotal int
+}
+
+// Percent returns the share of the file's pieces that have been downloaded,
+// from 0 to 100. Empty files are reported as 100% covered.
+func (c FilePieceCoverage) Percent() float64 {
+	if c.PiecesTotal == 0 {
+		return 100
+	}
+	return float64(c.PiecesHave) / float64(c.PiecesTotal) * 100
+}
+
+// FilePieceCoverage returns the piece coverage of every file in the torrent.
+// The torrent must have been fetched with the "files", "pieces" and
+// "pieceCount" fields. TorrentFile.BeginPiece and EndPiece require
+// Transmission 4.
+func (t Torrent) FilePieceCoverage() ([]FilePieceCoverage, error) {
+	bitfield, err := t.PieceBitfield()
+	if err != nil {
+		return nil, err
+	}
+
+	coverage := make([]FilePieceCoverage, 0, len(t.Files))
+	for i, file := range t.Files {
+		begin, end := int(file.BeginPiece), int(file.EndPiece)
+		if begin < 0 || end < begin || end > bitfield.Len() {
+			return nil, fmt.Errorf("file %d has invalid piece range [%d, %d) for %d pieces", i, begin, end, bitfield.Len())
+		}
+
+		coverage = append(coverage, FilePieceCoverage{
+			Index:       i,
+			Name:        file.Name,
+			BeginPiece:  begin,
+			EndPiece:    end,
+			PiecesHave:  bitfield.CountRange(begin, end),
+			PiecesTotal: end - begin,
+		})
+	}
+	return coverage, nil
+}
+
+// SwarmAvailability summarises how much of a torrent's missing data is
+// available from connected peers. Webseeds aren't taken into account.
+type SwarmAvailability struct {
+	PieceCount int
+	PiecesHave int
+
+	// PiecesMissing counts wanted pieces that have not been downloaded.
+	PiecesMissing int
+
+	// PiecesUnavailable counts wanted pieces that have not been downloaded
+	// and that no connected peer has.
+	PiecesUnavailable int
+
+	// MinPeers is the lowest number of connected peers that have any one
+	// missing wanted piece. It is 0 if no pieces are missing.
+	MinPeers int64
+}
+
+// PercentUnavailable returns the share of missing wanted pieces that no
+// connected peer has, from 0 to 100.
+func (a SwarmAvailability) PercentUnavailable() float64 {
+	if a.PiecesMissing == 0 {
+		return 0
+	}
+	return float64(a.PiecesUnavailable) / float64(a.PiecesMissing) * 100
+}
+
+// CanComplete reports whether every missing wanted piece is available from
+// at least one connected peer.
+func (a SwarmAvailability) CanComplete() bool {
+	return a.PiecesUnavailable == 0
+}
+
+var errNoAvailability = errors.New("torrent has no availability data: fetch the \"availability\" and \"pieceCount\" fields (requires Transmission 4)")
+
+// SwarmAvailability summarises the torrent's "availability" field. When the
+// torrent was also fetched with "files" and "wanted", pieces belonging only to
+// unwanted files are ignored.
+func (t Torrent) SwarmAvailability() (SwarmAvailability, error) {
+	if t.PieceCount > 0 && len(t.Availability) == 0 {
+		return SwarmAvailability{}, errNoAvailability
+	}
+	if int64(len(t.Availability)) != t.PieceCount {
+		return SwarmAvailability{}, fmt.Errorf("availability has %d entries, expected %d", len(t.Availability), t.PieceCount)
+	}
+
+	wanted := t.wantedPieces()
+	result := SwarmAvailability{PieceCount: int(t.PieceCount)}
+	for piece, peers := range t.Availability {
+		// Transmission reports -1 for pieces we already have.
+		if peers < 0 {
+			result.PiecesHave++
+			continue
+		}
+		if wanted != nil && !wanted[piece] {
+			continue
+		}
+
+		if result.PiecesMissing == 0 || peers < result.MinPeers {
+			result.MinPeers = peers
+		}
+		result.PiecesMissing++
+		if peers == 0 {
+			result.PiecesUnavailable++
+		}
+	}
+	return result, nil
+}
+
+// wantedPieces returns which pieces overlap a wanted file, or nil if the
+// torrent wasn't fetched with enough fields to tell.
+func (t Torrent) wantedPieces() []bool {
+	if len(t.Files) == 0 || len(t.Wanted) != len(t.Files) {
+		return nil
+	}
+
+	wanted := make([]bool, t.PieceCount)
+	for i, file := range t.Files {
+		if t.Wanted[i] == 0 {
+			continue
+		}
+		for piece := max(file.BeginPiece, 0); piece < min(file.EndPiece, t.PieceCount); piece++ {
+			wanted[piece] = true
+		}
+	}
+	return wanted
+}
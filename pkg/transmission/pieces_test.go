@@ -0,0 +1,110 @@
+package transmission
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitfield(t *testing.T) {
+	// 0b10110000, 0b01000000: pieces 0, 2, 3 and 9 of 10
+	b, err := DecodeBitfield("sEA=", 10)
+	require.NoError(t, err)
+
+	assert.Equal(t, 10, b.Len())
+	assert.True(t, b.Has(0))
+	assert.False(t, b.Has(1))
+	assert.True(t, b.Has(9))
+	assert.False(t, b.Has(10))
+	assert.False(t, b.Has(-1))
+	assert.Equal(t, 4, b.Count())
+	assert.Equal(t, 2, b.CountRange(1, 4))
+	assert.Equal(t, 1, b.CountRange(8, 100))
+	assert.True(t, b.HasRange(2, 4))
+	assert.False(t, b.HasRange(0, 3))
+	assert.Equal(t, []int{1, 4, 5, 6, 7, 8}, b.Missing())
+	assert.Equal(t, "sEA=", b.Encode())
+
+	assert.Equal(t, b, NewBitfield(10, 0, 2, 3, 9))
+
+	t.Run("too short", func(t *testing.T) {
+		_, err := DecodeBitfield("sA==", 10)
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid base64", func(t *testing.T) {
+		_, err := DecodeBitfield("!!", 1)
+		assert.Error(t, err)
+	})
+}
+
+func TestFilePieceCoverage(t *testing.T) {
+	torrent := Torrent{
+		PieceCount: 10,
+		Pieces:     NewBitfield(10, 0, 1, 2, 3, 9).Encode(),
+		Files: []TorrentFile{
+			{Name: "a", BeginPiece: 0, EndPiece: 4},
+			{Name: "b", BeginPiece: 3, EndPiece: 10},
+			{Name: "empty", BeginPiece: 10, EndPiece: 10},
+		},
+	}
+
+	coverage, err := torrent.FilePieceCoverage()
+	require.NoError(t, err)
+
+	assert.Equal(t, []FilePieceCoverage{
+		{Index: 0, Name: "a", BeginPiece: 0, EndPiece: 4, PiecesHave: 4, PiecesTotal: 4},
+		{Index: 1, Name: "b", BeginPiece: 3, EndPiece: 10, PiecesHave: 2, PiecesTotal: 7},
+		{Index: 2, Name: "empty", BeginPiece: 10, EndPiece: 10, PiecesHave: 0, PiecesTotal: 0},
+	}, coverage)
+	assert.Equal(t, float64(100), coverage[0].Percent())
+	assert.InDelta(t, 28.57, coverage[1].Percent(), 0.01)
+	assert.Equal(t, float64(100), coverage[2].Percent())
+}
+
+func TestSwarmAvailability(t *testing.T) {
+	t.Run("all pieces", func(t *testing.T) {
+		torrent := Torrent{
+			PieceCount:   6,
+			Availability: []int64{-1, -1, 3, 0, 1, 0},
+		}
+
+		availability, err := torrent.SwarmAvailability()
+		require.NoError(t, err)
+
+		assert.Equal(t, SwarmAvailability{
+			PieceCount:        6,
+			PiecesHave:        2,
+			PiecesMissing:     4,
+			PiecesUnavailable: 2,
+			MinPeers:          0,
+		}, availability)
+		assert.Equal(t, float64(50), availability.PercentUnavailable())
+		assert.False(t, availability.CanComplete())
+	})
+
+	t.Run("ignores unwanted files", func(t *testing.T) {
+		torrent := Torrent{
+			PieceCount:   6,
+			Availability: []int64{-1, -1, 3, 2, 0, 0},
+			Files: []TorrentFile{
+				{Name: "wanted", BeginPiece: 0, EndPiece: 4},
+				{Name: "unwanted", BeginPiece: 4, EndPiece: 6},
+			},
+			Wanted: []int64{1, 0},
+		}
+
+		availability, err := torrent.SwarmAvailability()
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, availability.PiecesMissing)
+		assert.Equal(t, int64(2), availability.MinPeers)
+		assert.True(t, availability.CanComplete())
+	})
+
+	t.Run("missing availability", func(t *testing.T) {
+		_, err := Torrent{PieceCount: 6}.SwarmAvailability()
+		assert.Error(t, err)
+	})
+}
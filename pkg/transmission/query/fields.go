@@ -0,0 +1,186 @@
+package query
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+)
+
+// field is a value that queries can refer to by name.
+type field struct {
+	name   string
+	kind   kind
+	labels []string
+	// requires are the TorrentGet fields needed to compute the value.
+	requires []string
+	get      func(*env) value
+}
+
+var (
+	// fields are the fields that can be used in queries.
+	fields = buildFields()
+	// torrentFields are all Torrent JSON fields, including those of types
+	// that queries don't support.
+	torrentFields = jsonFields(reflect.TypeFor[transmission.Torrent]())
+)
+
+// computedFields are derived from other fields. Durations are in seconds.
+var computedFields = []field{
+	{
+		name:     "age",
+		kind:     kindNumber,
+		requires: []string{"addedDate"},
+		get: func(env *env) value {
+			return value{num: float64(env.now.Unix() - env.torrent.AddedDate)}
+		},
+	},
+	{
+		name:     "idle",
+		kind:     kindNumber,
+		requires: []string{"activityDate"},
+		get: func(env *env) value {
+			return value{num: float64(env.now.Unix() - env.torrent.ActivityDate)}
+		},
+	},
+	{
+		// trackers are the lower case host names of the torrent's trackers.
+		name:     "trackers",
+		kind:     kindList,
+		requires: []string{"trackers"},
+		get: func(env *env) value {
+			var hosts []string
+			for _, announce := range env.torrent.TrackerTiers().Announces() {
+				u, err := url.Parse(announce)
+				if err != nil {
+					continue
+				}
+				host := strings.ToLower(u.Hostname())
+				if !slices.Contains(hosts, host) {
+					hosts = append(hosts, host)
+				}
+			}
+			return value{list: hosts}
+		},
+	},
+}
+
+// FieldNames returns the names of the fields that can be used in queries,
+// sorted.
+func FieldNames() []string {
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func lookupField(tok token) (field, error) {
+	f, exists := fields[tok.text]
+	if exists {
+		return f, nil
+	}
+	if _, exists := torrentFields[tok.text]; exists {
+		return field{}, errorf(tok.offset, "field %s can't be used in queries", tok.text)
+	}
+	return field{}, errorf(tok.offset, "unknown field %s", tok.text)
+}
+
+func buildFields() map[string]field {
+	result := make(map[string]field)
+	for name, structField := range jsonFields(reflect.TypeFor[transmission.Torrent]()) {
+		f, ok := reflectField(name, structField)
+		if ok {
+			result[name] = f
+		}
+	}
+	for _, f := range computedFields {
+		result[f.name] = f
+	}
+	return result
+}
+
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	result := make(map[string]reflect.StructField)
+	for _, structField := range reflect.VisibleFields(t) {
+		name, _, _ := strings.Cut(structField.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			result[name] = structField
+		}
+	}
+	return result
+}
+
+// reflectField returns the query field for a Torrent field, if its type is
+// supported.
+func reflectField(name string, structField reflect.StructField) (field, bool) {
+	index := structField.Index
+	fieldValue := func(env *env) reflect.Value {
+		return reflect.ValueOf(env.torrent).Elem().FieldByIndex(index)
+	}
+
+	f := field{name: name, requires: []string{name}}
+	t := structField.Type
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f.kind = kindNumber
+		labels, isEnum := enumLabels[t]
+		if !isEnum {
+			f.get = func(env *env) value { return value{num: float64(fieldValue(env).Int())} }
+			break
+		}
+		f.labels = labels
+		f.get = func(env *env) value {
+			v := fieldValue(env)
+			return value{num: float64(v.Int()), str: v.Interface().(fmt.Stringer).String()}
+		}
+	case reflect.Float32, reflect.Float64:
+		f.kind = kindNumber
+		f.get = func(env *env) value { return value{num: fieldValue(env).Float()} }
+	case reflect.String:
+		f.kind = kindString
+		f.get = func(env *env) value { return value{str: fieldValue(env).String()} }
+	case reflect.Bool:
+		f.kind = kindBool
+		f.get = func(env *env) value { return value{b: fieldValue(env).Bool()} }
+	case reflect.Slice:
+		if t.Elem().Kind() != reflect.String {
+			return field{}, false
+		}
+		f.kind = kindList
+		f.get = func(env *env) value {
+			v := fieldValue(env)
+			list := make([]string, v.Len())
+			for i := range list {
+				list[i] = v.Index(i).String()
+			}
+			return value{list: list}
+		}
+	default:
+		return field{}, false
+	}
+	return f, true
+}
+
+// enumLabels are the labels of the enum types of Torrent fields, in value
+// order.
+var enumLabels = map[reflect.Type][]string{
+	reflect.TypeFor[transmission.Priority]():      sortedLabels(transmission.PriorityByLabel),
+	reflect.TypeFor[transmission.RatioMode]():     sortedLabels(transmission.RatioModeByLabel),
+	reflect.TypeFor[transmission.IdleMode]():      sortedLabels(transmission.IdleModeByLabel),
+	reflect.TypeFor[transmission.TorrentError]():  sortedLabels(transmission.TorrentErrorByLabel),
+	reflect.TypeFor[transmission.TorrentStatus](): sortedLabels(transmission.TorrentStatusByLabel),
+}
+
+func sortedLabels[T ~int](labelsByValue map[T]string) []string {
+	labels := []string{}
+	for _, value := range slices.Sorted(maps.Keys(labelsByValue)) {
+		labels = append(labels, labelsByValue[value])
+	}
+	return labels
+}
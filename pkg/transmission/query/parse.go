@@ -0,0 +1,393 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenTrue
+	tokenFalse
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+	tokenComma
+	tokenNot
+	tokenAnd
+	tokenOr
+	tokenEq
+	tokenNe
+	tokenLt
+	tokenLe
+	tokenGt
+	tokenGe
+	tokenMatch
+	tokenIn
+)
+
+// operators are the punctuation tokens, with longer operators before their
+// prefixes.
+var operators = []struct {
+	text string
+	kind tokenKind
+}{
+	{"==", tokenEq},
+	{"!=", tokenNe},
+	{"<=", tokenLe},
+	{">=", tokenGe},
+	{"=~", tokenMatch},
+	{"&&", tokenAnd},
+	{"||", tokenOr},
+	{"<", tokenLt},
+	{">", tokenGt},
+	{"!", tokenNot},
+	{"(", tokenLParen},
+	{")", tokenRParen},
+	{"[", tokenLBracket},
+	{"]", tokenRBracket},
+	{",", tokenComma},
+}
+
+// durationUnits convert to seconds, the unit of the computed duration
+// fields.
+var durationUnits = map[string]float64{
+	"s": 1,
+	"m": 60,
+	"h": 60 * 60,
+	"d": 24 * 60 * 60,
+	"w": 7 * 24 * 60 * 60,
+}
+
+// sizeUnits convert to bytes. They are matched case-insensitively.
+var sizeUnits = map[string]float64{
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+type token struct {
+	kind   tokenKind
+	offset int
+	// text is the token as written in the query.
+	text string
+	// str is the unquoted value of a string token.
+	str string
+	// number is the value of a number token, with any unit applied.
+	number float64
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of query"
+	}
+	return t.text
+}
+
+func lex(input string) ([]token, error) {
+	var tokens []token
+	pos := 0
+	for {
+		for pos < len(input) && strings.IndexByte(" \t\r\n", input[pos]) >= 0 {
+			pos++
+		}
+		if pos == len(input) {
+			return append(tokens, token{kind: tokenEOF, offset: pos}), nil
+		}
+
+		tok, err := lexToken(input, pos)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		pos += len(tok.text)
+	}
+}
+
+func lexToken(input string, pos int) (token, error) {
+	rest := input[pos:]
+	c := rest[0]
+
+	switch {
+	case c == '"':
+		return lexString(input, pos)
+	case isDigit(c) || c == '.':
+		return lexNumber(input, pos)
+	case c == '-' && len(rest) > 1 && (isDigit(rest[1]) || rest[1] == '.'):
+		return lexNumber(input, pos)
+	case isIdentStart(c):
+		end := 1
+		for end < len(rest) && (isIdentStart(rest[end]) || isDigit(rest[end])) {
+			end++
+		}
+		tok := token{kind: tokenIdent, offset: pos, text: rest[:end]}
+		switch tok.text {
+		case "true":
+			tok.kind = tokenTrue
+		case "false":
+			tok.kind = tokenFalse
+		case "in":
+			tok.kind = tokenIn
+		}
+		return tok, nil
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(rest, op.text) {
+			return token{kind: op.kind, offset: pos, text: op.text}, nil
+		}
+	}
+
+	r, _ := utf8.DecodeRuneInString(rest)
+	return token{}, errorf(pos, "unexpected character %q", r)
+}
+
+func lexString(input string, pos int) (token, error) {
+	for end := pos + 1; end < len(input); end++ {
+		switch input[end] {
+		case '\\':
+			end++
+		case '"':
+			text := input[pos : end+1]
+			value, err := strconv.Unquote(text)
+			if err != nil {
+				return token{}, errorf(pos, "invalid string %s", text)
+			}
+			return token{kind: tokenString, offset: pos, text: text, str: value}, nil
+		}
+	}
+	return token{}, errorf(pos, "unterminated string")
+}
+
+// lexNumber reads a number with an optional sign and duration or size unit,
+// such as 2.5, -1, 30d or 1.5GiB.
+func lexNumber(input string, pos int) (token, error) {
+	end := pos
+	if input[end] == '-' {
+		end++
+	}
+	for end < len(input) && (isDigit(input[end]) || input[end] == '.') {
+		end++
+	}
+	number, err := strconv.ParseFloat(input[pos:end], 64)
+	if err != nil {
+		return token{}, errorf(pos, "invalid number %q", input[pos:end])
+	}
+
+	unitStart := end
+	for end < len(input) && isIdentStart(input[end]) {
+		end++
+	}
+	if unit := input[unitStart:end]; unit != "" {
+		multiplier, exists := durationUnits[unit]
+		if !exists {
+			multiplier, exists = sizeUnits[strings.ToLower(unit)]
+		}
+		if !exists {
+			return token{}, errorf(unitStart, "unknown unit %q", unit)
+		}
+		number *= multiplier
+	}
+
+	return token{kind: tokenNumber, offset: pos, text: input[pos:end], number: number}, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+// expr is a node of the parsed query.
+type expr interface {
+	offset() int
+}
+
+type binaryExpr struct {
+	op          token
+	left, right expr
+}
+
+type notExpr struct {
+	not token
+	x   expr
+}
+
+type identExpr struct{ token }
+
+type literalExpr struct{ token }
+
+type listExpr struct {
+	open     token
+	elements []literalExpr
+}
+
+func (e binaryExpr) offset() int  { return e.left.offset() }
+func (e notExpr) offset() int     { return e.not.offset }
+func (e identExpr) offset() int   { return e.token.offset }
+func (e literalExpr) offset() int { return e.token.offset }
+func (e listExpr) offset() int    { return e.open.offset }
+
+// parser is a recursive descent parser for:
+//
+//	or         = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | comparison
+//	comparison = operand [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" | "=~" | "in" ) operand ]
+//	operand    = "(" or ")" | ident | literal | "[" [ literal { "," literal } ] "]"
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func parse(input string) (expr, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser{tokens: tokens}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, errorf(tok.offset, "unexpected %s", tok)
+	}
+	return e, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) or() (expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOr {
+		op := p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenAnd {
+		op := p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (expr, error) {
+	if p.peek().kind == tokenNot {
+		not := p.next()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{not: not, x: x}, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (expr, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	switch p.peek().kind {
+	case tokenEq, tokenNe, tokenLt, tokenLe, tokenGt, tokenGe, tokenMatch, tokenIn:
+		op := p.next()
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return binaryExpr{op: op, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) operand() (expr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenLParen:
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, errorf(closing.offset, "expected ) but found %s", closing)
+		}
+		return e, nil
+	case tokenIdent:
+		return identExpr{tok}, nil
+	case tokenString, tokenNumber, tokenTrue, tokenFalse:
+		return literalExpr{tok}, nil
+	case tokenLBracket:
+		return p.list(tok)
+	}
+	return nil, errorf(tok.offset, "unexpected %s", tok)
+}
+
+func (p *parser) list(open token) (expr, error) {
+	list := listExpr{open: open}
+	if p.peek().kind == tokenRBracket {
+		p.next()
+		return list, nil
+	}
+	for {
+		tok := p.next()
+		switch tok.kind {
+		case tokenString, tokenNumber, tokenTrue, tokenFalse:
+			list.elements = append(list.elements, literalExpr{tok})
+		default:
+			return nil, errorf(tok.offset, "expected a literal but found %s", tok)
+		}
+
+		switch sep := p.next(); sep.kind {
+		case tokenComma:
+		case tokenRBracket:
+			return list, nil
+		default:
+			return nil, errorf(sep.offset, "expected , or ] but found %s", sep)
+		}
+	}
+}
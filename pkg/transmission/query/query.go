@@ -0,0 +1,381 @@
+// Package query implements a small expression language for filtering
+// torrents, for example:
+//
+//	status == "seed" && uploadRatio > 2 && "movies" in labels && age > 30d
+//
+// Field names are the JSON names of transmission.Torrent fields, plus the
+// computed fields age, idle and trackers. Enum fields such as status can be
+// compared with either their label or their number.
+//
+// Numbers may be negative, as in eta == -1, and may have a duration unit
+// (s, m, h, d, w), which converts them to seconds, or a size unit (B, KB, MB,
+// GB, TB, KiB, MiB, GiB, TiB), which converts them to bytes. Strings are
+// double quoted and use Go escapes.
+//
+// The operators are, from lowest to highest precedence: ||, &&, ! and the
+// comparisons ==, !=, <, <=, >, >=, =~ (regular expression match) and in
+// (list membership, with a list field or a literal such as ["seed", "stopped"]).
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+)
+
+// Error describes an invalid query.
+type Error struct {
+	Offset int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("query: %s at offset %d", e.Msg, e.Offset)
+}
+
+func errorf(offset int, format string, args ...any) error {
+	return &Error{Offset: offset, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Query is a compiled query. It is safe for concurrent use.
+type Query struct {
+	expression string
+	match      func(*env) bool
+	fields     []string
+}
+
+// Compile parses and type checks a query. An empty query matches every
+// torrent.
+func Compile(expression string) (*Query, error) {
+	q := &Query{expression: expression}
+	if strings.TrimSpace(expression) == "" {
+		q.match = func(*env) bool { return true }
+		return q, nil
+	}
+
+	e, err := parse(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	c := compiler{fields: make(map[string]bool)}
+	q.match, err = c.condition(e)
+	if err != nil {
+		return nil, err
+	}
+	for name := range c.fields {
+		q.fields = append(q.fields, name)
+	}
+	slices.Sort(q.fields)
+	return q, nil
+}
+
+// MustCompile is like Compile but panics if the query is invalid.
+func MustCompile(expression string) *Query {
+	q, err := Compile(expression)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+func (q *Query) String() string {
+	return q.expression
+}
+
+// Match reports whether the torrent matches the query. The torrent must have
+// been fetched with at least the fields returned by Fields.
+func (q *Query) Match(torrent transmission.Torrent) bool {
+	return q.MatchAt(torrent, time.Now())
+}
+
+// MatchAt is like Match but computes age and idle relative to now.
+func (q *Query) MatchAt(torrent transmission.Torrent, now time.Time) bool {
+	return q.match(&env{torrent: &torrent, now: now})
+}
+
+// Filter returns the torrents that match the query.
+func (q *Query) Filter(torrents []transmission.Torrent) []transmission.Torrent {
+	now := time.Now()
+	var result []transmission.Torrent
+	for _, torrent := range torrents {
+		if q.MatchAt(torrent, now) {
+			result = append(result, torrent)
+		}
+	}
+	return result
+}
+
+// Fields returns the TorrentGet fields needed to evaluate the query, together
+// with any extra fields, sorted and without duplicates.
+func (q *Query) Fields(extra ...string) []string {
+	fields := append(slices.Clone(q.fields), extra...)
+	slices.Sort(fields)
+	return slices.Compact(fields)
+}
+
+type env struct {
+	torrent *transmission.Torrent
+	now     time.Time
+}
+
+type kind int
+
+const (
+	kindBool kind = iota
+	kindNumber
+	kindString
+	kindList
+)
+
+var kindNames = map[kind]string{
+	kindBool:   "boolean",
+	kindNumber: "number",
+	kindString: "string",
+	kindList:   "list",
+}
+
+type value struct {
+	b    bool
+	num  float64
+	str  string
+	list []string
+}
+
+// operand is a compiled field, literal or nested condition.
+type operand struct {
+	kind kind
+	// labels are the valid string values of an enum field, which is a number
+	// that can also be compared with strings.
+	labels []string
+	// literal is set for literals, whose value is known at compile time.
+	literal *token
+	// desc describes the operand in errors.
+	desc string
+	eval func(*env) value
+}
+
+func (o operand) isEnum() bool {
+	return o.labels != nil
+}
+
+type compiler struct {
+	// fields are the JSON fields referenced by the query.
+	fields map[string]bool
+}
+
+func (c *compiler) condition(e expr) (func(*env) bool, error) {
+	switch e := e.(type) {
+	case binaryExpr:
+		switch e.op.kind {
+		case tokenAnd, tokenOr:
+			left, err := c.condition(e.left)
+			if err != nil {
+				return nil, err
+			}
+			right, err := c.condition(e.right)
+			if err != nil {
+				return nil, err
+			}
+			if e.op.kind == tokenAnd {
+				return func(env *env) bool { return left(env) && right(env) }, nil
+			}
+			return func(env *env) bool { return left(env) || right(env) }, nil
+		}
+		return c.comparison(e)
+	case notExpr:
+		x, err := c.condition(e.x)
+		if err != nil {
+			return nil, err
+		}
+		return func(env *env) bool { return !x(env) }, nil
+	}
+
+	o, err := c.operand(e)
+	if err != nil {
+		return nil, err
+	}
+	if o.kind != kindBool {
+		return nil, errorf(e.offset(), "%s is a %s, not a condition", o.desc, kindNames[o.kind])
+	}
+	return func(env *env) bool { return o.eval(env).b }, nil
+}
+
+func (c *compiler) operand(e expr) (operand, error) {
+	switch e := e.(type) {
+	case identExpr:
+		f, err := lookupField(e.token)
+		if err != nil {
+			return operand{}, err
+		}
+		for _, name := range f.requires {
+			c.fields[name] = true
+		}
+		return operand{kind: f.kind, labels: f.labels, desc: f.name, eval: f.get}, nil
+	case literalExpr:
+		return literal(e.token), nil
+	case listExpr:
+		return operand{}, errorf(e.offset(), "a list can only be used after in")
+	}
+
+	cond, err := c.condition(e)
+	if err != nil {
+		return operand{}, err
+	}
+	return operand{kind: kindBool, desc: "condition", eval: func(env *env) value {
+		return value{b: cond(env)}
+	}}, nil
+}
+
+func literal(tok token) operand {
+	o := operand{literal: &tok, desc: tok.text}
+	var v value
+	switch tok.kind {
+	case tokenString:
+		o.kind, v.str = kindString, tok.str
+	case tokenNumber:
+		o.kind, v.num = kindNumber, tok.number
+	default:
+		o.kind, v.b = kindBool, tok.kind == tokenTrue
+	}
+	o.eval = func(*env) value { return v }
+	return o
+}
+
+func (c *compiler) comparison(e binaryExpr) (func(*env) bool, error) {
+	left, err := c.operand(e.left)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.op.kind == tokenIn {
+		return c.in(e, left)
+	}
+
+	right, err := c.operand(e.right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op.kind {
+	case tokenEq, tokenNe:
+		eq, err := equal(e.op, left, right)
+		if err != nil {
+			return nil, err
+		}
+		if e.op.kind == tokenNe {
+			return func(env *env) bool { return !eq(env) }, nil
+		}
+		return eq, nil
+	case tokenMatch:
+		return match(e, left, right)
+	}
+
+	if left.kind != kindNumber || right.kind != kindNumber {
+		return nil, errorf(e.op.offset, "%s needs numbers but found %s %s and %s %s",
+			e.op, kindNames[left.kind], left.desc, kindNames[right.kind], right.desc)
+	}
+	compare := map[tokenKind]func(a, b float64) bool{
+		tokenLt: func(a, b float64) bool { return a < b },
+		tokenLe: func(a, b float64) bool { return a <= b },
+		tokenGt: func(a, b float64) bool { return a > b },
+		tokenGe: func(a, b float64) bool { return a >= b },
+	}[e.op.kind]
+	return func(env *env) bool {
+		return compare(left.eval(env).num, right.eval(env).num)
+	}, nil
+}
+
+// equal compares operands of the same kind, or an enum with a string.
+func equal(op token, left, right operand) (func(*env) bool, error) {
+	if left.kind == kindString && right.isEnum() {
+		left, right = right, left
+	}
+	if left.isEnum() && right.kind == kindString {
+		if err := checkLabel(left, right); err != nil {
+			return nil, err
+		}
+		return func(env *env) bool { return left.eval(env).str == right.eval(env).str }, nil
+	}
+
+	if left.kind != right.kind || left.kind == kindList {
+		return nil, errorf(op.offset, "can't compare %s %s with %s %s",
+			kindNames[left.kind], left.desc, kindNames[right.kind], right.desc)
+	}
+	switch left.kind {
+	case kindNumber:
+		return func(env *env) bool { return left.eval(env).num == right.eval(env).num }, nil
+	case kindString:
+		return func(env *env) bool { return left.eval(env).str == right.eval(env).str }, nil
+	}
+	return func(env *env) bool { return left.eval(env).b == right.eval(env).b }, nil
+}
+
+// checkLabel catches typos in literal enum labels, which would otherwise
+// never match.
+func checkLabel(enum, label operand) error {
+	if label.literal == nil || slices.Contains(enum.labels, label.literal.str) {
+		return nil
+	}
+	return errorf(label.literal.offset, "%s is not a valid %s, expected one of %s",
+		label.desc, enum.desc, strings.Join(enum.labels, ", "))
+}
+
+func match(e binaryExpr, left, right operand) (func(*env) bool, error) {
+	if right.literal == nil || right.kind != kindString {
+		return nil, errorf(e.right.offset(), "=~ needs a string pattern")
+	}
+	re, err := regexp.Compile(right.literal.str)
+	if err != nil {
+		return nil, errorf(e.right.offset(), "invalid pattern: %v", err)
+	}
+
+	switch {
+	case left.kind == kindList:
+		return func(env *env) bool {
+			return slices.ContainsFunc(left.eval(env).list, re.MatchString)
+		}, nil
+	case left.kind == kindString || left.isEnum():
+		return func(env *env) bool { return re.MatchString(left.eval(env).str) }, nil
+	}
+	return nil, errorf(e.op.offset, "can't match %s %s with a pattern", kindNames[left.kind], left.desc)
+}
+
+func (c *compiler) in(e binaryExpr, left operand) (func(*env) bool, error) {
+	if list, ok := e.right.(listExpr); ok {
+		var elements []func(*env) bool
+		for _, element := range list.elements {
+			eq, err := equal(e.op, left, literal(element.token))
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, eq)
+		}
+		return func(env *env) bool {
+			for _, eq := range elements {
+				if eq(env) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+
+	right, err := c.operand(e.right)
+	if err != nil {
+		return nil, err
+	}
+	if right.kind != kindList {
+		return nil, errorf(e.right.offset(), "in needs a list but found %s %s", kindNames[right.kind], right.desc)
+	}
+	if left.kind != kindString {
+		return nil, errorf(e.left.offset(), "%s has strings, not %s %s", right.desc, kindNames[left.kind], left.desc)
+	}
+	return func(env *env) bool {
+		return slices.Contains(right.eval(env).list, left.eval(env).str)
+	}, nil
+}
@@ -0,0 +1,155 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/j-dumbell/go-qbittorrent/pkg/transmission"
+)
+
+var now = time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+var (
+	movie = transmission.Torrent{
+		ID:           1,
+		Name:         "Some.Movie.2160p",
+		Status:       transmission.TorrentStatusSeed,
+		UploadRatio:  2.5,
+		Labels:       []string{"movies", "4k"},
+		AddedDate:    now.Add(-40 * 24 * time.Hour).Unix(),
+		ActivityDate: now.Add(-time.Hour).Unix(),
+		TotalSize:    50 << 30,
+		ETA:          -1,
+		IsPrivate:    true,
+		Trackers: []transmission.Tracker{
+			{Announce: "https://Tracker.Example:443/announce"},
+			{Announce: "udp://open.example:1337", Tier: 1},
+		},
+	}
+	episode = transmission.Torrent{
+		ID:           2,
+		Name:         "Some.Show.S01E01",
+		Status:       transmission.TorrentStatusDownload,
+		UploadRatio:  0.1,
+		Labels:       []string{"tv"},
+		AddedDate:    now.Add(-2 * 24 * time.Hour).Unix(),
+		ActivityDate: now.Add(-10 * 24 * time.Hour).Unix(),
+		TotalSize:    700 << 20,
+		ETA:          3600,
+		Error:        transmission.TorrentErrorTrackerWarning,
+	}
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{``, []int64{1, 2}},
+		{`status == "seed" && uploadRatio > 2 && "movies" in labels && age > 30d`, []int64{1}},
+		{`status == 6`, []int64{1}},
+		{`status != "seed"`, []int64{2}},
+		{`status in ["download", "stopped"]`, []int64{2}},
+		{`status in [4, 6]`, []int64{1, 2}},
+		{`status >= 4 && status < 6`, []int64{2}},
+		{`error == "tracker_warning"`, []int64{2}},
+		{`totalSize > 1GiB`, []int64{1}},
+		{`eta == -1`, []int64{1}},
+		{`eta > -1 && eta < 2h`, []int64{2}},
+		{`eta in [-2, -1]`, []int64{1}},
+		{`uploadRatio < 0`, nil},
+		{`uploadRatio > -.5`, []int64{1, 2}},
+		{`bandwidthPriority > -1 && seedRatioMode == "global"`, []int64{1, 2}},
+		{`totalSize <= 700MiB`, []int64{2}},
+		{`idle > 1w`, []int64{2}},
+		{`age < 3d && idle >= 2h`, []int64{2}},
+		{`isPrivate`, []int64{1}},
+		{`!isPrivate`, []int64{2}},
+		{`isPrivate == false`, []int64{2}},
+		{`name =~ "(?i)s\\d+e\\d+"`, []int64{2}},
+		{`status =~ "^seed"`, []int64{1}},
+		{`labels =~ "^4"`, []int64{1}},
+		{`"tracker.example" in trackers`, []int64{1}},
+		{`trackers =~ "open"`, []int64{1}},
+		{`"movies" in labels || "tv" in labels && uploadRatio > 1`, []int64{1}},
+		{`("movies" in labels || "tv" in labels) && uploadRatio < 1`, []int64{2}},
+		{`!(id == 1) && true`, []int64{2}},
+		{`name in []`, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			q, err := Compile(test.query)
+			require.NoError(t, err)
+
+			var got []int64
+			for _, torrent := range []transmission.Torrent{movie, episode} {
+				if q.MatchAt(torrent, now) {
+					got = append(got, torrent.ID)
+				}
+			}
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestFields(t *testing.T) {
+	q := MustCompile(`status == "seed" && uploadRatio > 2 && "movies" in labels && age > 30d && "x" in trackers`)
+	assert.Equal(t, []string{"addedDate", "labels", "status", "trackers", "uploadRatio"}, q.Fields())
+	assert.Equal(t, []string{"addedDate", "id", "labels", "name", "status", "trackers", "uploadRatio"}, q.Fields("name", "id", "status"))
+
+	assert.Empty(t, MustCompile("").Fields())
+	assert.Contains(t, FieldNames(), "uploadRatio")
+	assert.Contains(t, FieldNames(), "age")
+	assert.NotContains(t, FieldNames(), "files")
+}
+
+func TestFilter(t *testing.T) {
+	q := MustCompile(`"tv" in labels`)
+	assert.Equal(t, []transmission.Torrent{episode}, q.Filter([]transmission.Torrent{movie, episode}))
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		query  string
+		offset int
+		msg    string
+	}{
+		{`status == "seeding"`, 10, `"seeding" is not a valid status, expected one of stopped, check_wait, check, download_wait, download, seed_wait, seed`},
+		{`nope > 1`, 0, "unknown field nope"},
+		{`files == 1`, 0, "field files can't be used in queries"},
+		{`name > 1`, 5, "> needs numbers but found string name and number 1"},
+		{`name == 1`, 5, "can't compare string name with number 1"},
+		{`name`, 0, "name is a string, not a condition"},
+		{`name in labels && 1 in labels`, 18, "labels has strings, not number 1"},
+		{`"a" in name`, 7, "in needs a list but found string name"},
+		{`name =~ "("`, 8, "invalid pattern: error parsing regexp: missing closing ): `(`"},
+		{`name =~ name`, 8, "=~ needs a string pattern"},
+		{`age > 3y`, 7, `unknown unit "y"`},
+		{`eta == -x`, 7, "unexpected character '-'"},
+		{`eta == -.`, 7, `invalid number "-."`},
+		{`seedRatioMode == "off"`, 17, `"off" is not a valid seedRatioMode, expected one of global, single, unlimited`},
+		{`(isPrivate`, 10, "expected ) but found end of query"},
+		{`isPrivate &&`, 12, "unexpected end of query"},
+		{`isPrivate isStalled`, 10, "unexpected isStalled"},
+		{`name == "abc`, 8, "unterminated string"},
+		{`name == 'a'`, 8, "unexpected character '\\''"},
+		{`name in [1,]`, 11, "expected a literal but found ]"},
+		{`[1] == name`, 0, "a list can only be used after in"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			_, err := Compile(test.query)
+			var queryErr *Error
+			require.ErrorAs(t, err, &queryErr)
+			assert.Equal(t, test.msg, queryErr.Msg)
+			assert.Equal(t, test.offset, queryErr.Offset)
+		})
+	}
+
+	assert.Panics(t, func() { MustCompile("(") })
+}
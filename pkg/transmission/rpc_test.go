@@ -0,0 +1,84 @@
+package transmission
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rpcCall is a request received by a fake Transmission server.
+type rpcCall struct {
+	Method    string
+	Arguments json.RawMessage
+}
+
+// rpcHandler returns the arguments to respond with, or an error to return as
+// the RPC result.
+type rpcHandler func(call rpcCall) (any, error)
+
+type fakeServer struct {
+	mutex sync.Mutex
+	calls []rpcCall
+}
+
+func (s *fakeServer) Calls() []rpcCall {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]rpcCall(nil), s.calls...)
+}
+
+// CallsTo returns the recorded calls to method.
+func (s *fakeServer) CallsTo(method string) []rpcCall {
+	var result []rpcCall
+	for _, call := range s.Calls() {
+		if call.Method == method {
+			result = append(result, call)
+		}
+	}
+	return result
+}
+
+// newFakeServer starts a fake Transmission RPC server and returns a client
+// connected to it.
+func newFakeServer(t *testing.T, handler rpcHandler) (*Client, *fakeServer) {
+	t.Helper()
+
+	fake := &fakeServer{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var call rpcCall
+		if err := json.NewDecoder(r.Body).Decode(&call); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fake.mutex.Lock()
+		fake.calls = append(fake.calls, call)
+		fake.mutex.Unlock()
+
+		response := map[string]any{"result": "success"}
+		arguments, err := handler(call)
+		if err != nil {
+			response["result"] = err.Error()
+		} else if arguments != nil {
+			response["arguments"] = arguments
+		}
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := New(ClientParams{Host: server.URL})
+	require.NoError(t, err)
+	return client, fake
+}
+
+// decodeArgs unmarshals a recorded call's arguments.
+func decodeArgs[T any](t *testing.T, call rpcCall) T {
+	t.Helper()
+	var args T
+	require.NoError(t, json.Unmarshal(call.Arguments, &args))
+	return args
+}
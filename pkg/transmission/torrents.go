@@ -64,18 +64,18 @@ func (c *Client) TorrentReannounce(ctx context.Context, ids *TorrentIDs) error {
 // method in the Transmission API.
 type TorrentSetArgs struct {
 	// General per-torrent limits / properties
-	BandwidthPriority   *int  `json:"bandwidthPriority,omitempty"`   // tr_priority_t
-	DownloadLimit       *int  `json:"downloadLimit,omitempty"`       // KB/s
-	DownloadLimited     *bool `json:"downloadLimited,omitempty"`     // honor DownloadLimit
-	HonorsSessionLimits *bool `json:"honorsSessionLimits,omitempty"` // honor session limits
-	PeerLimit           *int  `json:"peer-limit,omitempty"`          // max peers
-	QueuePosition       *int  `json:"queuePosition,omitempty"`       // 0..n-1
+	BandwidthPriority   *Priority `json:"bandwidthPriority,omitempty"`
+	DownloadLimit       *int      `json:"downloadLimit,omitempty"`       // KB/s
+	DownloadLimited     *bool     `json:"downloadLimited,omitempty"`     // honor DownloadLimit
+	HonorsSessionLimits *bool     `json:"honorsSessionLimits,omitempty"` // honor session limits
+	PeerLimit           *int      `json:"peer-limit,omitempty"`          // max peers
+	QueuePosition       *int      `json:"queuePosition,omitempty"`       // 0..n-1
 
 	// Per-torrent seeding rules
-	SeedIdleLimit  *int     `json:"seedIdleLimit,omitempty"`  // minutes
-	SeedIdleMode   *int     `json:"seedIdleMode,omitempty"`   // see tr_idlelimit
-	SeedRatioLimit *float64 `json:"seedRatioLimit,omitempty"` // ratio
-	SeedRatioMode  *int     `json:"seedRatioMode,omitempty"`  // see tr_ratiolimit
+	SeedIdleLimit  *int       `json:"seedIdleLimit,omitempty"` // minutes
+	SeedIdleMode   *IdleMode  `json:"seedIdleMode,omitempty"`
+	SeedRatioLimit *float64   `json:"seedRatioLimit,omitempty"` // ratio
+	SeedRatioMode  *RatioMode `json:"seedRatioMode,omitempty"`
 
 	// File selection and priorities
 	FilesWanted    []int `json:"files-wanted,omitempty"`    // file indices
@@ -87,10 +87,17 @@ type TorrentSetArgs struct {
 	// Location
 	Location *string `json:"location,omitempty"` // new content location
 
-	// Trackers
-	TrackerAdd     []string        `json:"trackerAdd,omitempty"`     // announce URLs
-	TrackerRemove  []int           `json:"trackerRemove,omitempty"`  // tracker IDs
-	TrackerReplace [][]interface{} `json:"trackerReplace,omitempty"` // [ [id, url], ... ]
+	// Labels replaces all of the torrent's labels. Point to an empty slice
+	// to remove them all. See AddLabels and RemoveLabels to change labels
+	// without replacing them.
+	Labels *[]string `json:"labels,omitempty"`
+
+	// Trackers. TrackerList replaces all trackers and needs RPC version 17;
+	// the others edit individual trackers. See EditTrackers.
+	TrackerAdd     []string            `json:"trackerAdd,omitempty"`     // announce URLs
+	TrackerList    *TrackerList        `json:"trackerList,omitempty"`    // all trackers, by tier
+	TrackerRemove  []int64             `json:"trackerRemove,omitempty"`  // tracker IDs
+	TrackerReplace TrackerReplacements `json:"trackerReplace,omitempty"` // tracker ID to new URL
 
 	// Target torrents (ids follows the usual Transmission rules:
 	// single id, list of ids/hashStrings, or "recently-active")
@@ -124,7 +131,7 @@ type Torrent struct {
 	ActivityDate                int64             `json:"activityDate,omitempty"`
 	AddedDate                   int64             `json:"addedDate,omitempty"`
 	Availability                []int64           `json:"availability,omitempty"`
-	BandwidthPriority           int64             `json:"bandwidthPriority,omitempty"`
+	BandwidthPriority           Priority          `json:"bandwidthPriority,omitempty"`
 	BytesCompleted              []int64           `json:"bytesCompleted,omitempty"`
 	Comment                     string            `json:"comment,omitempty"`
 	CorruptEver                 int64             `json:"corruptEver,omitempty"`
@@ -137,7 +144,7 @@ type Torrent struct {
 	DownloadLimit               int64             `json:"downloadLimit,omitempty"`
 	DownloadLimited             bool              `json:"downloadLimited,omitempty"`
 	EditDate                    int64             `json:"editDate,omitempty"`
-	Error                       int64             `json:"error,omitempty"`
+	Error                       TorrentError      `json:"error,omitempty"`
 	ErrorString                 string            `json:"errorString,omitempty"`
 	ETA                         int64             `json:"eta,omitempty"`
 	ETAIdle                     int64             `json:"etaIdle,omitempty"`
@@ -160,7 +167,7 @@ type Torrent struct {
 	MaxConnectedPeers           int64             `json:"maxConnectedPeers,omitempty"`
 	MetadataPercentComplete     float64           `json:"metadataPercentComplete,omitempty"`
 	Name                        string            `json:"name,omitempty"`
-	PeerLimit                   int64             `json:"peerLimit,omitempty"`
+	PeerLimit                   int64             `json:"peer-limit,omitempty"`
 	Peers                       []Peer            `json:"peers,omitempty"`
 	PeersConnected              int64             `json:"peersConnected,omitempty"`
 	PeersFrom                   *PeersFrom        `json:"peersFrom,omitempty"`
@@ -171,7 +178,7 @@ type Torrent struct {
 	Pieces                      string            `json:"pieces,omitempty"`
 	PieceCount                  int64             `json:"pieceCount,omitempty"`
 	PieceSize                   int64             `json:"pieceSize,omitempty"`
-	Priorities                  []int64           `json:"priorities,omitempty"`
+	Priorities                  []Priority        `json:"priorities,omitempty"`
 	PrimaryMIMEType             string            `json:"primaryMimeType,omitempty"`
 	QueuePosition               int64             `json:"queuePosition,omitempty"`
 	RateDownload                int64             `json:"rateDownload,omitempty"` // B/s
@@ -180,9 +187,9 @@ type Torrent struct {
 	SecondsDownloading          int64             `json:"secondsDownloading,omitempty"`
 	SecondsSeeding              int64             `json:"secondsSeeding,omitempty"`
 	SeedIdleLimit               int64             `json:"seedIdleLimit,omitempty"`
-	SeedIdleMode                int64             `json:"seedIdleMode,omitempty"`
+	SeedIdleMode                IdleMode          `json:"seedIdleMode,omitempty"`
 	SeedRatioLimit              float64           `json:"seedRatioLimit,omitempty"`
-	SeedRatioMode               int64             `json:"seedRatioMode,omitempty"`
+	SeedRatioMode               RatioMode         `json:"seedRatioMode,omitempty"`
 	SequentialDownload          bool              `json:"sequentialDownload,omitempty"`
 	SequentialDownloadFromPiece int64             `json:"sequentialDownloadFromPiece,omitempty"`
 	SizeWhenDone                int64             `json:"sizeWhenDone,omitempty"`
@@ -191,7 +198,7 @@ type Torrent struct {
 	TorrentFile                 string            `json:"torrentFile,omitempty"`
 	TotalSize                   int64             `json:"totalSize,omitempty"`
 	Trackers                    []Tracker         `json:"trackers,omitempty"`
-	TrackerList                 string            `json:"trackerList,omitempty"`
+	TrackerList                 TrackerList       `json:"trackerList,omitempty"`
 	TrackerStats                []TrackerStat     `json:"trackerStats,omitempty"`
 	UploadedEver                int64             `json:"uploadedEver,omitempty"`
 	UploadLimit                 int64             `json:"uploadLimit,omitempty"`
@@ -225,11 +232,15 @@ var TorrentStatusByLabel = map[TorrentStatus]string{
 }
 
 func (ts TorrentStatus) String() string {
-	label, exists := TorrentStatusByLabel[ts]
-	if !exists {
-		return "unknown"
-	}
-	return label
+	return enumString(ts, TorrentStatusByLabel)
+}
+
+func (ts TorrentStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int(ts))
+}
+
+func (ts *TorrentStatus) UnmarshalJSON(data []byte) error {
+	return unmarshalEnum(data, ts, TorrentStatusByLabel)
 }
 
 type TorrentFile struct {
@@ -241,9 +252,9 @@ type TorrentFile struct {
 }
 
 type TorrentFileStat struct {
-	BytesCompleted int64 `json:"bytesCompleted,omitempty"`
-	Wanted         bool  `json:"wanted,omitempty"`   // NOTE: different from Torrent.Wanted (0/1 array)
-	Priority       int64 `json:"priority,omitempty"` // tr_priority_t
+	BytesCompleted int64    `json:"bytesCompleted,omitempty"`
+	Wanted         bool     `json:"wanted,omitempty"` // NOTE: different from Torrent.Wanted (0/1 array)
+	Priority       Priority `json:"priority,omitempty"`
 }
 
 type Peer struct {
@@ -287,34 +298,34 @@ type Tracker struct {
 }
 
 type TrackerStat struct {
-	Announce              string `json:"announce,omitempty"`
-	AnnounceState         int64  `json:"announceState,omitempty"`
-	DownloadCount         int64  `json:"downloadCount,omitempty"`
-	DownloaderCount       int64  `json:"downloaderCount,omitempty"`
-	HasAnnounced          bool   `json:"hasAnnounced,omitempty"`
-	HasScraped            bool   `json:"hasScraped,omitempty"`
-	Host                  string `json:"host,omitempty"`
-	ID                    int64  `json:"id,omitempty"`
-	IsBackup              bool   `json:"isBackup,omitempty"`
-	LastAnnouncePeerCount int64  `json:"lastAnnouncePeerCount,omitempty"`
-	LastAnnounceResult    string `json:"lastAnnounceResult,omitempty"`
-	LastAnnounceStartTime int64  `json:"lastAnnounceStartTime,omitempty"`
-	LastAnnounceSucceeded bool   `json:"lastAnnounceSucceeded,omitempty"`
-	LastAnnounceTime      int64  `json:"lastAnnounceTime,omitempty"`
-	LastAnnounceTimedOut  bool   `json:"lastAnnounceTimedOut,omitempty"`
-	LastScrapeResult      string `json:"lastScrapeResult,omitempty"`
-	LastScrapeStartTime   int64  `json:"lastScrapeStartTime,omitempty"`
-	LastScrapeSucceeded   bool   `json:"lastScrapeSucceeded,omitempty"`
-	LastScrapeTime        int64  `json:"lastScrapeTime,omitempty"`
-	LastScrapeTimedOut    bool   `json:"lastScrapeTimedOut,omitempty"`
-	LeecherCount          int64  `json:"leecherCount,omitempty"`
-	NextAnnounceTime      int64  `json:"nextAnnounceTime,omitempty"`
-	NextScrapeTime        int64  `json:"nextScrapeTime,omitempty"`
-	Scrape                string `json:"scrape,omitempty"`
-	ScrapeState           int64  `json:"scrapeState,omitempty"`
-	SeederCount           int64  `json:"seederCount,omitempty"`
-	Sitename              string `json:"sitename,omitempty"`
-	Tier                  int64  `json:"tier,omitempty"`
+	Announce              string       `json:"announce,omitempty"`
+	AnnounceState         TrackerState `json:"announceState,omitempty"`
+	DownloadCount         int64        `json:"downloadCount,omitempty"`
+	DownloaderCount       int64        `json:"downloaderCount,omitempty"`
+	HasAnnounced          bool         `json:"hasAnnounced,omitempty"`
+	HasScraped            bool         `json:"hasScraped,omitempty"`
+	Host                  string       `json:"host,omitempty"`
+	ID                    int64        `json:"id,omitempty"`
+	IsBackup              bool         `json:"isBackup,omitempty"`
+	LastAnnouncePeerCount int64        `json:"lastAnnouncePeerCount,omitempty"`
+	LastAnnounceResult    string       `json:"lastAnnounceResult,omitempty"`
+	LastAnnounceStartTime int64        `json:"lastAnnounceStartTime,omitempty"`
+	LastAnnounceSucceeded bool         `json:"lastAnnounceSucceeded,omitempty"`
+	LastAnnounceTime      int64        `json:"lastAnnounceTime,omitempty"`
+	LastAnnounceTimedOut  bool         `json:"lastAnnounceTimedOut,omitempty"`
+	LastScrapeResult      string       `json:"lastScrapeResult,omitempty"`
+	LastScrapeStartTime   int64        `json:"lastScrapeStartTime,omitempty"`
+	LastScrapeSucceeded   bool         `json:"lastScrapeSucceeded,omitempty"`
+	LastScrapeTime        int64        `json:"lastScrapeTime,omitempty"`
+	LastScrapeTimedOut    bool         `json:"lastScrapeTimedOut,omitempty"`
+	LeecherCount          int64        `json:"leecherCount,omitempty"`
+	NextAnnounceTime      int64        `json:"nextAnnounceTime,omitempty"`
+	NextScrapeTime        int64        `json:"nextScrapeTime,omitempty"`
+	Scrape                string       `json:"scrape,omitempty"`
+	ScrapeState           TrackerState `json:"scrapeState,omitempty"`
+	SeederCount           int64        `json:"seederCount,omitempty"`
+	Sitename              string       `json:"sitename,omitempty"`
+	Tier                  int64        `json:"tier,omitempty"`
 }
 
 var AllTorrentFields = structJSONFields[Torrent]()
@@ -338,16 +349,41 @@ type TorrentAddArgs struct {
 	Filename *string `json:"filename,omitempty"`
 	MetaInfo *string `json:"metainfo,omitempty"`
 
-	Cookies           *string `json:"cookies,omitempty"`
-	DownloadDir       *string `json:"download_dir,omitempty"`
-	Paused            *bool   `json:"paused,omitempty"`
-	PeerLimit         *int64  `json:"peer_limit,omitempty"`
-	BandwidthPriority *int64  `json:"bandwidth_priority,omitempty"`
-	FilesWanted       []int64 `json:"files_wanted,omitempty"`
-	FilesUnwanted     []int64 `json:"files_unwanted,omitempty"`
-	PriorityHigh      []int64 `json:"priority_high,omitempty"`
-	PriorityLow       []int64 `json:"priority_low,omitempty"`
-	PriorityNormal    []int64 `json:"priority_normal,omitempty"`
+	Cookies           *string   `json:"cookies,omitempty"`
+	DownloadDir       *string   `json:"download_dir,omitempty"`
+	Paused            *bool     `json:"paused,omitempty"`
+	PeerLimit         *int64    `json:"peer_limit,omitempty"`
+	BandwidthPriority *Priority `json:"bandwidth_priority,omitempty"`
+	FilesWanted       []int64   `json:"files_wanted,omitempty"`
+	FilesUnwanted     []int64   `json:"files_unwanted,omitempty"`
+	PriorityHigh      []int64   `json:"priority_high,omitempty"`
+	PriorityLow       []int64   `json:"priority_low,omitempty"`
+	PriorityNormal    []int64   `json:"priority_normal,omitempty"`
+	Labels            []string  `json:"labels,omitempty"`
+}
+
+// snakeCaseRPCVersion is the first RPC version whose torrent-add accepts
+// snake_case argument names (Transmission 4.1). Older daemons silently
+// ignore them.
+const snakeCaseRPCVersion = 18
+
+// legacyTorrentAddArgs is TorrentAddArgs with the argument names of daemons
+// before Transmission 4.1.
+type legacyTorrentAddArgs struct {
+	Filename *string `json:"filename,omitempty"`
+	MetaInfo *string `json:"metainfo,omitempty"`
+
+	Cookies           *string   `json:"cookies,omitempty"`
+	DownloadDir       *string   `json:"download-dir,omitempty"`
+	Paused            *bool     `json:"paused,omitempty"`
+	PeerLimit         *int64    `json:"peer-limit,omitempty"`
+	BandwidthPriority *Priority `json:"bandwidthPriority,omitempty"`
+	FilesWanted       []int64   `json:"files-wanted,omitempty"`
+	FilesUnwanted     []int64   `json:"files-unwanted,omitempty"`
+	PriorityHigh      []int64   `json:"priority-high,omitempty"`
+	PriorityLow       []int64   `json:"priority-low,omitempty"`
+	PriorityNormal    []int64   `json:"priority-normal,omitempty"`
+	Labels            []string  `json:"labels,omitempty"`
 }
 
 type TorrentAddResult struct {
@@ -361,7 +397,16 @@ type TorrentInfo struct {
 	HashString string `json:"hashString,omitempty"`
 }
 
+// TorrentAdd adds a torrent, naming the arguments as the daemon's RPC
+// version expects.
 func (c *Client) TorrentAdd(ctx context.Context, args TorrentAddArgs) (*TorrentAddResult, error) {
+	version, err := c.getRPCVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if version < snakeCaseRPCVersion {
+		return postWithArgs[legacyTorrentAddArgs, TorrentAddResult](ctx, c, "torrent-add", legacyTorrentAddArgs(args))
+	}
 	return postWithArgs[TorrentAddArgs, TorrentAddResult](ctx, c, "torrent-add", args)
 }
 
@@ -388,15 +433,18 @@ func (c *Client) TorrentSetLocation(ctx context.Context, args TorrentSetLocation
 
 type TorrentRenamePathArgs struct {
 	// IDs must be exactly 1 torrent
-	IDs  *TorrentIDs `json:"ids,omitempty"`
-	Path string      `json:"path"`
-	Name bool        `json:"name"`
+	IDs *TorrentIDs `json:"ids,omitempty"`
+	// Path is the file or directory to rename, relative to the torrent's
+	// download directory.
+	Path string `json:"path"`
+	// Name is the new name of the last component of Path.
+	Name string `json:"name"`
 }
 
 type TorrentRenamePathResult struct {
 	ID   int64  `json:"id"`
 	Path string `json:"path"`
-	Name bool   `json:"name"`
+	Name string `json:"name"`
 }
 
 func (c *Client) TorrentRenamePath(ctx context.Context, args TorrentRenamePathArgs) (*TorrentRenamePathResult, error) {
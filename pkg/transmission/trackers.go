@@ -0,0 +1,353 @@
+package transmission
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// trackerListRPCVersion is the first RPC version that supports setting
+// "trackerList" (Transmission 4.0).
+const trackerListRPCVersion = 17
+
+// TrackerList is a torrent's announce URLs grouped into tiers. Trackers in
+// the same tier are alternatives to each other, and tiers are tried in order.
+//
+// It is encoded as Transmission's "trackerList" string: one announce URL per
+// line, with tiers separated by a blank line.
+type TrackerList [][]string
+
+// ParseTrackerList parses a "trackerList" string. Surrounding whitespace is
+// ignored and consecutive blank lines are treated as a single separator.
+func ParseTrackerList(s string) TrackerList {
+	var list TrackerList
+	var tier []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if len(tier) > 0 {
+				list = append(list, tier)
+				tier = nil
+			}
+			continue
+		}
+		tier = append(tier, line)
+	}
+	if len(tier) > 0 {
+		list = append(list, tier)
+	}
+	return list
+}
+
+// String returns l in the "trackerList" format.
+func (l TrackerList) String() string {
+	var b strings.Builder
+	for i, tier := range l {
+		if len(tier) == 0 {
+			continue
+		}
+		if i > 0 && b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		for _, announce := range tier {
+			b.WriteString(announce)
+			b.WriteString("\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (l TrackerList) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *TrackerList) UnmarshalText(text []byte) error {
+	*l = ParseTrackerList(string(text))
+	return nil
+}
+
+// Announces returns all announce URLs in tier order.
+func (l TrackerList) Announces() []string {
+	var result []string
+	for _, tier := range l {
+		result = append(result, tier...)
+	}
+	return result
+}
+
+// Contains reports whether announce is in any tier.
+func (l TrackerList) Contains(announce string) bool {
+	for _, tier := range l {
+		if slices.Contains(tier, announce) {
+			return true
+		}
+	}
+	return false
+}
+
+// Clone returns a deep copy of l.
+func (l TrackerList) Clone() TrackerList {
+	if l == nil {
+		return nil
+	}
+	result := make(TrackerList, len(l))
+	for i, tier := range l {
+		result[i] = slices.Clone(tier)
+	}
+	return result
+}
+
+// Equal reports whether l and other have the same tiers in the same order.
+func (l TrackerList) Equal(other TrackerList) bool {
+	return slices.EqualFunc(l.normalize(), other.normalize(), slices.Equal[[]string])
+}
+
+// AddTier returns a copy of l with a new last tier containing the announce
+// URLs that aren't already in l.
+func (l TrackerList) AddTier(announces ...string) TrackerList {
+	result := l.Clone()
+	var tier []string
+	for _, announce := range announces {
+		if !result.Contains(announce) && !slices.Contains(tier, announce) {
+			tier = append(tier, announce)
+		}
+	}
+	if len(tier) > 0 {
+		result = append(result, tier)
+	}
+	return result
+}
+
+// Remove returns a copy of l without the announce URLs for which match
+// returns true. Tiers left empty are dropped.
+func (l TrackerList) Remove(match func(announce string) bool) TrackerList {
+	var result TrackerList
+	for _, tier := range l {
+		tier = slices.DeleteFunc(slices.Clone(tier), match)
+		if len(tier) > 0 {
+			result = append(result, tier)
+		}
+	}
+	return result
+}
+
+// ReplaceHost returns a copy of l with the host of every announce URL on
+// oldHost changed to newHost, keeping the tier and position of each tracker.
+//
+// If oldHost has no port it matches any port, and the port is kept unless
+// newHost has one. URLs that would duplicate an existing tracker are dropped.
+func (l TrackerList) ReplaceHost(oldHost, newHost string) TrackerList {
+	var result TrackerList
+	seen := make(map[string]bool)
+	for _, tier := range l {
+		var newTier []string
+		for _, announce := range tier {
+			announce = replaceAnnounceHost(announce, oldHost, newHost)
+			if seen[announce] {
+				continue
+			}
+			seen[announce] = true
+			newTier = append(newTier, announce)
+		}
+		if len(newTier) > 0 {
+			result = append(result, newTier)
+		}
+	}
+	return result
+}
+
+func replaceAnnounceHost(announce, oldHost, newHost string) string {
+	u, err := url.Parse(announce)
+	if err != nil {
+		return announce
+	}
+
+	if strings.Contains(oldHost, ":") {
+		if !strings.EqualFold(u.Host, oldHost) {
+			return announce
+		}
+		u.Host = newHost
+		return u.String()
+	}
+
+	if !strings.EqualFold(u.Hostname(), oldHost) {
+		return announce
+	}
+	if port := u.Port(); port != "" && !strings.Contains(newHost, ":") {
+		u.Host = newHost + ":" + port
+	} else {
+		u.Host = newHost
+	}
+	return u.String()
+}
+
+// normalize drops empty tiers.
+func (l TrackerList) normalize() TrackerList {
+	return slices.DeleteFunc(l.Clone(), func(tier []string) bool { return len(tier) == 0 })
+}
+
+// TrackerTiers returns the torrent's trackers grouped into tiers. It uses
+// TrackerList when available and otherwise falls back to Trackers, which
+// older daemons return instead.
+func (t Torrent) TrackerTiers() TrackerList {
+	if len(t.TrackerList) > 0 {
+		return t.TrackerList.Clone()
+	}
+
+	var list TrackerList
+	tierIndex := make(map[int64]int)
+	for _, tracker := range t.Trackers {
+		i, exists := tierIndex[tracker.Tier]
+		if !exists {
+			i = len(list)
+			tierIndex[tracker.Tier] = i
+			list = append(list, nil)
+		}
+		list[i] = append(list[i], tracker.Announce)
+	}
+	return list
+}
+
+// TrackerReplacement changes the announce URL of the tracker with ID.
+type TrackerReplacement struct {
+	ID       int64
+	Announce string
+}
+
+// TrackerReplacements is encoded as the flat [id, url, id, url, ...] array
+// that "trackerReplace" expects.
+type TrackerReplacements []TrackerReplacement
+
+func (r TrackerReplacements) MarshalJSON() ([]byte, error) {
+	flat := make([]any, 0, 2*len(r))
+	for _, replacement := range r {
+		flat = append(flat, replacement.ID, replacement.Announce)
+	}
+	return json.Marshal(flat)
+}
+
+func (r *TrackerReplacements) UnmarshalJSON(data []byte) error {
+	var flat []json.RawMessage
+	if err := json.Unmarshal(data, &flat); err != nil {
+		return err
+	}
+	if len(flat)%2 != 0 {
+		return errors.New("trackerReplace must have an even number of elements")
+	}
+
+	result := make(TrackerReplacements, 0, len(flat)/2)
+	for i := 0; i < len(flat); i += 2 {
+		var replacement TrackerReplacement
+		if err := json.Unmarshal(flat[i], &replacement.ID); err != nil {
+			return fmt.Errorf("error decoding tracker ID: %w", err)
+		}
+		if err := json.Unmarshal(flat[i+1], &replacement.Announce); err != nil {
+			return fmt.Errorf("error decoding announce URL: %w", err)
+		}
+		result = append(result, replacement)
+	}
+	*r = result
+	return nil
+}
+
+// TrackerChange records the trackers of a torrent before and after an edit.
+type TrackerChange struct {
+	ID  int64
+	Old TrackerList
+	New TrackerList
+}
+
+// EditTrackers applies edit to the tracker list of each torrent and saves
+// the torrents whose trackers changed.
+//
+// On daemons that support it the whole list is set with "trackerList". Older
+// daemons can't set tiers, so the change is applied with "trackerReplace",
+// "trackerRemove" and "trackerAdd" instead: removed trackers are replaced
+// in place by added ones where possible, and any other added trackers get a
+// tier of their own.
+func (c *Client) EditTrackers(ctx context.Context, ids *TorrentIDs, edit func(TrackerList) TrackerList) ([]TrackerChange, error) {
+	session, err := c.SessionGet(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting session: %w", err)
+	}
+
+	result, err := c.TorrentGet(ctx, TorrentGetArgs{
+		IDs:    ids,
+		Fields: []string{"id", "trackers", "trackerList"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting trackers: %w", err)
+	}
+
+	var changes []TrackerChange
+	for _, torrent := range result.Torrents {
+		old := torrent.TrackerTiers()
+		updated := edit(old.Clone()).normalize()
+		if old.Equal(updated) {
+			continue
+		}
+
+		args := TorrentSetArgs{Ids: []any{torrent.ID}}
+		if session.RPCVersion >= trackerListRPCVersion {
+			args.TrackerList = &updated
+		} else {
+			args.TrackerReplace, args.TrackerRemove, args.TrackerAdd = diffTrackers(torrent.Trackers, updated)
+		}
+		if err := c.TorrentSet(ctx, args); err != nil {
+			return changes, fmt.Errorf("error setting trackers of torrent %d: %w", torrent.ID, err)
+		}
+		changes = append(changes, TrackerChange{ID: torrent.ID, Old: old, New: updated})
+	}
+	return changes, nil
+}
+
+// diffTrackers returns the tracker-replace/remove/add arguments that turn
+// trackers into updated, for daemons without "trackerList".
+func diffTrackers(trackers []Tracker, updated TrackerList) (TrackerReplacements, []int64, []string) {
+	var removed []Tracker
+	for _, tracker := range trackers {
+		if !updated.Contains(tracker.Announce) {
+			removed = append(removed, tracker)
+		}
+	}
+
+	var added []string
+	for _, announce := range updated.Announces() {
+		if !slices.ContainsFunc(trackers, func(tracker Tracker) bool { return tracker.Announce == announce }) {
+			added = append(added, announce)
+		}
+	}
+
+	var replace TrackerReplacements
+	for len(removed) > 0 && len(added) > 0 {
+		replace = append(replace, TrackerReplacement{ID: removed[0].ID, Announce: added[0]})
+		removed, added = removed[1:], added[1:]
+	}
+
+	var remove []int64
+	for _, tracker := range removed {
+		remove = append(remove, tracker.ID)
+	}
+	return replace, remove, added
+}
+
+// ReplaceTrackerHost changes the host of every announce URL on oldHost to
+// newHost. See TrackerList.ReplaceHost.
+func (c *Client) ReplaceTrackerHost(ctx context.Context, ids *TorrentIDs, oldHost, newHost string) ([]TrackerChange, error) {
+	return c.EditTrackers(ctx, ids, func(list TrackerList) TrackerList {
+		return list.ReplaceHost(oldHost, newHost)
+	})
+}
+
+// AddTrackerTier adds a tier with the announce URLs to each torrent, for
+// example as a backup for its existing trackers. URLs a torrent already has
+// are skipped.
+func (c *Client) AddTrackerTier(ctx context.Context, ids *TorrentIDs, announces ...string) ([]TrackerChange, error) {
+	return c.EditTrackers(ctx, ids, func(list TrackerList) TrackerList {
+		return list.AddTier(announces...)
+	})
+}
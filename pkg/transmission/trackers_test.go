@@ -0,0 +1,181 @@
+package transmission
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTrackerList(t *testing.T) {
+	list := ParseTrackerList("http://a/announce\nhttp://b/announce\n\n\n udp://c:80 \n")
+	assert.Equal(t, TrackerList{
+		{"http://a/announce", "http://b/announce"},
+		{"udp://c:80"},
+	}, list)
+	assert.Equal(t, "http://a/announce\nhttp://b/announce\n\nudp://c:80", list.String())
+
+	assert.Nil(t, ParseTrackerList(""))
+	assert.Equal(t, "", TrackerList{}.String())
+	assert.Equal(t, "http://a", TrackerList{{}, {"http://a"}}.String())
+}
+
+func TestTrackerListJSON(t *testing.T) {
+	var torrent Torrent
+	require.NoError(t, json.Unmarshal([]byte(`{"trackerList":"http://a\n\nhttp://b"}`), &torrent))
+	assert.Equal(t, TrackerList{{"http://a"}, {"http://b"}}, torrent.TrackerList)
+
+	empty := TrackerList{}
+	data, err := json.Marshal(TorrentSetArgs{TrackerList: &empty})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"trackerList":""}`, string(data))
+
+	data, err = json.Marshal(TorrentSetArgs{TrackerReplace: TrackerReplacements{{ID: 1, Announce: "http://a"}, {ID: 3, Announce: "http://b"}}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"trackerReplace":[1,"http://a",3,"http://b"]}`, string(data))
+
+	var replacements TrackerReplacements
+	require.NoError(t, json.Unmarshal([]byte(`[1,"http://a",3,"http://b"]`), &replacements))
+	assert.Equal(t, TrackerReplacements{{ID: 1, Announce: "http://a"}, {ID: 3, Announce: "http://b"}}, replacements)
+	assert.Error(t, json.Unmarshal([]byte(`[1]`), &replacements))
+}
+
+func TestTrackerListEdits(t *testing.T) {
+	list := TrackerList{
+		{"http://old.example:8080/announce", "http://other.example/announce"},
+		{"udp://OLD.example/announce", "http://new.example:8080/announce"},
+	}
+
+	t.Run("replace host keeps port", func(t *testing.T) {
+		assert.Equal(t, TrackerList{
+			{"http://new.example:8080/announce", "http://other.example/announce"},
+			{"udp://new.example/announce"},
+		}, list.ReplaceHost("old.example", "new.example"))
+	})
+
+	t.Run("replace host and port", func(t *testing.T) {
+		assert.Equal(t, TrackerList{
+			{"http://new.example:9090/announce", "http://other.example/announce"},
+			{"udp://OLD.example/announce", "http://new.example:8080/announce"},
+		}, list.ReplaceHost("old.example:8080", "new.example:9090"))
+	})
+
+	t.Run("add tier", func(t *testing.T) {
+		result := list.AddTier("http://other.example/announce", "http://backup/announce", "http://backup/announce")
+		assert.Len(t, result, 3)
+		assert.Equal(t, []string{"http://backup/announce"}, result[2])
+		assert.Len(t, list, 2, "original is unchanged")
+
+		assert.Equal(t, list, list.AddTier("http://other.example/announce"))
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		result := list.Remove(func(announce string) bool { return announce == "http://other.example/announce" })
+		assert.Equal(t, TrackerList{
+			{"http://old.example:8080/announce"},
+			{"udp://OLD.example/announce", "http://new.example:8080/announce"},
+		}, result)
+	})
+}
+
+func TestTorrentTrackerTiers(t *testing.T) {
+	torrent := Torrent{Trackers: []Tracker{
+		{ID: 0, Announce: "http://a", Tier: 0},
+		{ID: 1, Announce: "http://b", Tier: 1},
+		{ID: 2, Announce: "http://c", Tier: 0},
+	}}
+	assert.Equal(t, TrackerList{{"http://a", "http://c"}, {"http://b"}}, torrent.TrackerTiers())
+
+	torrent.TrackerList = TrackerList{{"http://x"}}
+	assert.Equal(t, TrackerList{{"http://x"}}, torrent.TrackerTiers())
+}
+
+func trackerServer(t *testing.T, rpcVersion int) (*Client, *fakeServer) {
+	return newFakeServer(t, func(call rpcCall) (any, error) {
+		switch call.Method {
+		case "session-get":
+			return Session{RPCVersion: rpcVersion}, nil
+		case "torrent-get":
+			torrents := []Torrent{
+				{ID: 1, Trackers: []Tracker{
+					{ID: 0, Announce: "http://old.example/announce", Tier: 0},
+					{ID: 1, Announce: "http://keep.example/announce", Tier: 1},
+				}},
+				{ID: 2, Trackers: []Tracker{
+					{ID: 0, Announce: "http://keep.example/announce", Tier: 0},
+				}},
+			}
+			if rpcVersion >= trackerListRPCVersion {
+				for i := range torrents {
+					torrents[i].TrackerList = torrents[i].TrackerTiers()
+				}
+			}
+			return TorrentGetResult{Torrents: torrents}, nil
+		}
+		return nil, nil
+	})
+}
+
+func TestEditTrackers(t *testing.T) {
+	t.Run("tracker list", func(t *testing.T) {
+		client, server := trackerServer(t, 17)
+
+		changes, err := client.ReplaceTrackerHost(context.Background(), AllTorrents, "old.example", "new.example")
+		require.NoError(t, err)
+
+		require.Len(t, changes, 1)
+		assert.Equal(t, int64(1), changes[0].ID)
+		assert.Equal(t, TrackerList{{"http://new.example/announce"}, {"http://keep.example/announce"}}, changes[0].New)
+
+		calls := server.CallsTo("torrent-set")
+		require.Len(t, calls, 1)
+		assert.JSONEq(t, `{"ids":[1],"trackerList":"http://new.example/announce\n\nhttp://keep.example/announce"}`, string(calls[0].Arguments))
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		client, server := trackerServer(t, 16)
+
+		changes, err := client.EditTrackers(context.Background(), AllTorrents, func(list TrackerList) TrackerList {
+			list = list.ReplaceHost("old.example", "new.example")
+			return list.AddTier("http://backup/announce", "http://backup2/announce")
+		})
+		require.NoError(t, err)
+		require.Len(t, changes, 2)
+
+		calls := server.CallsTo("torrent-set")
+		require.Len(t, calls, 2)
+		assert.JSONEq(t, `{
+			"ids": [1],
+			"trackerReplace": [0, "http://new.example/announce"],
+			"trackerAdd": ["http://backup/announce", "http://backup2/announce"]
+		}`, string(calls[0].Arguments))
+		assert.JSONEq(t, `{
+			"ids": [2],
+			"trackerAdd": ["http://backup/announce", "http://backup2/announce"]
+		}`, string(calls[1].Arguments))
+	})
+
+	t.Run("fallback removes", func(t *testing.T) {
+		client, server := trackerServer(t, 16)
+
+		_, err := client.EditTrackers(context.Background(), NewTorrentIDs(1), func(list TrackerList) TrackerList {
+			return list.Remove(func(announce string) bool { return announce == "http://old.example/announce" })
+		})
+		require.NoError(t, err)
+
+		calls := server.CallsTo("torrent-set")
+		require.Len(t, calls, 1)
+		assert.JSONEq(t, `{"ids":[1],"trackerRemove":[0]}`, string(calls[0].Arguments))
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		client, server := trackerServer(t, 17)
+
+		changes, err := client.AddTrackerTier(context.Background(), AllTorrents, "http://keep.example/announce")
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+		assert.Empty(t, server.CallsTo("torrent-set"))
+	})
+}
@@ -0,0 +1,183 @@
+package transmission
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is a Transmission daemon version, as reported by session-get, e.g.
+// "4.0.5 (a6fe2a64aa)" or "4.1.0-beta.2 (1d7d8a4bbc)".
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease string
+	BuildHash  string
+
+	raw string
+}
+
+var versionPattern = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?(?:-([0-9A-Za-z.-]+))?\s*(?:\(([0-9A-Za-z]+)\))?$`)
+
+// ParseVersion parses a Transmission version string. Older daemons report
+// only a major and minor version (e.g. "2.94" or "3.00"), in which case Patch
+// is 0.
+func ParseVersion(s string) (Version, error) {
+	matches := versionPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if matches == nil {
+		return Version{}, fmt.Errorf("invalid Transmission version %q", s)
+	}
+
+	version := Version{
+		PreRelease: matches[4],
+		BuildHash:  matches[5],
+		raw:        s,
+	}
+
+	var err error
+	if version.Major, err = strconv.Atoi(matches[1]); err != nil {
+		return Version{}, fmt.Errorf("invalid major version in %q: %w", s, err)
+	}
+	if version.Minor, err = strconv.Atoi(matches[2]); err != nil {
+		return Version{}, fmt.Errorf("invalid minor version in %q: %w", s, err)
+	}
+	if matches[3] != "" {
+		if version.Patch, err = strconv.Atoi(matches[3]); err != nil {
+			return Version{}, fmt.Errorf("invalid patch version in %q: %w", s, err)
+		}
+	}
+
+	return version, nil
+}
+
+// String returns the version exactly as reported by Transmission.
+func (v Version) String() string {
+	if v.raw != "" {
+		return v.raw
+	}
+	if v.BuildHash == "" {
+		return v.Sem()
+	}
+	return fmt.Sprintf("%s (%s)", v.Sem(), v.BuildHash)
+}
+
+// Sem returns the semantic version without the build hash, e.g. "4.0.5" or
+// "4.1.0-beta.2". It returns "" if the version could not be parsed.
+func (v Version) Sem() string {
+	if v.IsZero() {
+		return ""
+	}
+	sem := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.PreRelease != "" {
+		sem += "-" + v.PreRelease
+	}
+	return sem
+}
+
+// Hash returns the build commit hash, e.g. "a6fe2a64aa".
+func (v Version) Hash() string {
+	return v.BuildHash
+}
+
+// IsZero reports whether the version is unset or could not be parsed.
+func (v Version) IsZero() bool {
+	return v.Major == 0 && v.Minor == 0 && v.Patch == 0 && v.PreRelease == ""
+}
+
+// Compare returns -1, 0 or 1 depending on whether v is lower than, equal to or
+// higher than other. Pre-releases are lower than the corresponding release,
+// and pre-release identifiers are compared as described by semver. Build
+// hashes are ignored.
+func (v Version) Compare(other Version) int {
+	if c := compareInts(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := compareInts(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := compareInts(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+	return comparePreReleases(v.PreRelease, other.PreRelease)
+}
+
+// LessThan reports whether v is lower than other.
+func (v Version) LessThan(other Version) bool {
+	return v.Compare(other) < 0
+}
+
+// AtLeast reports whether v is equal to or higher than major.minor.patch,
+// e.g. to check whether a daemon supports a feature.
+func (v Version) AtLeast(major, minor, patch int) bool {
+	return v.Compare(Version{Major: major, Minor: minor, Patch: patch}) >= 0
+}
+
+func (v Version) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.String())
+}
+
+// UnmarshalJSON never fails on an unrecognised version string, so that an
+// unusual daemon build doesn't break session-get. The raw string is kept and
+// the version is left zero.
+func (v *Version) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	parsed, err := ParseVersion(s)
+	if err != nil {
+		*v = Version{raw: s}
+		return nil
+	}
+	*v = parsed
+	return nil
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func comparePreReleases(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aNum, aErr := strconv.Atoi(aParts[i])
+		bNum, bErr := strconv.Atoi(bParts[i])
+
+		var c int
+		switch {
+		case aErr == nil && bErr == nil:
+			c = compareInts(aNum, bNum)
+		case aErr == nil:
+			c = -1
+		case bErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(aParts[i], bParts[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(aParts), len(bParts))
+}
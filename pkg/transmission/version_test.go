@@ -0,0 +1,115 @@
+package transmission
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Version
+	}{
+		{
+			input:    "4.0.5 (a6fe2a64aa)",
+			expected: Version{Major: 4, Minor: 0, Patch: 5, BuildHash: "a6fe2a64aa"},
+		},
+		{
+			input:    "4.1.0-beta.2 (1d7d8a4bbc)",
+			expected: Version{Major: 4, Minor: 1, Patch: 0, PreRelease: "beta.2", BuildHash: "1d7d8a4bbc"},
+		},
+		{
+			input:    "2.94 (d8e60ee44f)",
+			expected: Version{Major: 2, Minor: 94, BuildHash: "d8e60ee44f"},
+		},
+		{
+			input:    "3.00",
+			expected: Version{Major: 3, Minor: 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			actual, err := ParseVersion(tt.input)
+			require.NoError(t, err)
+
+			tt.expected.raw = tt.input
+			assert.Equal(t, tt.expected, actual)
+			assert.Equal(t, tt.input, actual.String())
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ParseVersion("nightly")
+		assert.Error(t, err)
+	})
+}
+
+func TestVersionSemAndHash(t *testing.T) {
+	v, err := ParseVersion("4.1.0-beta.2 (1d7d8a4bbc)")
+	require.NoError(t, err)
+
+	assert.Equal(t, "4.1.0-beta.2", v.Sem())
+	assert.Equal(t, "1d7d8a4bbc", v.Hash())
+	assert.Equal(t, "", Version{}.Sem())
+}
+
+func TestVersionCompare(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "4.0.5", b: "4.0.5 (a6fe2a64aa)", expected: 0},
+		{a: "4.0.5", b: "4.0.6", expected: -1},
+		{a: "4.1.0", b: "4.0.6", expected: 1},
+		{a: "3.00", b: "4.0.0", expected: -1},
+		{a: "4.1.0-beta.2", b: "4.1.0", expected: -1},
+		{a: "4.1.0-beta.2", b: "4.1.0-beta.10", expected: -1},
+		{a: "4.1.0-beta.1", b: "4.1.0-alpha.3", expected: 1},
+		{a: "4.1.0-beta", b: "4.1.0-beta.1", expected: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			a, err := ParseVersion(tt.a)
+			require.NoError(t, err)
+			b, err := ParseVersion(tt.b)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expected, a.Compare(b))
+			assert.Equal(t, -tt.expected, b.Compare(a))
+		})
+	}
+
+	t.Run("AtLeast", func(t *testing.T) {
+		v, err := ParseVersion("4.0.5 (a6fe2a64aa)")
+		require.NoError(t, err)
+
+		assert.True(t, v.AtLeast(4, 0, 0))
+		assert.True(t, v.AtLeast(4, 0, 5))
+		assert.False(t, v.AtLeast(4, 1, 0))
+	})
+}
+
+func TestVersionJSON(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		var session Session
+		require.NoError(t, json.Unmarshal([]byte(`{"version":"4.0.5 (a6fe2a64aa)"}`), &session))
+		assert.Equal(t, 4, session.Version.Major)
+		assert.Equal(t, "a6fe2a64aa", session.Version.BuildHash)
+
+		marshalled, err := json.Marshal(session.Version)
+		require.NoError(t, err)
+		assert.JSONEq(t, `"4.0.5 (a6fe2a64aa)"`, string(marshalled))
+	})
+
+	t.Run("unrecognised version", func(t *testing.T) {
+		var v Version
+		require.NoError(t, json.Unmarshal([]byte(`"nightly"`), &v))
+		assert.True(t, v.IsZero())
+		assert.Equal(t, "nightly", v.String())
+	})
+}